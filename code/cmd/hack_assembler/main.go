@@ -2,13 +2,22 @@ package main
 
 import (
 	"bytes"
+	"cmp"
+	"encoding/json"
 	"fmt"
+	"go/token"
+	"maps"
 	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
+	"unicode"
 
 	"github.com/teris-io/cli"
 	"its-hmny.dev/nand2tetris/pkg/asm"
 	"its-hmny.dev/nand2tetris/pkg/hack"
+	"its-hmny.dev/nand2tetris/pkg/utils"
 )
 
 var Description = strings.ReplaceAll(`
@@ -22,8 +31,28 @@ var HackAssembler = cli.New(Description).
 		WithType(cli.TypeString)).
 	WithArg(cli.NewArg("output", "The compiled binary output (.hack)").
 		WithType(cli.TypeString)).
+	WithOption(cli.NewOption("listing", "Writes the listing (.lst) of the program: ROM address, binary word and source line").
+		WithType(cli.TypeString)).
+	WithOption(cli.NewOption("symbols", "Writes the final symbol table (labels and variables) as JSON").
+		WithType(cli.TypeString)).
+	WithOption(cli.NewOption("strict", "Rejects jumps to undeclared labels and warns about variables named like a label").
+		WithType(cli.TypeBool)).
+	WithOption(cli.NewOption("max-vars", "The max number of variables allocated in strict mode (default 240, up to the stack)").
+		WithType(cli.TypeInt)).
+	WithOption(cli.NewOption("format", "The output format: 'hack' (default), 'raw', 'ihex', 'logisim', 'readmemb', 'readmemh' or 'go'").
+		WithType(cli.TypeString)).
 	WithAction(Handler)
 
+// The encoders available for the output, the Go source one depends on the output path (see 'Handler').
+var Formats = map[string]hack.Encoder{
+	"hack":     hack.EncodeHack,     // Textual, one '%016b' word per line (nand2tetris tools)
+	"raw":      hack.EncodeRaw,      // Binary image, 2 bytes per word in big-endian order
+	"ihex":     hack.EncodeIntelHex, // Intel HEX records, byte addressed
+	"logisim":  hack.EncodeLogisim,  // Logisim 'v2.0 raw' memory image
+	"readmemb": hack.EncodeReadMemB, // Verilog '$readmemb' memory file
+	"readmemh": hack.EncodeReadMemH, // Verilog '$readmemh' memory file
+}
+
 func Handler(args []string, options map[string]string) int {
 	input, err := os.ReadFile(args[0])
 	if err != nil {
@@ -31,6 +60,17 @@ func Handler(args []string, options map[string]string) int {
 		return -1
 	}
 
+	format, encoder := options["format"], hack.EncodeHack
+	switch {
+	case format == "go": // The package is named after the output directory, the variable is always 'Program'
+		encoder = hack.GoSourceEncoder(PackageName(args[1]), "Program")
+	case format != "":
+		if encoder = Formats[format]; encoder == nil {
+			fmt.Printf("ERROR: Unsupported output format '%s'\n", format)
+			return -1
+		}
+	}
+
 	output, err := os.Create(args[1])
 	if err != nil {
 		fmt.Printf("ERROR: Unable to open output file: %s\n", err)
@@ -39,11 +79,20 @@ func Handler(args []string, options map[string]string) int {
 	defer output.Close()
 
 	// Instantiate a parser for the Asm program
-	parser := asm.NewParser(bytes.NewReader(input))
+	parser := asm.NewFileParser(bytes.NewReader(input), args[0])
 	// Parses the input file content and extract an AST (as a 'asm.Program') from it.
 	asmProgram, err := parser.Parse()
 	if err != nil {
-		fmt.Printf("ERROR: Unable to complete 'parsing' pass: %s\n", err)
+		fmt.Println(utils.FormatError("parsing", err))
+		return -1
+	}
+
+	// Instantiate an expander to resolve the macros and directives of the Asm program
+	expander := asm.NewExpander(asmProgram)
+	// Expands macros, includes and constants so that only plain instructions are left.
+	asmProgram, err = expander.Expand()
+	if err != nil {
+		fmt.Println(utils.FormatError("expansion", err))
 		return -1
 	}
 
@@ -52,25 +101,149 @@ func Handler(args []string, options map[string]string) int {
 	// Lowers the asm.Program to an in-memory/IR representation of its Hack counterpart 'hack.Program'.
 	hackProgram, table, err := lowerer.Lower()
 	if err != nil {
-		fmt.Printf("ERROR: Unable to complete 'lowering' pass: %s\n", err)
+		fmt.Println(utils.FormatError("lowering", err))
 		return -1
 	}
 
+	// The codegen phase adds the variables to the table, so the labels are saved beforehand
+	labels := maps.Clone(table)
+
 	// Now, instantiates a code generator for the Hack (compiled) program
 	codegen := hack.NewCodeGenerator(hackProgram, table)
-	// Iterates over each instruction and spits out the relative textual representation.
-	compiled, err := codegen.Generate()
+	if _, enabled := options["strict"]; enabled {
+		maxVars, err := strconv.ParseUint(cmp.Or(options["max-vars"], "0"), 10, 16)
+		if err != nil {
+			fmt.Printf("ERROR: Invalid number of variables '%s'\n", options["max-vars"])
+			return -1
+		}
+		codegen = hack.NewStrictCodeGenerator(hackProgram, table, uint16(maxVars))
+	}
+	// Iterates over each instruction and produces the relative binary word.
+	words, err := codegen.Encode()
+	for _, warning := range codegen.Warnings() {
+		fmt.Printf("WARNING: %s\n", warning)
+	}
 	if err != nil {
-		fmt.Printf("ERROR: Unable to complete 'codegen' pass:\n\t %s", err)
+		fmt.Println(utils.FormatError("codegen", err))
+		return -1
+	}
+
+	if err := encoder(output, words); err != nil {
+		fmt.Printf("ERROR: Unable to write output file: %s\n", err)
 		return -1
 	}
 
-	for _, comp := range compiled {
-		line := fmt.Sprintf("%s\n", comp)
-		output.Write([]byte(line))
+	if path := options["listing"]; path != "" {
+		if err := WriteListing(path, asmProgram, words); err != nil {
+			fmt.Printf("ERROR: Unable to write listing file: %s\n", err)
+			return -1
+		}
+	}
+
+	if path := options["symbols"]; path != "" {
+		if err := WriteSymbols(path, labels, table); err != nil {
+			fmt.Printf("ERROR: Unable to write symbols file: %s\n", err)
+			return -1
+		}
 	}
 
 	return 0
 }
 
+// Writes the listing of 'program' to 'path', each A and C instruction is listed along with its ROM
+// address, its binary word (in 'words') and the source line it comes from (and where to find the
+// latter, since it can be part of a macro or an included file). The label declarations are listed
+// as well, with the ROM address they point to. The lowering phase emits exactly one binary word for
+// each A and C instruction, so 'words' are in the same order as those instructions.
+func WriteListing(path string, program asm.Program, words []uint16) error {
+	sources, address := map[string][]string{}, 0
+
+	// Returns the (trimmed) source line at 'position', the files are read only once
+	source := func(position utils.Position) string {
+		if _, found := sources[position.File]; !found {
+			content, _ := os.ReadFile(position.File)
+			sources[position.File] = strings.Split(string(content), "\n")
+		}
+		if lines := sources[position.File]; position.IsValid() && position.Line <= len(lines) {
+			return strings.TrimSpace(lines[position.Line-1])
+		}
+		return ""
+	}
+
+	listing := &strings.Builder{}
+	for _, inst := range program {
+		switch tInst := inst.(type) {
+		case asm.AInstruction, asm.CInstruction:
+			if address >= len(words) {
+				return fmt.Errorf("missing binary word for instruction at ROM address %d", address)
+			}
+			position := positionOf(tInst)
+			fmt.Fprintf(listing, "%05d  %016b  %-16s  %s\n", address, words[address], locationOf(position), source(position))
+			address++
+
+		case asm.LabelDecl:
+			fmt.Fprintf(listing, "%05d  %16s  %-16s  %s\n", address, "", locationOf(tInst.Position), source(tInst.Position))
+		}
+	}
+
+	return os.WriteFile(path, []byte(listing.String()), 0644)
+}
+
+// Returns the position of an A or C instruction in the source code.
+func positionOf(inst asm.Instruction) utils.Position {
+	switch tInst := inst.(type) {
+	case asm.AInstruction:
+		return tInst.Position
+	case asm.CInstruction:
+		return tInst.Position
+	}
+	return utils.Position{}
+}
+
+// Returns a short reference to 'position' (e.g. 'Max.asm:12'), the directories are omitted.
+func locationOf(position utils.Position) string {
+	if !position.IsValid() {
+		return ""
+	}
+	return fmt.Sprintf("%s:%d", filepath.Base(position.File), position.Line)
+}
+
+// Returns the name of the Go package for the output file at 'path', that is the name of the
+// directory containing it (without the characters not allowed) or 'main' if not a valid one.
+func PackageName(path string) string {
+	absolute, err := filepath.Abs(path)
+	if err != nil {
+		return "main"
+	}
+
+	name := strings.ToLower(regexp.MustCompile(`[^A-Za-z0-9_]`).ReplaceAllString(filepath.Base(filepath.Dir(absolute)), ""))
+	if name == "" || !unicode.IsLetter(rune(name[0])) || token.IsKeyword(name) {
+		return "main"
+	}
+	return name
+}
+
+// The content of the symbols file, the labels point to ROM while the variables point to RAM.
+type SymbolMap struct {
+	Labels    hack.SymbolTable `json:"labels"`    // The labels declared, with their ROM address
+	Variables hack.SymbolTable `json:"variables"` // The variables allocated (from 16 onwards), with their RAM address
+}
+
+// Writes the final symbol table to 'path' as JSON, 'labels' are the symbols declared in the program
+// while the other entries in 'table' are the variables allocated during the codegen phase.
+func WriteSymbols(path string, labels hack.SymbolTable, table hack.SymbolTable) error {
+	symbols := SymbolMap{Labels: labels, Variables: hack.SymbolTable{}}
+	for name, address := range table {
+		if _, isLabel := labels[name]; !isLabel {
+			symbols.Variables[name] = address
+		}
+	}
+
+	content, err := json.MarshalIndent(symbols, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(content, '\n'), 0644)
+}
+
 func main() { os.Exit(HackAssembler.Run(os.Args, os.Stdout)) }
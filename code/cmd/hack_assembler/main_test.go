@@ -1,8 +1,13 @@
 package main
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"testing"
 )
 
@@ -28,6 +33,15 @@ func TestHackAssembler(t *testing.T) {
 		}
 	}
 
+	// Uses whitespaces inside the C instructions (e.g. 'M = M + D'), the output is compared to the tracked binary
+	t.Run("Mult.asm", func(t *testing.T) {
+		base := "../../../projects/04 - Machine Language/01 - Mult"
+		input := fmt.Sprintf("%s/%s", base, "Mult.asm")
+		output := filepath.Join(t.TempDir(), "Mult.hack")
+		compare := fmt.Sprintf("%s/%s", base, "Mult.hack")
+		test(input, output, compare)
+	})
+
 	t.Run("Add.asm", func(t *testing.T) {
 		base := "../../../projects/06 - Assembler/01 - Add"
 		input := fmt.Sprintf("%s/%s", base, "Add.asm")
@@ -83,4 +97,76 @@ func TestHackAssembler(t *testing.T) {
 		compare := fmt.Sprintf("%s/%s", base, "PongL.cmp")
 		test(input, output, compare)
 	})
+
+	// Writes both listing and symbols, the variables are allocated after the labels are resolved
+	t.Run("Listing and symbols", func(t *testing.T) {
+		input := "../../../projects/06 - Assembler/03 - Rect/Rect.asm"
+		dir := t.TempDir()
+		options := map[string]string{"listing": filepath.Join(dir, "Rect.lst"), "symbols": filepath.Join(dir, "Rect.json")}
+		if status := Handler([]string{input, filepath.Join(dir, "Rect.hack")}, options); status != 0 {
+			t.Fatalf("Unexpected exit status code: expected 0 got: %d", status)
+		}
+
+		content, err := os.ReadFile(options["symbols"])
+		if err != nil {
+			t.Fatalf("Error reading symbols file: %v", err)
+		}
+		symbols := SymbolMap{}
+		if err := json.Unmarshal(content, &symbols); err != nil {
+			t.Fatalf("Invalid symbols file: %v", err)
+		}
+		if fmt.Sprint(symbols.Labels) != "map[END:24 LOOP:10]" || fmt.Sprint(symbols.Variables) != "map[addr:17 n:16]" {
+			t.Fatalf("Unexpected symbols: %+v", symbols)
+		}
+
+		listing, err := os.ReadFile(options["listing"])
+		if err != nil {
+			t.Fatalf("Error reading listing file: %v", err)
+		}
+		lines := strings.Split(string(listing), "\n")
+		if len(lines) < 11 || strings.Join(strings.Fields(lines[10]), " ") != "00010 Rect.asm:21 (LOOP)" {
+			t.Fatalf("Expected the 'LOOP' label declaration at ROM address 10, got '%s'", lines[10])
+		}
+		if strings.Join(strings.Fields(lines[11]), " ") != "00010 0000000000010001 Rect.asm:23 @addr" {
+			t.Fatalf("Expected '@addr' at ROM address 10, got '%s'", lines[11])
+		}
+	})
+
+	// The raw binary image holds the same words of the textual format, 2 bytes each in big-endian order
+	t.Run("Output formats", func(t *testing.T) {
+		base := "../../../projects/06 - Assembler/01 - Add"
+		output := filepath.Join(t.TempDir(), "Add.bin")
+		if status := Handler([]string{base + "/Add.asm", output}, map[string]string{"format": "raw"}); status != 0 {
+			t.Fatalf("Unexpected exit status code: expected 0 got: %d", status)
+		}
+
+		raw, _ := os.ReadFile(output)
+		compare, _ := os.ReadFile(base + "/Add.cmp")
+		expected := []byte{}
+		for _, line := range strings.Fields(string(compare)) {
+			word, _ := strconv.ParseUint(line, 2, 16)
+			expected = append(expected, byte(word>>8), byte(word))
+		}
+		if !bytes.Equal(raw, expected) {
+			t.Fatalf("Expected raw image %x, got %x", expected, raw)
+		}
+
+		if status := Handler([]string{base + "/Add.asm", output}, map[string]string{"format": "unknown"}); status == 0 {
+			t.Fatal("Expected failure for unsupported output format")
+		}
+	})
+
+	// The typo in the jump target is allocated as a variable unless the strict mode is enabled
+	t.Run("Strict mode", func(t *testing.T) {
+		dir := t.TempDir()
+		input, output := filepath.Join(dir, "Typo.asm"), filepath.Join(dir, "Typo.hack")
+		os.WriteFile(input, []byte("(LOOP)\n@LOPP\n0;JMP\n"), 0644)
+
+		if status := Handler([]string{input, output}, map[string]string{}); status != 0 {
+			t.Fatalf("Unexpected exit status code: expected 0 got: %d", status)
+		}
+		if status := Handler([]string{input, output}, map[string]string{"strict": "true"}); status == 0 {
+			t.Fatal("Expected failure for jump to undeclared label in strict mode")
+		}
+	})
 }
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/teris-io/cli"
+	"its-hmny.dev/nand2tetris/pkg/asm"
+	"its-hmny.dev/nand2tetris/pkg/hack"
+	"its-hmny.dev/nand2tetris/pkg/utils"
+)
+
+var Description = strings.ReplaceAll(`
+The Hack Disassembler takes machine code that can be executed by the Hack computer and
+translates it back into the Hack assembly language. The process involves decoding each
+instruction, recovering the symbols (jump labels and built-in ones) and generating assembly code.
+`, "\n", " ")
+
+var HackDisassembler = cli.New(Description).
+	WithArg(cli.NewArg("input", "The compiled binary (.hack) file to be disassembled").
+		WithType(cli.TypeString)).
+	WithArg(cli.NewArg("output", "The assembler output (.asm)").
+		WithType(cli.TypeString)).
+	WithAction(Handler)
+
+func Handler(args []string, options map[string]string) int {
+	input, err := os.ReadFile(args[0])
+	if err != nil {
+		fmt.Printf("ERROR: Unable to open input file: %s\n", err)
+		return -1
+	}
+
+	output, err := os.Create(args[1])
+	if err != nil {
+		fmt.Printf("ERROR: Unable to open output file: %s\n", err)
+		return -1
+	}
+	defer output.Close()
+
+	// Instantiate a decoder for the Hack program
+	decoder := hack.NewFileDecoder(bytes.NewReader(input), args[0])
+	// Decodes the input file content and extract its in-memory representation (as a 'hack.Program').
+	hackProgram, err := decoder.Decode()
+	if err != nil {
+		fmt.Println(utils.FormatError("decoding", err))
+		return -1
+	}
+
+	// Instantiate a lifter to convert the program from Hack to Asm
+	lifter := asm.NewLifter(hackProgram)
+	// Lifts the hack.Program to an in-memory/IR representation of its Asm counterpart 'asm.Program'.
+	asmProgram, err := lifter.Lift()
+	if err != nil {
+		fmt.Println(utils.FormatError("lifting", err))
+		return -1
+	}
+
+	// Now, instantiates a code generator for the Asm (disassembled) program
+	codegen := asm.NewCodeGenerator(asmProgram)
+	// Iterates over each instruction and spits out the relative textual representation.
+	disassembled, err := codegen.Generate()
+	if err != nil {
+		fmt.Printf("ERROR: Unable to complete 'codegen' pass:\n\t %s", err)
+		return -1
+	}
+
+	for _, inst := range disassembled {
+		line := fmt.Sprintf("%s\n", inst)
+		output.Write([]byte(line))
+	}
+
+	return 0
+}
+
+func main() { os.Exit(HackDisassembler.Run(os.Args, os.Stdout)) }
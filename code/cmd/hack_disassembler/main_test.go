@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"its-hmny.dev/nand2tetris/pkg/asm"
+	"its-hmny.dev/nand2tetris/pkg/hack"
+)
+
+// This test checks that the disassembled programs, once assembled back, produce the same binary.
+func TestHackDisassembler(t *testing.T) {
+	test := func(input string) {
+		output := filepath.Join(t.TempDir(), "output.asm")
+		status := Handler([]string{input, output}, nil)
+		if status != 0 {
+			t.Fatalf("Unexpected exit status code: expected 0 got: %d", status)
+		}
+
+		disassembled, err := os.ReadFile(output)
+		if err != nil {
+			t.Fatalf("Error reading output file %s: %v", output, err)
+		}
+		expected, err := os.ReadFile(input)
+		if err != nil {
+			t.Fatalf("Error reading input file %s: %v", input, err)
+		}
+
+		parser := asm.NewParser(bytes.NewReader(disassembled))
+		asmProgram, err := parser.Parse()
+		if err != nil {
+			t.Fatalf("Unable to parse the disassembled program: %v", err)
+		}
+		lowerer := asm.NewLowerer(asmProgram)
+		hackProgram, table, err := lowerer.Lower()
+		if err != nil {
+			t.Fatalf("Unable to lower the disassembled program: %v", err)
+		}
+		codegen := hack.NewCodeGenerator(hackProgram, table)
+		compiled, err := codegen.Generate()
+		if err != nil {
+			t.Fatalf("Unable to assemble the disassembled program: %v", err)
+		}
+
+		// The comparison is done line by line since some of the inputs use the CRLF line terminator
+		if strings.Join(compiled, "\n") != strings.Join(strings.Fields(string(expected)), "\n") {
+			t.Fatal("The assembled program and the original one do not match")
+		}
+	}
+
+	t.Run("Add.hack", func(t *testing.T) { test("../../../projects/06 - Assembler/01 - Add/Add.hack") })
+	t.Run("Max.hack", func(t *testing.T) { test("../../../projects/06 - Assembler/02 - Max/Max.hack") })
+	t.Run("MaxL.hack", func(t *testing.T) { test("../../../projects/06 - Assembler/02 - Max/MaxL.hack") })
+	t.Run("Rect.hack", func(t *testing.T) { test("../../../projects/06 - Assembler/03 - Rect/Rect.hack") })
+	t.Run("RectL.hack", func(t *testing.T) { test("../../../projects/06 - Assembler/03 - Rect/RectL.hack") })
+	t.Run("Pong.hack", func(t *testing.T) { test("../../../projects/06 - Assembler/04 - Pong/Pong.hack") })
+	t.Run("PongL.hack", func(t *testing.T) { test("../../../projects/06 - Assembler/04 - Pong/PongL.hack") })
+	t.Run("Computer/Rect.hack", func(t *testing.T) { test("../../../projects/05 - Computer Architecture/03 - Computer/Rect.hack") })
+
+	t.Run("Invalid binary", func(t *testing.T) {
+		input := filepath.Join(t.TempDir(), "invalid.hack")
+		os.WriteFile(input, []byte("0000000000000001\n10101\n"), 0644)
+		if status := Handler([]string{input, filepath.Join(t.TempDir(), "output.asm")}, nil); status == 0 {
+			t.Fatalf("Expected failure on malformed input, got exit status 0")
+		}
+	})
+}
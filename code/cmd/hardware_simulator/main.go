@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/teris-io/cli"
+	"its-hmny.dev/nand2tetris/pkg/hdl"
+	"its-hmny.dev/nand2tetris/pkg/tst"
+)
+
+var Description = strings.ReplaceAll(`
+The Hardware Simulator runs a test script (.tst) against a chip described in the HDL language,
+the chip is simulated at the gate level (its parts are either looked up in the project folders
+or provided as builtin chips) and the output of the script is compared against the .cmp file.
+`, "\n", " ")
+
+var HardwareSimulator = cli.New(Description).
+	WithArg(cli.NewArg("input", "The test script (.tst) file to be executed").
+		WithType(cli.TypeString)).
+	WithOption(cli.NewOption("library", "Additional folder where to look for the chip's parts").
+		WithType(cli.TypeString)).
+	WithAction(Handler)
+
+func Handler(args []string, options map[string]string) int {
+	if len(args) < 1 {
+		fmt.Printf("ERROR: Not enough arguments provided, use --help\n")
+		return -1
+	}
+
+	input, err := os.Open(args[0])
+	if err != nil {
+		fmt.Printf("ERROR: Unable to open input file: %s\n", err)
+		return -1
+	}
+	defer input.Close()
+
+	// Instantiate a parser for the test script
+	parser := tst.NewParser(input)
+	// Parses the input file content and extract an AST (as a 'tst.Script') from it.
+	script, err := parser.Parse()
+	if err != nil {
+		fmt.Printf("ERROR: Unable to complete 'parsing' pass: %s\n", err)
+		return -1
+	}
+
+	library := []string{}
+	if options["library"] != "" {
+		library = append(library, options["library"])
+	}
+
+	// Instantiate the gate-level simulator and a runner to drive it with the test script
+	simulator := hdl.NewSimulator(library...)
+	runner := tst.NewRunner(script, &simulator, filepath.Dir(args[0]))
+	// Runs the whole script, comparing each line of the output with the compare file.
+	if err := runner.Run(); err != nil {
+		fmt.Printf("ERROR: Unable to complete 'simulation' pass: %s\n", err)
+		return -1
+	}
+
+	fmt.Printf("End of script - Comparison ended successfully\n")
+	return 0
+}
+
+func main() { os.Exit(HardwareSimulator.Run(os.Args, os.Stdout)) }
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestHardwareSimulator(t *testing.T) {
+	test := func(pattern string) {
+		scripts, err := filepath.Glob(pattern)
+		if err != nil || len(scripts) == 0 {
+			t.Fatalf("No test script found matching '%s'", pattern)
+		}
+
+		for _, script := range scripts {
+			t.Run(filepath.Base(script), func(t *testing.T) {
+				status := Handler([]string{script}, nil)
+				if status != 0 {
+					t.Fatalf("Unexpected exit status code: expected 0 got: %d", status)
+				}
+			})
+		}
+	}
+
+	t.Run("01 - Logic Gates", func(t *testing.T) {
+		test("../../../projects/01 - Logic Gates/*/*.tst")
+	})
+
+	t.Run("02 - Boolean Arithmetic", func(t *testing.T) {
+		test("../../../projects/02 - Boolean Arithmetic/*/*.tst")
+	})
+
+	t.Run("03 - Sequential Logic", func(t *testing.T) {
+		test("../../../projects/03 - Sequential Logic/*/*.tst")
+	})
+
+	// NOTE: 'Memory.tst' is skipped since it requires the user to press keys on the keyboard
+	t.Run("05 - Computer Architecture", func(t *testing.T) {
+		test("../../../projects/05 - Computer Architecture/0[23]*/*.tst")
+	})
+}
@@ -30,6 +30,10 @@ var JackCompiler = cli.New(Description).
 		WithType(cli.TypeBool)).
 	WithOption(cli.NewOption("typecheck", "Does a full type check of source code before emitting any output").
 		WithType(cli.TypeBool)).
+	WithOption(cli.NewOption("emit", "The output to emit for each class: 'vm' (default), 'tokens' or 'xml' (project 10 format)").
+		WithType(cli.TypeString)).
+	WithOption(cli.NewOption("precedence", "The precedence of binary operators: 'left-to-right' (default, like the reference) or 'standard' (C-like)").
+		WithType(cli.TypeString)).
 	WithAction(Handler)
 
 func Handler(args []string, options map[string]string) int {
@@ -60,6 +64,17 @@ func Handler(args []string, options map[string]string) int {
 		})
 	}
 
+	// The chains of binary operations are evaluated strictly from left to right unless requested otherwise
+	newParser := jack.NewFileParser
+	switch precedence := options["precedence"]; precedence {
+	case "", "left-to-right":
+	case "standard":
+		newParser = jack.NewStandardParser
+	default:
+		fmt.Printf("ERROR: Unsupported precedence '%s', use one of 'left-to-right' or 'standard'\n", precedence)
+		return -1
+	}
+
 	for _, tu := range TUs {
 		content, err := os.ReadFile(tu)
 		if err != nil {
@@ -68,13 +83,13 @@ func Handler(args []string, options map[string]string) int {
 		}
 
 		// Instantiate a parser for the Vm program
-		parser := jack.NewParser(bytes.NewReader(content))
+		parser := newParser(bytes.NewReader(content), tu)
 		// Removes root directory and file extension to use as module name
 		filename, extension := path.Base(tu), path.Ext(tu)
 		// Parses the input file content and extract an AST (as a 'vm.Module') from it.
 		program[strings.TrimSuffix(filename, extension)], err = parser.Parse()
 		if err != nil {
-			fmt.Printf("ERROR: Unable to complete 'parsing' pass: %s\n", err)
+			fmt.Println(utils.FormatError("parsing", err))
 			return -1
 		}
 	}
@@ -94,18 +109,33 @@ func Handler(args []string, options map[string]string) int {
 
 	if _, enabled := options["typecheck"]; enabled {
 		checker := jack.NewTypeChecker(program)
-		if _, err := checker.Check(); err != nil {
-			fmt.Printf("ERROR: Unable to complete 'typechecking' pass: %s\n", err)
+		diagnostics, err := checker.Check()
+		if err != nil {
+			fmt.Println(utils.FormatError("typechecking", err))
+			return -1
+		}
+
+		// Every problem found is reported, not just the first one, so that they can be fixed in one go
+		for _, diagnostic := range diagnostics {
+			fmt.Println(diagnostic)
+		}
+		if jack.HasErrors(diagnostics) {
+			fmt.Printf("ERROR: Unable to complete 'typechecking' pass: %d problem(s) found\n", len(diagnostics))
 			return -1
 		}
 	}
 
+	// The syntax analyzer outputs (project 10) are emitted in place of the compiled VM code
+	if emit, enabled := options["emit"]; enabled && emit != "vm" {
+		return EmitXML(TUs, program, emit)
+	}
+
 	// Instantiate a lowerer to convert the program from Jack to Vm
 	lowerer := jack.NewLowerer(program)
 	// Lowers the jack.Program to an in-memory/IR representation of its Vm counterpart 'vm.Program'.
 	vmProgram, err := lowerer.Lowerer()
 	if err != nil {
-		fmt.Printf("ERROR: Unable to complete 'lowering' pass: %s\n", err)
+		fmt.Println(utils.FormatError("lowering", err))
 		return -1
 	}
 
@@ -143,4 +173,38 @@ func Handler(args []string, options map[string]string) int {
 	return 0
 }
 
+// Emits for each TU either the tokens ('xxxT.xml') or the parse tree ('xxx.xml') of its class in the
+// XML format used by the nand2tetris project 10, so that they can be compared w/ the reference ones.
+func EmitXML(TUs []string, program jack.Program, emit string) int {
+	if emit != "tokens" && emit != "xml" {
+		fmt.Printf("ERROR: Unsupported output '%s', use one of 'vm', 'tokens' or 'xml'\n", emit)
+		return -1
+	}
+
+	for _, tu := range TUs {
+		// Removes root directory and file extension to use as module name
+		filename, extension := path.Base(tu), path.Ext(tu)
+
+		emitter := jack.NewXMLEmitter(program[strings.TrimSuffix(filename, extension)])
+		tree, err := emitter.Emit()
+		if err != nil {
+			fmt.Println(utils.FormatError("emit", err))
+			return -1
+		}
+
+		// The tokens are a flat list, w/o any indentation, wrapped by a 'tokens' element
+		content, suffix := tree.XML("  "), ""
+		if emit == "tokens" {
+			content, suffix = jack.Element{Tag: "tokens", Children: tree.Tokens()}.XML(""), "T"
+		}
+
+		if err := os.WriteFile(fmt.Sprintf("%s%s.xml", strings.TrimSuffix(tu, extension), suffix), []byte(content), 0644); err != nil {
+			fmt.Printf("ERROR: Unable to write output file: %s\n", err)
+			return -1
+		}
+	}
+
+	return 0
+}
+
 func main() { os.Exit(JackCompiler.Run(os.Args, os.Stdout)) }
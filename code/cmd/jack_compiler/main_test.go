@@ -1,10 +1,15 @@
 package main
 
 import (
+	"bytes"
 	"fmt"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"strings"
 	"testing"
+
+	"its-hmny.dev/nand2tetris/pkg/vm"
 )
 
 // This test checks the output of mmy Jack Compiler against the pre generated output of the same Jack Compiler
@@ -143,6 +148,12 @@ func TestAgainstBuiltIn(t *testing.T) {
 		tester := fmt.Sprintf("%s/%s", base, "Pong.diff")
 		test([]string{base}, base, true, tester)
 	})
+
+	t.Run("TicTacToe", func(t *testing.T) {
+		base := "../../../projects/09 - High-Level Language/09 - Tic Tac Toe"
+		tester := fmt.Sprintf("%s/%s", base, "TicTacToe.diff")
+		test([]string{base}, base, true, tester)
+	})
 }
 
 // This test checks the typechecking functionality of the Jack Compiler. It runs a fork of the code from
@@ -208,3 +219,385 @@ func TestTypeChecking(t *testing.T) {
 		test([]string{base}, base, false, true) // No need to link the stdlib here
 	})
 }
+
+// This test checks the syntax analyzer outputs (tokens and parse tree) of my Jack Compiler against the
+// reference ones of the project 10. The comparison ignores whitespaces, just like the TextComparer does,
+// and the outputs are emitted in a copy of the project so that the reference files are left untouched.
+func TestSyntaxAnalyzer(t *testing.T) {
+	test := func(base string, classes []string) {
+		dir := t.TempDir()
+		if err := os.CopyFS(dir, os.DirFS(base)); err != nil {
+			t.Fatalf("Failed to copy the project: %v", err)
+		}
+
+		for _, emit := range []string{"tokens", "xml"} {
+			if status := Handler([]string{dir}, map[string]string{"emit": emit}); status != 0 {
+				t.Fatalf("Unexpected exit status code: expected 0 got: %d", status)
+			}
+		}
+
+		normalize := func(content []byte) string { return strings.Join(strings.Fields(string(content)), "") }
+		for _, class := range classes {
+			for _, output := range []string{class + "T.xml", class + ".xml"} {
+				expected, _ := os.ReadFile(filepath.Join(base, output))
+				generated, err := os.ReadFile(filepath.Join(dir, output))
+				if err != nil {
+					t.Fatalf("Failed to read generated output: %v", err)
+				}
+				if normalize(generated) != normalize(expected) {
+					t.Errorf("The generated '%s' and the expected one do not match", output)
+				}
+			}
+		}
+	}
+
+	t.Run("ArrayTest", func(t *testing.T) {
+		test("../../../projects/10 - Jack I: Syntax Analysis/01 - ArrayTest", []string{"Main"})
+	})
+
+	t.Run("ExpressionLessSquare", func(t *testing.T) {
+		test("../../../projects/10 - Jack I: Syntax Analysis/02 - ExpressionLessSquare", []string{"Main", "Square", "SquareGame"})
+	})
+
+	t.Run("Square", func(t *testing.T) {
+		// ! The reference 'Main' outputs were generated from a version of 'Main.jack' where the 'more'
+		// ! subroutine is named 'test', so they cannot match the source code shipped in this folder.
+		test("../../../projects/10 - Jack I: Syntax Analysis/03 - Square", []string{"Square", "SquareGame"})
+	})
+
+	t.Run("Parentheses", func(t *testing.T) {
+		// Every pair of parentheses is kept in the parse tree, whatever the kind of the nested term
+		dir := t.TempDir()
+		source := "class Main {\n  function void main() {\n    var int x;\n    let x = ((x)) + (-x) + (Main.f());\n    return;\n  }\n}"
+		if err := os.WriteFile(filepath.Join(dir, "Main.jack"), []byte(source), 0644); err != nil {
+			t.Fatalf("Failed to write the source file: %v", err)
+		}
+		if status := Handler([]string{dir}, map[string]string{"emit": "xml"}); status != 0 {
+			t.Fatalf("Unexpected exit status code: expected 0 got: %d", status)
+		}
+
+		generated, err := os.ReadFile(filepath.Join(dir, "Main.xml"))
+		if err != nil {
+			t.Fatalf("Failed to read generated output: %v", err)
+		}
+		expected := strings.Join([]string{
+			"<expression>",
+			"<term><symbol>(</symbol><expression><term><symbol>(</symbol><expression><term><identifier>x</identifier></term></expression><symbol>)</symbol></term></expression><symbol>)</symbol></term>",
+			"<symbol>+</symbol>",
+			"<term><symbol>(</symbol><expression><term><symbol>-</symbol><term><identifier>x</identifier></term></term></expression><symbol>)</symbol></term>",
+			"<symbol>+</symbol>",
+			"<term><symbol>(</symbol><expression><term><identifier>Main</identifier><symbol>.</symbol><identifier>f</identifier><symbol>(</symbol><expressionList></expressionList><symbol>)</symbol></term></expression><symbol>)</symbol></term>",
+			"</expression>",
+		}, "")
+		if normalized := strings.Join(strings.Fields(string(generated)), ""); !strings.Contains(normalized, expected) {
+			t.Errorf("Expected the parse tree to contain:\n%s\ngot:\n%s", expected, normalized)
+		}
+	})
+
+	t.Run("UnsupportedOutput", func(t *testing.T) {
+		base := "../../../projects/10 - Jack I: Syntax Analysis/01 - ArrayTest"
+		if status := Handler([]string{base}, map[string]string{"emit": "json"}); status == 0 {
+			t.Fatalf("Expected failure for an unsupported output, got exit status 0")
+		}
+	})
+}
+
+// This test checks the '--precedence' option of the Jack Compiler, the same class is compiled both with
+// the strict left-to-right evaluation of the reference compiler and with the C-like precedence.
+func TestPrecedence(t *testing.T) {
+	test := func(precedence string, expected string) {
+		dir := t.TempDir()
+		source := "class Main {\n  function int main() {\n    return 1 + 2 * 3;\n  }\n}"
+		if err := os.WriteFile(filepath.Join(dir, "Main.jack"), []byte(source), 0644); err != nil {
+			t.Fatalf("Failed to write the source file: %v", err)
+		}
+
+		if status := Handler([]string{dir}, map[string]string{"stdlib": "true", "precedence": precedence}); status != 0 {
+			t.Fatalf("Unexpected exit status code: expected 0 got: %d", status)
+		}
+
+		compiled, err := os.ReadFile(filepath.Join(dir, "Main.vm"))
+		if err != nil {
+			t.Fatalf("Failed to read compilation output: %v", err)
+		}
+		if got := strings.Join(strings.Fields(string(compiled)), " "); got != expected {
+			t.Fatalf("Expected:\n%s\ngot:\n%s", expected, got)
+		}
+	}
+
+	t.Run("LeftToRight", func(t *testing.T) {
+		expected := "function Main.main 0 push constant 1 push constant 2 add push constant 3 call Math.multiply 2 return"
+		test("", expected)
+		test("left-to-right", expected)
+	})
+
+	t.Run("Standard", func(t *testing.T) {
+		test("standard", "function Main.main 0 push constant 1 push constant 2 push constant 3 call Math.multiply 2 add return")
+	})
+
+	t.Run("UnsupportedPrecedence", func(t *testing.T) {
+		if status := Handler([]string{t.TempDir()}, map[string]string{"precedence": "right-to-left"}); status == 0 {
+			t.Fatalf("Expected failure for an unsupported precedence, got exit status 0")
+		}
+	})
+}
+
+// Compiles the 'sources' of the classes (with the builtin stdlib), runs the program on the VM
+// interpreter until it halts and returns the RAM at the end of the execution.
+func Execute(t *testing.T, sources map[string]string) []uint16 {
+	dir, program := t.TempDir(), vm.Program{}
+	for class, source := range sources {
+		if err := os.WriteFile(filepath.Join(dir, class+".jack"), []byte(source), 0644); err != nil {
+			t.Fatalf("Failed to write the source file: %v", err)
+		}
+	}
+	if status := Handler([]string{dir}, map[string]string{"stdlib": "true"}); status != 0 {
+		t.Fatalf("Unexpected exit status code: expected 0 got: %d", status)
+	}
+
+	for class := range sources {
+		compiled, err := os.ReadFile(filepath.Join(dir, class+".vm"))
+		if err != nil {
+			t.Fatalf("Failed to open compilation output: %v", err)
+		}
+		parser := vm.NewParser(bytes.NewReader(compiled))
+		if program[class+".vm"], err = parser.Parse(); err != nil {
+			t.Fatalf("Unexpected parsing error: %v", err)
+		}
+	}
+
+	interpreter, err := vm.NewInterpreter(program)
+	if err != nil {
+		t.Fatalf("Unexpected linking error: %v", err)
+	}
+	if err := interpreter.EnableBuiltIns("Array", "Keyboard", "Math", "Memory", "Output", "Screen", "String", "Sys"); err != nil {
+		t.Fatalf("Unexpected error enabling the builtins: %v", err)
+	}
+	if err := interpreter.Bootstrap("Sys.init"); err != nil {
+		t.Fatalf("Unexpected bootstrap error: %v", err)
+	}
+	if err := interpreter.Run(100_000); err != nil || !interpreter.Halted() {
+		t.Fatalf("Expected the program to halt, got error: %v", err)
+	}
+
+	return interpreter.RAM
+}
+
+// This test checks the operators that are not part of the original Jack language, the class is compiled
+// and then executed by the 'vm.Interpreter' (with the OS implemented natively), each result is stored
+// in the RAM starting from address 8000. The RHS of '&&' and '||' must be evaluated only when needed.
+func TestOperators(t *testing.T) {
+	source := `class Main {
+		static int calls;
+
+		function boolean touch() { let calls = calls + 1; return 1 = 1; }
+
+		function void main() {
+			var Array out;
+			let out = 8000;
+			let out[0] = 7 % 3;
+			let out[1] = -7 % 3;
+			let out[2] = 2 <= 3;
+			let out[3] = 3 <= 3;
+			let out[4] = 4 <= 3;
+			let out[5] = 3 >= 4;
+			let out[6] = 3 != 4;
+			let out[7] = 3 ~= 3;
+			let out[8] = (1 = 2) && Main.touch();
+			let out[9] = (1 = 1) || Main.touch();
+			let out[10] = (1 = 1) && Main.touch();
+			let out[11] = (1 = 2) || Main.touch();
+			let out[12] = calls;
+			let out[13] = (1 < 2) && (2 < 3) || (3 < 2);
+			let out[14] = 1 + 6 % 4 * 2; // Evaluated strictly left to right
+			return;
+		}
+	}`
+	ram := Execute(t, map[string]string{"Main": source})
+
+	// The comparisons (like the VM ones) use -1 for 'true' and 0 for 'false'
+	expected := []int16{1, -1, -1, -1, 0, 0, -1, 0, 0, -1, -1, -1, 2, -1, 6}
+	for i, value := range expected {
+		if got := int16(ram[8000+i]); got != value {
+			t.Errorf("Unexpected value at RAM[%d]: expected %d got %d", 8000+i, value, got)
+		}
+	}
+}
+
+// Same as 'TestOperators' but for the 'for', 'break' and 'continue' statements, 'continue' must
+// jump to the step of a 'for' loop (and to the condition of a 'while' one) of the innermost loop.
+func TestLoops(t *testing.T) {
+	source := `class Main {
+		function void main() {
+			var Array out;
+			var int i, j, sum;
+			let out = 8000;
+
+			for (let i = 0; i < 5; let i = i + 1) { let sum = sum + i; }
+			let out[0] = sum;
+			let out[1] = i;
+
+			let sum = 0;
+			for (let i = 0; i < 10; let i = i + 1) {
+				if ((i & 1) = 1) { continue; }
+				if (i > 6) { break; }
+				let sum = sum + i;
+			}
+			let out[2] = sum;
+			let out[3] = i;
+
+			let sum = 0;
+			for (let i = 0; i < 3; let i = i + 1) {
+				for (let j = 0; ; let j = j + 1) {
+					if (j > i) { break; }
+					let sum = sum + 1;
+				}
+			}
+			let out[4] = sum;
+
+			let i = 0;
+			let sum = 0;
+			while (i < 10) {
+				let i = i + 1;
+				if (i < 4) { continue; }
+				if (i = 8) { break; }
+				let sum = sum + i;
+			}
+			let out[5] = sum;
+
+			let j = 0;
+			for (; j < 3; ) { let j = j + 1; }
+			let out[6] = j;
+			return;
+		}
+	}`
+	ram := Execute(t, map[string]string{"Main": source})
+
+	expected := []int16{10, 5, 12, 8, 6, 22, 3}
+	for i, value := range expected {
+		if got := int16(ram[8000+i]); got != value {
+			t.Errorf("Unexpected value at RAM[%d]: expected %d got %d", 8000+i, value, got)
+		}
+	}
+}
+
+// Same as 'TestOperators' but for the 'switch' statement, both w/ dense labels (lowered w/ a binary
+// search) and w/ sparse ones (compared one by one), the values around each label are checked as well.
+func TestSwitch(t *testing.T) {
+	source := `class Main {
+		function int dense(int x) {
+			switch (x) {
+				case -1: return 10;
+				case 0: return 20;
+				case 2: return 30;
+				case 3:
+				case 4: return 50;
+				case 6: return 60;
+				default: return 70;
+			}
+			return 0;
+		}
+
+		function int sparse(int x) {
+			var int result;
+			switch (x) {
+				case 1000: let result = 1; break;
+				case -7: let result = 2;
+				case 'z': let result = 3;
+				case 'A': let result = 4;
+			}
+			return result;
+		}
+
+		function void main() {
+			var Array out;
+			var int i, sum;
+			let out = 8000;
+			for (let i = -3; i < 9; let i = i + 1) { let out[i + 3] = Main.dense(i); }
+			let out[12] = Main.sparse(1000);
+			let out[13] = Main.sparse(-7);
+			let out[14] = Main.sparse(122);
+			let out[15] = Main.sparse(65);
+			let out[16] = Main.sparse(0);
+
+			// 'break' exits from the switch while 'continue' goes to the next iteration of the loop
+			for (let i = 0; i < 10; let i = i + 1) {
+				switch (i) {
+					case 1: break;
+					case 2: continue;
+					case 3: let sum = sum + 100;
+				}
+				let sum = sum + 1;
+			}
+			let out[17] = sum;
+			return;
+		}
+	}`
+	ram := Execute(t, map[string]string{"Main": source})
+
+	// For 'dense' the first 12 values are the results for -3..8, case 3 is empty (there's no fallthrough)
+	expected := []int16{70, 70, 10, 20, 70, 30, 0, 50, 70, 60, 70, 70, 1, 2, 3, 4, 0, 109}
+	for i, value := range expected {
+		if got := int16(ram[8000+i]); got != value {
+			t.Errorf("Unexpected value at RAM[%d]: expected %d got %d", 8000+i, value, got)
+		}
+	}
+}
+
+// Same as 'TestOperators' but for the constants, referenced by name in the declaring class and w/
+// 'Class.NAME' in the others, also as 'switch' labels (local variables take precedence over them).
+// The enum members can be qualified by the enum name as well, that is also an alias of 'int'.
+func TestConstants(t *testing.T) {
+	screen := `class Display {
+		const int WIDTH = 512;
+		const int HEIGHT = 256;
+		const char BLANK = ' ';
+		enum Key { LEFT = 130, UP, RIGHT, DOWN }
+
+		function int pixels() { return WIDTH / 16 * HEIGHT; }
+	}`
+	main := `class Main {
+		enum Direction { NORTH, EAST, SOUTH = -2, WEST }
+
+		function Direction turn(int key) {
+			switch (key) {
+				case Display.LEFT: return WEST;
+				case Display.Key.UP: return Direction.NORTH;
+				case Display.RIGHT: return EAST;
+				case Display.DOWN: return SOUTH;
+			}
+			return -100;
+		}
+
+		function void main() {
+			var Array out;
+			var int EAST;
+			var Direction d;
+			let out = 8000;
+			let EAST = 42;
+			let out[0] = Display.WIDTH;
+			let out[1] = Display.HEIGHT;
+			let out[2] = Display.pixels();
+			let out[3] = Display.BLANK;
+			let out[4] = Main.turn(130);
+			let out[5] = Main.turn(131);
+			let out[6] = Main.turn(132);
+			let out[7] = Main.turn(133);
+			let out[8] = Main.turn(0);
+			let out[9] = EAST;
+			let out[10] = Main.EAST;
+			let d = Main.turn(133);
+			let out[11] = d;
+			let out[12] = Main.Direction.WEST - Direction.EAST;
+			return;
+		}
+	}`
+	ram := Execute(t, map[string]string{"Display": screen, "Main": main})
+
+	expected := []int16{512, 256, 8192, 32, -1, 0, 1, -2, -100, 42, 1, -2, -2}
+	for i, value := range expected {
+		if got := int16(ram[8000+i]); got != value {
+			t.Errorf("Unexpected value at RAM[%d]: expected %d got %d", 8000+i, value, got)
+		}
+	}
+}
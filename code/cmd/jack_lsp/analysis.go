@@ -0,0 +1,373 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"its-hmny.dev/nand2tetris/pkg/jack"
+	"its-hmny.dev/nand2tetris/pkg/utils"
+)
+
+// ----------------------------------------------------------------------------
+// Workspace
+
+// This section builds the 'jack.Program' a document belongs to and resolves the identifiers in it.
+//
+// Just like the 'jack_compiler' does, a program is made of all the '.jack' files in the same folder,
+// the open documents take precedence over their content on disk (that may be outdated) while the
+// classes of the standard library not defined by the user are provided by 'StandardLibraryABI'.
+// While the user is typing the document may contain syntax errors, in that case the last version
+// that was parsed successfully is used so that most of the features keep working.
+
+// Matches an identifier qualified by a class or variable name before the cursor (e.g. 'Output.pri').
+var qualifiedRegex = regexp.MustCompile(`([A-Za-z_][A-Za-z0-9_]*)\.([A-Za-z0-9_]*)$`)
+
+// Parses the content of the document at 'path', caching the class on success.
+func (s *Server) parse(path string, content []byte) (jack.Class, error) {
+	parser := jack.NewFileParser(bytes.NewReader(content), path)
+	class, err := parser.Parse()
+	if err != nil {
+		return jack.Class{}, err
+	}
+
+	s.classes[path] = class
+	return class, nil
+}
+
+// Returns the last version of the document at 'path', the open one or the one on disk.
+func (s *Server) content(path string) ([]byte, error) {
+	if text, open := s.documents[path]; open {
+		return []byte(text), nil
+	}
+	return os.ReadFile(path)
+}
+
+// Returns the class defined in the document at 'path', if the document cannot be parsed the
+// last version that was parsed successfully is returned instead (along with the parsing error).
+func (s *Server) class(path string) (jack.Class, bool, error) {
+	content, err := s.content(path)
+	if err != nil {
+		return jack.Class{}, false, err
+	}
+
+	class, err := s.parse(path, content)
+	if err != nil {
+		cached, found := s.classes[path]
+		return cached, found, err
+	}
+	return class, true, nil
+}
+
+// Builds the program that the document at 'path' belongs to (see the section description).
+func (s *Server) program(path string) jack.Program {
+	program := jack.Program{}
+
+	files, _ := filepath.Glob(filepath.Join(filepath.Dir(path), "*.jack"))
+	if !slices.Contains(files, path) { // The document may have not been saved on disk yet
+		files = append(files, path)
+	}
+	for _, file := range files {
+		if class, found, _ := s.class(file); found {
+			program[class.Name] = class
+		}
+	}
+
+	for name, abi := range jack.StandardLibraryABI {
+		if _, defined := program[name]; defined {
+			continue // User defined classes (e.g. the ones of the OS project) take precedence
+		}
+
+		class := jack.Class{Name: name, Subroutines: utils.OrderedMap[string, jack.Subroutine]{}}
+		for _, subroutine := range sortedABI(abi) {
+			class.Subroutines.Set(subroutine.Name, subroutine)
+		}
+		program[name] = class
+	}
+
+	return program
+}
+
+// Returns the diagnostics for the document at 'path', either the syntax error that prevents its
+// parsing or all the problems found by the 'jack.TypeChecker' in the class defined by it.
+func (s *Server) diagnose(path string) []Diagnostic {
+	content, err := s.content(path)
+	if err != nil {
+		return []Diagnostic{{Severity: SeverityError, Source: "jack", Message: err.Error()}}
+	}
+
+	if _, err := s.parse(path, content); err != nil {
+		var located utils.PositionError
+		if !errors.As(err, &located) {
+			return []Diagnostic{{Severity: SeverityError, Source: "jack", Message: err.Error()}}
+		}
+
+		// Only the first line of the message is kept, the snippet is useless in the editor
+		message, _, _ := strings.Cut(located.Err.Error(), "\n")
+		return []Diagnostic{{Range: ToRange(located.Position, 1), Severity: SeverityError, Code: "syntax-error", Source: "jack", Message: message}}
+	}
+
+	checker := jack.NewTypeChecker(s.program(path))
+	problems, err := checker.Check()
+	if err != nil {
+		return []Diagnostic{{Severity: SeverityError, Source: "jack", Message: err.Error()}}
+	}
+
+	diagnostics := []Diagnostic{}
+	for _, problem := range problems {
+		if problem.Position.File != path { // The diagnostics of the other files are published on their own
+			continue
+		}
+
+		severity := SeverityError
+		if problem.Severity == jack.Warning {
+			severity = SeverityWarning
+		}
+		diagnostics = append(diagnostics, Diagnostic{
+			Range:    ToRange(problem.Position, 1),
+			Severity: severity,
+			Code:     string(problem.Code),
+			Source:   "jack",
+			Message:  problem.Message,
+		})
+	}
+
+	return diagnostics
+}
+
+// ----------------------------------------------------------------------------
+// Symbols resolution
+
+// A Symbol is the declaration an identifier in the source code refers to.
+type Symbol struct {
+	Class      jack.Class       // The class declared or the one that contains the declaration
+	Subroutine *jack.Subroutine // The subroutine declared, if any
+	Variable   *jack.Variable   // The variable (field, static, argument or local) declared, if any
+}
+
+// Returns the location of the declaration, invalid for the entries of the standard library.
+func (s Symbol) Position() utils.Position {
+	switch {
+	case s.Variable != nil:
+		return s.Variable.Position
+	case s.Subroutine != nil:
+		return s.Subroutine.Position
+	default:
+		return s.Class.Position
+	}
+}
+
+// Returns a textual representation of the symbol, formatted as it would be declared in Jack.
+func (s Symbol) Signature() string {
+	switch {
+	case s.Variable != nil:
+		kind := string(s.Variable.VarType)
+		if s.Variable.VarType == jack.Local {
+			kind = "var"
+		}
+		return fmt.Sprintf("%s %s %s", kind, s.Variable.DataType, s.Variable.Name)
+
+	case s.Subroutine != nil:
+		args := []string{}
+		for _, arg := range s.Subroutine.Arguments {
+			args = append(args, fmt.Sprintf("%s %s", arg.DataType, arg.Name))
+		}
+		return fmt.Sprintf("%s %s %s.%s(%s)", s.Subroutine.Type, s.Subroutine.Return, s.Class.Name, s.Subroutine.Name, strings.Join(args, ", "))
+
+	default:
+		return fmt.Sprintf("class %s", s.Class.Name)
+	}
+}
+
+// Resolves the identifier found at 'position' in the document at 'path' to its declaration.
+func (s *Server) resolve(path string, position Position) (Symbol, bool) {
+	content, err := s.content(path)
+	if err != nil {
+		return Symbol{}, false
+	}
+	class, found, _ := s.class(path)
+	if !found {
+		return Symbol{}, false
+	}
+
+	lines := strings.Split(string(content), "\n")
+	if position.Line >= len(lines) {
+		return Symbol{}, false
+	}
+	name, qualifier, call := identifierAt(lines[position.Line], position.Character)
+	if name == "" {
+		return Symbol{}, false
+	}
+
+	program := s.program(path)
+	scopes := scopesAt(class, utils.Position{Line: position.Line + 1, Column: position.Character + 1})
+
+	// Qualified names are members of either a class (e.g. 'Math.max') or an object (e.g. 'list.dispose')
+	if qualifier != "" {
+		target, found := program[qualifier]
+		if _, variable, err := scopes.ResolveVariable(qualifier); err == nil && variable.DataType.Main == jack.Object {
+			target, found = program[variable.DataType.Subtype]
+		}
+		if !found {
+			return Symbol{}, false
+		}
+
+		subroutine, exists := target.Subroutines.Get(name)
+		if !exists {
+			return Symbol{}, false
+		}
+		return Symbol{Class: target, Subroutine: &subroutine}, true
+	}
+
+	// Unqualified calls always refer to the subroutines of the current class
+	if call {
+		subroutine, exists := class.Subroutines.Get(name)
+		if !exists {
+			return Symbol{}, false
+		}
+		return Symbol{Class: class, Subroutine: &subroutine}, true
+	}
+
+	if _, variable, err := scopes.ResolveVariable(name); err == nil {
+		return Symbol{Class: class, Variable: &variable}, true
+	}
+	if target, exists := program[name]; exists {
+		return Symbol{Class: target}, true
+	}
+	if subroutine, exists := class.Subroutines.Get(name); exists { // e.g. the name in the declaration
+		return Symbol{Class: class, Subroutine: &subroutine}, true
+	}
+
+	return Symbol{}, false
+}
+
+// Returns the members of the class or object named 'qualifier' (e.g. 'Output' or 'list') that
+// start with 'prefix', as visible from the 'position' in the document at 'path'.
+func (s *Server) members(path string, position Position, qualifier, prefix string) []Symbol {
+	program := s.program(path)
+	class, _, _ := s.class(path)
+	scopes := scopesAt(class, utils.Position{Line: position.Line + 1, Column: position.Character + 1})
+
+	target, found := program[qualifier]
+	if _, variable, err := scopes.ResolveVariable(qualifier); err == nil && variable.DataType.Main == jack.Object {
+		target, found = program[variable.DataType.Subtype]
+	}
+	if !found {
+		return nil
+	}
+
+	symbols := []Symbol{}
+	for _, subroutine := range target.Subroutines.Entries() {
+		if strings.HasPrefix(subroutine.Name, prefix) {
+			symbols = append(symbols, Symbol{Class: target, Subroutine: &subroutine})
+		}
+	}
+	return symbols
+}
+
+// Returns the identifier that contains the (0-based) 'column' in 'line', the name of the class
+// or variable that qualifies it (if any) and whether the identifier is followed by a call.
+func identifierAt(line string, column int) (string, string, bool) {
+	isIdent := func(char byte) bool {
+		return char == '_' || (char >= 'a' && char <= 'z') || (char >= 'A' && char <= 'Z') || (char >= '0' && char <= '9')
+	}
+
+	start, end := min(column, len(line)), min(column, len(line))
+	for start > 0 && isIdent(line[start-1]) {
+		start--
+	}
+	for end < len(line) && isIdent(line[end]) {
+		end++
+	}
+	if start == end {
+		return "", "", false
+	}
+
+	qualifier := ""
+	if start > 0 && line[start-1] == '.' {
+		if match := qualifiedRegex.FindStringSubmatch(line[:start]); match != nil {
+			qualifier = match[1]
+		}
+	}
+
+	call := strings.HasPrefix(strings.TrimLeft(line[end:], " \t"), "(")
+	return line[start:end], qualifier, call
+}
+
+// Returns the variables visible at 'position' in 'class': its fields and, if the position is
+// inside a subroutine, the arguments and the local variables of the latter.
+func scopesAt(class jack.Class, position utils.Position) *jack.ScopeTable {
+	scopes := jack.NewScopeTable()
+	scopes.PushClassScope(class.Name)
+	for _, field := range class.Fields.Entries() {
+		scopes.RegisterVariable(field)
+	}
+
+	// The enclosing subroutine is the last one declared before the position
+	var enclosing *jack.Subroutine
+	for _, subroutine := range class.Subroutines.Entries() {
+		if precedes(subroutine.Position, position) {
+			enclosing = &subroutine
+		}
+	}
+	if enclosing == nil {
+		return scopes
+	}
+
+	scopes.PushSubRoutineScope(enclosing.Name)
+	for _, arg := range enclosing.Arguments {
+		scopes.RegisterVariable(arg)
+	}
+
+	var register func(statements []jack.Statement)
+	register = func(statements []jack.Statement) {
+		for _, statement := range statements {
+			switch tStmt := statement.(type) {
+			case jack.VarStmt:
+				for _, variable := range tStmt.Vars {
+					scopes.RegisterVariable(variable)
+				}
+			case jack.IfStmt:
+				register(tStmt.ThenBlock)
+				register(tStmt.ElseBlock)
+			case jack.WhileStmt:
+				register(tStmt.Block)
+			case jack.ForStmt:
+				register(tStmt.Block)
+			case jack.SwitchStmt:
+				for _, sc := range tStmt.Cases {
+					register(sc.Block)
+				}
+				register(tStmt.Default)
+			}
+		}
+	}
+	register(enclosing.Statements)
+
+	return scopes
+}
+
+// Returns whether the position 'a' comes before (or is the same as) the position 'b'.
+func precedes(a, b utils.Position) bool {
+	return a.Line < b.Line || (a.Line == b.Line && a.Column <= b.Column)
+}
+
+// Returns the entries of the standard library ABI of a class sorted by name, to be deterministic.
+func sortedABI(abi map[string]jack.Subroutine) []jack.Subroutine {
+	names := []string{}
+	for name := range abi {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	subroutines := []jack.Subroutine{}
+	for _, name := range names {
+		subroutines = append(subroutines, abi[name])
+	}
+	return subroutines
+}
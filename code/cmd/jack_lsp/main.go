@@ -0,0 +1,27 @@
+package main
+
+import (
+	"os"
+	"strings"
+
+	"github.com/teris-io/cli"
+)
+
+var Description = strings.ReplaceAll(`
+The Jack Language Server provides editor support for the Jack language using the Language
+Server Protocol (LSP) over stdio: diagnostics on save, go-to-definition, hover, completion of
+the class members and document symbols. Editors are supposed to spawn it as a child process.
+`, "\n", " ")
+
+var JackLSP = cli.New(Description).
+	// Most editors pass '--stdio' to the language servers, it's the only transport supported anyway
+	WithOption(cli.NewOption("stdio", "Communicates with the client over stdin/stdout (default)").
+		WithType(cli.TypeBool)).
+	WithAction(Handler)
+
+func Handler(args []string, options map[string]string) int {
+	server := NewServer(os.Stdin, os.Stdout)
+	return server.Run()
+}
+
+func main() { os.Exit(JackLSP.Run(os.Args, os.Stdout)) }
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// A scripted LSP client, it talks with an in-process 'Server' through a couple of pipes.
+//
+// Since the pipes are synchronous the messages from the server are read in the background, if
+// not the server would block while sending a notification the client is not waiting for.
+type client struct {
+	t        *testing.T
+	writer   io.Writer
+	messages chan Message
+	nextID   int
+
+	notifications []Message // The notifications received from the server, in order
+}
+
+// Sends the request and waits for the response, the notifications received meanwhile are saved.
+func (c *client) request(method string, params any, result any) *ResponseError {
+	c.nextID++
+	id, _ := json.Marshal(c.nextID)
+	raw, _ := json.Marshal(params)
+	if err := WriteMessage(c.writer, Message{JSONRPC: "2.0", ID: id, Method: method, Params: raw}); err != nil {
+		c.t.Fatalf("Unable to send '%s' request: %s", method, err)
+	}
+
+	for {
+		message, open := <-c.messages
+		if !open {
+			c.t.Fatalf("Unable to read '%s' response, the stream is closed", method)
+		}
+		if message.ID == nil {
+			c.notifications = append(c.notifications, message)
+			continue
+		}
+		if string(message.ID) != string(id) {
+			c.t.Fatalf("Unexpected response id: expected %s, got %s", id, message.ID)
+		}
+		if message.Error != nil {
+			return message.Error
+		}
+		if err := json.Unmarshal(message.Result, result); err != nil {
+			c.t.Fatalf("Unable to decode '%s' result: %s", method, err)
+		}
+		return nil
+	}
+}
+
+// Sends the notification, no response is expected from the server.
+func (c *client) notify(method string, params any) {
+	raw, _ := json.Marshal(params)
+	if err := WriteMessage(c.writer, Message{JSONRPC: "2.0", Method: method, Params: raw}); err != nil {
+		c.t.Fatalf("Unable to send '%s' notification: %s", method, err)
+	}
+}
+
+// Waits for the next 'textDocument/publishDiagnostics' notification, by sending a dummy request
+// the notifications sent before its response (e.g. after a 'didSave') are surely received.
+func (c *client) diagnostics() PublishDiagnosticsParams {
+	c.notifications = nil
+	c.request("textDocument/documentSymbol", DocumentSymbolParams{TextDocument: TextDocumentIdentifier{URI: "file:///nowhere.jack"}}, &[]DocumentSymbol{})
+
+	for _, notification := range c.notifications {
+		if notification.Method == "textDocument/publishDiagnostics" {
+			params := PublishDiagnosticsParams{}
+			json.Unmarshal(notification.Params, &params)
+			return params
+		}
+	}
+	c.t.Fatalf("Expected diagnostics to be published, got nothing")
+	return PublishDiagnosticsParams{}
+}
+
+var mainSource = `class Main {
+    function void main() {
+        var Point p;
+        let p = Point.new(3, 4);
+        do Output.printInt(p.getX());
+        do Point.
+        return;
+    }
+}
+`
+
+var pointSource = `class Point {
+    field int x, y;
+
+    constructor Point new(int ax, int ay) {
+        let x = ax;
+        let y = ay;
+        return this;
+    }
+
+    method int getX() { return x; }
+    method int getY() { return y; }
+}
+`
+
+func TestLanguageServer(t *testing.T) {
+	dir := t.TempDir()
+	mainPath, pointPath := filepath.Join(dir, "Main.jack"), filepath.Join(dir, "Point.jack")
+	os.WriteFile(pointPath, []byte(pointSource), 0644)
+	mainURI, pointURI := PathToURI(mainPath), PathToURI(pointPath)
+
+	clientReader, serverWriter := io.Pipe()
+	serverReader, clientWriter := io.Pipe()
+
+	status := make(chan int)
+	go func() {
+		status <- NewServer(serverReader, serverWriter).Run()
+		serverWriter.Close()
+	}()
+
+	c := &client{t: t, writer: clientWriter, messages: make(chan Message, 16)}
+	go func() {
+		reader := bufio.NewReader(clientReader)
+		for message, err := ReadMessage(reader); err == nil; message, err = ReadMessage(reader) {
+			c.messages <- message
+		}
+		close(c.messages)
+	}()
+
+	t.Run("Lifecycle", func(t *testing.T) {
+		if err := c.request("textDocument/hover", TextDocumentPositionParams{}, &Hover{}); err == nil || err.Code != ServerNotInitialized {
+			t.Fatalf("Expected 'ServerNotInitialized' error, got %v", err)
+		}
+
+		result := map[string]any{}
+		if err := c.request("initialize", map[string]any{"capabilities": map[string]any{}}, &result); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if _, found := result["capabilities"]; !found {
+			t.Fatalf("Expected capabilities in the 'initialize' result, got %v", result)
+		}
+		c.notify("initialized", map[string]any{})
+
+		if err := c.request("workspace/symbol", map[string]any{}, &result); err == nil || err.Code != MethodNotFound {
+			t.Fatalf("Expected 'MethodNotFound' error, got %v", err)
+		}
+	})
+
+	t.Run("Diagnostics", func(t *testing.T) {
+		// The 'Point.' (completion is tested below) is read as 'Point.return', the error is at the ';'
+		c.notify("textDocument/didOpen", DidOpenTextDocumentParams{TextDocument: TextDocumentItem{URI: mainURI, LanguageID: "jack", Text: mainSource}})
+		published := c.diagnostics()
+		if published.URI != mainURI || len(published.Diagnostics) != 1 || published.Diagnostics[0].Code != "syntax-error" {
+			t.Fatalf("Expected a single syntax error, got %+v", published)
+		}
+		if start := published.Diagnostics[0].Range.Start; start.Line != 6 || start.Character != 14 {
+			t.Fatalf("Expected syntax error at 6:14, got %d:%d", start.Line, start.Character)
+		}
+
+		// Once fixed and saved all the type errors are reported, not just the first one
+		fixed := strings.Replace(mainSource, "do Point.\n", "let p = 1;\n        let q = true;\n", 1)
+		c.notify("textDocument/didSave", DidSaveTextDocumentParams{TextDocument: TextDocumentIdentifier{URI: mainURI}, Text: &fixed})
+		published = c.diagnostics()
+		if len(published.Diagnostics) != 2 {
+			t.Fatalf("Expected 2 diagnostics, got %+v", published.Diagnostics)
+		}
+		if published.Diagnostics[0].Code != "type-mismatch" || published.Diagnostics[1].Code != "undeclared-variable" {
+			t.Fatalf("Unexpected diagnostics: %+v", published.Diagnostics)
+		}
+
+		c.notify("textDocument/didChange", DidChangeTextDocumentParams{
+			TextDocument: TextDocumentIdentifier{URI: mainURI},
+			ContentChanges: []struct {
+				Text string `json:"text"`
+			}{{Text: mainSource}},
+		})
+	})
+
+	t.Run("Definition", func(t *testing.T) {
+		test := func(line, character int, expected *Location) {
+			location := &Location{}
+			params := TextDocumentPositionParams{TextDocument: TextDocumentIdentifier{URI: mainURI}, Position: Position{Line: line, Character: character}}
+			if err := c.request("textDocument/definition", params, &location); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if (location == nil) != (expected == nil) || (location != nil && *location != *expected) {
+				t.Fatalf("Expected definition %+v, got %+v", expected, location)
+			}
+		}
+
+		at := func(uri string, line, character, length int) *Location {
+			return &Location{URI: uri, Range: Range{Start: Position{line, character}, End: Position{line, character + length}}}
+		}
+
+		test(3, 23, at(pointURI, 3, 22, 3)) // 'new' in 'Point.new(3, 4)'
+		test(3, 17, at(pointURI, 0, 6, 5))  // 'Point' in 'Point.new(3, 4)'
+		test(4, 30, at(pointURI, 9, 15, 4)) // 'getX' in 'p.getX()', resolved through the type of 'p'
+		test(4, 28, at(mainURI, 2, 18, 1))  // 'p' in 'p.getX()'
+		test(4, 20, nil)                    // 'printInt' has no source code, it's part of the stdlib
+	})
+
+	t.Run("Hover", func(t *testing.T) {
+		test := func(line, character int, expected string) {
+			hover := Hover{}
+			params := TextDocumentPositionParams{TextDocument: TextDocumentIdentifier{URI: mainURI}, Position: Position{Line: line, Character: character}}
+			if err := c.request("textDocument/hover", params, &hover); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !strings.Contains(hover.Contents.Value, expected) {
+				t.Fatalf("Expected hover to contain '%s', got '%s'", expected, hover.Contents.Value)
+			}
+		}
+
+		test(2, 18, "var Point p")
+		test(3, 23, "constructor Point Point.new(int ax, int ay)")
+		test(4, 20, "function void Output.printInt(int i)")
+		test(4, 20, "standard library")
+	})
+
+	t.Run("Completion", func(t *testing.T) {
+		items := []CompletionItem{}
+		params := TextDocumentPositionParams{TextDocument: TextDocumentIdentifier{URI: mainURI}, Position: Position{Line: 5, Character: 17}}
+		if err := c.request("textDocument/completion", params, &items); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		labels := []string{}
+		for _, item := range items {
+			labels = append(labels, item.Label)
+		}
+		if strings.Join(labels, ",") != "new,getX,getY" {
+			t.Fatalf("Expected completion of the 'Point' members, got %v", labels)
+		}
+	})
+
+	t.Run("Document symbols", func(t *testing.T) {
+		symbols := []DocumentSymbol{}
+		params := DocumentSymbolParams{TextDocument: TextDocumentIdentifier{URI: pointURI}}
+		if err := c.request("textDocument/documentSymbol", params, &symbols); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		if len(symbols) != 1 || symbols[0].Name != "Point" || symbols[0].Kind != SymbolClass {
+			t.Fatalf("Expected the 'Point' class symbol, got %+v", symbols)
+		}
+		children := []string{}
+		for _, child := range symbols[0].Children {
+			children = append(children, child.Name)
+		}
+		if strings.Join(children, ",") != "x,y,new,getX,getY" {
+			t.Fatalf("Expected fields and subroutines as children, got %v", children)
+		}
+	})
+
+	t.Run("Shutdown", func(t *testing.T) {
+		var result any
+		if err := c.request("shutdown", nil, &result); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		c.notify("exit", nil)
+		if code := <-status; code != 0 {
+			t.Fatalf("Expected exit code 0 after 'shutdown', got %d", code)
+		}
+	})
+}
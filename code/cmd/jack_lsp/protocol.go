@@ -0,0 +1,254 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"its-hmny.dev/nand2tetris/pkg/utils"
+)
+
+// ----------------------------------------------------------------------------
+// JSON-RPC transport
+
+// This section implements the base protocol of the Language Server Protocol (LSP).
+//
+// Each message is a JSON-RPC 2.0 object preceded by a set of HTTP-like headers, the only one we
+// care about is 'Content-Length' that tells the size (in bytes) of the JSON content that follows:
+//
+//	Content-Length: 52\r\n
+//	\r\n
+//	{"jsonrpc":"2.0","id":1,"method":"shutdown"}
+
+// A Message is either a request (has both 'ID' and 'Method'), a notification (has only 'Method')
+// or a response (has only 'ID'), the 'Params' are decoded lazily based on the method invoked.
+type Message struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *ResponseError  `json:"error,omitempty"`
+}
+
+// The response for a successful request, 'Result' is always included even when 'null'.
+type Response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  any             `json:"result"`
+}
+
+// The response for a failed request, as per spec the 'result' field is omitted.
+type ErrorResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Error   ResponseError   `json:"error"`
+}
+
+// A notification sent from the server to the client, no response is expected.
+type Notification struct {
+	JSONRPC string `json:"jsonrpc"`
+	Method  string `json:"method"`
+	Params  any    `json:"params"`
+}
+
+type ResponseError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// Error codes defined by the JSON-RPC and LSP specs
+const (
+	InvalidRequest       = -32600
+	MethodNotFound       = -32601
+	InvalidParams        = -32602
+	ServerNotInitialized = -32002
+)
+
+// Reads the next message from 'r', returns 'io.EOF' when the stream is closed between messages.
+func ReadMessage(r *bufio.Reader) (Message, error) {
+	length := -1
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return Message{}, err
+		}
+
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" { // The empty line separates the headers from the content
+			break
+		}
+
+		name, value, found := strings.Cut(line, ":")
+		if found && strings.EqualFold(strings.TrimSpace(name), "Content-Length") {
+			if length, err = strconv.Atoi(strings.TrimSpace(value)); err != nil {
+				return Message{}, fmt.Errorf("invalid 'Content-Length' header: %s", value)
+			}
+		}
+	}
+	if length < 0 {
+		return Message{}, fmt.Errorf("missing 'Content-Length' header")
+	}
+
+	content := make([]byte, length)
+	if _, err := io.ReadFull(r, content); err != nil {
+		return Message{}, err
+	}
+
+	message := Message{}
+	if err := json.Unmarshal(content, &message); err != nil {
+		return Message{}, fmt.Errorf("invalid JSON content: %s", err)
+	}
+	return message, nil
+}
+
+// Writes 'message' to 'w' along with the headers required by the base protocol.
+func WriteMessage(w io.Writer, message any) error {
+	content, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "Content-Length: %d\r\n\r\n%s", len(content), content)
+	return err
+}
+
+// ----------------------------------------------------------------------------
+// LSP structures
+
+// This section contains the subset of the LSP structures used by the server, the names and the
+// fields are the same ones used in the specification so they can be looked up easily.
+
+type Position struct {
+	Line      int `json:"line"`      // Zero-based line in the document
+	Character int `json:"character"` // Zero-based offset in the line (Jack sources are ASCII)
+}
+
+type Range struct {
+	Start Position `json:"start"`
+	End   Position `json:"end"`
+}
+
+type Location struct {
+	URI   string `json:"uri"`
+	Range Range  `json:"range"`
+}
+
+type TextDocumentIdentifier struct {
+	URI string `json:"uri"`
+}
+
+type TextDocumentItem struct {
+	URI        string `json:"uri"`
+	LanguageID string `json:"languageId"`
+	Version    int    `json:"version"`
+	Text       string `json:"text"`
+}
+
+type DidOpenTextDocumentParams struct {
+	TextDocument TextDocumentItem `json:"textDocument"`
+}
+
+type DidChangeTextDocumentParams struct {
+	TextDocument   TextDocumentIdentifier `json:"textDocument"`
+	ContentChanges []struct {
+		Text string `json:"text"`
+	} `json:"contentChanges"`
+}
+
+type DidSaveTextDocumentParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+	Text         *string                `json:"text,omitempty"`
+}
+
+type DidCloseTextDocumentParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+}
+
+type TextDocumentPositionParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+	Position     Position               `json:"position"`
+}
+
+type DocumentSymbolParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+}
+
+type PublishDiagnosticsParams struct {
+	URI         string       `json:"uri"`
+	Diagnostics []Diagnostic `json:"diagnostics"`
+}
+
+type Diagnostic struct {
+	Range    Range  `json:"range"`
+	Severity int    `json:"severity"`
+	Code     string `json:"code,omitempty"`
+	Source   string `json:"source"`
+	Message  string `json:"message"`
+}
+
+type Hover struct {
+	Contents MarkupContent `json:"contents"`
+	Range    *Range        `json:"range,omitempty"`
+}
+
+type MarkupContent struct {
+	Kind  string `json:"kind"`
+	Value string `json:"value"`
+}
+
+type CompletionItem struct {
+	Label  string `json:"label"`
+	Kind   int    `json:"kind"`
+	Detail string `json:"detail,omitempty"`
+}
+
+type DocumentSymbol struct {
+	Name           string           `json:"name"`
+	Detail         string           `json:"detail,omitempty"`
+	Kind           int              `json:"kind"`
+	Range          Range            `json:"range"`
+	SelectionRange Range            `json:"selectionRange"`
+	Children       []DocumentSymbol `json:"children,omitempty"`
+}
+
+// Enums defined by the LSP spec (only the values actually used by the server)
+const (
+	SeverityError   = 1
+	SeverityWarning = 2
+
+	CompletionMethod      = 2
+	CompletionFunction    = 3
+	CompletionConstructor = 4
+
+	SymbolClass       = 5
+	SymbolMethod      = 6
+	SymbolField       = 8
+	SymbolConstructor = 9
+	SymbolFunction    = 12
+	SymbolVariable    = 13
+)
+
+// Converts a 'file://' URI to the path of the file on the local filesystem.
+func URIToPath(uri string) (string, error) {
+	parsed, err := url.Parse(uri)
+	if err != nil || parsed.Scheme != "file" {
+		return "", fmt.Errorf("unsupported document URI '%s'", uri)
+	}
+	return parsed.Path, nil
+}
+
+// Converts the path of a file on the local filesystem to its 'file://' URI.
+func PathToURI(path string) string {
+	return (&url.URL{Scheme: "file", Path: path}).String()
+}
+
+// Converts a 'utils.Position' (1-based) to the range that covers 'length' characters from it.
+func ToRange(position utils.Position, length int) Range {
+	start := Position{Line: max(position.Line-1, 0), Character: max(position.Column-1, 0)}
+	return Range{Start: start, End: Position{Line: start.Line, Character: start.Character + length}}
+}
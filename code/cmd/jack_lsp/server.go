@@ -0,0 +1,345 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"its-hmny.dev/nand2tetris/pkg/jack"
+)
+
+// ----------------------------------------------------------------------------
+// Language Server
+
+// This section implements the lifecycle of the language server and the handlers of each method.
+//
+// The server is single threaded: each message is read from the client, handled and (if it is a
+// request) answered before moving on to the next one. This is more than enough for Jack programs,
+// that are small, and saves us from synchronizing the access to the documents and their classes.
+type Server struct {
+	reader *bufio.Reader // The stream of messages coming from the client
+	writer io.Writer     // The stream of messages going to the client
+
+	documents map[string]string     // The content of the open documents, indexed by their path
+	classes   map[string]jack.Class // The last class parsed successfully for each document
+
+	initialized bool // Whether the 'initialize' request has been received
+	shutdown    bool // Whether the 'shutdown' request has been received
+}
+
+// Initializes and returns to the caller a brand new 'Server' struct.
+// Requires both the argument 'r' and 'w' to be valid and usable.
+func NewServer(r io.Reader, w io.Writer) *Server {
+	return &Server{
+		reader:    bufio.NewReader(r),
+		writer:    w,
+		documents: map[string]string{},
+		classes:   map[string]jack.Class{},
+	}
+}
+
+// Serves the client until the 'exit' notification is received (or the stream is closed), returns
+// the exit code of the process: as per spec it's 0 only if a 'shutdown' request was received before.
+func (s *Server) Run() int {
+	for {
+		message, err := ReadMessage(s.reader)
+		if errors.Is(err, io.EOF) {
+			return 1
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: Unable to read message: %s\n", err)
+			return 1
+		}
+
+		if message.Method == "exit" {
+			if s.shutdown {
+				return 0
+			}
+			return 1
+		}
+
+		result, rpcErr := s.Handle(message)
+		if message.ID == nil { // Notifications never get a response, even if they fail
+			continue
+		}
+
+		if rpcErr != nil {
+			err = WriteMessage(s.writer, ErrorResponse{JSONRPC: "2.0", ID: message.ID, Error: *rpcErr})
+		} else {
+			err = WriteMessage(s.writer, Response{JSONRPC: "2.0", ID: message.ID, Result: result})
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: Unable to write response: %s\n", err)
+			return 1
+		}
+	}
+}
+
+// Dispatches the message to the handler of its method, returns either the result or the error.
+func (s *Server) Handle(message Message) (any, *ResponseError) {
+	if !s.initialized && message.Method != "initialize" {
+		return nil, &ResponseError{Code: ServerNotInitialized, Message: "the server has not been initialized yet"}
+	}
+	if s.shutdown {
+		return nil, &ResponseError{Code: InvalidRequest, Message: "the server is shutting down"}
+	}
+
+	switch message.Method {
+	case "initialize":
+		return s.HandleInitialize()
+	case "initialized":
+		return nil, nil
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+
+	case "textDocument/didOpen":
+		params := DidOpenTextDocumentParams{}
+		return decode(message.Params, &params, func() (any, *ResponseError) { return s.HandleDidOpen(params) })
+	case "textDocument/didChange":
+		params := DidChangeTextDocumentParams{}
+		return decode(message.Params, &params, func() (any, *ResponseError) { return s.HandleDidChange(params) })
+	case "textDocument/didSave":
+		params := DidSaveTextDocumentParams{}
+		return decode(message.Params, &params, func() (any, *ResponseError) { return s.HandleDidSave(params) })
+	case "textDocument/didClose":
+		params := DidCloseTextDocumentParams{}
+		return decode(message.Params, &params, func() (any, *ResponseError) { return s.HandleDidClose(params) })
+
+	case "textDocument/definition":
+		params := TextDocumentPositionParams{}
+		return decode(message.Params, &params, func() (any, *ResponseError) { return s.HandleDefinition(params) })
+	case "textDocument/hover":
+		params := TextDocumentPositionParams{}
+		return decode(message.Params, &params, func() (any, *ResponseError) { return s.HandleHover(params) })
+	case "textDocument/completion":
+		params := TextDocumentPositionParams{}
+		return decode(message.Params, &params, func() (any, *ResponseError) { return s.HandleCompletion(params) })
+	case "textDocument/documentSymbol":
+		params := DocumentSymbolParams{}
+		return decode(message.Params, &params, func() (any, *ResponseError) { return s.HandleDocumentSymbol(params) })
+
+	default:
+		return nil, &ResponseError{Code: MethodNotFound, Message: fmt.Sprintf("method '%s' not supported", message.Method)}
+	}
+}
+
+// Decodes the 'raw' params in 'params' and, if successful, invokes the 'handler'.
+func decode(raw json.RawMessage, params any, handler func() (any, *ResponseError)) (any, *ResponseError) {
+	if err := json.Unmarshal(raw, params); err != nil {
+		return nil, &ResponseError{Code: InvalidParams, Message: fmt.Sprintf("invalid params: %s", err)}
+	}
+	return handler()
+}
+
+// Returns the path of the document identified by 'uri' or the error to be sent to the client.
+func documentPath(uri string) (string, *ResponseError) {
+	path, err := URIToPath(uri)
+	if err != nil {
+		return "", &ResponseError{Code: InvalidParams, Message: err.Error()}
+	}
+	return filepath.Clean(path), nil
+}
+
+// Handles the 'initialize' request, advertising the features supported by the server.
+func (s *Server) HandleInitialize() (any, *ResponseError) {
+	s.initialized = true
+	return map[string]any{
+		"capabilities": map[string]any{
+			// The full content is synced at every change, the documents are tiny anyway
+			"textDocumentSync":       map[string]any{"openClose": true, "change": 1, "save": map[string]any{"includeText": true}},
+			"definitionProvider":     true,
+			"hoverProvider":          true,
+			"completionProvider":     map[string]any{"triggerCharacters": []string{"."}},
+			"documentSymbolProvider": true,
+		},
+		"serverInfo": map[string]any{"name": "jack_lsp"},
+	}, nil
+}
+
+// Handles the 'textDocument/didOpen' notification, the diagnostics are published right away.
+func (s *Server) HandleDidOpen(params DidOpenTextDocumentParams) (any, *ResponseError) {
+	path, rpcErr := documentPath(params.TextDocument.URI)
+	if rpcErr != nil {
+		return nil, rpcErr
+	}
+
+	s.documents[path] = params.TextDocument.Text
+	return nil, s.publish(params.TextDocument.URI, path)
+}
+
+// Handles the 'textDocument/didChange' notification, only full content sync is supported.
+func (s *Server) HandleDidChange(params DidChangeTextDocumentParams) (any, *ResponseError) {
+	path, rpcErr := documentPath(params.TextDocument.URI)
+	if rpcErr != nil {
+		return nil, rpcErr
+	}
+
+	for _, change := range params.ContentChanges {
+		s.documents[path] = change.Text
+	}
+	return nil, nil
+}
+
+// Handles the 'textDocument/didSave' notification, the diagnostics are (re)published.
+func (s *Server) HandleDidSave(params DidSaveTextDocumentParams) (any, *ResponseError) {
+	path, rpcErr := documentPath(params.TextDocument.URI)
+	if rpcErr != nil {
+		return nil, rpcErr
+	}
+
+	if params.Text != nil {
+		s.documents[path] = *params.Text
+	}
+	return nil, s.publish(params.TextDocument.URI, path)
+}
+
+// Handles the 'textDocument/didClose' notification, from now on the content on disk is used.
+func (s *Server) HandleDidClose(params DidCloseTextDocumentParams) (any, *ResponseError) {
+	path, rpcErr := documentPath(params.TextDocument.URI)
+	if rpcErr != nil {
+		return nil, rpcErr
+	}
+
+	delete(s.documents, path)
+	return nil, nil
+}
+
+// Sends the 'textDocument/publishDiagnostics' notification for the document at 'path'.
+func (s *Server) publish(uri, path string) *ResponseError {
+	params := PublishDiagnosticsParams{URI: uri, Diagnostics: s.diagnose(path)}
+	if err := WriteMessage(s.writer, Notification{JSONRPC: "2.0", Method: "textDocument/publishDiagnostics", Params: params}); err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: Unable to publish diagnostics: %s\n", err)
+	}
+	return nil
+}
+
+// Handles the 'textDocument/definition' request, the entries of the standard library have no
+// source code so 'null' is returned for them (as well as for the unresolved identifiers).
+func (s *Server) HandleDefinition(params TextDocumentPositionParams) (any, *ResponseError) {
+	path, rpcErr := documentPath(params.TextDocument.URI)
+	if rpcErr != nil {
+		return nil, rpcErr
+	}
+
+	symbol, found := s.resolve(path, params.Position)
+	if position := symbol.Position(); found && position.IsValid() {
+		return Location{URI: PathToURI(position.File), Range: ToRange(position, len(symbolName(symbol)))}, nil
+	}
+	return nil, nil
+}
+
+// Handles the 'textDocument/hover' request, showing the declaration of the identifier.
+func (s *Server) HandleHover(params TextDocumentPositionParams) (any, *ResponseError) {
+	path, rpcErr := documentPath(params.TextDocument.URI)
+	if rpcErr != nil {
+		return nil, rpcErr
+	}
+
+	symbol, found := s.resolve(path, params.Position)
+	if !found {
+		return nil, nil
+	}
+
+	value := fmt.Sprintf("```jack\n%s\n```", symbol.Signature())
+	if !symbol.Position().IsValid() {
+		value += "\n\nPart of the Jack standard library."
+	}
+	return Hover{Contents: MarkupContent{Kind: "markdown", Value: value}}, nil
+}
+
+// Handles the 'textDocument/completion' request, the members of a class or object are suggested
+// right after the dot (e.g. 'Output.' or 'list.'), nothing is suggested in the other cases.
+func (s *Server) HandleCompletion(params TextDocumentPositionParams) (any, *ResponseError) {
+	path, rpcErr := documentPath(params.TextDocument.URI)
+	if rpcErr != nil {
+		return nil, rpcErr
+	}
+
+	content, err := s.content(path)
+	if err != nil {
+		return []CompletionItem{}, nil
+	}
+
+	lines := strings.Split(string(content), "\n")
+	if params.Position.Line >= len(lines) {
+		return []CompletionItem{}, nil
+	}
+	line := lines[params.Position.Line]
+	match := qualifiedRegex.FindStringSubmatch(line[:min(params.Position.Character, len(line))])
+	if match == nil {
+		return []CompletionItem{}, nil
+	}
+
+	items := []CompletionItem{}
+	for _, symbol := range s.members(path, params.Position, match[1], match[2]) {
+		kind := CompletionFunction
+		switch symbol.Subroutine.Type {
+		case jack.Method:
+			kind = CompletionMethod
+		case jack.Constructor:
+			kind = CompletionConstructor
+		}
+		items = append(items, CompletionItem{Label: symbol.Subroutine.Name, Kind: kind, Detail: symbol.Signature()})
+	}
+	return items, nil
+}
+
+// Handles the 'textDocument/documentSymbol' request, the class is returned with its fields and
+// subroutines nested inside.
+func (s *Server) HandleDocumentSymbol(params DocumentSymbolParams) (any, *ResponseError) {
+	path, rpcErr := documentPath(params.TextDocument.URI)
+	if rpcErr != nil {
+		return nil, rpcErr
+	}
+
+	class, found, _ := s.class(path)
+	if !found {
+		return []DocumentSymbol{}, nil
+	}
+
+	children := []DocumentSymbol{}
+	for _, field := range class.Fields.Entries() {
+		kind, symbol := SymbolField, Symbol{Class: class, Variable: &field}
+		if field.VarType == jack.Static {
+			kind = SymbolVariable
+		}
+		children = append(children, documentSymbol(symbol, kind, nil))
+	}
+	for _, subroutine := range class.Subroutines.Entries() {
+		kind, symbol := SymbolFunction, Symbol{Class: class, Subroutine: &subroutine}
+		switch subroutine.Type {
+		case jack.Method:
+			kind = SymbolMethod
+		case jack.Constructor:
+			kind = SymbolConstructor
+		}
+		children = append(children, documentSymbol(symbol, kind, nil))
+	}
+
+	return []DocumentSymbol{documentSymbol(Symbol{Class: class}, SymbolClass, children)}, nil
+}
+
+// Converts a 'Symbol' to its 'DocumentSymbol' counterpart (with the given 'kind' and 'children').
+func documentSymbol(symbol Symbol, kind int, children []DocumentSymbol) DocumentSymbol {
+	name := symbolName(symbol)
+	selection := ToRange(symbol.Position(), len(name))
+	return DocumentSymbol{Name: name, Detail: symbol.Signature(), Kind: kind, Range: selection, SelectionRange: selection, Children: children}
+}
+
+// Returns the name of the declared symbol (class, subroutine or variable).
+func symbolName(symbol Symbol) string {
+	switch {
+	case symbol.Variable != nil:
+		return symbol.Variable.Name
+	case symbol.Subroutine != nil:
+		return symbol.Subroutine.Name
+	default:
+		return symbol.Class.Name
+	}
+}
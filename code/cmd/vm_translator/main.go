@@ -2,6 +2,7 @@ package main
 
 import (
 	"bytes"
+	"cmp"
 	"fmt"
 	"os"
 	"path"
@@ -9,6 +10,7 @@ import (
 
 	"github.com/teris-io/cli"
 	"its-hmny.dev/nand2tetris/pkg/asm"
+	"its-hmny.dev/nand2tetris/pkg/utils"
 	"its-hmny.dev/nand2tetris/pkg/vm"
 )
 
@@ -26,6 +28,16 @@ var VmTranslator = cli.New(Description).
 		WithType(cli.TypeString)).
 	WithOption(cli.NewOption("bootstrap", "Includes bootstrap code in the final .asm file").
 		WithType(cli.TypeBool)).
+	WithOption(cli.NewOption("prune", "Drops the functions that can't be reached from the entrypoint").
+		WithType(cli.TypeBool)).
+	WithOption(cli.NewOption("report-unused", "Drops the functions that can't be reached from the entrypoint and lists them").
+		WithType(cli.TypeBool)).
+	WithOption(cli.NewOption("entry", "The entrypoint function used by '--prune' (default 'Sys.init')").
+		WithType(cli.TypeString)).
+	WithOption(cli.NewOption("compact", "Shares a single call, return and comparison routine across the program").
+		WithType(cli.TypeBool)).
+	WithOption(cli.NewOption("optimize", "Applies peephole optimizations to the translated .asm code").
+		WithType(cli.TypeBool)).
 	WithAction(Handler)
 
 func Handler(args []string, options map[string]string) int {
@@ -55,21 +67,49 @@ func Handler(args []string, options map[string]string) int {
 		}
 
 		// Instantiate a parser for the Vm program
-		parser := vm.NewParser(bytes.NewReader(content))
+		parser := vm.NewFileParser(bytes.NewReader(content), input)
 		// Parses the input file content and extract an AST (as a 'vm.Module') from it.
 		program[path.Base(input)], err = parser.Parse()
 		if err != nil {
-			fmt.Printf("ERROR: Unable to complete 'parsing' pass: %s\n", err)
+			fmt.Println(utils.FormatError("parsing", err))
+			return -1
+		}
+	}
+
+	// When the user opts in, the functions never called (directly or not) from the entrypoint are dropped
+	_, prune := options["prune"]
+	_, report := options["report-unused"]
+	if prune || report {
+		// The bootstrap code always jumps to 'Sys.init', so it's kept regardless of the entrypoint
+		entrypoints := []string{cmp.Or(options["entry"], "Sys.init")}
+		if _, bootstrap := options["bootstrap"]; bootstrap && entrypoints[0] != "Sys.init" {
+			entrypoints = append(entrypoints, "Sys.init")
+		}
+
+		pruner := vm.NewPruner(program, entrypoints...)
+		pruned, removed, err := pruner.Prune()
+		if err != nil {
+			fmt.Println(utils.FormatError("pruning", err))
 			return -1
 		}
+
+		if report {
+			for _, function := range removed {
+				fmt.Printf("UNUSED: '%s' declared at %s\n", function.Name, function.Position)
+			}
+		}
+		program = pruned
 	}
 
 	// Instantiate a lowerer to convert the program from Vm to Asm
 	lowerer := vm.NewLowerer(program)
+	if _, enabled := options["compact"]; enabled {
+		lowerer = vm.NewCompactLowerer(program)
+	}
 	// Lowers the vm.Program to an in-memory/IR representation of its Asm counterpart 'asm.Program'.
 	asmProgram, err := lowerer.Lowerer()
 	if err != nil {
-		fmt.Printf("ERROR: Unable to complete 'lowering' pass: %s\n", err)
+		fmt.Println(utils.FormatError("lowering", err))
 		return -1
 	}
 
@@ -88,6 +128,16 @@ func Handler(args []string, options map[string]string) int {
 		}, asmProgram...)
 	}
 
+	// When the user opts in, the translated program is optimized before the codegen phase
+	if _, enabled := options["optimize"]; enabled {
+		optimizer := asm.NewOptimizer(asmProgram)
+		asmProgram, err = optimizer.Optimize()
+		if err != nil {
+			fmt.Println(utils.FormatError("optimization", err))
+			return -1
+		}
+	}
+
 	// Now, instantiates a code generator for the Asm (compiled) program
 	codegen := asm.NewCodeGenerator(asmProgram)
 	// Iterates over each instruction and spits out the relative textual representation.
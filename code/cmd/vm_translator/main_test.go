@@ -1,9 +1,17 @@
 package main
 
 import (
+	"bytes"
 	"fmt"
-	"os/exec"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+
+	"its-hmny.dev/nand2tetris/pkg/asm"
+	"its-hmny.dev/nand2tetris/pkg/emulator"
+	"its-hmny.dev/nand2tetris/pkg/tst"
+	"its-hmny.dev/nand2tetris/pkg/vm"
 )
 
 func TestVMTranslator(t *testing.T) {
@@ -18,8 +26,21 @@ func TestVMTranslator(t *testing.T) {
 			t.Fatalf("Unexpected exit status code: expected 0 got: %d", status)
 		}
 
-		cmd := exec.Command("../../../tools/CPUEmulator.sh", test)
-		if err := cmd.Run(); err != nil {
+		file, err := os.Open(test)
+		if err != nil {
+			t.Fatalf("Error opening the '%s' test file: %s", test, err)
+		}
+		defer file.Close()
+
+		parser := tst.NewParser(file)
+		script, err := parser.Parse()
+		if err != nil {
+			t.Fatalf("Error parsing the '%s' test file: %s", test, err)
+		}
+
+		cpu := emulator.NewCPU()
+		runner := tst.NewRunner(script, &cpu, filepath.Dir(test))
+		if err := runner.Run(); err != nil {
 			t.Fatalf("Error while running the '%s' test file: %s", test, err)
 		}
 	}
@@ -119,3 +140,257 @@ func TestVMTranslator(t *testing.T) {
 		test(inputs, output, true, tester)
 	})
 }
+
+// Translates the same program twice, with the modules provided in a different order: since the modules
+// are always lowered in alphabetical order, the two outputs (and the statics allocation) are the same.
+func TestDeterministicTranslation(t *testing.T) {
+	base := "../../../projects/08 - VM II: Program Flow/06 - StaticsTest"
+	inputs := []string{filepath.Join(base, "Sys.vm"), filepath.Join(base, "Class1.vm"), filepath.Join(base, "Class2.vm")}
+
+	outputs := []string{}
+	for _, order := range [][]string{inputs, {inputs[2], inputs[0], inputs[1]}} {
+		options := map[string]string{"bootstrap": "true", "output": filepath.Join(t.TempDir(), "StaticsTest.asm")}
+		if status := Handler(order, options); status != 0 {
+			t.Fatalf("Unexpected exit status code: expected 0 got: %d", status)
+		}
+		content, err := os.ReadFile(options["output"])
+		if err != nil {
+			t.Fatalf("Error reading the translated program: %s", err)
+		}
+		outputs = append(outputs, string(content))
+	}
+
+	if outputs[0] != outputs[1] {
+		t.Fatalf("Expected the same translation regardless of the order of the modules")
+	}
+}
+
+// Translates the programs linked with the OS, only keeping the functions reachable from 'Sys.init'.
+func TestPruning(t *testing.T) {
+	base := "../../../projects/08 - VM II: Program Flow/05 - FibonacciElement"
+	inputs, _ := filepath.Glob("../../../tools/OS/*.vm")
+	inputs = append(inputs, filepath.Join(base, "Sys.vm"), filepath.Join(base, "Main.vm"))
+
+	test := func(inputs []string, options map[string]string, fail bool) int {
+		options["output"] = filepath.Join(t.TempDir(), "FibonacciElement.asm")
+		status := Handler(inputs, options)
+		if fail && status == 0 {
+			t.Fatalf("Expected failure, got exit status code 0")
+		}
+		if !fail && status != 0 {
+			t.Fatalf("Unexpected exit status code: expected 0 got: %d", status)
+		}
+
+		content, _ := os.ReadFile(options["output"])
+		return len(strings.Split(string(content), "\n"))
+	}
+
+	t.Run("Unused functions", func(t *testing.T) {
+		// FibonacciElement doesn't call any OS function, so the whole OS is dropped
+		linked := test(inputs, map[string]string{"bootstrap": "true"}, false)
+		pruned := test(inputs, map[string]string{"bootstrap": "true", "prune": "true"}, false)
+		if reported := test(inputs, map[string]string{"bootstrap": "true", "report-unused": "true"}, false); pruned >= linked || reported != pruned {
+			t.Fatalf("Unexpected program size: linked %d lines, pruned %d (reported %d)", linked, pruned, reported)
+		}
+
+		if alone := test(inputs[len(inputs)-2:], map[string]string{"bootstrap": "true"}, false); alone != pruned {
+			t.Fatalf("Expected the same program without the OS, got %d lines (instead of %d)", pruned, alone)
+		}
+	})
+
+	t.Run("Custom entrypoint", func(t *testing.T) {
+		// With the bootstrap code 'Sys.init' is always kept, since it's where the program starts from
+		pruned := test(inputs, map[string]string{"bootstrap": "true", "prune": "true"}, false)
+		if entry := test(inputs, map[string]string{"bootstrap": "true", "prune": "true", "entry": "Main.fibonacci"}, false); entry != pruned {
+			t.Fatalf("Expected 'Sys.init' to be kept, got %d lines (instead of %d)", entry, pruned)
+		}
+		pruned = test(inputs, map[string]string{"prune": "true"}, false)
+		if entry := test(inputs, map[string]string{"prune": "true", "entry": "Main.fibonacci"}, false); entry >= pruned {
+			t.Fatalf("Expected 'Sys.init' to be dropped, got %d lines (with it %d)", entry, pruned)
+		}
+	})
+
+	t.Run("Invalid entrypoint", func(t *testing.T) {
+		test(inputs, map[string]string{"prune": "true", "entry": "Main.missing"}, true)
+	})
+}
+
+// Translates the programs with the '--compact' option, the result has to be smaller than the plain
+// translation, behave like the 'vm.Interpreter' and still pass the test scripts (that allow some spare
+// clock cycles for the extra jumps). The scripts are run on a copy in a temporary folder, so that the
+// reference '.asm' files are not overwritten.
+func TestCompactTranslation(t *testing.T) {
+	test := func(base string, files []string, output string, tester string) {
+		inputs := []string{}
+		for _, file := range files {
+			inputs = append(inputs, filepath.Join(base, file))
+		}
+
+		size := crossCheck(t, inputs, map[string]string{"bootstrap": "true"}, nil)
+		if compact := crossCheck(t, inputs, map[string]string{"bootstrap": "true", "compact": "true"}, nil); compact >= size {
+			t.Fatalf("Expected a smaller program, got %d instructions (originally %d)", compact, size)
+		}
+
+		dir := t.TempDir()
+		for _, file := range []string{tester, strings.TrimSuffix(tester, ".tst") + ".cmp"} {
+			content, err := os.ReadFile(filepath.Join(base, file))
+			if err != nil {
+				t.Fatalf("Error reading the '%s' test file: %s", file, err)
+			}
+			os.WriteFile(filepath.Join(dir, file), content, 0644)
+		}
+		options := map[string]string{"bootstrap": "true", "compact": "true", "output": filepath.Join(dir, output)}
+		if status := Handler(inputs, options); status != 0 {
+			t.Fatalf("Unexpected exit status code: expected 0 got: %d", status)
+		}
+
+		file, err := os.Open(filepath.Join(dir, tester))
+		if err != nil {
+			t.Fatalf("Error opening the '%s' test file: %s", tester, err)
+		}
+		defer file.Close()
+
+		parser := tst.NewParser(file)
+		script, err := parser.Parse()
+		if err != nil {
+			t.Fatalf("Error parsing the '%s' test file: %s", tester, err)
+		}
+
+		cpu := emulator.NewCPU()
+		runner := tst.NewRunner(script, &cpu, dir)
+		if err := runner.Run(); err != nil {
+			t.Fatalf("Error while running the '%s' test file: %s", tester, err)
+		}
+	}
+
+	t.Run("NestedCall.vm", func(t *testing.T) {
+		test("../../../projects/08 - VM II: Program Flow/04 - NestedCall", []string{"Sys.vm"}, "NestedCall.asm", "NestedCall.tst")
+	})
+	t.Run("FibonacciElement.vm", func(t *testing.T) {
+		base := "../../../projects/08 - VM II: Program Flow/05 - FibonacciElement"
+		test(base, []string{"Sys.vm", "Main.vm"}, "FibonacciElement.asm", "FibonacciElement.tst")
+	})
+	t.Run("StaticsTest.vm", func(t *testing.T) {
+		base := "../../../projects/08 - VM II: Program Flow/06 - StaticsTest"
+		test(base, []string{"Sys.vm", "Class1.vm", "Class2.vm"}, "StaticsTest.asm", "StaticsTest.tst")
+	})
+}
+
+// Translates the program 'inputs' with the given 'options' and cross-checks it against the
+// 'vm.Interpreter', both starting with the same 'ram' content. Returns the size of the program.
+func crossCheck(t *testing.T, inputs []string, options map[string]string, ram map[int]uint16) int {
+	options["output"] = filepath.Join(t.TempDir(), "Program.asm")
+	if status := Handler(inputs, options); status != 0 {
+		t.Fatalf("Unexpected exit status code: expected 0 got: %d", status)
+	}
+
+	content, err := os.Open(options["output"])
+	if err != nil {
+		t.Fatalf("Error opening the translated program: %s", err)
+	}
+	defer content.Close()
+
+	parser := asm.NewParser(content)
+	program, err := parser.Parse()
+	if err != nil {
+		t.Fatalf("Error parsing the translated program: %s", err)
+	}
+	lowerer := asm.NewLowerer(program)
+	hackProgram, table, err := lowerer.Lower()
+	if err != nil {
+		t.Fatalf("Error lowering the translated program: %s", err)
+	}
+
+	cpu := emulator.NewCPU()
+	if err := cpu.LoadProgram(hackProgram, table); err != nil {
+		t.Fatalf("Error loading the translated program: %s", err)
+	}
+
+	vmProgram := vm.Program{}
+	for _, input := range inputs {
+		file, _ := os.ReadFile(input)
+		parser := vm.NewFileParser(bytes.NewReader(file), input)
+		if vmProgram[filepath.Base(input)], err = parser.Parse(); err != nil {
+			t.Fatalf("Error parsing the '%s' module: %s", input, err)
+		}
+	}
+	interpreter, err := vm.NewInterpreter(vmProgram)
+	if err != nil {
+		t.Fatalf("Error loading the program on the interpreter: %s", err)
+	}
+
+	for address, value := range ram {
+		cpu.RAM[address], interpreter.RAM[address] = value, value
+	}
+	if _, bootstrap := options["bootstrap"]; bootstrap {
+		interpreter.Bootstrap("Sys.init")
+	}
+
+	if err := interpreter.CrossCheck(&cpu, len(hackProgram)); err != nil {
+		t.Fatalf("Error cross-checking the translated program: %s", err)
+	}
+	return len(hackProgram)
+}
+
+// Translates the same program with and without the '--optimize' option, both have to behave like
+// the 'vm.Interpreter' does while the optimized one has to be smaller.
+func TestOptimization(t *testing.T) {
+	// The segments (and arguments) used by the programs without 'Sys.init', same as the test scripts
+	segments := map[int]uint16{0: 256, 1: 300, 2: 400, 3: 3000, 4: 3010, 400: 6, 401: 3000}
+
+	test := func(base string, files []string, bootstrap bool, ram map[int]uint16) {
+		inputs := []string{}
+		for _, file := range files {
+			inputs = append(inputs, filepath.Join(base, file))
+		}
+
+		options := map[string]string{}
+		if bootstrap {
+			options["bootstrap"] = fmt.Sprint(bootstrap)
+		}
+		size := crossCheck(t, inputs, options, ram)
+		options["optimize"] = fmt.Sprint(true)
+		if optimized := crossCheck(t, inputs, options, ram); optimized >= size {
+			t.Fatalf("Expected a smaller program, got %d instructions (originally %d)", optimized, size)
+		}
+	}
+
+	t.Run("SimpleAdd.vm", func(t *testing.T) {
+		test("../../../projects/07 - VM I: Stack Arithmetic/01 - SimpleAdd", []string{"SimpleAdd.vm"}, false, segments)
+	})
+	t.Run("StackTest.vm", func(t *testing.T) {
+		test("../../../projects/07 - VM I: Stack Arithmetic/02 - StackTest", []string{"StackTest.vm"}, false, segments)
+	})
+	t.Run("BasicTest.vm", func(t *testing.T) {
+		test("../../../projects/07 - VM I: Stack Arithmetic/03 - BasicTest", []string{"BasicTest.vm"}, false, segments)
+	})
+	t.Run("PointerTest.vm", func(t *testing.T) {
+		test("../../../projects/07 - VM I: Stack Arithmetic/04 - PointerTest", []string{"PointerTest.vm"}, false, segments)
+	})
+	t.Run("StaticTest.vm", func(t *testing.T) {
+		test("../../../projects/07 - VM I: Stack Arithmetic/05 - StaticTest", []string{"StaticTest.vm"}, false, segments)
+	})
+	t.Run("BasicLoop.vm", func(t *testing.T) {
+		test("../../../projects/08 - VM II: Program Flow/01 - BasicLoop", []string{"BasicLoop.vm"}, false, segments)
+	})
+	t.Run("FibonacciSeries.vm", func(t *testing.T) {
+		test("../../../projects/08 - VM II: Program Flow/02 - FibonacciSeries", []string{"FibonacciSeries.vm"}, false, segments)
+	})
+	t.Run("SimpleFunction.vm", func(t *testing.T) {
+		// The caller frame is built by hand, its return address is outside of the ROM so that
+		// the execution stops when returning from the function
+		test("../../../projects/08 - VM II: Program Flow/03 - SimpleFunction", []string{"SimpleFunction.vm"}, false, map[int]uint16{
+			0: 317, 1: 317, 2: 310, 3: 3000, 4: 4000, 310: 1234, 311: 37,
+			312: emulator.ROMSize - 1, 313: 305, 314: 300, 315: 3010, 316: 4010,
+		})
+	})
+	t.Run("NestedCall.vm", func(t *testing.T) {
+		test("../../../projects/08 - VM II: Program Flow/04 - NestedCall", []string{"Sys.vm"}, true, nil)
+	})
+	t.Run("FibonacciElement.vm", func(t *testing.T) {
+		test("../../../projects/08 - VM II: Program Flow/05 - FibonacciElement", []string{"Sys.vm", "Main.vm"}, true, nil)
+	})
+	t.Run("StaticsTest.vm", func(t *testing.T) {
+		test("../../../projects/08 - VM II: Program Flow/06 - StaticsTest", []string{"Sys.vm", "Class1.vm", "Class2.vm"}, true, nil)
+	})
+}
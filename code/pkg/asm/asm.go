@@ -1,5 +1,7 @@
 package asm
 
+import "its-hmny.dev/nand2tetris/pkg/utils"
+
 // ----------------------------------------------------------------------------
 // General information
 
@@ -8,6 +10,7 @@ package asm
 // We declare a shared 'Instruction' interface for both A and C instructions as well as defining
 // custom labels for specific code section (allowing arbitrary jumps) at runtime during code execution.
 // This in turns enables iterations and conditionals both here and at the upper levels (VM, Compiler).
+// Every instruction keeps track of its 'Position' in the source file, to be used when reporting errors.
 
 // A Asm program is just a linear and contiguous sequence of instructions.
 type Program []Instruction
@@ -25,7 +28,8 @@ type Instruction interface{}
 // During the lowering phases this label will be mapped to their location in the program
 // and a symbol table will be generated from it, the latter will be used in the codegen phase.
 type LabelDecl struct {
-	Name string // The symbol/ident chosen by the user for the label
+	Name     string         // The symbol/ident chosen by the user for the label
+	Position utils.Position // The location of the declaration in the source code
 }
 
 // ----------------------------------------------------------------------------
@@ -38,10 +42,32 @@ type LabelDecl struct {
 // includes both the RAM and the memory mapped I/O). The location can be referenced
 // either by an alias (labels) or by specifying the raw location.
 // During the lowering phase each label will be assigned its type (Raw | BuiltIn | Label).
+// The location can also be a constant expression (e.g. '@SCREEN+32'), in that case it's stored
+// in 'Expr' and left unresolved until the codegen phase, when all the labels are known.
 type AInstruction struct {
-	Location string // A generic "payload" (the label/builtin/raw symbol)
+	Location string         // A generic "payload" (the label/builtin/raw symbol)
+	Expr     Expression     // The constant expression (if any), 'Location' is empty when provided
+	Position utils.Position // The location of the instruction in the source code
+}
+
+// ----------------------------------------------------------------------------
+// Constant Expressions
+
+// In memory representation of a constant expression for the Assembler language.
+//
+// Each operand is either a 'string' (the same payload of an A Instruction location) or another
+// 'BinaryExpr', the parenthesized sub-expressions do not need a node since the tree retains them.
+type Expression interface{}
+
+// A binary operation between two sub-expressions, see 'Precedence' for the operators available.
+type BinaryExpr struct {
+	Operator string     // The operator applied to the operands
+	Lhs, Rhs Expression // The left and right hand side operands
 }
 
+// The precedence of each operator (higher binds tighter), all of them are left associative.
+var Precedence = map[string]int{"|": 1, "&": 2, "+": 3, "-": 3, "*": 4, "/": 4}
+
 // ----------------------------------------------------------------------------
 // C Instructions
 
@@ -54,4 +80,56 @@ type CInstruction struct {
 	Comp string // The 'computation' bit-codes, defines the calculation that the CPU should perform
 	Dest string // The 'destination' bit-codes, defines if/where the result should be saved
 	Jump string // The 'jump' bit-codes, define on what premise the jump to another instruction should occur
+
+	Position utils.Position // The location of the instruction in the source code
+}
+
+// ----------------------------------------------------------------------------
+// Macro Declarations
+
+// In memory representation of a macro declaration for the Assembler language.
+//
+// A macro is a named and parametrized sequence of instructions, declared between the '.macro'
+// and '.endm' directives, that is copy-pasted at each invocation by the 'Expander'. Inside the
+// body the parameters can be used in place of any A Instruction location (or macro argument),
+// while the labels declared in the body are local to each expansion to avoid collisions.
+type MacroDecl struct {
+	Name   string        // The name used to invoke the macro
+	Params []string      // The names of the parameters, in order
+	Body   []Instruction // The instructions expanded at each invocation
+
+	Position utils.Position // The location of the declaration in the source code
+}
+
+// In memory representation of a macro invocation for the Assembler language.
+//
+// Each argument is a label/builtin/raw symbol (the same payload of the A Instruction) that is
+// bound to the respective parameter of the macro, the number of arguments should match them.
+type MacroCall struct {
+	Name string   // The name of the macro to be expanded
+	Args []string // The arguments provided, in order
+
+	Position utils.Position // The location of the invocation in the source code
+}
+
+// ----------------------------------------------------------------------------
+// Directives
+
+// In memory representation of the '.include' directive for the Assembler language.
+//
+// The instructions (and declarations) of the included file are placed in the including one as is,
+// a relative path is resolved starting from the directory of the including file.
+type Include struct {
+	Path     string         // The path of the included file, as written by the user
+	Position utils.Position // The location of the directive in the source code
+}
+
+// In memory representation of the '.define' directive for the Assembler language.
+//
+// A named constant is just an alias for a value (either a number or another symbol), each A
+// Instruction (or macro argument) referencing the former is replaced with the latter.
+type Define struct {
+	Name     string         // The name of the constant
+	Value    string         // The value aliased, can be a raw location or another symbol
+	Position utils.Position // The location of the directive in the source code
 }
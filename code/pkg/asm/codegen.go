@@ -55,6 +55,9 @@ func (cg *CodeGenerator) Generate() ([]string, error) {
 
 // Specialized function to convert an A Instruction to the Asm format.
 func (CodeGenerator) GenerateAInst(inst AInstruction) (string, error) {
+	if inst.Expr != nil { // Constant expressions are evaluated only when producing the binary
+		return fmt.Sprintf("@%s", FormatExpression(inst.Expr)), nil
+	}
 	// Pre-check on the label/built-in/raw label (is required not to be empty)
 	if inst.Location == "" {
 		return "", fmt.Errorf("unable to produce empty label declaration")
@@ -70,23 +73,29 @@ func (CodeGenerator) GenerateAInst(inst AInstruction) (string, error) {
 
 // Specialized function to convert a C Instruction to the Asm format.
 func (CodeGenerator) GenerateCInst(inst CInstruction) (string, error) {
-	// Pre-check on the 'comp' (required), 'dest' and 'jump' (either one or the other)
-	if _, found := hack.CompTable[inst.Comp]; inst.Comp == "" || !found {
+	// Pre-check on the 'comp' (required), 'dest' and 'jump' (at least one of them)
+	if _, found := hack.CanonicalComp(inst.Comp); inst.Comp == "" || !found {
 		return "", fmt.Errorf("expected valid 'comp' directive in CInst, got: '%s'", inst.Comp)
 	}
-	if inst.Jump != "" && inst.Dest != "" {
+	if inst.Jump == "" && inst.Dest == "" {
 		return "", fmt.Errorf("expected either 'dest' or 'jump' directive in CInst")
 	}
+	if _, found := hack.CanonicalDest(inst.Dest); !found {
+		return "", fmt.Errorf("expected valid 'dest' directive in CInst, got: '%s'", inst.Dest)
+	}
+	if _, found := hack.JumpTable[inst.Jump]; !found {
+		return "", fmt.Errorf("expected valid 'jump' directive in CInst, got: '%s'", inst.Jump)
+	}
 
-	// The instruction has either a valid 'jump' or valid 'dest' directive
-	if _, found := hack.DestTable[inst.Dest]; inst.Dest != "" && found {
+	// The instruction has a valid 'dest' directive, a valid 'jump' directive or both of them
+	switch {
+	case inst.Dest != "" && inst.Jump != "":
+		return fmt.Sprintf("%s=%s;%s", inst.Dest, inst.Comp, inst.Jump), nil
+	case inst.Dest != "":
 		return fmt.Sprintf("%s=%s", inst.Dest, inst.Comp), nil
-	}
-	if _, found := hack.JumpTable[inst.Jump]; inst.Jump != "" && found {
+	default:
 		return fmt.Sprintf("%s;%s", inst.Comp, inst.Jump), nil
 	}
-
-	return "", fmt.Errorf("neither 'dest' or 'jump' directives are valid in C Inst")
 }
 
 // Specialized function to convert an Label Declaration to the Asm format.
@@ -99,3 +108,22 @@ func (CodeGenerator) GenerateLabelDecl(inst LabelDecl) (string, error) {
 	}
 	return fmt.Sprintf("(%s)", inst.Name), nil
 }
+
+// Returns the textual representation of a constant expression, the parentheses are added only
+// where needed to preserve the structure of the tree (e.g. '(SCREEN+32)*2' but 'SCREEN+32*2').
+func FormatExpression(expr Expression) string {
+	operation, isOperation := expr.(BinaryExpr)
+	if !isOperation {
+		return fmt.Sprint(expr)
+	}
+
+	lhs, rhs := FormatExpression(operation.Lhs), FormatExpression(operation.Rhs)
+	if inner, isOperation := operation.Lhs.(BinaryExpr); isOperation && Precedence[inner.Operator] < Precedence[operation.Operator] {
+		lhs = fmt.Sprintf("(%s)", lhs)
+	}
+	// Being left associative, the right hand side needs them also with the same precedence (e.g. 'A-(B-C)')
+	if inner, isOperation := operation.Rhs.(BinaryExpr); isOperation && Precedence[inner.Operator] <= Precedence[operation.Operator] {
+		rhs = fmt.Sprintf("(%s)", rhs)
+	}
+	return fmt.Sprintf("%s%s%s", lhs, operation.Operator, rhs)
+}
@@ -128,6 +128,17 @@ func TestCInstructions(t *testing.T) {
 
 	})
 
+	t.Run("Full ISA", func(t *testing.T) {
+		// Both 'dest' and 'jump' directives, any permutation of the 'dest' and commutative 'comp'
+		test(asm.CInstruction{Comp: "M", Dest: "D", Jump: "JGT"}, "D=M;JGT", false)
+		test(asm.CInstruction{Comp: "D+1", Dest: "DMA", Jump: "JMP"}, "DMA=D+1;JMP", false)
+		test(asm.CInstruction{Comp: "A+D", Dest: "DM"}, "DM=A+D", false)
+		test(asm.CInstruction{Comp: "M|D", Jump: "JNE"}, "M|D;JNE", false)
+		// Registers listed more than once in 'dest' and unknown 'jump' directives
+		test(asm.CInstruction{Comp: "0", Dest: "MM"}, "", true)
+		test(asm.CInstruction{Comp: "0", Dest: "D", Jump: "JJJ"}, "", true)
+	})
+
 	t.Run("Malformed Inst", func(t *testing.T) {
 		// Comp only C Instruction, should fail and return an error
 		test(asm.CInstruction{Comp: "D+1", Jump: ""}, "", true)
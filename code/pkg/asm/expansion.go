@@ -0,0 +1,224 @@
+package asm
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// ----------------------------------------------------------------------------
+// Asm Expander
+
+// The Expander takes an 'asm.Program' with directives and macros and produces a plain one.
+//
+// It's the macro assembler layer and runs before the 'Lowerer', that only knows about A Instructions,
+// C Instructions and Label declarations. The expansion is done in two passes over the program:
+// - The included files are parsed and inlined, while the macros and the constants are collected
+// - The macro invocations are replaced by their body and the constants by their value
+//
+// Since the declarations are collected beforehand, both macros and constants can be used before
+// (or in a different file than) their declaration. Each expansion of a macro gets a fresh copy of
+// the labels declared in its body, named '<MACRO>$<LABEL>.<N>' to avoid any collision.
+type Expander struct {
+	program Program
+
+	macros    map[string]MacroDecl // The macros declared in the program (and in the included files)
+	defines   map[string]Define    // The constants declared in the program (and in the included files)
+	expansion int                  // The counter of the expansions done, used to name the local labels
+}
+
+// The max depth of nested macro invocations, a safeguard against the (indirectly) recursive ones.
+const MaxExpansionDepth = 64
+
+// Initializes and returns to the caller a brand new 'Expander' struct.
+// Requires the argument Program to be not nil nor empty.
+func NewExpander(p Program) Expander {
+	return Expander{program: p}
+}
+
+// Triggers the expansion process. At first the includes are resolved and the declarations are
+// collected, then each macro invocation and constant is replaced until only plain instructions remain.
+func (e *Expander) Expand() (Program, error) {
+	e.macros, e.defines, e.expansion = map[string]MacroDecl{}, map[string]Define{}, 0
+
+	if len(e.program) == 0 {
+		return nil, fmt.Errorf("the given 'program' is empty")
+	}
+
+	collected, err := e.Collect(e.program, []string{})
+	if err != nil {
+		return nil, err
+	}
+
+	expanded := Program{}
+	for _, inst := range collected {
+		instructions, err := e.HandleInstruction(inst, map[string]string{}, []string{})
+		if err != nil {
+			return nil, err
+		}
+		expanded = append(expanded, instructions...)
+	}
+
+	return expanded, nil
+}
+
+// Resolves the 'asm.Include' directives and collects the declarations found in 'program', returns
+// the remaining instructions. 'includes' is the chain of files being included, to detect cycles.
+func (e *Expander) Collect(program Program, includes []string) (Program, error) {
+	collected := Program{}
+
+	for _, inst := range program {
+		switch tInst := inst.(type) {
+		case MacroDecl:
+			if other, found := e.macros[tInst.Name]; found {
+				return nil, tInst.Position.Errorf("macro '%s' already declared at %s", tInst.Name, other.Position)
+			}
+			e.macros[tInst.Name] = tInst
+
+		case Define:
+			if other, found := e.defines[tInst.Name]; found {
+				return nil, tInst.Position.Errorf("constant '%s' already declared at %s", tInst.Name, other.Position)
+			}
+			e.defines[tInst.Name] = tInst
+
+		case Include:
+			included, err := e.HandleInclude(tInst, includes)
+			if err != nil {
+				return nil, err
+			}
+			collected = append(collected, included...)
+
+		default:
+			collected = append(collected, inst)
+		}
+	}
+
+	return collected, nil
+}
+
+// Specialized function to parse the file referenced by an 'asm.Include' and collect its content.
+func (e *Expander) HandleInclude(inst Include, includes []string) (Program, error) {
+	path := inst.Path
+	if !filepath.IsAbs(path) { // Relative paths start from the directory of the including file
+		path = filepath.Join(filepath.Dir(inst.Position.File), path)
+	}
+
+	if absolute, err := filepath.Abs(path); err == nil {
+		path = absolute
+	}
+	if slices.Contains(includes, path) {
+		return nil, inst.Position.Errorf("circular inclusion of '%s'", inst.Path)
+	}
+
+	content, err := os.Open(path)
+	if err != nil {
+		return nil, inst.Position.Errorf("unable to include '%s': %s", inst.Path, err)
+	}
+	defer content.Close()
+
+	parser := NewFileParser(content, path)
+	program, err := parser.Parse()
+	if err != nil {
+		return nil, err
+	}
+
+	return e.Collect(program, append(includes, path))
+}
+
+// Expands a single instruction, the 'bindings' map the parameters of the macro being expanded
+// (if any) to their arguments and its local labels to their unique name, 'stack' is the chain of
+// macro invocations that lead here. Constants are replaced only once the bindings are applied.
+func (e *Expander) HandleInstruction(inst Instruction, bindings map[string]string, stack []string) (Program, error) {
+	switch tInst := inst.(type) {
+	case AInstruction:
+		if tInst.Expr != nil {
+			tInst.Expr = e.resolveExpr(tInst.Expr, bindings)
+			return Program{tInst}, nil
+		}
+		tInst.Location = e.resolve(tInst.Location, bindings)
+		return Program{tInst}, nil
+
+	case LabelDecl:
+		if local, found := bindings[tInst.Name]; found {
+			tInst.Name = local
+		}
+		return Program{tInst}, nil
+
+	case CInstruction:
+		return Program{tInst}, nil
+
+	case MacroCall:
+		return e.HandleMacroCall(tInst, bindings, stack)
+
+	default: // Error case, unrecognized instruction type
+		return nil, fmt.Errorf("unrecognized instruction '%T'", inst)
+	}
+}
+
+// Specialized function to replace an 'asm.MacroCall' with the (expanded) body of the macro.
+func (e *Expander) HandleMacroCall(call MacroCall, bindings map[string]string, stack []string) (Program, error) {
+	macro, found := e.macros[call.Name]
+	if !found {
+		return nil, call.Position.Errorf("undeclared macro '%s'", call.Name)
+	}
+	if len(call.Args) != len(macro.Params) {
+		return nil, call.Position.Errorf("macro '%s' expects %d argument(s), got %d", call.Name, len(macro.Params), len(call.Args))
+	}
+	if len(stack) >= MaxExpansionDepth || slices.Contains(stack, call.Name) {
+		return nil, call.Position.Errorf("recursive invocation of macro '%s'", call.Name)
+	}
+
+	// The labels declared in the body are renamed first, so that a parameter can shadow them
+	e.expansion++
+	scope := map[string]string{}
+	for _, inst := range macro.Body {
+		if label, isLabel := inst.(LabelDecl); isLabel {
+			scope[label.Name] = fmt.Sprintf("%s$%s.%d", macro.Name, label.Name, e.expansion)
+		}
+	}
+	// The arguments are resolved in the scope of the caller (e.g. a parameter passed along)
+	for i, param := range macro.Params {
+		scope[param] = e.resolve(call.Args[i], bindings)
+	}
+
+	expanded := Program{}
+	for _, inst := range macro.Body {
+		instructions, err := e.HandleInstruction(inst, scope, append(stack, call.Name))
+		if err != nil {
+			return nil, err
+		}
+		expanded = append(expanded, instructions...)
+	}
+
+	return expanded, nil
+}
+
+// Resolves 'symbol' to the argument or local label bound to it and then to the value of the
+// constant with the same name (if any), a constant can be defined in terms of another one.
+func (e *Expander) resolve(symbol string, bindings map[string]string) string {
+	if bound, found := bindings[symbol]; found {
+		symbol = bound
+	}
+
+	for seen := []string{}; !slices.Contains(seen, symbol); {
+		define, found := e.defines[symbol]
+		if !found {
+			break
+		}
+		seen, symbol = append(seen, symbol), define.Value
+	}
+	return symbol
+}
+
+// Same as 'resolve' but applied to each operand of the constant expression 'expr'.
+func (e *Expander) resolveExpr(expr Expression, bindings map[string]string) Expression {
+	switch tExpr := expr.(type) {
+	case string:
+		return e.resolve(tExpr, bindings)
+	case BinaryExpr:
+		return BinaryExpr{Operator: tExpr.Operator, Lhs: e.resolveExpr(tExpr.Lhs, bindings), Rhs: e.resolveExpr(tExpr.Rhs, bindings)}
+	default:
+		return expr
+	}
+}
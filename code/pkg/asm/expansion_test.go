@@ -0,0 +1,146 @@
+package asm_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"its-hmny.dev/nand2tetris/pkg/asm"
+)
+
+func TestExpander(t *testing.T) {
+	// Parses and expands 'source' (as if it was the file 'Main.asm' in 'dir'), returns the program
+	expand := func(dir string, source string) (asm.Program, error) {
+		parser := asm.NewFileParser(strings.NewReader(source), filepath.Join(dir, "Main.asm"))
+		program, err := parser.Parse()
+		if err != nil {
+			t.Fatalf("Unexpected parsing error: %v", err)
+		}
+		expander := asm.NewExpander(program)
+		return expander.Expand()
+	}
+
+	// Compares the expanded program against the expected one, ignoring the positions
+	test := func(dir string, source string, expected asm.Program) {
+		expanded, err := expand(dir, source)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if len(expanded) != len(expected) {
+			t.Fatalf("Expected %d instructions, got %d: %+v", len(expected), len(expanded), expanded)
+		}
+		for i, inst := range expanded {
+			switch tInst := inst.(type) {
+			case asm.AInstruction:
+				tInst.Position = expected[i].(asm.AInstruction).Position
+				inst = tInst
+			case asm.CInstruction:
+				tInst.Position = expected[i].(asm.CInstruction).Position
+				inst = tInst
+			case asm.LabelDecl:
+				tInst.Position = expected[i].(asm.LabelDecl).Position
+				inst = tInst
+			}
+			if inst != expected[i] {
+				t.Fatalf("Expected %+v at index %d, got %+v", expected[i], i, inst)
+			}
+		}
+	}
+
+	t.Run("Macros with parameters", func(t *testing.T) {
+		test("", `
+			.macro PUSH_D
+				@SP
+				AM=M+1
+				A=A-1
+				M=D
+			.endm
+			.macro PUSH_CONST value
+				@value
+				D=A
+				PUSH_D // Nested invocation
+			.endm
+			PUSH_CONST 17
+		`, asm.Program{
+			asm.AInstruction{Location: "17"},
+			asm.CInstruction{Dest: "D", Comp: "A"},
+			asm.AInstruction{Location: "SP"},
+			asm.CInstruction{Dest: "AM", Comp: "M+1"},
+			asm.CInstruction{Dest: "A", Comp: "A-1"},
+			asm.CInstruction{Dest: "M", Comp: "D"},
+		})
+	})
+
+	t.Run("Local labels", func(t *testing.T) {
+		// Each expansion gets its own copy of 'LOOP', while 'END' is not declared by the macro
+		test("", `
+			.macro WAIT register
+				(LOOP)
+				@register
+				D=M
+				@LOOP
+				D;JEQ
+				@END
+				0;JMP
+			.endm
+			WAIT KBD
+			WAIT R0
+			(END)
+		`, asm.Program{
+			asm.LabelDecl{Name: "WAIT$LOOP.1"},
+			asm.AInstruction{Location: "KBD"},
+			asm.CInstruction{Dest: "D", Comp: "M"},
+			asm.AInstruction{Location: "WAIT$LOOP.1"},
+			asm.CInstruction{Comp: "D", Jump: "JEQ"},
+			asm.AInstruction{Location: "END"},
+			asm.CInstruction{Comp: "0", Jump: "JMP"},
+			asm.LabelDecl{Name: "WAIT$LOOP.2"},
+			asm.AInstruction{Location: "R0"},
+			asm.CInstruction{Dest: "D", Comp: "M"},
+			asm.AInstruction{Location: "WAIT$LOOP.2"},
+			asm.CInstruction{Comp: "D", Jump: "JEQ"},
+			asm.AInstruction{Location: "END"},
+			asm.CInstruction{Comp: "0", Jump: "JMP"},
+			asm.LabelDecl{Name: "END"},
+		})
+	})
+
+	t.Run("Constants and includes", func(t *testing.T) {
+		dir := t.TempDir()
+		os.MkdirAll(filepath.Join(dir, "lib"), 0755)
+		os.WriteFile(filepath.Join(dir, "lib", "Screen.asm"), []byte(".define WHITE 0\n.define COLOR BLACK\n"), 0644)
+		os.WriteFile(filepath.Join(dir, "Macros.asm"), []byte(".include \"lib/Screen.asm\"\n.macro FILL color\n@color\nD=A\n.endm\n"), 0644)
+
+		// Both constants and macros can be used before their declaration, also through other constants
+		test(dir, `
+			FILL COLOR
+			.include "Macros.asm"
+			@WHITE
+			.define BLACK 1
+		`, asm.Program{
+			asm.AInstruction{Location: "1"},
+			asm.CInstruction{Dest: "D", Comp: "A"},
+			asm.AInstruction{Location: "0"},
+		})
+	})
+
+	t.Run("Invalid programs", func(t *testing.T) {
+		dir := t.TempDir()
+		os.WriteFile(filepath.Join(dir, "Loop.asm"), []byte(".include \"Loop.asm\"\n"), 0644)
+
+		for _, source := range []string{
+			"UNKNOWN 1, 2",                                 // Undeclared macro
+			".macro M2 a, b\n@a\n.endm\nM2 1",              // Wrong number of arguments
+			".macro SELF\nSELF\n.endm\nSELF",               // Recursive invocation
+			".macro TWICE\n@0\n.endm\n.macro TWICE\n.endm", // Duplicate macro
+			".define N 1\n.define N 2\n@N",                 // Duplicate constant
+			".include \"Missing.asm\"",                     // Missing included file
+			".include \"Loop.asm\"",                        // Circular inclusion
+		} {
+			if _, err := expand(dir, source); err == nil {
+				t.Fatalf("Expected error for '%s', got nil", source)
+			}
+		}
+	})
+}
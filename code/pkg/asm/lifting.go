@@ -0,0 +1,141 @@
+package asm
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"its-hmny.dev/nand2tetris/pkg/hack"
+)
+
+// ----------------------------------------------------------------------------
+// Asm Lifter
+
+// The Lifter takes a 'hack.Program' and produces its 'asm.Program' counterpart.
+//
+// This is the opposite of what the 'Lowerer' does and it's used to disassemble a binary program
+// back to a readable form. The binary format does not retain any symbol so they're recovered with
+// a couple of heuristics, while still guaranteeing that the program assembles back to the same binary:
+// - The address loaded right before a jump is a location in ROM, a label is declared there
+// - The address loaded right before a memory access ('M') is named after its built-in symbol (if any)
+// - The memory maps ('SCREEN' and 'KBD') are named also when the address is used as a value (e.g.
+// 'D=A' to compute a pointer), the registers instead are not since the same values (0 to 15) are
+// way more likely to be plain constants than addresses (e.g. '@5' followed by 'D=A')
+type Lifter struct{ program hack.Program }
+
+// The name used for each of the well-known addresses of 'hack.BuiltInTable', when more than one
+// symbol share the same address the VM specific one is preferred (e.g. 'SP' instead of 'R0').
+var BuiltInNames = map[uint16]string{
+	0: "SP", 1: "LCL", 2: "ARG", 3: "THIS", 4: "THAT",
+	5: "R5", 6: "R6", 7: "R7", 8: "R8", 9: "R9", 10: "R10",
+	11: "R11", 12: "R12", 13: "R13", 14: "R14", 15: "R15",
+	16384: "SCREEN", 24576: "KBD",
+}
+
+// Initializes and returns to the caller a brand new 'Lifter' struct.
+// Requires the argument Program to be not nil nor empty.
+func NewLifter(p hack.Program) Lifter {
+	return Lifter{program: p}
+}
+
+// Triggers the lifting process. At first the jump targets are collected, then each instruction
+// is converted to its 'asm.Instruction' counterpart while declaring the labels where needed.
+func (l *Lifter) Lift() (Program, error) {
+	if len(l.program) == 0 {
+		return nil, fmt.Errorf("the given 'program' is empty")
+	}
+
+	// Each address is resolved only once so that the A instructions loading it are renamed consistently
+	addresses := make([]uint16, len(l.program))
+	for i, inst := range l.program {
+		if aInst, isAInst := inst.(hack.AInstruction); isAInst && aInst.LocType == hack.Raw {
+			address, err := strconv.ParseUint(aInst.LocName, 10, 16)
+			if err != nil || uint16(address) >= hack.MaxAddressableMemory {
+				return nil, fmt.Errorf("invalid raw address '%s' at instruction %d", aInst.LocName, i)
+			}
+			addresses[i] = uint16(address)
+		}
+	}
+
+	// The targets can go one past the end of the program (e.g. a label declared after the last instruction)
+	targets := map[uint16]bool{}
+	for i := range l.program {
+		if l.isJumpTarget(i) && int(addresses[i]) <= len(l.program) {
+			targets[addresses[i]] = true
+		}
+	}
+
+	lifted := Program{}
+	for i, inst := range l.program {
+		if targets[uint16(i)] {
+			lifted = append(lifted, LabelDecl{Name: LabelName(uint16(i))})
+		}
+
+		switch tInst := inst.(type) {
+		case hack.AInstruction:
+			lifted = append(lifted, l.HandleAInst(tInst, i, targets[addresses[i]]))
+		case hack.CInstruction:
+			lifted = append(lifted, CInstruction{Comp: tInst.Comp, Dest: tInst.Dest, Jump: tInst.Jump})
+		default: // Error case, unrecognized instruction type
+			return nil, fmt.Errorf("unrecognized instruction '%T'", inst)
+		}
+	}
+	if targets[uint16(len(l.program))] {
+		lifted = append(lifted, LabelDecl{Name: LabelName(uint16(len(l.program)))})
+	}
+
+	return lifted, nil
+}
+
+// Specialized function to convert the 'hack.AInstruction' at index 'i' to an 'asm.AInstruction',
+// 'target' tells whether the address loaded is one of the jump targets found in the program.
+func (l *Lifter) HandleAInst(inst hack.AInstruction, i int, target bool) AInstruction {
+	if inst.LocType != hack.Raw { // Symbols are already there, nothing to recover
+		return AInstruction{Location: inst.LocName}
+	}
+
+	address, _ := strconv.ParseUint(inst.LocName, 10, 16)
+	if target && l.isJumpTarget(i) {
+		return AInstruction{Location: LabelName(uint16(address))}
+	}
+	name, found := BuiltInNames[uint16(address)]
+	if found && (l.isMemoryAccess(i) || (isMemoryMap(uint16(address)) && l.isValueUse(i))) {
+		return AInstruction{Location: name}
+	}
+	return AInstruction{Location: inst.LocName}
+}
+
+// Returns whether the instruction at index 'i' loads the address for the jump that follows it.
+func (l *Lifter) isJumpTarget(i int) bool {
+	if _, isAInst := l.program[i].(hack.AInstruction); !isAInst || i+1 >= len(l.program) {
+		return false
+	}
+	next, isCInst := l.program[i+1].(hack.CInstruction)
+	return isCInst && next.Jump != ""
+}
+
+// Returns whether the instruction at index 'i' loads the address of the memory accessed right after.
+func (l *Lifter) isMemoryAccess(i int) bool {
+	if i+1 >= len(l.program) {
+		return false
+	}
+	next, isCInst := l.program[i+1].(hack.CInstruction)
+	return isCInst && (strings.Contains(next.Comp, "M") || strings.Contains(next.Dest, "M"))
+}
+
+// Returns whether the address loaded by the instruction at index 'i' is used as a value right after.
+func (l *Lifter) isValueUse(i int) bool {
+	if i+1 >= len(l.program) {
+		return false
+	}
+	next, isCInst := l.program[i+1].(hack.CInstruction)
+	return isCInst && next.Jump == "" && strings.Contains(next.Comp, "A")
+}
+
+// Returns whether 'address' is the base of one of the memory maps (either 'SCREEN' or 'KBD').
+func isMemoryMap(address uint16) bool {
+	return address == hack.BuiltInTable["SCREEN"] || address == hack.BuiltInTable["KBD"]
+}
+
+// Returns the name of the label synthesized for the location 'address' in ROM.
+func LabelName(address uint16) string { return fmt.Sprintf("LABEL_%d", address) }
@@ -0,0 +1,68 @@
+package asm_test
+
+import (
+	"testing"
+
+	"its-hmny.dev/nand2tetris/pkg/asm"
+	"its-hmny.dev/nand2tetris/pkg/hack"
+)
+
+func TestLifter(t *testing.T) {
+	raw := func(address string) hack.AInstruction { return hack.AInstruction{LocType: hack.Raw, LocName: address} }
+
+	test := func(program hack.Program, expected asm.Program) {
+		lifter := asm.NewLifter(program)
+		lifted, err := lifter.Lift()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if len(lifted) != len(expected) {
+			t.Fatalf("Expected %d instructions, got %d: %+v", len(expected), len(lifted), lifted)
+		}
+		for i := range expected {
+			if lifted[i] != expected[i] {
+				t.Fatalf("Expected %+v at index %d, got %+v", expected[i], i, lifted[i])
+			}
+		}
+	}
+
+	t.Run("Jump targets", func(t *testing.T) {
+		// A label is declared for each jump target, also past the end of the program
+		test(hack.Program{raw("2"), hack.CInstruction{Comp: "D", Jump: "JGT"}, raw("4"), hack.CInstruction{Comp: "0", Jump: "JMP"}},
+			asm.Program{
+				asm.AInstruction{Location: "LABEL_2"}, asm.CInstruction{Comp: "D", Jump: "JGT"},
+				asm.LabelDecl{Name: "LABEL_2"}, asm.AInstruction{Location: "LABEL_4"}, asm.CInstruction{Comp: "0", Jump: "JMP"},
+				asm.LabelDecl{Name: "LABEL_4"},
+			})
+		// The same address used as a constant is left untouched
+		test(hack.Program{raw("0"), hack.CInstruction{Comp: "A", Dest: "D"}, raw("0"), hack.CInstruction{Comp: "0", Jump: "JMP"}},
+			asm.Program{
+				asm.LabelDecl{Name: "LABEL_0"}, asm.AInstruction{Location: "0"}, asm.CInstruction{Comp: "A", Dest: "D"},
+				asm.AInstruction{Location: "LABEL_0"}, asm.CInstruction{Comp: "0", Jump: "JMP"},
+			})
+	})
+
+	t.Run("Built-in symbols", func(t *testing.T) {
+		// The addresses accessed through 'M' are named after their built-in symbol
+		test(hack.Program{raw("0"), hack.CInstruction{Comp: "M", Dest: "A"}, raw("24576"), hack.CInstruction{Comp: "D", Dest: "M"}, raw("16384"), hack.CInstruction{Comp: "A", Dest: "D"}},
+			asm.Program{
+				asm.AInstruction{Location: "SP"}, asm.CInstruction{Comp: "M", Dest: "A"},
+				asm.AInstruction{Location: "KBD"}, asm.CInstruction{Comp: "D", Dest: "M"},
+				asm.AInstruction{Location: "SCREEN"}, asm.CInstruction{Comp: "A", Dest: "D"},
+			})
+		// The memory maps are named also when used as a value, the registers are left as constants
+		test(hack.Program{raw("16384"), hack.CInstruction{Comp: "D+A", Dest: "D"}, raw("5"), hack.CInstruction{Comp: "A", Dest: "D"}, raw("24576"), hack.CInstruction{Comp: "A-1", Dest: "D"}},
+			asm.Program{
+				asm.AInstruction{Location: "SCREEN"}, asm.CInstruction{Comp: "D+A", Dest: "D"},
+				asm.AInstruction{Location: "5"}, asm.CInstruction{Comp: "A", Dest: "D"},
+				asm.AInstruction{Location: "KBD"}, asm.CInstruction{Comp: "A-1", Dest: "D"},
+			})
+	})
+
+	t.Run("Empty program", func(t *testing.T) {
+		lifter := asm.NewLifter(hack.Program{})
+		if _, err := lifter.Lift(); err == nil {
+			t.Fatalf("Expected error on empty program, got nil")
+		}
+	})
+}
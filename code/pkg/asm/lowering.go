@@ -65,34 +65,78 @@ func (l *Lowerer) Lower() (hack.Program, hack.SymbolTable, error) {
 }
 
 // Specialized function to convert a 'asm.AInstruction' node to an 'hack.AInstruction'.
-func (Lowerer) HandleAInst(inst AInstruction) (hack.Instruction, error) {
+func (l Lowerer) HandleAInst(inst AInstruction) (hack.Instruction, error) {
+	// A constant expression is lowered operand by operand, then evaluated during the codegen phase
+	if inst.Expr != nil {
+		expr, err := l.HandleExpression(inst.Expr)
+		if err != nil {
+			return nil, inst.Position.Wrap(err)
+		}
+		return hack.AInstruction{LocType: hack.Compute, LocName: FormatExpression(inst.Expr), Expr: expr, Position: inst.Position}, nil
+	}
+
 	// Based on one of the following cases below (the type of the symbol) we do different things:
 	// 1) If it's present in the BuiltInTable is we set the 'LocType'to 'BuiltIn' accordingly
 	if _, found := hack.BuiltInTable[inst.Location]; found {
-		return hack.AInstruction{LocType: hack.BuiltIn, LocName: inst.Location}, nil
+		return hack.AInstruction{LocType: hack.BuiltIn, LocName: inst.Location, Position: inst.Position}, nil
 	}
 	// 2) If it can be parsed as an int we set the 'LocType' to 'Raw' accordingly
 	if _, err := strconv.ParseInt(inst.Location, 10, 16); err == nil {
-		return hack.AInstruction{LocType: hack.Raw, LocName: inst.Location}, nil
+		return hack.AInstruction{LocType: hack.Raw, LocName: inst.Location, Position: inst.Position}, nil
 	}
 	// 3) Else it's a user defined label and we set 'LocType' to 'Label' accordingly
-	return hack.AInstruction{LocType: hack.Label, LocName: inst.Location}, nil
+	return hack.AInstruction{LocType: hack.Label, LocName: inst.Location, Position: inst.Position}, nil
+}
+
+// Specialized function to convert an 'asm.Expression' to its 'hack.Expression' counterpart,
+// each operand is lowered as the location of a standalone A Instruction would be.
+func (l Lowerer) HandleExpression(expr Expression) (hack.Expression, error) {
+	switch tExpr := expr.(type) {
+	case string:
+		return l.HandleAInst(AInstruction{Location: tExpr})
+	case BinaryExpr:
+		if _, found := Precedence[tExpr.Operator]; !found {
+			return nil, fmt.Errorf("unknown operator '%s' in expression", tExpr.Operator)
+		}
+		lhs, err := l.HandleExpression(tExpr.Lhs)
+		if err != nil {
+			return nil, err
+		}
+		rhs, err := l.HandleExpression(tExpr.Rhs)
+		if err != nil {
+			return nil, err
+		}
+		return hack.BinaryExpr{Operator: tExpr.Operator, Lhs: lhs, Rhs: rhs}, nil
+	default: // Error case, unrecognized expression type
+		return nil, fmt.Errorf("unrecognized expression '%T'", expr)
+	}
 }
 
 // Specialized function to convert a 'asm.CInstruction' node to an 'hack.CInstruction'.
 func (Lowerer) HandleCInst(inst CInstruction) (hack.Instruction, error) {
 	if inst.Comp == "" { // Pre-check: CInstruction.Comp should always be provided
-		return nil, fmt.Errorf("'Comp' sub-instruction should always be provided")
+		return nil, inst.Position.Errorf("'Comp' sub-instruction should always be provided")
 	}
 
-	if inst.Dest != "" && inst.Jump == "" {
-		return hack.CInstruction{Dest: inst.Dest, Comp: inst.Comp}, nil
+	if inst.Dest == "" && inst.Jump == "" {
+		return nil, inst.Position.Errorf("expected either node 'Dest' or 'Jump' sub-instructions")
+	}
+
+	// Both the commutative aliases and the permutations of the destination are resolved here, so
+	// that the 'hack.CInstruction' always uses the mnemonics found in the translation tables.
+	comp, found := hack.CanonicalComp(inst.Comp)
+	if !found {
+		return nil, inst.Position.Errorf("unknown 'Comp' sub-instruction '%s'", inst.Comp)
+	}
+	dest, found := hack.CanonicalDest(inst.Dest)
+	if !found {
+		return nil, inst.Position.Errorf("unknown 'Dest' sub-instruction '%s'", inst.Dest)
 	}
-	if inst.Jump != "" && inst.Dest == "" {
-		return hack.CInstruction{Comp: inst.Comp, Jump: inst.Jump}, nil
+	if _, found := hack.JumpTable[inst.Jump]; !found {
+		return nil, inst.Position.Errorf("unknown 'Jump' sub-instruction '%s'", inst.Jump)
 	}
 
-	return nil, fmt.Errorf("expected either node 'Dest' or 'Jump' sub-instructions")
+	return hack.CInstruction{Dest: dest, Comp: comp, Jump: inst.Jump}, nil
 }
 
 // Specialized function to extract from a 'asm.LabelDecl' node to the identifier of the label.
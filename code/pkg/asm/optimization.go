@@ -0,0 +1,334 @@
+package asm
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"its-hmny.dev/nand2tetris/pkg/hack"
+)
+
+// ----------------------------------------------------------------------------
+// Asm Optimizer
+
+// The Optimizer takes an 'asm.Program' and produces a smaller (and faster) equivalent one.
+//
+// It's a peephole optimizer tailored on the code produced by the 'vm.Lowerer', that is really verbose
+// since every VM operation is translated on its own (e.g. pushing a value on the stack only to pop it
+// right after). The program is rewritten by the following passes, until none of them applies anymore:
+//   - 'ThreadJumps': A jump to a label that just jumps somewhere else goes straight to the final target
+//   - 'CollapsePushPop': A push on the stack immediately followed by a pop is replaced by nothing
+//   - 'EliminateRedundantLoads': Loads of a value already in the A or D register are removed
+//   - 'EliminateDeadStores': Stores on R13, R14 and R15 overwritten before being read are removed
+//
+// Like the 'vm.Lowerer' does, the optimizer assumes that the memory above the Stack Pointer is free
+// and that R13, R14 and R15 are reserved for internal usage, their content can differ from the one
+// of the original program while everything else in the RAM is left exactly the same.
+type Optimizer struct {
+	program Program
+
+	labels map[string]bool // The labels declared in the program, to tell them apart from variables
+}
+
+// The instructions that push the D register on the stack, shared by every push in the VM lowering.
+var pushTail = Program{
+	AInstruction{Location: "SP"},
+	CInstruction{Dest: "A", Comp: "M"},
+	CInstruction{Dest: "M", Comp: "D"},
+	AInstruction{Location: "SP"},
+	CInstruction{Dest: "M", Comp: "M+1"},
+}
+
+// The instructions that pop the top of the stack on the D register, shared by every pop in the VM lowering.
+var popHead = Program{
+	AInstruction{Location: "SP"},
+	CInstruction{Dest: "AM", Comp: "M-1"},
+	CInstruction{Dest: "D", Comp: "M"},
+}
+
+// Initializes and returns to the caller a brand new 'Optimizer' struct.
+// Requires the argument Program to be not nil nor empty.
+func NewOptimizer(p Program) Optimizer {
+	return Optimizer{program: p}
+}
+
+// Triggers the optimization process. Each pass is applied to the output of the previous one and
+// the whole pipeline is repeated until a fixed point is reached, since a pass can open up new
+// opportunities for the other ones (e.g. a dead store leaves behind a redundant A Instruction).
+func (o *Optimizer) Optimize() (Program, error) {
+	if len(o.program) == 0 {
+		return nil, fmt.Errorf("the given 'program' is empty")
+	}
+
+	o.labels = map[string]bool{}
+	for _, inst := range o.program {
+		switch tInst := inst.(type) {
+		case LabelDecl:
+			o.labels[tInst.Name] = true
+		case AInstruction, CInstruction:
+		default: // Error case, only plain instructions are expected (see 'asm.Expander')
+			return nil, fmt.Errorf("unrecognized instruction '%T'", inst)
+		}
+	}
+
+	program := slices.Clone(o.program)
+	for changed := true; changed; {
+		passes := []func(Program) (Program, bool){
+			o.ThreadJumps, o.CollapsePushPop, o.EliminateRedundantLoads, o.EliminateDeadStores,
+		}
+
+		changed = false
+		for _, pass := range passes {
+			optimized, applied := pass(program)
+			program, changed = optimized, changed || applied
+		}
+	}
+
+	return program, nil
+}
+
+// Redirects the jumps to a 'trampoline' (a label followed only by an unconditional jump to another
+// label) straight to the final destination. The A register holds the destination in both cases.
+func (o *Optimizer) ThreadJumps(program Program) (Program, bool) {
+	trampolines := map[string]string{}
+	for i, inst := range program {
+		label, isLabel := inst.(LabelDecl)
+		if !isLabel {
+			continue
+		}
+
+		next := i + 1 // Multiple labels can be declared at the same location
+		for next < len(program) && isLabelDecl(program[next]) {
+			next++
+		}
+		if next+1 < len(program) {
+			target, isAInst := program[next].(AInstruction)
+			if isAInst && target.Expr == nil && o.labels[target.Location] && isUnconditionalJump(program[next+1]) {
+				trampolines[label.Name] = target.Location
+			}
+		}
+	}
+
+	optimized, changed := slices.Clone(program), false
+	for i := 0; i+1 < len(program); i++ {
+		load, isAInst := program[i].(AInstruction)
+		jump, isCInst := program[i+1].(CInstruction)
+		if !isAInst || !isCInst || load.Expr != nil || jump.Jump == "" {
+			continue
+		}
+		// The jump must not make use of the A register, not even when it falls through
+		if jump.Dest != "" || strings.ContainsAny(jump.Comp, "AM") {
+			continue
+		}
+		if jump.Jump != "JMP" && i+2 < len(program) && !isAInstruction(program[i+2]) {
+			continue
+		}
+
+		target, seen := load.Location, map[string]bool{}
+		for next, found := trampolines[target]; found && !seen[target]; next, found = trampolines[target] {
+			seen[target], target = true, next
+		}
+		if target != load.Location {
+			load.Location, changed = target, true
+			optimized[i] = load
+		}
+	}
+
+	return optimized, changed
+}
+
+// Removes a push on the stack immediately followed by a pop, since the value popped is the same
+// one that was in the D register before the push. The value is left above the Stack Pointer anyway
+// so the only difference is that it's not written in memory, thus the next instruction has to be an
+// A Instruction as well (otherwise it could make use of the address left in the A register).
+func (o *Optimizer) CollapsePushPop(program Program) (Program, bool) {
+	optimized, changed := Program{}, false
+	pattern := append(slices.Clone(pushTail), popHead...)
+
+	for i := 0; i < len(program); i++ {
+		end := i + len(pattern)
+		if end < len(program) && matches(program[i:end], pattern) && isAInstruction(program[end]) {
+			i, changed = end-1, true
+			continue
+		}
+		optimized = append(optimized, program[i])
+	}
+
+	return optimized, changed
+}
+
+// Removes the instructions that load a value already in place, keeping track (within a basic block)
+// of the symbol pointed by the A register and of the symbol whose value is held by the D register:
+//   - An A Instruction followed by another one or pointing to the same symbol already in A
+//   - A 'D=M' or 'M=D' instruction when D already holds the value of the symbol pointed by A
+func (o *Optimizer) EliminateRedundantLoads(program Program) (Program, bool) {
+	optimized, changed := Program{}, false
+	a, d := "", "" // The symbol in the A register and the one whose value is in D ('' when unknown)
+
+	for i, inst := range program {
+		switch tInst := inst.(type) {
+		case LabelDecl: // Can be reached from anywhere, nothing is known about the registers
+			a, d = "", ""
+
+		case AInstruction:
+			if (i+1 < len(program) && isAInstruction(program[i+1])) || (tInst.Expr == nil && tInst.Location == a && a != "") {
+				changed = true
+				continue
+			}
+			a = tInst.Location
+			if tInst.Expr != nil {
+				a = ""
+			}
+
+		case CInstruction:
+			mirrored := tInst.Jump == "" && a != "" && d == a
+			if mirrored && ((tInst.Dest == "D" && tInst.Comp == "M") || (tInst.Dest == "M" && tInst.Comp == "D")) {
+				changed = true
+				continue
+			}
+			a, d = track(tInst, a, d)
+		}
+
+		optimized = append(optimized, inst)
+	}
+
+	return optimized, changed
+}
+
+// Removes the stores on R13, R14 and R15 that are overwritten before being read (in the same basic
+// block). The A Instruction left behind is removed afterwards by 'EliminateRedundantLoads'.
+func (o *Optimizer) EliminateDeadStores(program Program) (Program, bool) {
+	optimized, changed := Program{}, false
+	a := "" // The symbol in the A register ('' when unknown)
+
+	for i, inst := range program {
+		switch tInst := inst.(type) {
+		case LabelDecl:
+			a = ""
+
+		case AInstruction:
+			a = tInst.Location
+			if tInst.Expr != nil {
+				a = ""
+			}
+
+		case CInstruction:
+			address, isScratch := hack.BuiltInTable[a]
+			isScratch = isScratch && address >= 13 && address <= 15
+			if isScratch && tInst.Dest == "M" && tInst.Jump == "" && o.overwritten(program[i+1:], a, address) {
+				changed = true
+				continue
+			}
+			a, _ = track(tInst, a, "")
+		}
+
+		optimized = append(optimized, inst)
+	}
+
+	return optimized, changed
+}
+
+// Reports whether the memory at 'address' is written by 'program' before being read, 'a' is the
+// symbol in the A register at the beginning. The scan stops (conservatively) at the first label
+// or jump since the memory location could be read after the jump or before reaching the label.
+func (o *Optimizer) overwritten(program Program, a string, address uint16) bool {
+	for _, inst := range program {
+		switch tInst := inst.(type) {
+		case LabelDecl:
+			return false
+
+		case AInstruction:
+			a = tInst.Location
+			if tInst.Expr != nil {
+				a = ""
+			}
+
+		case CInstruction:
+			if strings.Contains(tInst.Comp, "M") && o.aliases(a, address) {
+				return false
+			}
+			if tInst.Jump != "" {
+				return false
+			}
+			if value, found := hack.BuiltInTable[a]; found && value == address && strings.Contains(tInst.Dest, "M") {
+				return true
+			}
+			a, _ = track(tInst, a, "")
+		}
+	}
+
+	return false
+}
+
+// Reports whether 'symbol' could point to the RAM location 'address'. The labels are ROM locations
+// and could match any address, while the variables are allocated from 16 onwards (after R15).
+func (o *Optimizer) aliases(symbol string, address uint16) bool {
+	if symbol == "" {
+		return true
+	}
+	if value, found := hack.BuiltInTable[symbol]; found {
+		return value == address
+	}
+	if value, err := strconv.ParseUint(symbol, 10, 16); err == nil {
+		return uint16(value) == address
+	}
+	return o.labels[symbol]
+}
+
+// Updates the symbols known to be in the A and D registers (see 'EliminateRedundantLoads') after
+// the execution of the C Instruction 'inst'. The memory is written at the address before the update.
+func track(inst CInstruction, a, d string) (string, string) {
+	writesA, writesD, writesM := strings.Contains(inst.Dest, "A"), strings.Contains(inst.Dest, "D"), strings.Contains(inst.Dest, "M")
+
+	switch {
+	case writesD && !writesA && (writesM || inst.Comp == "M"): // Both D and M[A] end up with the same value
+		d = a
+	case writesD:
+		d = ""
+	case writesM && inst.Comp == "D" && a != "": // Writing D somewhere else doesn't change the value D mirrors
+		d = a
+	case writesM && inst.Comp != "D":
+		d = ""
+	}
+
+	if writesA {
+		a = ""
+	}
+	return a, d
+}
+
+// Reports whether 'program' is made of the same instructions of 'pattern' (positions are ignored).
+func matches(program Program, pattern Program) bool {
+	for i, inst := range pattern {
+		switch tInst := inst.(type) {
+		case AInstruction:
+			other, ok := program[i].(AInstruction)
+			if !ok || other.Expr != nil || other.Location != tInst.Location {
+				return false
+			}
+		case CInstruction:
+			other, ok := program[i].(CInstruction)
+			if !ok || other.Dest != tInst.Dest || other.Comp != tInst.Comp || other.Jump != tInst.Jump {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func isLabelDecl(inst Instruction) bool {
+	_, ok := inst.(LabelDecl)
+	return ok
+}
+
+func isAInstruction(inst Instruction) bool {
+	_, ok := inst.(AInstruction)
+	return ok
+}
+
+// Reports whether 'inst' is a jump taken regardless of the computation (that is not stored anywhere).
+func isUnconditionalJump(inst Instruction) bool {
+	jump, ok := inst.(CInstruction)
+	return ok && jump.Jump == "JMP" && jump.Dest == ""
+}
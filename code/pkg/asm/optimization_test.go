@@ -0,0 +1,72 @@
+package asm_test
+
+import (
+	"strings"
+	"testing"
+
+	"its-hmny.dev/nand2tetris/pkg/asm"
+)
+
+func TestOptimizer(t *testing.T) {
+	// Parses 'source', optimizes it and compares the result with the (textual) 'expected' program
+	test := func(source string, expected string) {
+		parser := asm.NewParser(strings.NewReader(source))
+		program, err := parser.Parse()
+		if err != nil {
+			t.Fatalf("Unexpected parsing error: %v", err)
+		}
+
+		optimizer := asm.NewOptimizer(program)
+		optimized, err := optimizer.Optimize()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		codegen := asm.NewCodeGenerator(optimized)
+		compiled, err := codegen.Generate()
+		if err != nil {
+			t.Fatalf("Unexpected codegen error: %v", err)
+		}
+
+		expected = strings.Join(strings.Fields(expected), "\n")
+		if got := strings.Join(compiled, "\n"); got != expected {
+			t.Fatalf("Expected:\n%s\ngot:\n%s", expected, got)
+		}
+	}
+
+	t.Run("Redundant loads", func(t *testing.T) {
+		test("@R13 M=D @R13 D=M @SP A=M M=D", "@R13 M=D @SP A=M M=D")
+		test("@5 @6 D=A @LCL M=D @LCL M=D", "@6 D=A @LCL M=D")
+		// The A register is unknown after a label, and D is changed by 'D=D+A'
+		test("@R13 M=D (LOOP) @R13 D=D+A @R13 M=D", "@R13 M=D (LOOP) @R13 D=D+A M=D")
+	})
+
+	t.Run("Dead stores", func(t *testing.T) {
+		test("@R13 M=D @5 D=A @R13 M=D @SP A=M M=D", "@5 D=A @R13 M=D @SP A=M M=D")
+		// The first store could be read through the pointer in R14 (or after the jump)
+		test("@R13 M=D @R14 A=M D=M @R13 M=D @SP A=M M=D", "@R13 M=D @R14 A=M D=M @R13 M=D @SP A=M M=D")
+		test("@R13 M=D @END D;JEQ @R13 M=0 (END)", "@R13 M=D @END D;JEQ @R13 M=0 (END)")
+	})
+
+	t.Run("Push and pop pairs", func(t *testing.T) {
+		test("@7 D=A @SP A=M M=D @SP M=M+1 @SP AM=M-1 D=M @R13 M=D", "@7 D=A @R13 M=D")
+		// The address of the stack top could be used by the next instruction
+		test("@SP A=M M=D @SP M=M+1 @SP AM=M-1 D=M M=-D", "@SP A=M M=D @SP M=M+1 AM=M-1 D=M M=-D")
+	})
+
+	t.Run("Jump threading", func(t *testing.T) {
+		test("@FIRST 0;JMP (FIRST) @SECOND 0;JMP (SECOND) (THIRD) @END 0;JMP (END) @END 0;JMP",
+			"@END 0;JMP (FIRST) @END 0;JMP (SECOND) (THIRD) @END 0;JMP (END) @END 0;JMP")
+		test("@FIRST D;JGT @SP (FIRST) @END 0;JMP (END)", "@END D;JGT @SP (FIRST) @END 0;JMP (END)")
+		// The A register is used when the jump is not taken, or by the jump itself
+		test("@FIRST D;JGT M=D (FIRST) @END 0;JMP (END)", "@FIRST D;JGT M=D (FIRST) @END 0;JMP (END)")
+		test("@FIRST A;JGT (FIRST) @END 0;JMP (END)", "@FIRST A;JGT (FIRST) @END 0;JMP (END)")
+	})
+
+	t.Run("Empty program", func(t *testing.T) {
+		optimizer := asm.NewOptimizer(asm.Program{})
+		if _, err := optimizer.Optimize(); err == nil {
+			t.Fatalf("Expected error, got nil")
+		}
+	})
+}
@@ -1,11 +1,16 @@
 package asm
 
 import (
+	"bytes"
 	"fmt"
 	"io"
 	"os"
+	"slices"
+	"strings"
 
 	pc "github.com/prataprc/goparsec"
+	"its-hmny.dev/nand2tetris/pkg/hack"
+	"its-hmny.dev/nand2tetris/pkg/utils"
 )
 
 // ----------------------------------------------------------------------------
@@ -20,72 +25,114 @@ import (
 // Top level object, will generate the traversable AST based on the input plus the PCs below.
 var ast = pc.NewAST("assembler", 0)
 
+// Records the furthest failure of the PCs below, used to report syntax errors to the user.
+var syntax = utils.NewSyntaxTracker()
+
 var (
 	// Parser combinator for an entire Assembler program (a sequence of comments and instructions)
 	pProgram = ast.ManyUntil("program", nil, ast.OrdChoice("item", nil, pComment, pInstruction), pc.End())
 
-	// Parser combinator for a generic Assembler instruction (either C, A or Label declaration)
-	pInstruction = ast.OrdChoice("instruction", nil, pAInst, pCInst, pLabelDecl)
+	// Parser combinator for a generic Assembler instruction (either C, A, Label declaration or a directive)
+	pInstruction = ast.OrdChoice("instruction", nil, pAInst, pCInst, pLabelDecl, pMacroDecl, pInclude, pDefine, pMacroCall)
+	// Parser combinator for the instructions allowed in the body of a macro (nested declarations are not)
+	pBodyInstruction = ast.OrdChoice("instruction", nil, pAInst, pCInst, pLabelDecl, pMacroCall)
 	// Parser combinator for comments in Assembler program
 	pComment = ast.And("comment", nil, pc.Atom("//", "//"), pc.Token(`(?m).*$`, "COMMENT"))
 
 	// Parser combinator for A Instructions
-	pAInst = ast.And("a-inst", nil, pc.Atom("@", "@"), pLabel)
+	pAInst = ast.And("a-inst", nil, syntax.Atom("@", "@"), &pExpression)
 	// Parser combinator for new label declaration
-	pLabelDecl = ast.And("label-decl", nil, pc.Atom("(", "("), pLabel, pc.Atom(")", ")"))
+	pLabelDecl = ast.And("label-decl", nil, syntax.Atom("(", "("), pLabel, syntax.Atom(")", ")"))
 	// Parser combinator for C Instructions
 	pCInst = ast.And("c-inst", nil,
-		ast.Maybe("maybe-assign", nil, ast.And("assign", nil, pDest, pc.Atom("=", "="))),
+		ast.Maybe("maybe-assign", nil, ast.And("assign", nil, pDest, syntax.Atom("=", "="))),
 		pComp, // 'comp' should always be provided
-		ast.Maybe("maybe-goto", nil, ast.And("goto", nil, pc.Atom(";", ";"), pJump)),
+		ast.Maybe("maybe-goto", nil, ast.And("goto", nil, syntax.Atom(";", ";"), pJump)),
+	)
+
+	// Parser combinator for macro declarations, the body goes on until the matching '.endm' directive
+	pMacroDecl = ast.And("macro-decl", nil,
+		pc.Token(`\.macro\b`, ".macro"), pMacroName, ast.Kleene("params", nil, pArgument, pArgSeparator),
+		ast.Kleene("body", nil, ast.OrdChoice("item", nil, pComment, pBodyInstruction)),
+		syntax.Expect("'.endm'", pc.Token(`\.endm\b`, ".endm")),
 	)
+	// Parser combinator for macro invocations, the arguments are on the same line of the macro name
+	pMacroCall = ast.And("macro-call", nil, pMacroName, ast.Kleene("args", nil, pArgument, pArgSeparator))
+	// Parser combinator for the inclusion of another Assembler source file
+	pInclude = ast.And("include", nil, pc.Token(`\.include\b`, ".include"), syntax.Expect("path", pc.Token(`"[^"\n]*"`, "PATH")))
+	// Parser combinator for the declaration of named constants
+	pDefine = ast.And("define", nil, pc.Token(`\.define\b`, ".define"), pMacroName, syntax.Expect("value", pArgument))
 )
 
 var (
 	// Generic label parser (A Instruction + Label declaration)
 	// NOTE: A label can be any sequence of letters, digits, and symbols (_, ., $, :).
 	// NOTE: A label cannot begin with a leading digit (a symbol is indeed allowed).
-	pLabel = ast.OrdChoice("label", nil, pc.Int(), pc.Token(`[A-Za-z_.$:][0-9a-zA-Z_.$:]*`, "SYMBOL"))
+	pLabel = syntax.Expect("label", ast.OrdChoice("label", nil, pc.Int(), pc.Token(`[A-Za-z_.$:][0-9a-zA-Z_.$:]*`, "SYMBOL")))
 
 	// Generic destination parser (C Instruction subsection)
-	// NOTE: The order of the Atom is reversed w.r.t. the one provided in the translation table cause
-	// if not the single destination section will match before in the PC (BFS Search algorithm)
-	pDest = ast.OrdChoice("dest", nil,
-		pc.Atom("AM", "AM"), pc.Atom("AD", "AD"), pc.Atom("MD", "MD"),
-		pc.Atom("D", "D"), pc.Atom("A", "A"), pc.Atom("M", "M"),
-	)
+	// NOTE: The registers can be listed in any order (e.g. 'MD', 'DM', 'AMD' or 'ADM') so here we
+	// NOTE: accept any combination of them, duplicates are rejected later on by 'HandleCInst'.
+	// NOTE: The word boundary avoids matching the first letters of a macro name (e.g. 'DUP' or 'MOVE').
+	pDest = syntax.Expect("destination", pc.Token(`[ADM]{1,3}\b`, "DEST"))
 
 	// Generic computation parser (C Instruction subsection)
-	// NOTE: The order of the Atom is reversed w.r.t. the one provided in the translation table cause
-	// if not the 'Constant and identifiers' part will match before the order (BFS Search algorithm)
-	pComp = ast.OrdChoice("comp", nil,
-		// - Bitwise register with register operations
-		pc.Atom("D&A", "D&A"), pc.Atom("D&M", "D&M"),
-		pc.Atom("D|A", "D|A"), pc.Atom("D|M", "D|M"),
-		// - Register with register operations
-		pc.Atom("D+A", "D+A"), pc.Atom("D+M", "D+M"),
-		pc.Atom("D-A", "D-A"), pc.Atom("D-M", "D-M"),
-		pc.Atom("A-D", "A-D"), pc.Atom("M-D", "M-D"),
-		// - Increment and decrement operations
-		pc.Atom("D+1", "D+1"), pc.Atom("A+1", "A+1"), pc.Atom("M+1", "M+1"),
-		pc.Atom("D-1", "D-1"), pc.Atom("A-1", "A-1"), pc.Atom("M-1", "M-1"),
-		// - Binary and numerical negations
-		pc.Atom("!D", "!D"), pc.Atom("!A", "!A"), pc.Atom("!M", "!M"),
-		pc.Atom("-D", "-D"), pc.Atom("-A", "-A"), pc.Atom("-M", "-M"),
-		// - Constants and identities
-		pc.Atom("0", "0"), pc.Atom("1", "1"), pc.Atom("-1", "-1"),
-		pc.Atom("D", "D"), pc.Atom("A", "A"), pc.Atom("M", "M"),
-	)
+	// NOTE: Either a unary operation on a register/constant or a binary one between two of them, the
+	// NOTE: whitespaces are allowed in between (e.g. 'M = M + D') while the validation of the operation
+	// NOTE: against the translation table (and its commutative aliases) is done later on by 'HandleCInst'.
+	pComp = syntax.Expect("computation", pc.Token(`[-!]?[ \t]*[ADM01](?:[ \t]*[-+&|][ \t]*[ADM1])?\b`, "COMP"))
 
 	// Generic jump parser (C Instruction subsection)
-	pJump = ast.OrdChoice("jump", nil,
+	pJump = syntax.Expect("jump", ast.OrdChoice("jump", nil,
 		pc.Atom("JNE", "JNE"), pc.Atom("JEQ", "JEQ"),
 		pc.Atom("JGT", "JGT"), pc.Atom("JGE", "JGE"),
 		pc.Atom("JLT", "JLT"), pc.Atom("JLE", "JLE"),
 		pc.Atom("JMP", "JMP"),
-	)
+	))
+
+	// Generic name parser (macro names and constants), unlike labels they cannot contain symbols
+	pMacroName = syntax.Expect("name", pc.Token(`[A-Za-z_][0-9A-Za-z_]*`, "NAME"))
+
+	// Generic argument parser (macro parameters/arguments and constant values)
+	// NOTE: Since the arity of a macro is not known while parsing the arguments are required to be
+	// NOTE: on the same line of the directive/invocation, so only spaces and tabs are skipped here.
+	pArgument     = inlineToken(`[0-9A-Za-z_.$:]+`, "ARG")
+	pArgSeparator = inlineToken(`,`, ",")
+
+	// Generic operator parser (A Instruction constant expressions)
+	// NOTE: The operator should be on the same line of the previous operand, otherwise a C instruction
+	// NOTE: like '-1;JMP' right after an A instruction would be read as part of the latter expression.
+	pOperator = inlineToken(`[-+*/&|]`, "OPERATOR")
+)
+
+var (
+	// Parser combinator for the location of an A Instruction, either a label or a constant expression
+	// NOTE: The operands and the operators are read as a flat sequence, the precedence of the latter
+	// NOTE: is taken into account only while building the 'asm.Expression' tree by 'HandleExpression'.
+	pExpression pc.Parser
+	// Parser combinator for the operands of a constant expression (label or parenthesized expression)
+	pOperand pc.Parser
 )
 
+// Initializes the recursive PCs, since they reference each other they cannot be initialized inline.
+func init() {
+	pOperand = ast.OrdChoice("operand", nil, pLabel, ast.And("group", nil, pc.Atom("(", "("), &pExpression, syntax.Atom(")", ")")))
+	pExpression = ast.And("expression", nil, pOperand, ast.Kleene("operations", nil, ast.And("operation", nil, pOperator, pOperand)))
+}
+
+// Same as 'pc.Token' but the leading whitespaces skipped cannot include a newline.
+func inlineToken(pattern string, name string) pc.Parser {
+	return func(s pc.Scanner) (pc.ParsecNode, pc.Scanner) {
+		news := s.Clone()
+		news.SkipAny(`^[ \t]*`)
+		cursor := news.GetCursor()
+		if token, _ := news.Match("^" + pattern); token != nil {
+			return pc.NewTerminal(name, string(token), cursor), news
+		}
+		return nil, s
+	}
+}
+
 // ----------------------------------------------------------------------------
 // Asm Parser
 
@@ -96,7 +143,11 @@ var (
 // - PARSEC_DEBUG: Verbose logging to inspect which of the PCs gets triggered and match
 // - EXPORT_AST:   Exports in the DEBUG_FOLDER a Graphviz representation of the AST
 // - PRINT_AST:    Print on the stdout a textual representation of the AST
-type Parser struct{ reader io.Reader }
+type Parser struct {
+	reader io.Reader        // The source of the program to be parsed
+	name   string           // The name of the source file, used in the position of the nodes
+	source utils.SourceFile // Maps the offsets of the nodes in the AST to their position
+}
 
 // Initializes and returns to the caller a brand new 'Parser' struct.
 // Requires the argument io.Reader 'r' to be valid and usable.
@@ -104,6 +155,12 @@ func NewParser(r io.Reader) Parser {
 	return Parser{reader: r}
 }
 
+// Initializes and returns to the caller a brand new 'Parser' struct, the instructions parsed
+// will reference 'name' as their source file (e.g. 'Add.asm:3:1') in their 'Position'.
+func NewFileParser(r io.Reader, name string) Parser {
+	return Parser{reader: r, name: name}
+}
+
 // Parser entrypoint divides the 2 phases of the parsing pipeline
 // Text --> AST: This step is done using PCs and returns a generic traversable AST
 // AST --> IR: This step is done by traversing the AST and extracting the 'asm.Instruction'
@@ -113,9 +170,9 @@ func (p *Parser) Parse() (Program, error) {
 		return nil, fmt.Errorf("cannot read from 'io.Reader': %s", err)
 	}
 
-	ast, success := p.FromSource(content)
-	if !success {
-		return nil, fmt.Errorf("failed to parse AST from input content")
+	ast, err := p.FromSource(content)
+	if err != nil {
+		return nil, err
 	}
 
 	return p.FromAST(ast)
@@ -123,7 +180,8 @@ func (p *Parser) Parse() (Program, error) {
 
 // Scans the textual input stream coming from the 'reader' method and returns a traversable AST
 // (Abstract Syntax Tree) that can be eventually visited to extract/transform the info available.
-func (p *Parser) FromSource(source []byte) (pc.Queryable, bool) {
+// If the input is not consumed entirely a syntax error pointing to the offending token is returned.
+func (p *Parser) FromSource(source []byte) (pc.Queryable, error) {
 
 	// Feature flag: Enable 'goparsec' library's debug logs
 	if os.Getenv("PARSEC_DEBUG") != "" {
@@ -131,7 +189,9 @@ func (p *Parser) FromSource(source []byte) (pc.Queryable, bool) {
 	}
 
 	// We generate the traversable Abstract Syntax Tree from the source content
-	root, _ := ast.Parsewith(pProgram, pc.NewScanner(source))
+	p.source = utils.NewSourceFile(p.name, source)
+	syntax.Reset()
+	root, scanner := ast.Parsewith(pProgram, pc.NewScanner(source))
 
 	// Feature flag: Enables export of the AST as Dot file (debug.ast.fot)
 	if os.Getenv("EXPORT_AST") != "" {
@@ -146,20 +206,35 @@ func (p *Parser) FromSource(source []byte) (pc.Queryable, bool) {
 		ast.Prettyprint()
 	}
 
-	return root, true // Success is based on the reaching of 'EOF'
+	// Success is based on the reaching of 'EOF' (trailing whitespaces are allowed), an empty
+	// source has no AST at all since the 'program' node requires at least one child to be produced
+	if _, scanner = scanner.SkipWS(); !scanner.Endof() || (root == nil && len(bytes.TrimSpace(source)) > 0) {
+		return nil, syntax.Error(p.source, scanner)
+	}
+
+	return root, nil
 }
 
 // This function takes the root node of the raw parsed AST and does a DFS on it parsing
 // one by one each subtree and retuning a 'asm.Program' that can be used as in-memory and
 // type-safe AST not dependent on the parsing library used.
 func (p *Parser) FromAST(root pc.Queryable) (Program, error) {
-	program := []Instruction{}
-
+	if root == nil { // Empty source file, see 'FromSource'
+		return Program{}, nil
+	}
 	if root.GetName() != "program" {
 		return nil, fmt.Errorf("expected node 'program', found %s", root.GetName())
 	}
 
-	for _, child := range root.GetChildren() {
+	return p.HandleInstructions(root.GetChildren())
+}
+
+// Converts a sequence of instruction (or comment) nodes to their 'asm.Instruction' counterpart,
+// shared between the top level of the program and the body of the macro declarations.
+func (p *Parser) HandleInstructions(nodes []pc.Queryable) (Program, error) {
+	program := []Instruction{}
+
+	for _, child := range nodes {
 		switch child.GetName() {
 		case "a-inst": // A Instruction subtree, appends 'asm.AInstruction' to 'program'
 			inst, err := p.HandleAInst(child)
@@ -182,6 +257,34 @@ func (p *Parser) FromAST(root pc.Queryable) (Program, error) {
 			}
 			program = append(program, inst)
 
+		case "macro-decl": // Macro declaration subtree, appends 'asm.MacroDecl' to 'program'
+			inst, err := p.HandleMacroDecl(child)
+			if inst == nil || err != nil {
+				return nil, err
+			}
+			program = append(program, inst)
+
+		case "macro-call": // Macro invocation subtree, appends 'asm.MacroCall' to 'program'
+			inst, err := p.HandleMacroCall(child)
+			if inst == nil || err != nil {
+				return nil, err
+			}
+			program = append(program, inst)
+
+		case "include": // Include directive subtree, appends 'asm.Include' to 'program'
+			inst, err := p.HandleInclude(child)
+			if inst == nil || err != nil {
+				return nil, err
+			}
+			program = append(program, inst)
+
+		case "define": // Define directive subtree, appends 'asm.Define' to 'program'
+			inst, err := p.HandleDefine(child)
+			if inst == nil || err != nil {
+				return nil, err
+			}
+			program = append(program, inst)
+
 		case "comment": // Comment nodes in the AST are just skipped
 			continue
 
@@ -194,42 +297,112 @@ func (p *Parser) FromAST(root pc.Queryable) (Program, error) {
 }
 
 // Specialized function to convert a "a-inst" node to an 'asm.AInstruction'.
-func (Parser) HandleAInst(inst pc.Queryable) (Instruction, error) {
+func (p Parser) HandleAInst(inst pc.Queryable) (Instruction, error) {
 	if inst.GetName() != "a-inst" { // Prelude checks: inspects the node to verify it's an 'a-inst'
 		return nil, fmt.Errorf("expected node 'a-inst', found %s", inst.GetName())
 	}
 
-	symbol := inst.GetChildren()[1] // Prelude checks: inspects the label node type (INT | SYMBOL)
-	if symbol.GetName() != "INT" && symbol.GetName() != "SYMBOL" {
-		return nil, fmt.Errorf("expected token 'SYMBOL' or 'INT', got %s", symbol.GetName())
+	expr, err := p.HandleExpression(inst.GetChildren()[1])
+	if err != nil {
+		return nil, err
+	}
+
+	// A plain label is kept as is, only the actual expressions are evaluated later on
+	if symbol, isSymbol := expr.(string); isSymbol {
+		return AInstruction{Location: symbol, Position: p.source.PositionOf(inst)}, nil
+	}
+	return AInstruction{Expr: expr, Position: p.source.PositionOf(inst)}, nil
+}
+
+// Specialized function to convert an "expression" node to an 'asm.Expression'.
+//
+// The operations are folded with the classic operator precedence parsing: each operand is pushed on
+// a stack along with the operator that precedes it, the operations with higher (or equal, since
+// they're left associative) precedence on top of the stack are reduced before pushing a new one.
+func (p Parser) HandleExpression(expr pc.Queryable) (Expression, error) {
+	if expr.GetName() != "expression" { // Prelude checks: inspects the node to verify it's an 'expression'
+		return nil, fmt.Errorf("expected node 'expression', found %s", expr.GetName())
+	}
+
+	first, err := p.HandleOperand(expr.GetChildren()[0])
+	if err != nil {
+		return nil, err
+	}
+
+	operands, operators := []Expression{first}, []string{}
+	reduce := func() {
+		n, m := len(operands), len(operators)
+		operation := BinaryExpr{Operator: operators[m-1], Lhs: operands[n-2], Rhs: operands[n-1]}
+		operands, operators = append(operands[:n-2], operation), operators[:m-1]
+	}
+
+	for _, operation := range expr.GetChildren()[1].GetChildren() {
+		operator := operation.GetChildren()[0].GetValue()
+		operand, err := p.HandleOperand(operation.GetChildren()[1])
+		if err != nil {
+			return nil, err
+		}
+
+		for len(operators) > 0 && Precedence[operators[len(operators)-1]] >= Precedence[operator] {
+			reduce()
+		}
+		operands, operators = append(operands, operand), append(operators, operator)
+	}
+	for len(operators) > 0 {
+		reduce()
 	}
 
-	return AInstruction{Location: symbol.GetValue()}, nil
+	return operands[0], nil
+}
+
+// Specialized function to convert an operand node (INT, SYMBOL or "group") to an 'asm.Expression'.
+func (p Parser) HandleOperand(operand pc.Queryable) (Expression, error) {
+	switch operand.GetName() {
+	case "INT", "SYMBOL":
+		return operand.GetValue(), nil
+	case "group":
+		return p.HandleExpression(operand.GetChildren()[1])
+	default: // Prelude checks: inspects the operand node type (INT | SYMBOL | group)
+		return nil, fmt.Errorf("expected token 'SYMBOL', 'INT' or node 'group', got %s", operand.GetName())
+	}
 }
 
 // Specialized function to convert a "c-inst" node to an 'asm.CInstruction'.
-func (Parser) HandleCInst(inst pc.Queryable) (Instruction, error) {
+func (p Parser) HandleCInst(inst pc.Queryable) (Instruction, error) {
 	if inst.GetName() != "c-inst" { // Prelude checks: inspects the node to verify it's an 'a-inst'
 		return nil, fmt.Errorf("expected node 'c-inst', found %s", inst.GetName())
 	}
 
 	dest, comp, jump := inst.GetChildren()[0], inst.GetChildren()[1], inst.GetChildren()[2]
+	position := p.source.PositionOf(inst)
+
+	// The whitespaces allowed inside the computation are not part of the instruction itself
+	cInst := CInstruction{Comp: strings.Join(strings.Fields(comp.GetValue()), ""), Position: position}
+	if _, found := hack.CanonicalComp(cInst.Comp); !found {
+		return nil, position.Errorf("unknown computation '%s'", cInst.Comp)
+	}
 
 	if dest.GetName() == "assign" && len(dest.GetChildren()) == 2 {
-		dest = dest.GetChildren()[0]
-		return CInstruction{Dest: dest.GetValue(), Comp: comp.GetValue()}, nil
+		cInst.Dest = dest.GetChildren()[0].GetValue()
+		if _, found := hack.CanonicalDest(cInst.Dest); !found {
+			return nil, position.Errorf("invalid destination '%s', each register can appear only once", cInst.Dest)
+		}
 	}
 
-	if jump.GetName() == "goto" || len(jump.GetChildren()) == 2 {
-		jump = jump.GetChildren()[1]
-		return CInstruction{Comp: comp.GetValue(), Jump: jump.GetValue()}, nil
+	if jump.GetName() == "goto" && len(jump.GetChildren()) == 2 {
+		cInst.Jump = jump.GetChildren()[1].GetValue()
 	}
 
-	return nil, fmt.Errorf("expected either node 'assign' or 'goto' not found")
+	// Both can be provided at the same time (e.g. 'D=M;JGT') but at least one of them is required
+	if cInst.Dest == "" && cInst.Jump == "" {
+		return nil, position.Errorf("expected either node 'assign' or 'goto' not found")
+	}
+
+	return cInst, nil
 }
 
 // Specialized function to extract from a "label-decl" node to an 'asm.LabelDecl'.
-func (Parser) HandleLabelDecl(decl pc.Queryable) (Instruction, error) {
+func (p Parser) HandleLabelDecl(decl pc.Queryable) (Instruction, error) {
 	if decl.GetName() != "label-decl" { // Prelude checks: inspects the node to verify it's a 'label-decl'
 		return nil, fmt.Errorf("expected node 'a-inst', found %s", decl.GetName())
 	}
@@ -239,5 +412,70 @@ func (Parser) HandleLabelDecl(decl pc.Queryable) (Instruction, error) {
 		return nil, fmt.Errorf("expected token 'SYMBOL', got %s", symbol.GetName())
 	}
 
-	return LabelDecl{Name: symbol.GetValue()}, nil
+	return LabelDecl{Name: symbol.GetValue(), Position: p.source.PositionOf(decl)}, nil
+}
+
+// Specialized function to convert a "macro-decl" node to an 'asm.MacroDecl'.
+func (p *Parser) HandleMacroDecl(decl pc.Queryable) (Instruction, error) {
+	if decl.GetName() != "macro-decl" { // Prelude checks: inspects the node to verify it's a 'macro-decl'
+		return nil, fmt.Errorf("expected node 'macro-decl', found %s", decl.GetName())
+	}
+
+	name, params, body := decl.GetChildren()[1], decl.GetChildren()[2], decl.GetChildren()[3]
+	position := p.source.PositionOf(decl)
+
+	macro := MacroDecl{Name: name.GetValue(), Params: []string{}, Position: position}
+	for _, param := range params.GetChildren() {
+		if slices.Contains(macro.Params, param.GetValue()) {
+			return nil, position.Errorf("duplicate parameter '%s' in macro '%s'", param.GetValue(), macro.Name)
+		}
+		macro.Params = append(macro.Params, param.GetValue())
+	}
+
+	instructions, err := p.HandleInstructions(body.GetChildren())
+	if err != nil {
+		return nil, err
+	}
+	macro.Body = instructions
+
+	return macro, nil
+}
+
+// Specialized function to convert a "macro-call" node to an 'asm.MacroCall'.
+func (p Parser) HandleMacroCall(call pc.Queryable) (Instruction, error) {
+	if call.GetName() != "macro-call" { // Prelude checks: inspects the node to verify it's a 'macro-call'
+		return nil, fmt.Errorf("expected node 'macro-call', found %s", call.GetName())
+	}
+
+	name, args := call.GetChildren()[0], call.GetChildren()[1]
+	macro := MacroCall{Name: name.GetValue(), Args: []string{}, Position: p.source.PositionOf(call)}
+	for _, arg := range args.GetChildren() {
+		macro.Args = append(macro.Args, arg.GetValue())
+	}
+
+	return macro, nil
+}
+
+// Specialized function to convert an "include" node to an 'asm.Include'.
+func (p Parser) HandleInclude(include pc.Queryable) (Instruction, error) {
+	if include.GetName() != "include" { // Prelude checks: inspects the node to verify it's an 'include'
+		return nil, fmt.Errorf("expected node 'include', found %s", include.GetName())
+	}
+
+	path := strings.Trim(include.GetChildren()[1].GetValue(), `"`)
+	if path == "" {
+		return nil, p.source.PositionOf(include).Errorf("empty path in '.include' directive")
+	}
+
+	return Include{Path: path, Position: p.source.PositionOf(include)}, nil
+}
+
+// Specialized function to convert a "define" node to an 'asm.Define'.
+func (p Parser) HandleDefine(define pc.Queryable) (Instruction, error) {
+	if define.GetName() != "define" { // Prelude checks: inspects the node to verify it's a 'define'
+		return nil, fmt.Errorf("expected node 'define', found %s", define.GetName())
+	}
+
+	name, value := define.GetChildren()[1], define.GetChildren()[2]
+	return Define{Name: name.GetValue(), Value: value.GetValue(), Position: p.source.PositionOf(define)}, nil
 }
@@ -0,0 +1,175 @@
+package asm_test
+
+import (
+	"strings"
+	"testing"
+
+	"its-hmny.dev/nand2tetris/pkg/asm"
+)
+
+// Checks that every form of C instruction allowed by the Hack ISA is parsed correctly.
+func TestParseCInstructions(t *testing.T) {
+	test := func(source string, expected asm.CInstruction) {
+		parser := asm.NewParser(strings.NewReader(source))
+		program, err := parser.Parse()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if len(program) != 1 {
+			t.Fatalf("Expected a single instruction, got %d", len(program))
+		}
+		expected.Position = program[0].(asm.CInstruction).Position
+		if program[0] != expected {
+			t.Fatalf("Expected %+v, got %+v", expected, program[0])
+		}
+	}
+
+	t.Run("Destination permutations", func(t *testing.T) {
+		test("AMD=M+1", asm.CInstruction{Dest: "AMD", Comp: "M+1"})
+		test("ADM=M+1", asm.CInstruction{Dest: "ADM", Comp: "M+1"})
+		test("DM=D", asm.CInstruction{Dest: "DM", Comp: "D"})
+		test("MA=0", asm.CInstruction{Dest: "MA", Comp: "0"})
+		test("DA=-1", asm.CInstruction{Dest: "DA", Comp: "-1"})
+	})
+
+	t.Run("Destination and jump", func(t *testing.T) {
+		test("D=M;JGT", asm.CInstruction{Dest: "D", Comp: "M", Jump: "JGT"})
+		test("AM=M-1;JMP", asm.CInstruction{Dest: "AM", Comp: "M-1", Jump: "JMP"})
+	})
+
+	t.Run("Commutative aliases", func(t *testing.T) {
+		test("D=A+D", asm.CInstruction{Dest: "D", Comp: "A+D"})
+		test("M=M|D", asm.CInstruction{Dest: "M", Comp: "M|D"})
+		test("A&D;JEQ", asm.CInstruction{Comp: "A&D", Jump: "JEQ"})
+	})
+
+	t.Run("Whitespaces", func(t *testing.T) {
+		test("M = M + D", asm.CInstruction{Dest: "M", Comp: "M+D"})
+		test("M = M -1", asm.CInstruction{Dest: "M", Comp: "M-1"})
+		test("D = ! A ; JNE", asm.CInstruction{Dest: "D", Comp: "!A", Jump: "JNE"})
+	})
+
+	t.Run("Invalid instructions", func(t *testing.T) {
+		for _, source := range []string{"AA=M", "DMD=1", "D=A+A", "D=M-M", "D=1-D"} {
+			parser := asm.NewParser(strings.NewReader(source))
+			if _, err := parser.Parse(); err == nil {
+				t.Fatalf("Expected error for '%s', got nil", source)
+			}
+		}
+	})
+}
+
+// Checks that a program that cannot be parsed entirely is rejected, pointing to the offending token.
+func TestSyntaxErrors(t *testing.T) {
+	test := func(source string, expected string) {
+		parser := asm.NewFileParser(strings.NewReader(source), "Test.asm")
+		_, err := parser.Parse()
+		if err == nil {
+			t.Fatalf("Expected syntax error, got nil")
+		}
+		if err.Error() != expected {
+			t.Fatalf("Expected error:\n%s\ngot:\n%s", expected, err)
+		}
+	}
+
+	t.Run("Invalid computation", func(t *testing.T) {
+		test("@foo\nD=Q\n@bar", "Test.asm:2:3: unexpected 'Q', expected computation\n    D=Q\n      ^")
+	})
+	t.Run("Invalid jump", func(t *testing.T) {
+		test("@1\n\tD;JMX\n", "Test.asm:2:4: unexpected 'JMX', expected jump\n    \tD;JMX\n    \t  ^")
+	})
+	t.Run("Unterminated label", func(t *testing.T) {
+		test("(LOOP\n@LOOP", "Test.asm:2:1: unexpected '@', expected ')'\n    @LOOP\n    ^")
+	})
+	t.Run("Missing label", func(t *testing.T) {
+		test("@", "Test.asm:1:2: unexpected end of file, expected label\n    @\n     ^")
+	})
+}
+
+// Checks that the macro assembler directives are parsed correctly and that macro names are not
+// mistaken for C instructions even if they start with a register name (e.g. 'DUP' or 'MOVE').
+func TestParseDirectives(t *testing.T) {
+	source := `
+		.define SIZE 32 // Comments are allowed after the directives
+		.include "lib/Stack.asm"
+		.macro DUP
+			MOVE SP, R13
+		.endm
+		DUP
+		D=M
+	`
+	parser := asm.NewParser(strings.NewReader(source))
+	program, err := parser.Parse()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(program) != 5 {
+		t.Fatalf("Expected 5 instructions, got %d: %+v", len(program), program)
+	}
+
+	if define := program[0].(asm.Define); define.Name != "SIZE" || define.Value != "32" {
+		t.Fatalf("Unexpected define: %+v", define)
+	}
+	if include := program[1].(asm.Include); include.Path != "lib/Stack.asm" {
+		t.Fatalf("Unexpected include: %+v", include)
+	}
+	macro := program[2].(asm.MacroDecl)
+	if macro.Name != "DUP" || len(macro.Params) != 0 || len(macro.Body) != 1 {
+		t.Fatalf("Unexpected macro: %+v", macro)
+	}
+	if call := macro.Body[0].(asm.MacroCall); call.Name != "MOVE" || strings.Join(call.Args, ",") != "SP,R13" {
+		t.Fatalf("Unexpected macro call: %+v", call)
+	}
+	// The arguments are on the same line, so the C instruction that follows is not one of them
+	if call := program[3].(asm.MacroCall); call.Name != "DUP" || len(call.Args) != 0 {
+		t.Fatalf("Unexpected macro call: %+v", call)
+	}
+
+	for _, source := range []string{".macro A1\n@0", ".macro OUTER\n.macro INNER\n.endm\n.endm", ".include"} {
+		parser := asm.NewParser(strings.NewReader(source))
+		if _, err := parser.Parse(); err == nil {
+			t.Fatalf("Expected error for '%s', got nil", source)
+		}
+	}
+}
+
+// Checks that the constant expressions in A instructions are parsed honoring the operators precedence.
+func TestParseExpressions(t *testing.T) {
+	test := func(source string, expected string) {
+		parser := asm.NewParser(strings.NewReader(source))
+		program, err := parser.Parse()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if len(program) != 1 {
+			t.Fatalf("Expected a single instruction, got %d", len(program))
+		}
+		// The tree is compared through its textual representation, that only keeps the needed parentheses
+		inst := program[0].(asm.AInstruction)
+		if inst.Expr == nil || inst.Location != "" || asm.FormatExpression(inst.Expr) != expected {
+			t.Fatalf("Expected expression '%s', got %+v", expected, inst)
+		}
+	}
+
+	test("@SCREEN+32", "SCREEN+32")
+	test("@BUFFER + 3", "BUFFER+3")
+	test("@(256*2)*1", "256*2*1")
+	test("@1+2*3", "1+2*3")
+	test("@(1+2)*3", "(1+2)*3")
+	test("@10-(4-3)", "10-(4-3)")
+	test("@10-4-3", "10-4-3")
+	test("@KBD|1&3", "KBD|1&3")
+	test("@(KBD|1)&3", "(KBD|1)&3")
+
+	// A plain location (even if parenthesized) is not an expression, also a trailing comment is not an operator
+	for _, source := range []string{"@SCREEN // Comment", "@(SCREEN)", "@R1\n-1;JMP"} {
+		parser := asm.NewParser(strings.NewReader(source))
+		program, err := parser.Parse()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if inst := program[0].(asm.AInstruction); inst.Expr != nil {
+			t.Fatalf("Expected plain location for '%s', got %+v", source, inst)
+		}
+	}
+}
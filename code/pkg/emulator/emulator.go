@@ -0,0 +1,48 @@
+package emulator
+
+// ----------------------------------------------------------------------------
+// General information
+
+// This section contains some general information about the Hack CPU emulator.
+//
+// The emulator models the Hack computer as described by the nand2tetris spec: a CPU with
+// two 16 bit registers (A and D) plus the Program Counter (PC), a read-only instruction
+// memory (ROM) and a read-write data memory (RAM). Both memories are 32K words wide, the
+// RAM also contains the memory mapped I/O devices (Screen and Keyboard) at the well-known
+// locations already defined in the 'hack.BuiltInTable'.
+//
+// Each call to 'Step' emulates a full clock cycle: the instruction pointed by the PC is
+// fetched from the ROM, decoded and then executed (eventually updating registers and RAM).
+
+const (
+	ROMSize uint16 = 1 << 15 // Number of instructions that can be stored in the ROM
+	RAMSize uint16 = 1 << 15 // Number of words addressable in the RAM (including the memory mapped I/O)
+)
+
+// In memory representation of the Hack CPU and the memories (both RAM and ROM) attached to it.
+//
+// Registers and memories are exposed directly so that the caller (e.g. tests or a debugger)
+// can inspect or tamper with them freely between one clock cycle and the next one.
+type CPU struct {
+	A  uint16 // The 'address' register, also used as a general purpose register
+	D  uint16 // The 'data' register, used to store intermediate results of the computation
+	PC uint16 // The 'program counter' register, points to the next instruction to be executed
+
+	RAM []uint16 // The data memory, 32K words including the Screen and Keyboard memory maps
+	ROM []uint16 // The instruction memory, 32K words containing the (binary) program to execute
+
+	Cycles uint64 // The number of clock cycles elapsed since the last reset
+}
+
+// Initializes and returns to the caller a brand new 'CPU' struct.
+// Both the memories as well as the registers are zeroed out, use one of the 'Load*' methods
+// to flash the ROM with a program before starting the execution with 'Step' or 'Run'.
+func NewCPU() CPU {
+	return CPU{RAM: make([]uint16, RAMSize), ROM: make([]uint16, ROMSize)}
+}
+
+// Resets the CPU, like asserting the 'reset' bit on the real hardware: only the PC is affected
+// while both the A and D register as well as the RAM maintain their content across the reset.
+func (c *CPU) Reset() {
+	c.PC, c.Cycles = 0, 0
+}
@@ -0,0 +1,107 @@
+package emulator_test
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"its-hmny.dev/nand2tetris/pkg/asm"
+	"its-hmny.dev/nand2tetris/pkg/emulator"
+)
+
+func TestExecution(t *testing.T) {
+	// Assembles a small program (from source) and flashes it on a brand new CPU
+	load := func(source string) emulator.CPU {
+		parser := asm.NewParser(bytes.NewReader([]byte(source)))
+		asmProgram, err := parser.Parse()
+		if err != nil {
+			t.Fatalf("unexpected error parsing program: %s", err)
+		}
+
+		lowerer := asm.NewLowerer(asmProgram)
+		hackProgram, table, err := lowerer.Lower()
+		if err != nil {
+			t.Fatalf("unexpected error lowering program: %s", err)
+		}
+
+		cpu := emulator.NewCPU()
+		if err := cpu.LoadProgram(hackProgram, table); err != nil {
+			t.Fatalf("unexpected error loading program: %s", err)
+		}
+		return cpu
+	}
+
+	t.Run("Registers and memory", func(t *testing.T) {
+		cpu := load("@7\nD=A\n@100\nM=D\nMD=M+1\nA=D-1\nD=!A")
+		if err := cpu.Run(7); err != nil {
+			t.Fatalf("unexpected error during execution: %s", err)
+		}
+		if cpu.RAM[100] != 8 || cpu.A != 7 || cpu.D != ^uint16(7) || cpu.PC != 7 {
+			t.Errorf("unexpected state: RAM[100]=%d A=%d D=%d PC=%d", cpu.RAM[100], cpu.A, cpu.D, cpu.PC)
+		}
+	})
+
+	t.Run("Conditional jumps", func(t *testing.T) {
+		// Sums the number from 1 to 10 in RAM[0] using a loop and a conditional jump
+		cpu := load("@10\nD=A\n@1\nM=D\n(LOOP)\n@1\nD=M\n@END\nD;JEQ\n@0\nM=D+M\n@1\nM=M-1\n@LOOP\n0;JMP\n(END)\n@END\n0;JMP")
+		if err := cpu.Run(200); err != nil {
+			t.Fatalf("unexpected error during execution: %s", err)
+		}
+		if cpu.RAM[0] != 55 {
+			t.Errorf("expected RAM[0] to be 55, got %d", cpu.RAM[0])
+		}
+	})
+
+	t.Run("Out of bound access", func(t *testing.T) {
+		cpu := load("D=-1\nA=D\nM=1")
+		if err := cpu.Run(3); err == nil {
+			t.Errorf("expected out of bound error while writing RAM[65535]")
+		}
+	})
+
+	t.Run("Invalid instructions", func(t *testing.T) {
+		// Both an unknown 'comp' opcode and a C instruction w/o the '111' prefix are rejected
+		for _, word := range []uint16{0b1110111110000000, 0b1000101010000111} {
+			cpu := emulator.NewCPU()
+			if err := cpu.LoadROM([]uint16{word}); err != nil {
+				t.Fatalf("unexpected error loading program: %s", err)
+			}
+			if err := cpu.Step(); err == nil {
+				t.Errorf("expected error executing instruction %016b", word)
+			}
+		}
+	})
+}
+
+func TestMult(t *testing.T) {
+	content, err := os.ReadFile("../../../projects/04 - Machine Language/01 - Mult/Mult.hack")
+	if err != nil {
+		t.Fatalf("Error reading input file: %s", err)
+	}
+	words, err := emulator.ReadHack(bytes.NewReader(content))
+	if err != nil {
+		t.Fatalf("Error parsing input file: %s", err)
+	}
+
+	test := func(r0, r1 uint16) {
+		cpu := emulator.NewCPU()
+		if err := cpu.LoadROM(words); err != nil {
+			t.Fatalf("unexpected error loading program: %s", err)
+		}
+
+		cpu.RAM[0], cpu.RAM[1], cpu.RAM[2] = r0, r1, 0xFFFF
+		if err := cpu.Run(300); err != nil {
+			t.Fatalf("unexpected error during execution: %s", err)
+		}
+		if cpu.RAM[2] != r0*r1 {
+			t.Errorf("expected %d * %d = %d, got %d", r0, r1, r0*r1, cpu.RAM[2])
+		}
+	}
+
+	test(0, 0)
+	test(1, 0)
+	test(0, 2)
+	test(3, 1)
+	test(2, 4)
+	test(6, 7)
+}
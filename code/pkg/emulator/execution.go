@@ -0,0 +1,110 @@
+package emulator
+
+import (
+	"fmt"
+
+	"its-hmny.dev/nand2tetris/pkg/hack"
+)
+
+// ----------------------------------------------------------------------------
+// Execution
+
+// Emulates 'n' clock cycles, stopping at the first one that returns an error.
+func (c *CPU) Run(n int) error {
+	for cycle := 0; cycle < n; cycle++ {
+		if err := c.Step(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Emulates a single clock cycle: fetches the instruction pointed by the PC, decodes it and
+// executes it. Returns an error if the PC points outside of the ROM, if the instruction is
+// not a valid one or if it tries to access a location outside of the RAM.
+func (c *CPU) Step() error {
+	if c.PC >= ROMSize {
+		return fmt.Errorf("program counter %d points outside of the ROM", c.PC)
+	}
+	word := c.ROM[c.PC]
+
+	// A Instruction: The whole instruction (opcode bit is zero) is loaded in the A register
+	if word&(1<<15) == 0 {
+		c.A, c.PC, c.Cycles = word, c.PC+1, c.Cycles+1
+		return nil
+	}
+
+	// C Instruction: The word is validated by the same decoder of the disassembler (e.g. unknown
+	// 'comp' opcodes or a missing '111' prefix), then we extract the 'comp', 'dest' and 'jump' bit-codes
+	if _, err := hack.DecodeInstruction(word); err != nil {
+		return fmt.Errorf("unable to execute instruction at %d: %w", c.PC, err)
+	}
+	comp, dest, jump := (word>>6)&0b1111111, (word>>3)&0b111, word&0b111
+
+	// The 'a' bit selects whether the ALU 'y' operand is the A register or the M register
+	y := c.A
+	if comp&(1<<6) != 0 {
+		if c.A >= RAMSize {
+			return fmt.Errorf("instruction at %d reads RAM[%d], out of bound", c.PC, c.A)
+		}
+		y = c.RAM[c.A]
+	}
+	out := ALU(c.D, y, comp)
+
+	// Writes are evaluated all at the same time (at the end of the clock cycle), this means
+	// that both the M register and the jump target are addressed by the 'old' A register.
+	address := c.A
+	if dest&0b001 != 0 {
+		if address >= RAMSize {
+			return fmt.Errorf("instruction at %d writes RAM[%d], out of bound", c.PC, address)
+		}
+		c.RAM[address] = out
+	}
+	if dest&0b100 != 0 {
+		c.A = out
+	}
+	if dest&0b010 != 0 {
+		c.D = out
+	}
+
+	// The jump bits are respectively 'out < 0', 'out == 0' and 'out > 0'
+	negative, zero := int16(out) < 0, out == 0
+	positive := !negative && !zero
+	if (jump&0b100 != 0 && negative) || (jump&0b010 != 0 && zero) || (jump&0b001 != 0 && positive) {
+		c.PC = address
+	} else {
+		c.PC++
+	}
+
+	c.Cycles++
+	return nil
+}
+
+// Emulates the Hack ALU, computing the output for the operands 'x' and 'y' based on the
+// 'comp' opcode. Only the 6 control bits (zx, nx, zy, ny, f, no) are taken into account
+// since the selection between the A and M register (the 'a' bit) is done by the caller.
+func ALU(x, y uint16, comp uint16) uint16 {
+	if comp&0b100000 != 0 { // zx: Zeroes the 'x' input
+		x = 0
+	}
+	if comp&0b010000 != 0 { // nx: Negates the 'x' input
+		x = ^x
+	}
+	if comp&0b001000 != 0 { // zy: Zeroes the 'y' input
+		y = 0
+	}
+	if comp&0b000100 != 0 { // ny: Negates the 'y' input
+		y = ^y
+	}
+
+	out := x & y
+	if comp&0b000010 != 0 { // f: Selects between 'x + y' and 'x & y'
+		out = x + y
+	}
+	if comp&0b000001 != 0 { // no: Negates the output
+		out = ^out
+	}
+
+	return out
+}
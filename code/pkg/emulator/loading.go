@@ -0,0 +1,81 @@
+package emulator
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"its-hmny.dev/nand2tetris/pkg/hack"
+)
+
+// ----------------------------------------------------------------------------
+// ROM Loading
+
+// This section defines the different ways to flash a program on the emulator's ROM.
+//
+// The program can be provided either as an in-memory 'hack.Program' (that will be translated
+// to its binary counterpart with the same 'hack.CodeGenerator' used by the assembler) or as
+// the textual '.hack' format where each line contains the 16 bit binary representation of
+// an instruction. Each load will also reset the CPU so that the execution starts from zero.
+
+// Flashes the given list of binary instructions 'words' on the ROM, the remaining part of the
+// ROM (the one not covered by the program) is zeroed out. An error is returned if the program
+// doesn't fit in the ROM.
+func (c *CPU) LoadROM(words []uint16) error {
+	if len(words) > int(ROMSize) {
+		return fmt.Errorf("program too big for the ROM, got %d instructions (max %d)", len(words), ROMSize)
+	}
+
+	clear(c.ROM)
+	copy(c.ROM, words)
+	c.Reset()
+
+	return nil
+}
+
+// Translates the given 'hack.Program' to its binary representation and flashes it on the ROM.
+// The optional Symbol Table 'st' is forwarded as is to the 'hack.CodeGenerator' in order to
+// resolve user-defined labels and variables.
+func (c *CPU) LoadProgram(p hack.Program, st hack.SymbolTable) error {
+	if st == nil { // The codegen will allocate variables on the table, so it cannot be nil
+		st = hack.SymbolTable{}
+	}
+
+	codegen := hack.NewCodeGenerator(p, st)
+	words, err := codegen.Encode()
+	if err != nil {
+		return fmt.Errorf("unable to generate binary for program: %w", err)
+	}
+
+	return c.LoadROM(words)
+}
+
+// Reads from 'r' a program in the textual '.hack' format and returns the list of its binary
+// instructions. Empty lines are skipped while any other malformed line returns an error.
+func ReadHack(r io.Reader) ([]uint16, error) {
+	words, scanner := []uint16{}, bufio.NewScanner(r)
+
+	for lineno := 1; scanner.Scan(); lineno++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if len(line) != 16 {
+			return nil, fmt.Errorf("line %d: expected 16 bit instruction, got '%s'", lineno, line)
+		}
+
+		word, err := strconv.ParseUint(line, 2, 16)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: unable to parse binary instruction '%s'", lineno, line)
+		}
+		words = append(words, uint16(word))
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("cannot read from 'io.Reader': %s", err)
+	}
+
+	return words, nil
+}
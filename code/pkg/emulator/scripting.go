@@ -0,0 +1,121 @@
+package emulator
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+
+	"its-hmny.dev/nand2tetris/pkg/asm"
+)
+
+// ----------------------------------------------------------------------------
+// Test script support
+
+// This section implements the 'tst.Simulator' interface on top of the CPU, this way the test
+// scripts (.tst files) written for the official CPU Emulator can be run against our emulator.
+//
+// The variables available to the scripts are the same ones exposed by the official emulator:
+//   - 'A', 'D' and 'PC': The CPU registers
+//   - 'RAM[n]' and 'ROM[n]': The content of the memories at the given address 'n'
+//
+// Programs can be loaded either as binary '.hack' files or as '.asm' source files, the latter
+// are translated on the fly using the same pipeline (parsing and lowering) of the assembler.
+
+// Matches the memory variables (e.g. 'RAM[42]') extracting the memory name and the address.
+var memoryRegex = regexp.MustCompile(`^(RAM|ROM)\[([0-9]+)\]$`)
+
+// Loads the program at 'path' on the ROM, only the default 'target' is supported.
+func (c *CPU) Load(target, path string) error {
+	if target != "" && target != "ROM32K" {
+		return fmt.Errorf("unable to load program into '%s', only 'ROM32K' is supported", target)
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	switch filepath.Ext(path) {
+	case ".hack":
+		words, err := ReadHack(bytes.NewReader(content))
+		if err != nil {
+			return err
+		}
+		return c.LoadROM(words)
+
+	case ".asm":
+		parser := asm.NewParser(bytes.NewReader(content))
+		asmProgram, err := parser.Parse()
+		if err != nil {
+			return fmt.Errorf("unable to parse program: %w", err)
+		}
+		lowerer := asm.NewLowerer(asmProgram)
+		hackProgram, table, err := lowerer.Lower()
+		if err != nil {
+			return fmt.Errorf("unable to lower program: %w", err)
+		}
+		return c.LoadProgram(hackProgram, table)
+
+	default:
+		return fmt.Errorf("unsupported file extension '%s', expected '.hack' or '.asm'", filepath.Ext(path))
+	}
+}
+
+// Sets the register or memory location 'variable' to 'value'.
+func (c *CPU) Set(variable string, value int16) error {
+	location, err := c.locate(variable)
+	if err != nil {
+		return err
+	}
+
+	*location = uint16(value)
+	return nil
+}
+
+// Returns the current value of the register or memory location 'variable'.
+func (c *CPU) Get(variable string) (int16, error) {
+	location, err := c.locate(variable)
+	if err != nil {
+		return 0, err
+	}
+
+	return int16(*location), nil
+}
+
+// The CPU has no combinational logic to evaluate outside the clock cycle, so this is a no-op.
+func (c *CPU) Eval() error { return nil }
+
+// The whole instruction is executed on the falling edge of the clock, so this is a no-op.
+func (c *CPU) Tick() error { return nil }
+
+// Executes the instruction pointed by the PC, completing the clock cycle.
+func (c *CPU) Tock() error { return c.Step() }
+
+// Resolves the name of a register or memory location to a pointer to its content.
+func (c *CPU) locate(variable string) (*uint16, error) {
+	switch variable {
+	case "A":
+		return &c.A, nil
+	case "D":
+		return &c.D, nil
+	case "PC":
+		return &c.PC, nil
+	}
+
+	match := memoryRegex.FindStringSubmatch(variable)
+	if match == nil {
+		return nil, fmt.Errorf("unrecognized variable '%s'", variable)
+	}
+
+	address, err := strconv.ParseUint(match[2], 10, 16)
+	if err != nil || address >= uint64(RAMSize) {
+		return nil, fmt.Errorf("address '%s' out of bound for variable '%s'", match[2], variable)
+	}
+	if match[1] == "ROM" {
+		return &c.ROM[address], nil
+	}
+	return &c.RAM[address], nil
+}
@@ -2,7 +2,10 @@ package hack
 
 import (
 	"fmt"
+	"maps"
+	"slices"
 	"strconv"
+	"strings"
 )
 
 // ----------------------------------------------------------------------------
@@ -16,6 +19,7 @@ import (
 //  - 'CompTable': Specifies how to translate the 'Comp' opcode in C instructions
 //  - 'DestTable': Specifies how to translate the 'Dest' opcode in C instructions
 //  - 'JumpTable': Specifies how to translate the 'Jump' opcode in C instructions
+//  - 'CompAliases': Specifies the commutative variants allowed for the 'Comp' opcode in C instructions
 
 var (
 	BuiltInTable = map[string]uint16{
@@ -57,8 +61,41 @@ var (
 		"": 0b000, "JGT": 0b001, "JEQ": 0b010, "JGE": 0b011,
 		"JLT": 0b100, "JNE": 0b101, "JLE": 0b110, "JMP": 0b111,
 	}
+
+	// Commutative variants of the 'CompTable' operations, they're kept apart (and not added to the
+	// 'CompTable' itself) so that each opcode keeps being associated to exactly one mnemonic.
+	CompAliases = map[string]string{
+		"A+D": "D+A", "M+D": "D+M", "A&D": "D&A", "M&D": "D&M", "A|D": "D|A", "M|D": "D|M",
+		"1+D": "D+1", "1+A": "A+1", "1+M": "M+1",
+	}
 )
 
+// Resolves 'comp' to the mnemonic used as key in the 'CompTable', be it the mnemonic itself
+// or one of its commutative variants (e.g. 'A+D' is resolved to 'D+A'), if valid at all.
+func CanonicalComp(comp string) (string, bool) {
+	if alias, found := CompAliases[comp]; found {
+		return alias, true
+	}
+	_, found := CompTable[comp]
+	return comp, found
+}
+
+// Resolves 'dest' to the mnemonic used as key in the 'DestTable', since the registers can be listed
+// in any order (e.g. 'DM', 'MA' or 'ADM') each one is allowed at most once, if valid at all.
+func CanonicalDest(dest string) (string, bool) {
+	bits := map[rune]uint16{'A': 0b100, 'D': 0b010, 'M': 0b001}
+
+	opcode := uint16(0)
+	for _, register := range dest {
+		bit, found := bits[register]
+		if !found || opcode&bit != 0 {
+			return dest, false
+		}
+		opcode |= bit
+	}
+	return DestOpcodes[opcode], true
+}
+
 // ----------------------------------------------------------------------------
 // Code Generator
 
@@ -66,12 +103,26 @@ var (
 //
 // In order to resolve user defined labels in A instructions, during initialization of
 // of the Code Generator a Symbol Table should be provided.
+//
+// By default any label not found in the Symbol Table is allocated as a variable, in strict mode
+// (see 'NewStrictCodeGenerator') the following safeguards are in place:
+// - A label loaded right before a jump has to be declared, a typo there is not a variable
+// - The variables cannot be more than a given limit (e.g. to avoid overflowing into the stack)
+// - A warning is reported for each variable whose name is near-identical to a declared label
 type CodeGenerator struct {
 	program    Program     // The set of instructions to convert in Hack binary format
 	table      SymbolTable // Mapping to resolve user-defined labels to their underlying address
 	nVarOffset uint16      // Internal offset to allocate memory for new variables
+
+	strict   bool        // Enables the safeguards listed above
+	labels   SymbolTable // The labels declared in the program (the table before any allocation)
+	maxVars  uint16      // The max number of variables allocated in strict mode
+	warnings []string    // The warnings reported in strict mode
 }
 
+// The default limit of variables in strict mode, the ones past it would overflow into the stack.
+const DefaultMaxVariables uint16 = 256 - 16
+
 // Initializes and returns to the caller a brand new 'CodeGenerator' struct.
 // Requires both a non-nil Program 'p' (what we want to translate) as well as
 // an optionally nullable Symbol Table 'st' used to resolve user defined labels.
@@ -79,32 +130,66 @@ func NewCodeGenerator(p Program, st SymbolTable) CodeGenerator {
 	return CodeGenerator{program: p, table: st}
 }
 
+// Initializes and returns to the caller a brand new 'CodeGenerator' struct in strict mode, at most
+// 'maxVars' variables will be allocated (if zero then 'DefaultMaxVariables' is used instead).
+// Requires the Symbol Table 'st' to already contain all the labels declared in the program.
+func NewStrictCodeGenerator(p Program, st SymbolTable, maxVars uint16) CodeGenerator {
+	if maxVars == 0 {
+		maxVars = DefaultMaxVariables
+	}
+	return CodeGenerator{program: p, table: st, strict: true, labels: maps.Clone(st), maxVars: maxVars}
+}
+
+// Returns the warnings reported so far, only the strict mode reports them.
+func (cg *CodeGenerator) Warnings() []string { return cg.warnings }
+
 // Translates each instruction in the 'Program' to the Hack binary format.
 //
 // Each instruction will pass through the following step: evaluation, validation and then conversion
 // to its binary representation (stored inside a uint16) so that it can be further elaborated by the
 // function caller (e.g. dumping .hack code to a file, runtime interpretation, ...).
 func (cg *CodeGenerator) Generate() ([]string, error) {
-	hack := make([]string, 0, len(cg.program))
+	words, err := cg.Encode()
+	if err != nil {
+		return nil, err
+	}
+
+	hack := make([]string, 0, len(words))
+	for _, word := range words {
+		hack = append(hack, fmt.Sprintf("%016b", word))
+	}
+
+	return hack, nil
+}
 
-	for _, instruction := range cg.program {
-		var generated string = ""
+// Same as 'Generate' but the instructions are returned as numeric words instead of their textual
+// representation, to be used by the encoders of the other output formats (see 'encoding.go').
+func (cg *CodeGenerator) Encode() ([]uint16, error) {
+	words := make([]uint16, 0, len(cg.program))
+
+	for i, instruction := range cg.program {
+		var encoded uint16 = 0
 		var err error = nil
 
 		switch tInstruction := instruction.(type) {
 		case AInstruction:
-			generated, err = cg.GenerateAInst(tInstruction)
+			if err = cg.checkJumpTarget(tInstruction, i); err != nil {
+				break
+			}
+			encoded, err = cg.EncodeAInst(tInstruction)
 		case CInstruction:
-			generated, err = cg.GenerateCInst(tInstruction)
+			encoded, err = cg.EncodeCInst(tInstruction)
+		default: // Error case, unrecognized instruction type
+			err = fmt.Errorf("unrecognized instruction '%T'", instruction)
 		}
 
 		if err != nil {
 			return nil, err
 		}
-		hack = append(hack, generated)
+		words = append(words, encoded)
 	}
 
-	return hack, nil
+	return words, nil
 }
 
 // Specialized function to convert an A Instruction to the Hack format.
@@ -113,6 +198,43 @@ func (cg *CodeGenerator) Generate() ([]string, error) {
 // on their respective symbol tables in order to determine the 'real' location address.
 // For location not resolved or resolved to an Out-of-Bound address an error is returned.
 func (cg *CodeGenerator) GenerateAInst(inst AInstruction) (string, error) {
+	word, err := cg.EncodeAInst(inst)
+	if err != nil {
+		return "", err
+	}
+	// So here we just need to convert the address to its 16 bit binary representation
+	return fmt.Sprintf("%016b", word), nil
+}
+
+// Same as 'GenerateAInst' but returns the numeric word instead of its textual representation.
+// The errors carry the position of the instruction in the source code, when available.
+func (cg *CodeGenerator) EncodeAInst(inst AInstruction) (uint16, error) {
+	if inst.LocType == Compute { // Constant expressions are evaluated and checked as a whole
+		value, err := cg.Evaluate(inst.Expr)
+		if err != nil {
+			return 0, inst.Position.Errorf("unable to evaluate expression '%s': %s", inst.LocName, err)
+		}
+		if value < 0 || value >= int(MaxAddressableMemory) {
+			return 0, inst.Position.Errorf("expression '%s' resolved to %d, beyond the addressable memory", inst.LocName, value)
+		}
+		return uint16(value), nil
+	}
+
+	address, err := cg.resolve(inst)
+	if err != nil {
+		return 0, inst.Position.Wrap(err)
+	}
+	// An A instruction always has the first bit set to zero (the opcode bit) this also mean
+	// that, since each instructions 16 bit there are only 15 bit to address the Hack computer
+	// memory this in turn means that the an address over 2^15 is invalid and out of bound.
+	if address > MaxAddressableMemory {
+		return 0, inst.Position.Errorf("location '%s resolved to an address not allowed", inst.LocName)
+	}
+	return address, nil
+}
+
+// Resolves the location of a (non 'Compute') A Instruction to its address.
+func (cg *CodeGenerator) resolve(inst AInstruction) (uint16, error) {
 	found, address := false, uint16(0)
 
 	switch inst.LocType {
@@ -123,28 +245,157 @@ func (cg *CodeGenerator) GenerateAInst(inst AInstruction) (string, error) {
 		address, found = cg.table[inst.LocName]
 		// If not found we treat it as a new variable
 		if !found {
-			// Assign a new memory location starting from 16 onwards
-			address, found = 16+cg.nVarOffset, true
-			// And update the SymbolTable so that future references
-			// gets resolved/points to the same locations in RAM
-			cg.table[inst.LocName] = address
-			cg.nVarOffset++
+			return cg.allocate(inst.LocName)
 		}
 	case BuiltIn: // Lookup the registry name in the WellKnow table
 		address, found = BuiltInTable[inst.LocName]
 	}
 
 	if !found {
-		return "", fmt.Errorf("unable to resolve address for location '%s'", inst.LocName)
+		return 0, fmt.Errorf("unable to resolve address for location '%s'", inst.LocName)
 	}
-	// An A instruction always has the first bit set to zero (the opcode bit) this also mean
-	// that, since each instructions 16 bit there are only 15 bit to address the Hack computer
-	// memory this in turn means that the an address over 2^15 is invalid and out of bound.
-	if address > MaxAddressableMemory {
-		return "", fmt.Errorf("location '%s resolved to an address not allowed", inst.LocName)
+	return address, nil
+}
+
+// Allocates a new variable named 'name', in strict mode the limit of variables is enforced.
+func (cg *CodeGenerator) allocate(name string) (uint16, error) {
+	if cg.strict && cg.nVarOffset >= cg.maxVars {
+		return 0, fmt.Errorf("unable to allocate variable '%s', the limit of %d variables is exceeded", name, cg.maxVars)
+	}
+	if cg.strict {
+		for _, label := range slices.Sorted(maps.Keys(cg.labels)) { // Sorted to report them in a stable order
+			if nearlyIdentical(name, label) {
+				cg.warnings = append(cg.warnings, fmt.Sprintf("variable '%s' has a name near-identical to label '%s', is it a typo?", name, label))
+			}
+		}
+	}
+
+	// Assign a new memory location starting from 16 onwards
+	address := 16 + cg.nVarOffset
+	// And update the SymbolTable so that future references
+	// gets resolved/points to the same locations in RAM
+	cg.table[name] = address
+	cg.nVarOffset++
+
+	return address, nil
+}
+
+// In strict mode, checks that the A Instruction at index 'i' does not load an undeclared label
+// right before a jump (e.g. '@LOPP' followed by '0;JMP'), otherwise it would become a variable.
+// For constant expressions (e.g. '@LOPP+2') every label used as operand is checked.
+func (cg *CodeGenerator) checkJumpTarget(inst AInstruction, i int) error {
+	if !cg.strict || (inst.LocType != Label && inst.LocType != Compute) || i+1 >= len(cg.program) {
+		return nil
+	}
+	if next, isCInst := cg.program[i+1].(CInstruction); !isCInst || next.Jump == "" {
+		return nil
+	}
+	if inst.LocType == Compute {
+		return inst.Position.Wrap(cg.checkJumpExpr(inst.Expr))
+	}
+	if _, declared := cg.labels[inst.LocName]; !declared {
+		return inst.Position.Errorf("jump to undeclared label '%s'", inst.LocName)
+	}
+	return nil
+}
+
+// Checks that every label used in the jump target expression 'expr' is declared.
+func (cg *CodeGenerator) checkJumpExpr(expr Expression) error {
+	switch tExpr := expr.(type) {
+	case AInstruction:
+		if _, declared := cg.labels[tExpr.LocName]; tExpr.LocType == Label && !declared {
+			return fmt.Errorf("jump to undeclared label '%s'", tExpr.LocName)
+		}
+	case BinaryExpr:
+		if err := cg.checkJumpExpr(tExpr.Lhs); err != nil {
+			return err
+		}
+		return cg.checkJumpExpr(tExpr.Rhs)
+	}
+	return nil
+}
+
+// Returns whether two (different) names are near-identical: either they differ only in case or
+// one can be obtained from the other with a single edit (insertion, deletion or substitution).
+func nearlyIdentical(a string, b string) bool {
+	if a == b {
+		return false
+	}
+	if strings.EqualFold(a, b) {
+		return true
+	}
+	if min(len(a), len(b)) < 3 { // Short names differ by a single edit way too often
+		return false
+	}
+
+	if len(a) > len(b) {
+		a, b = b, a
+	}
+	if len(b)-len(a) > 1 {
+		return false
+	}
+
+	// Skips the common prefix, then the rest should match once the different character is skipped
+	i := 0
+	for i < len(a) && a[i] == b[i] {
+		i++
+	}
+	if len(a) == len(b) {
+		return a[i+1:] == b[i+1:]
+	}
+	return a[i:] == b[i+1:]
+}
+
+// Evaluates a constant expression, the operands are resolved as any other A Instruction location
+// (so a label not declared is allocated as a variable). The intermediate results are not bounded.
+func (cg *CodeGenerator) Evaluate(expr Expression) (int, error) {
+	switch tExpr := expr.(type) {
+	case AInstruction:
+		if tExpr.LocType == Raw { // Literals are not addresses, so negative values are allowed (e.g. 'KBD+-1')
+			value, err := strconv.Atoi(tExpr.LocName)
+			if err != nil {
+				return 0, fmt.Errorf("invalid literal '%s'", tExpr.LocName)
+			}
+			return value, nil
+		}
+		address, err := cg.resolve(tExpr)
+		if err != nil {
+			return 0, err
+		}
+		return int(address), nil
+
+	case BinaryExpr:
+		lhs, err := cg.Evaluate(tExpr.Lhs)
+		if err != nil {
+			return 0, err
+		}
+		rhs, err := cg.Evaluate(tExpr.Rhs)
+		if err != nil {
+			return 0, err
+		}
+
+		switch tExpr.Operator {
+		case "+":
+			return lhs + rhs, nil
+		case "-":
+			return lhs - rhs, nil
+		case "*":
+			return lhs * rhs, nil
+		case "/":
+			if rhs == 0 {
+				return 0, fmt.Errorf("division by zero")
+			}
+			return lhs / rhs, nil
+		case "&":
+			return lhs & rhs, nil
+		case "|":
+			return lhs | rhs, nil
+		}
+		return 0, fmt.Errorf("unknown operator '%s'", tExpr.Operator)
+
+	default: // Error case, unrecognized expression type
+		return 0, fmt.Errorf("unrecognized expression '%T'", expr)
 	}
-	// So here we just need to convert the address to its 16 bit binary representation
-	return fmt.Sprintf("%016b", address), nil
 }
 
 // Specialized function to convert a C Instruction to the Hack format.
@@ -153,33 +404,42 @@ func (cg *CodeGenerator) GenerateAInst(inst AInstruction) (string, error) {
 // on their respective symbol tables in order to determine the 'real' location address.
 // For location not resolved or resolved to an Out-of-Bound address an error is returned.
 func (cg *CodeGenerator) GenerateCInst(inst CInstruction) (string, error) {
+	word, err := cg.EncodeCInst(inst)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%016b", word), nil
+}
+
+// Same as 'GenerateCInst' but returns the numeric word instead of its textual representation.
+func (cg *CodeGenerator) EncodeCInst(inst CInstruction) (uint16, error) {
 	command := uint16(0b111 << 13) // Puts the initial '111' opcode at the start
 
 	// Since the 'Comp' bit-codes are the only ones mandatory we check before translation
 	// that the are provided, the check on their well-formed(ness) will come when querying
 	// the translation mappings (this also applies to 'Dest' and 'Jump' bit-codes).
-	if _, found := CompTable[inst.Comp]; inst.Comp == "" || !found {
-		return "", fmt.Errorf("unable to translate C instruction, missing or invalid operation code")
+	if _, found := CanonicalComp(inst.Comp); inst.Comp == "" || !found {
+		return 0, fmt.Errorf("unable to translate C instruction, missing or invalid operation code")
 	}
 
 	// CInst.Comp: Command translation with bit-a-bit manipulation
-	if opcode, found := CompTable[inst.Comp]; found {
-		command |= opcode << 6
+	if comp, found := CanonicalComp(inst.Comp); found {
+		command |= CompTable[comp] << 6
 	} else {
-		return "", fmt.Errorf("unable to translate C instruction, unknown 'comp' opcode '%s'", inst.Comp)
+		return 0, fmt.Errorf("unable to translate C instruction, unknown 'comp' opcode '%s'", inst.Comp)
 	}
 	// CInst.Dest: Command translation with bit-a-bit manipulation
-	if opcode, found := DestTable[inst.Dest]; found {
-		command |= opcode << 3
+	if dest, found := CanonicalDest(inst.Dest); found {
+		command |= DestTable[dest] << 3
 	} else {
-		return "", fmt.Errorf("unable to translate C instruction, unknown 'dest' opcode '%s'", inst.Dest)
+		return 0, fmt.Errorf("unable to translate C instruction, unknown 'dest' opcode '%s'", inst.Dest)
 	}
 	// CInst.Jump: Command translation with bit-a-bit manipulation
 	if opcode, found := JumpTable[inst.Jump]; found {
 		command |= opcode
 	} else {
-		return "", fmt.Errorf("unable to translate C instruction, unknown 'jump' opcode '%s'", inst.Jump)
+		return 0, fmt.Errorf("unable to translate C instruction, unknown 'jump' opcode '%s'", inst.Jump)
 	}
 
-	return fmt.Sprintf("%016b", command), nil
+	return command, nil
 }
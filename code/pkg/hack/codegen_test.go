@@ -2,9 +2,11 @@ package hack_test
 
 import (
 	"fmt"
+	"slices"
 	"testing"
 
 	"its-hmny.dev/nand2tetris/pkg/hack"
+	"its-hmny.dev/nand2tetris/pkg/utils"
 )
 
 func TestAInstructions(t *testing.T) {
@@ -87,6 +89,46 @@ func TestAInstructions(t *testing.T) {
 		test(hack.AInstruction{LocType: hack.Label, LocName: "NEXT"}, fmt.Sprintf("%016b", 18), true)
 		test(hack.AInstruction{LocType: hack.Label, LocName: "DUNNO"}, fmt.Sprintf("%016b", 19), true)
 	})
+
+	t.Run("Constant expressions", func(t *testing.T) {
+		raw := func(value string) hack.AInstruction { return hack.AInstruction{LocType: hack.Raw, LocName: value} }
+		label := func(name string) hack.AInstruction { return hack.AInstruction{LocType: hack.Label, LocName: name} }
+		screen := hack.AInstruction{LocType: hack.BuiltIn, LocName: "SCREEN"}
+
+		compute := func(expr hack.Expression, expected int) {
+			inst := hack.AInstruction{LocType: hack.Compute, LocName: fmt.Sprint(expr), Expr: expr}
+			res, err := codegen.GenerateAInst(inst)
+			if expected < 0 && err == nil {
+				t.Fatalf("Expected error for %+v, got '%s'", expr, res)
+			}
+			if expected >= 0 && (err != nil || res != fmt.Sprintf("%016b", expected)) {
+				t.Fatalf("Expected %016b for %+v, got '%s' (%v)", expected, expr, res, err)
+			}
+		}
+
+		compute(hack.BinaryExpr{Operator: "+", Lhs: screen, Rhs: raw("32")}, 16384+32)
+		compute(hack.BinaryExpr{Operator: "*", Lhs: raw("256"), Rhs: raw("2")}, 512)
+		compute(hack.BinaryExpr{Operator: "/", Lhs: label("Test2"), Rhs: raw("2")}, 33)
+		compute(hack.BinaryExpr{Operator: "|", Lhs: raw("8"), Rhs: hack.BinaryExpr{Operator: "&", Lhs: label("JUMP"), Rhs: raw("7")}}, 10)
+		// Negative intermediate results are allowed, as long as the final one is addressable
+		compute(hack.BinaryExpr{Operator: "-", Lhs: hack.BinaryExpr{Operator: "-", Lhs: raw("1"), Rhs: raw("5")}, Rhs: raw("-10")}, 6)
+		// The labels not declared are allocated as variables, just like the standalone ones
+		compute(hack.BinaryExpr{Operator: "+", Lhs: label("BUFFER"), Rhs: raw("3")}, 20+3)
+		compute(hack.BinaryExpr{Operator: "+", Lhs: label("BUFFER"), Rhs: raw("1")}, 20+1)
+
+		compute(hack.BinaryExpr{Operator: "*", Lhs: screen, Rhs: raw("2")}, -1)   // Beyond the addressable memory
+		compute(hack.BinaryExpr{Operator: "-", Lhs: raw("0"), Rhs: raw("1")}, -1) // Negative address
+		compute(hack.BinaryExpr{Operator: "/", Lhs: screen, Rhs: raw("0")}, -1)   // Division by zero
+		compute(hack.BinaryExpr{Operator: "%", Lhs: screen, Rhs: raw("2")}, -1)   // Unknown operator
+
+		// The errors point to the instruction in the source code, whenever its position is known
+		inst := hack.AInstruction{LocType: hack.Compute, LocName: "32767+1", Position: utils.Position{File: "Main.asm", Line: 3, Column: 1},
+			Expr: hack.BinaryExpr{Operator: "+", Lhs: raw("32767"), Rhs: raw("1")}}
+		expected := "Main.asm:3:1: expression '32767+1' resolved to 32768, beyond the addressable memory"
+		if _, err := codegen.EncodeAInst(inst); err == nil || err.Error() != expected {
+			t.Fatalf("Expected error '%s', got %v", expected, err)
+		}
+	})
 }
 
 func TestCInstructions(t *testing.T) {
@@ -152,4 +194,101 @@ func TestCInstructions(t *testing.T) {
 		test(hack.CInstruction{Comp: "D", Dest: "AMD"}, "1110001100111000", false)
 		test(hack.CInstruction{Comp: "A", Dest: "AMD"}, "1110110000111000", false)
 	})
+
+	t.Run("Aliases and permutations", func(t *testing.T) {
+		// Commutative 'comp' aliases are translated as their counterpart in the 'CompTable'
+		test(hack.CInstruction{Comp: "A+D", Dest: "D"}, "1110000010010000", false)
+		test(hack.CInstruction{Comp: "M&D", Dest: "D"}, "1111000000010000", false)
+		test(hack.CInstruction{Comp: "1+M", Dest: "M"}, "1111110111001000", false)
+		// Every ordering of the 'dest' registers is translated the same way
+		test(hack.CInstruction{Comp: "0", Dest: "DM"}, "1110101010011000", false)
+		test(hack.CInstruction{Comp: "0", Dest: "MA"}, "1110101010101000", false)
+		test(hack.CInstruction{Comp: "0", Dest: "DMA", Jump: "JMP"}, "1110101010111111", false)
+		// Registers listed more than once are not allowed
+		test(hack.CInstruction{Comp: "0", Dest: "DD"}, "", true)
+	})
+}
+
+func TestStrictMode(t *testing.T) {
+	label := func(name string) hack.AInstruction { return hack.AInstruction{LocType: hack.Label, LocName: name} }
+	jump := hack.CInstruction{Comp: "0", Jump: "JMP"}
+	store := hack.CInstruction{Comp: "D", Dest: "M"}
+
+	t.Run("Jump targets", func(t *testing.T) {
+		// The typo in the jump target ('LOPP') is a variable in the default mode, an error in strict mode
+		program := hack.Program{label("LOOP"), jump, label("LOPP"), jump}
+
+		codegen := hack.NewCodeGenerator(program, hack.SymbolTable{"LOOP": 0})
+		if _, err := codegen.Encode(); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		codegen = hack.NewStrictCodeGenerator(program, hack.SymbolTable{"LOOP": 0}, 0)
+		if _, err := codegen.Encode(); err == nil {
+			t.Fatal("Expected error for jump to undeclared label 'LOPP', got nil")
+		}
+
+		// The error points to the A Instruction in the source code, whenever its position is known
+		typo := hack.AInstruction{LocType: hack.Label, LocName: "LOPP", Position: utils.Position{File: "Typo.asm", Line: 2, Column: 1}}
+		codegen = hack.NewStrictCodeGenerator(hack.Program{label("LOOP"), jump, typo, jump}, hack.SymbolTable{"LOOP": 0}, 0)
+		if _, err := codegen.Encode(); err == nil || err.Error() != "Typo.asm:2:1: jump to undeclared label 'LOPP'" {
+			t.Fatalf("Expected error at 'Typo.asm:2:1' for jump to undeclared label 'LOPP', got %v", err)
+		}
+
+		// Same goes for the labels used in a constant expression (e.g. '@LOPP+2')
+		expr := hack.AInstruction{LocType: hack.Compute, LocName: "LOPP+2", Expr: hack.BinaryExpr{
+			Operator: "+", Lhs: label("LOPP"), Rhs: hack.AInstruction{LocType: hack.Raw, LocName: "2"},
+		}}
+		codegen = hack.NewStrictCodeGenerator(hack.Program{label("LOOP"), jump, expr, jump}, hack.SymbolTable{"LOOP": 0}, 0)
+		if _, err := codegen.Encode(); err == nil {
+			t.Fatal("Expected error for jump to undeclared label 'LOPP' in expression, got nil")
+		}
+	})
+
+	t.Run("Variables limit", func(t *testing.T) {
+		program := hack.Program{label("a"), store, label("b"), store, label("a"), store}
+
+		codegen := hack.NewStrictCodeGenerator(program, hack.SymbolTable{}, 2)
+		if _, err := codegen.Encode(); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		codegen = hack.NewStrictCodeGenerator(append(program, label("c"), store), hack.SymbolTable{}, 2)
+		if _, err := codegen.Encode(); err == nil {
+			t.Fatal("Expected error for the third variable 'c', got nil")
+		}
+
+		// By default the variables can go up to the stack (from 16 to 255)
+		program = hack.Program{}
+		for i := range int(hack.DefaultMaxVariables) + 1 {
+			program = append(program, label(fmt.Sprintf("var%d", i)), store)
+		}
+		codegen = hack.NewStrictCodeGenerator(program, hack.SymbolTable{}, 0)
+		if _, err := codegen.Encode(); err == nil {
+			t.Fatal("Expected error for the variable overflowing into the stack, got nil")
+		}
+	})
+
+	t.Run("Near-identical names", func(t *testing.T) {
+		program := hack.Program{label("loop"), store, label("OUTPT"), store, label("EN"), store, label("counter"), store, label("LOOP"), jump}
+
+		codegen := hack.NewStrictCodeGenerator(program, hack.SymbolTable{"LOOP": 0, "OUTPUT": 2, "END": 4}, 0)
+		if _, err := codegen.Encode(); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		// Only 'loop' (case) and 'OUTPT' (deletion) are reported, 'EN' is too short to be meaningful
+		if warnings := codegen.Warnings(); len(warnings) != 2 {
+			t.Fatalf("Expected 2 warnings, got %v", warnings)
+		}
+
+		// With more than one near-identical label the warnings are always reported in the same order
+		program = hack.Program{label("LOOq"), store}
+		for range 10 {
+			codegen := hack.NewStrictCodeGenerator(program, hack.SymbolTable{"LOOP": 0, "LOOQ": 2, "LOOR": 4, "LOO": 6}, 0)
+			if _, err := codegen.Encode(); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if warnings := codegen.Warnings(); len(warnings) != 4 || !slices.IsSorted(warnings) {
+				t.Fatalf("Expected 4 sorted warnings, got %v", warnings)
+			}
+		}
+	})
 }
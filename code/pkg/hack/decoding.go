@@ -0,0 +1,115 @@
+package hack
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"its-hmny.dev/nand2tetris/pkg/utils"
+)
+
+// ----------------------------------------------------------------------------
+// Reverse translation tables
+
+// This section contains the inverse of the translation tables used by the codegen phase.
+//
+// Each one maps the bit-codes back to the mnemonic they are generated from, since the original
+// tables are bijective no information is lost during the inversion. They're used in the decoding
+// phase to convert the binary instructions back to their in-memory representation.
+
+var (
+	CompOpcodes = invert(CompTable) // Resolves the 'comp' bit-codes (including the 'a' bit) to their mnemonic
+	DestOpcodes = invert(DestTable) // Resolves the 'dest' bit-codes to their mnemonic
+	JumpOpcodes = invert(JumpTable) // Resolves the 'jump' bit-codes to their mnemonic
+)
+
+func invert(table map[string]uint16) map[uint16]string {
+	inverted := make(map[uint16]string, len(table))
+	for mnemonic, opcode := range table {
+		inverted[opcode] = mnemonic
+	}
+	return inverted
+}
+
+// ----------------------------------------------------------------------------
+// Decoder
+
+// Takes the textual Hack binary format and produces its 'hack.Program' counterpart.
+//
+// The format is the one generated by the 'CodeGenerator': one instruction per line, each one
+// represented by exactly 16 characters ('0' or '1'), blank lines are allowed and simply skipped.
+// Since the binary format has no notion of labels every A Instruction decoded is a 'Raw' one.
+type Decoder struct {
+	reader io.Reader // The source of the binary program to be decoded
+	name   string    // The name of the source file, used in the position of the errors
+}
+
+// Initializes and returns to the caller a brand new 'Decoder' struct.
+// Requires the argument io.Reader 'r' to be valid and usable.
+func NewDecoder(r io.Reader) Decoder {
+	return Decoder{reader: r}
+}
+
+// Initializes and returns to the caller a brand new 'Decoder' struct, the errors
+// will reference 'name' as their source file (e.g. 'Max.hack:12:1') in their position.
+func NewFileDecoder(r io.Reader, name string) Decoder {
+	return Decoder{reader: r, name: name}
+}
+
+// Decodes the instructions line by line, returns an error that points to the offending line
+// if the latter is not a well-formed 16 bit word or does not encode a valid Hack instruction.
+func (d *Decoder) Decode() (Program, error) {
+	program, scanner := Program{}, bufio.NewScanner(d.reader)
+
+	for line := 1; scanner.Scan(); line++ {
+		position := utils.Position{File: d.name, Line: line, Column: 1}
+
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+
+		word, err := strconv.ParseUint(text, 2, 16)
+		if err != nil || len(text) != 16 {
+			return nil, position.Errorf("expected a 16 bit binary word, got '%s'", text)
+		}
+
+		instruction, err := DecodeInstruction(uint16(word))
+		if err != nil {
+			return nil, position.Wrap(err)
+		}
+		program = append(program, instruction)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("cannot read from 'io.Reader': %s", err)
+	}
+	return program, nil
+}
+
+// Decodes a single 16 bit word to either an 'AInstruction' or a 'CInstruction'.
+//
+// This is the exact opposite of what 'GenerateAInst' and 'GenerateCInst' do: the opcode bit
+// tells the instruction type, then the A Instruction payload is the address while for the
+// C Instruction each group of bits is looked up in the respective reverse translation table.
+func DecodeInstruction(word uint16) (Instruction, error) {
+	// The A instruction has the opcode bit set to zero, the other 15 bits are the address
+	if word&(1<<15) == 0 {
+		return AInstruction{LocType: Raw, LocName: strconv.Itoa(int(word))}, nil
+	}
+
+	// The C instruction has the first 3 bits always set to one (the 2 after the opcode are unused)
+	if word>>13 != 0b111 {
+		return nil, fmt.Errorf("unable to decode C instruction '%016b', expected '111' prefix", word)
+	}
+
+	comp, found := CompOpcodes[(word>>6)&0b1111111]
+	if !found {
+		return nil, fmt.Errorf("unable to decode C instruction '%016b', unknown 'comp' opcode '%07b'", word, (word>>6)&0b1111111)
+	}
+
+	// Every 3 bit combination is valid for both 'dest' and 'jump' so the lookup cannot fail
+	return CInstruction{Comp: comp, Dest: DestOpcodes[(word>>3)&0b111], Jump: JumpOpcodes[word&0b111]}, nil
+}
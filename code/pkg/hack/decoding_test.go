@@ -0,0 +1,71 @@
+package hack_test
+
+import (
+	"strings"
+	"testing"
+
+	"its-hmny.dev/nand2tetris/pkg/hack"
+)
+
+func TestDecodeInstruction(t *testing.T) {
+	test := func(word uint16, expected hack.Instruction, fail bool) {
+		decoded, err := hack.DecodeInstruction(word)
+		if (err != nil) != fail {
+			t.Fatalf("Unexpected error for word '%016b': %v", word, err)
+		}
+		if !fail && decoded != expected {
+			t.Fatalf("Expected %+v for word '%016b', got %+v", expected, word, decoded)
+		}
+	}
+
+	t.Run("A Instructions", func(t *testing.T) {
+		test(0b0000000000000000, hack.AInstruction{LocType: hack.Raw, LocName: "0"}, false)
+		test(0b0100000000000000, hack.AInstruction{LocType: hack.Raw, LocName: "16384"}, false)
+		test(0b0111111111111111, hack.AInstruction{LocType: hack.Raw, LocName: "32767"}, false)
+	})
+
+	t.Run("C Instructions", func(t *testing.T) {
+		test(0b1110110000010000, hack.CInstruction{Comp: "A", Dest: "D"}, false)
+		test(0b1111110111001000, hack.CInstruction{Comp: "M+1", Dest: "M"}, false)
+		test(0b1110001100000001, hack.CInstruction{Comp: "D", Jump: "JGT"}, false)
+		test(0b1110101010000111, hack.CInstruction{Comp: "0", Jump: "JMP"}, false)
+		test(0b1111000010111000, hack.CInstruction{Comp: "D+M", Dest: "AMD"}, false)
+	})
+
+	t.Run("Invalid instructions", func(t *testing.T) {
+		test(0b1000110000010000, nil, true) // Missing the '111' prefix
+		test(0b1110000001010000, nil, true) // Unknown 'comp' opcode
+		test(0b1111101010010000, nil, true) // Unknown 'comp' opcode ('0' w/ the 'a' bit set)
+	})
+
+	// Every instruction generated from the translation tables is decoded back to itself
+	t.Run("Round trip", func(t *testing.T) {
+		codegen := hack.NewCodeGenerator(hack.Program{}, hack.SymbolTable{})
+		for comp := range hack.CompTable {
+			for dest := range hack.DestTable {
+				for jump := range hack.JumpTable {
+					inst := hack.CInstruction{Comp: comp, Dest: dest, Jump: jump}
+					generated, _ := codegen.GenerateCInst(inst)
+					decoder := hack.NewDecoder(strings.NewReader(generated))
+					program, err := decoder.Decode()
+					if err != nil || len(program) != 1 || program[0] != inst {
+						t.Fatalf("Expected %+v to be decoded from '%s', got %+v (%v)", inst, generated, program, err)
+					}
+				}
+			}
+		}
+	})
+}
+
+func TestDecoder(t *testing.T) {
+	decoder := hack.NewFileDecoder(strings.NewReader("0000000000000010\n\n1110110000010000\n1110001100000001 \n"), "Test.hack")
+	program, err := decoder.Decode()
+	if err != nil || len(program) != 3 {
+		t.Fatalf("Expected 3 instructions, got %d (%v)", len(program), err)
+	}
+
+	decoder = hack.NewFileDecoder(strings.NewReader("0000000000000010\n111011000001000\n"), "Test.hack")
+	if _, err := decoder.Decode(); err == nil || !strings.HasPrefix(err.Error(), "Test.hack:2:1: expected a 16 bit binary word") {
+		t.Fatalf("Expected error at 'Test.hack:2:1', got %v", err)
+	}
+}
@@ -0,0 +1,138 @@
+package hack
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// ----------------------------------------------------------------------------
+// Output formats
+
+// This section contains the encoders for the formats a compiled Hack program can be exported to.
+//
+// The textual '.hack' format (one '%016b' word per line) is the one used by the nand2tetris tools, the
+// other ones are meant to load the program in other simulators, in FPGA ROM initializers or to embed
+// it in another Go program. Each encoder takes the numeric words produced by 'CodeGenerator.Encode'.
+
+// An Encoder writes the binary 'words' of a program to 'w' in a specific format.
+type Encoder func(w io.Writer, words []uint16) error
+
+// Writes the textual '.hack' format, the same produced by 'CodeGenerator.Generate'.
+func EncodeHack(w io.Writer, words []uint16) error {
+	return writeLines(w, "", words, "%016b\n")
+}
+
+// Writes the words as a raw binary image, each word is 2 bytes in big-endian order.
+func EncodeRaw(w io.Writer, words []uint16) error {
+	return binary.Write(w, binary.BigEndian, words)
+}
+
+// Writes the Intel HEX format, each data record holds up to 8 words (16 bytes) in big-endian order
+// and the addresses are expressed in bytes, as most ROM programmers do. Since the Hack ROM is 32K
+// words long the whole program fits in the 16 bit address space, so no extended record is needed.
+func EncodeIntelHex(w io.Writer, words []uint16) error {
+	if len(words) > int(MaxAddressableMemory) {
+		return fmt.Errorf("program too big for Intel HEX format, got %d words (max %d)", len(words), MaxAddressableMemory)
+	}
+
+	writer := bufio.NewWriter(w)
+	for start := 0; start < len(words); start += 8 {
+		data := []byte{}
+		for _, word := range words[start:min(start+8, len(words))] {
+			data = binary.BigEndian.AppendUint16(data, word)
+		}
+		writeHexRecord(writer, uint16(start*2), 0x00, data)
+	}
+	writeHexRecord(writer, 0, 0x01, nil) // End Of File record
+
+	return writer.Flush()
+}
+
+// Writes a single Intel HEX record, the checksum is the two's complement of the sum of its bytes.
+func writeHexRecord(w *bufio.Writer, address uint16, kind byte, data []byte) {
+	checksum := byte(len(data)) + byte(address>>8) + byte(address) + kind
+	fmt.Fprintf(w, ":%02X%04X%02X", len(data), address, kind)
+	for _, b := range data {
+		fmt.Fprintf(w, "%02X", b)
+		checksum += b
+	}
+	fmt.Fprintf(w, "%02X\n", -checksum)
+}
+
+// Writes the 'v2.0 raw' image format used by the Logisim ROM/RAM components. The words are written
+// in hexadecimal (8 per line), the runs of 4 or more equal words use the 'count*value' shorthand.
+func EncodeLogisim(w io.Writer, words []uint16) error {
+	writer := bufio.NewWriter(w)
+	fmt.Fprintln(writer, "v2.0 raw")
+
+	for i, column := 0, 0; i < len(words); column++ {
+		run := 1
+		for i+run < len(words) && words[i+run] == words[i] {
+			run++
+		}
+		if run < 4 { // Short runs are cheaper to write one word at a time
+			run = 1
+		}
+
+		if column > 0 && column%8 == 0 {
+			fmt.Fprintln(writer)
+		} else if column > 0 {
+			fmt.Fprint(writer, " ")
+		}
+		if run > 1 {
+			fmt.Fprintf(writer, "%d*%x", run, words[i])
+		} else {
+			fmt.Fprintf(writer, "%x", words[i])
+		}
+		i += run
+	}
+	fmt.Fprintln(writer)
+
+	return writer.Flush()
+}
+
+// Writes a memory file for the Verilog '$readmemb' system task, one binary word per line.
+func EncodeReadMemB(w io.Writer, words []uint16) error {
+	return writeLines(w, "// Hack program, load with $readmemb\n", words, "%016b\n")
+}
+
+// Writes a memory file for the Verilog '$readmemh' system task, one hexadecimal word per line.
+func EncodeReadMemH(w io.Writer, words []uint16) error {
+	return writeLines(w, "// Hack program, load with $readmemh\n", words, "%04x\n")
+}
+
+// Returns an encoder that writes a Go source file, part of package 'pkg', where the program is
+// declared as the '[]uint16' variable 'name' (8 words per line, in hexadecimal).
+func GoSourceEncoder(pkg string, name string) Encoder {
+	return func(w io.Writer, words []uint16) error {
+		writer := bufio.NewWriter(w)
+		fmt.Fprintf(writer, "// Code generated by hack_assembler. DO NOT EDIT.\n\npackage %s\n\n", pkg)
+		fmt.Fprintf(writer, "var %s = []uint16{\n", name)
+
+		for start := 0; start < len(words); start += 8 {
+			fmt.Fprint(writer, "\t")
+			for i, word := range words[start:min(start+8, len(words))] {
+				if i > 0 {
+					fmt.Fprint(writer, " ")
+				}
+				fmt.Fprintf(writer, "0x%04X,", word)
+			}
+			fmt.Fprintln(writer)
+		}
+		fmt.Fprintln(writer, "}")
+
+		return writer.Flush()
+	}
+}
+
+// Writes the 'header' followed by each word formatted according to 'format'.
+func writeLines(w io.Writer, header string, words []uint16, format string) error {
+	writer := bufio.NewWriter(w)
+	fmt.Fprint(writer, header)
+	for _, word := range words {
+		fmt.Fprintf(writer, format, word)
+	}
+	return writer.Flush()
+}
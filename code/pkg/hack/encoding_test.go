@@ -0,0 +1,50 @@
+package hack_test
+
+import (
+	"bytes"
+	"testing"
+
+	"its-hmny.dev/nand2tetris/pkg/hack"
+)
+
+func TestEncoders(t *testing.T) {
+	// '@2', 'D=A', '@3', 'D=D+A', '@0', 'M=D' followed by a run of zeros (to test the Logisim shorthand)
+	words := []uint16{0x0002, 0xEC10, 0x0003, 0xE090, 0x0000, 0xE308, 0, 0, 0, 0}
+
+	test := func(encoder hack.Encoder, expected string) {
+		buffer := &bytes.Buffer{}
+		if err := encoder(buffer, words); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if buffer.String() != expected {
+			t.Fatalf("Expected:\n%s\ngot:\n%s", expected, buffer.String())
+		}
+	}
+
+	t.Run("Hack", func(t *testing.T) {
+		test(hack.EncodeHack, "0000000000000010\n1110110000010000\n0000000000000011\n1110000010010000\n"+
+			"0000000000000000\n1110001100001000\n0000000000000000\n0000000000000000\n0000000000000000\n0000000000000000\n")
+	})
+
+	t.Run("Raw", func(t *testing.T) {
+		test(hack.EncodeRaw, "\x00\x02\xEC\x10\x00\x03\xE0\x90\x00\x00\xE3\x08\x00\x00\x00\x00\x00\x00\x00\x00")
+	})
+
+	t.Run("Intel HEX", func(t *testing.T) {
+		// The checksum makes the sum of all the bytes in the record (itself included) zero
+		test(hack.EncodeIntelHex, ":100000000002EC100003E0900000E3080000000094\n:0400100000000000EC\n:00000001FF\n")
+	})
+
+	t.Run("Logisim", func(t *testing.T) {
+		test(hack.EncodeLogisim, "v2.0 raw\n2 ec10 3 e090 0 e308 4*0\n")
+	})
+
+	t.Run("Verilog", func(t *testing.T) {
+		test(hack.EncodeReadMemH, "// Hack program, load with $readmemh\n0002\nec10\n0003\ne090\n0000\ne308\n0000\n0000\n0000\n0000\n")
+	})
+
+	t.Run("Go source", func(t *testing.T) {
+		test(hack.GoSourceEncoder("rom", "Add"), "// Code generated by hack_assembler. DO NOT EDIT.\n\npackage rom\n\n"+
+			"var Add = []uint16{\n\t0x0002, 0xEC10, 0x0003, 0xE090, 0x0000, 0xE308, 0x0000, 0x0000,\n\t0x0000, 0x0000,\n}\n")
+	})
+}
@@ -1,5 +1,7 @@
 package hack
 
+import "its-hmny.dev/nand2tetris/pkg/utils"
+
 // ----------------------------------------------------------------------------
 // General information
 
@@ -36,6 +38,9 @@ const MaxAddressableMemory uint16 = (1 << 15) // Max memory address indexable fo
 type AInstruction struct {
 	LocType LocationType // The type of the location identified by 'Name' field
 	LocName string       // A generic "payload" (the label/builtin/raw symbol)
+	Expr    Expression   // The constant expression to be evaluated, only for 'Computed' locations
+
+	Position utils.Position // The location of the instruction in the source code (if any)
 }
 
 type LocationType uint8 // Enumeration for all the different type of location (built-in, label, raw)
@@ -44,8 +49,25 @@ const (
 	Raw     LocationType = 0 // Raw address literal (e.g. @2345, @8989)
 	Label   LocationType = 1 // User-defined location w/ a user given name (e.g. @MAIN, @LOOP)
 	BuiltIn LocationType = 2 // Predefined  associations by the Hack specs (@SCREEN, @KBD, @R1)
+	Compute LocationType = 3 // Constant expression over the other types (e.g. @SCREEN+32, @(256*2))
 )
 
+// ----------------------------------------------------------------------------
+// Constant Expressions
+
+// In memory representation of a constant expression used as location of an A Instruction.
+//
+// The operands are themselves A Instructions (with a 'Raw', 'Label' or 'BuiltIn' location) so
+// that they're resolved exactly as the standalone ones, the expression is evaluated only during
+// the codegen phase since it's the first moment where both labels and variables are known.
+type Expression interface{}
+
+// A binary operation between two sub-expressions (either 'AInstruction' or 'BinaryExpr').
+type BinaryExpr struct {
+	Operator string     // One of the supported operators (+, -, *, /, &, |)
+	Lhs, Rhs Expression // The left and right hand side operands
+}
+
 // ----------------------------------------------------------------------------
 // C Instructions
 
@@ -0,0 +1,249 @@
+package hdl
+
+import (
+	"its-hmny.dev/nand2tetris/pkg/emulator"
+)
+
+// ----------------------------------------------------------------------------
+// Builtin chips
+
+// This section defines the native (Go) implementation of the builtin chips.
+//
+// The set of builtin chips is the same one provided by the official Hardware Simulator (see the
+// 'tools/builtInChips' folder) and they're used whenever a part is not found in the chip's folder.
+// This is what makes the simulation of the bigger chips (e.g. the RAM16K or the whole Computer)
+// feasible: only the chip under test is simulated at the gate level while its parts are native.
+//
+// Each builtin chip is described by its interface (input and output pins) plus some behaviors:
+//   - 'eval': The combinational logic of the chip, computes the outputs from inputs and state
+//   - 'tick': Only for clocked chips, updates the internal state on the raising edge of the clock
+//   - 'tock': Only for clocked chips, updates the outputs on the falling edge of the clock
+//
+// Just like the official simulator the internal state (e.g. 'DRegister[]') changes as soon as
+// the clock raises while the outputs of registers change only when the clock falls.
+type builtin struct {
+	name    string            // The name of the builtin chip (e.g. 'Nand', 'RAM8')
+	inputs  []Pin             // The list of input pins of the chip
+	outputs []Pin             // The list of output pins of the chip
+	pins    map[string]uint16 // The current value of every pin (both inputs and outputs)
+
+	state []uint16 // The internal state (registers and memories), nil for combinational chips
+
+	eval func(b *builtin) // Computes the output pins from the input pins and the internal state
+	tick func(b *builtin) // Updates the internal state from the clocked input pins (if any)
+	tock func(b *builtin) // Updates the output pins from the internal state (if any)
+}
+
+// Specification of a builtin chip, used as a template to instantiate a new 'builtin'.
+type builtinSpec struct {
+	inputs  []Pin
+	outputs []Pin
+	state   int // The size of the internal state, in words
+	eval    func(b *builtin)
+	tick    func(b *builtin)
+	tock    func(b *builtin)
+}
+
+// Instantiates the builtin chip 'name', returns false if there's no such builtin chip.
+func NewBuiltIn(name string) (Component, bool) {
+	spec, found := builtinSpecs[name]
+	if !found {
+		return nil, false
+	}
+
+	b := &builtin{name: name, inputs: spec.inputs, outputs: spec.outputs, eval: spec.eval, tick: spec.tick, tock: spec.tock}
+	b.pins = make(map[string]uint16, len(spec.inputs)+len(spec.outputs))
+	if spec.state > 0 {
+		b.state = make([]uint16, spec.state)
+	}
+
+	return b, true
+}
+
+func (b *builtin) Name() string    { return b.name }
+func (b *builtin) Inputs() []Pin   { return b.inputs }
+func (b *builtin) Outputs() []Pin  { return b.outputs }
+func (b *builtin) State() []uint16 { return b.state }
+
+func (b *builtin) Get(pin string) uint16        { return b.pins[pin] }
+func (b *builtin) Set(pin string, value uint16) { b.pins[pin] = value }
+
+func (b *builtin) Eval() error {
+	if b.eval != nil {
+		b.eval(b)
+	}
+	return nil
+}
+
+func (b *builtin) Tick() {
+	if b.tick != nil {
+		b.tick(b)
+	}
+}
+
+func (b *builtin) Tock() {
+	if b.tock != nil {
+		b.tock(b)
+	}
+}
+
+// ----------------------------------------------------------------------------
+// Builtin specifications
+
+// Helpers to declare the pins of the builtin chips in a compact way.
+func pins(width int, names ...string) []Pin {
+	declared := make([]Pin, len(names))
+	for i, name := range names {
+		declared[i] = Pin{Name: name, Width: width}
+	}
+	return declared
+}
+
+// Converts a boolean to its single bit representation.
+func bit(b bool) uint16 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// Specification of the combinational logic of a 2-way multiplexer ('sel' chooses between 'a' and 'b').
+func selector(b *builtin) {
+	if b.pins["sel"]&1 == 1 {
+		b.pins["out"] = b.pins["b"]
+	} else {
+		b.pins["out"] = b.pins["a"]
+	}
+}
+
+// Specification of the output of a register-like chip ('out' is the stored value).
+func register(b *builtin) { b.pins["out"] = b.state[0] }
+
+// Specification of the sequential logic of a register-like chip (stores 'in' if 'load' is set).
+func load(b *builtin) {
+	if b.pins["load"]&1 == 1 {
+		b.state[0] = b.pins["in"]
+	}
+}
+
+// Specification of a memory chip with 'size' words addressed by 'address'.
+func memory(size int, width int) builtinSpec {
+	mask := uint16(size - 1)
+	return builtinSpec{
+		inputs:  []Pin{{Name: "in", Width: 16}, {Name: "load", Width: 1}, {Name: "address", Width: width}},
+		outputs: pins(16, "out"),
+		state:   size,
+		eval:    func(b *builtin) { b.pins["out"] = b.state[b.pins["address"]&mask] },
+		tick: func(b *builtin) {
+			if b.pins["load"]&1 == 1 {
+				b.state[b.pins["address"]&mask] = b.pins["in"]
+			}
+		},
+	}
+}
+
+// Specification of a multiplexer with 'ways' inputs of 16 bits each.
+func multiplexer(ways int) builtinSpec {
+	names, sel := []string{"a", "b", "c", "d", "e", "f", "g", "h"}[:ways], 0
+	for 1<<sel < ways {
+		sel++
+	}
+	return builtinSpec{
+		inputs:  append(pins(16, names...), Pin{Name: "sel", Width: sel}),
+		outputs: pins(16, "out"),
+		eval:    func(b *builtin) { b.pins["out"] = b.pins[names[int(b.pins["sel"])%ways]] },
+	}
+}
+
+// Specification of a demultiplexer with 'ways' outputs of 1 bit each.
+func demultiplexer(ways int) builtinSpec {
+	names, sel := []string{"a", "b", "c", "d", "e", "f", "g", "h"}[:ways], 0
+	for 1<<sel < ways {
+		sel++
+	}
+	return builtinSpec{
+		inputs:  []Pin{{Name: "in", Width: 1}, {Name: "sel", Width: sel}},
+		outputs: pins(1, names...),
+		eval: func(b *builtin) {
+			for i, name := range names {
+				b.pins[name] = b.pins["in"] & bit(int(b.pins["sel"]) == i)
+			}
+		},
+	}
+}
+
+// Specification of every builtin chip, indexed by name.
+var builtinSpecs = map[string]builtinSpec{
+	// Project 01: Logic gates
+	"Nand": {inputs: pins(1, "a", "b"), outputs: pins(1, "out"), eval: func(b *builtin) { b.pins["out"] = ^(b.pins["a"] & b.pins["b"]) & 1 }},
+	"Not":  {inputs: pins(1, "in"), outputs: pins(1, "out"), eval: func(b *builtin) { b.pins["out"] = ^b.pins["in"] & 1 }},
+	"And":  {inputs: pins(1, "a", "b"), outputs: pins(1, "out"), eval: func(b *builtin) { b.pins["out"] = b.pins["a"] & b.pins["b"] }},
+	"Or":   {inputs: pins(1, "a", "b"), outputs: pins(1, "out"), eval: func(b *builtin) { b.pins["out"] = b.pins["a"] | b.pins["b"] }},
+	"Xor":  {inputs: pins(1, "a", "b"), outputs: pins(1, "out"), eval: func(b *builtin) { b.pins["out"] = b.pins["a"] ^ b.pins["b"] }},
+	"Mux":  {inputs: pins(1, "a", "b", "sel"), outputs: pins(1, "out"), eval: selector},
+	"DMux": demultiplexer(2),
+
+	"Not16":  {inputs: pins(16, "in"), outputs: pins(16, "out"), eval: func(b *builtin) { b.pins["out"] = ^b.pins["in"] }},
+	"And16":  {inputs: pins(16, "a", "b"), outputs: pins(16, "out"), eval: func(b *builtin) { b.pins["out"] = b.pins["a"] & b.pins["b"] }},
+	"Or16":   {inputs: pins(16, "a", "b"), outputs: pins(16, "out"), eval: func(b *builtin) { b.pins["out"] = b.pins["a"] | b.pins["b"] }},
+	"Mux16":  {inputs: append(pins(16, "a", "b"), Pin{Name: "sel", Width: 1}), outputs: pins(16, "out"), eval: selector},
+	"Or8Way": {inputs: pins(8, "in"), outputs: pins(1, "out"), eval: func(b *builtin) { b.pins["out"] = bit(b.pins["in"]&0xFF != 0) }},
+
+	"Mux4Way16": multiplexer(4),
+	"Mux8Way16": multiplexer(8),
+	"DMux4Way":  demultiplexer(4),
+	"DMux8Way":  demultiplexer(8),
+
+	// Project 02: Boolean arithmetic
+	"HalfAdder": {inputs: pins(1, "a", "b"), outputs: pins(1, "sum", "carry"), eval: func(b *builtin) {
+		sum := b.pins["a"] + b.pins["b"]
+		b.pins["sum"], b.pins["carry"] = sum&1, sum>>1
+	}},
+	"FullAdder": {inputs: pins(1, "a", "b", "c"), outputs: pins(1, "sum", "carry"), eval: func(b *builtin) {
+		sum := b.pins["a"] + b.pins["b"] + b.pins["c"]
+		b.pins["sum"], b.pins["carry"] = sum&1, sum>>1
+	}},
+	"Add16": {inputs: pins(16, "a", "b"), outputs: pins(16, "out"), eval: func(b *builtin) { b.pins["out"] = b.pins["a"] + b.pins["b"] }},
+	"Inc16": {inputs: pins(16, "in"), outputs: pins(16, "out"), eval: func(b *builtin) { b.pins["out"] = b.pins["in"] + 1 }},
+	"ALU": {
+		inputs:  append(pins(16, "x", "y"), pins(1, "zx", "nx", "zy", "ny", "f", "no")...),
+		outputs: append(pins(16, "out"), pins(1, "zr", "ng")...),
+		eval: func(b *builtin) {
+			comp := b.pins["zx"]<<5 | b.pins["nx"]<<4 | b.pins["zy"]<<3 | b.pins["ny"]<<2 | b.pins["f"]<<1 | b.pins["no"]
+			out := emulator.ALU(b.pins["x"], b.pins["y"], comp)
+			b.pins["out"], b.pins["zr"], b.pins["ng"] = out, bit(out == 0), out>>15
+		},
+	},
+
+	// Project 03: Sequential logic
+	"DFF":       {inputs: pins(1, "in"), outputs: pins(1, "out"), state: 1, tick: func(b *builtin) { b.state[0] = b.pins["in"] }, tock: register},
+	"Bit":       {inputs: pins(1, "in", "load"), outputs: pins(1, "out"), state: 1, tick: load, tock: register},
+	"Register":  {inputs: []Pin{{Name: "in", Width: 16}, {Name: "load", Width: 1}}, outputs: pins(16, "out"), state: 1, tick: load, tock: register},
+	"ARegister": {inputs: []Pin{{Name: "in", Width: 16}, {Name: "load", Width: 1}}, outputs: pins(16, "out"), state: 1, tick: load, tock: register},
+	"DRegister": {inputs: []Pin{{Name: "in", Width: 16}, {Name: "load", Width: 1}}, outputs: pins(16, "out"), state: 1, tick: load, tock: register},
+	"PC": {
+		inputs: append(pins(16, "in"), pins(1, "load", "inc", "reset")...), outputs: pins(16, "out"), state: 1, tock: register,
+		tick: func(b *builtin) {
+			switch {
+			case b.pins["reset"]&1 == 1:
+				b.state[0] = 0
+			case b.pins["load"]&1 == 1:
+				b.state[0] = b.pins["in"]
+			case b.pins["inc"]&1 == 1:
+				b.state[0]++
+			}
+		},
+	},
+	"RAM8":   memory(8, 3),
+	"RAM64":  memory(64, 6),
+	"RAM512": memory(512, 9),
+	"RAM4K":  memory(4096, 12),
+	"RAM16K": memory(16384, 14),
+
+	// Project 05: Computer architecture
+	"Screen":   memory(8192, 13),
+	"Keyboard": {outputs: pins(16, "out"), state: 1, eval: register},
+	"ROM32K": {inputs: pins(15, "address"), outputs: pins(16, "out"), state: 32768, eval: func(b *builtin) {
+		b.pins["out"] = b.state[b.pins["address"]&0x7FFF]
+	}},
+}
@@ -0,0 +1,62 @@
+package hdl
+
+// ----------------------------------------------------------------------------
+// General information
+
+// This section contains some general information about the nand2tetris HDL language.
+//
+// The Hardware Description Language (HDL) is used to describe a chip as a composition of other
+// (simpler) chips called parts, down to the only primitive gate: the 'Nand'. Each chip declares
+// its interface (input and output pins, eventually multi-bit buses) and then how its parts are
+// connected to each other, either through the chip's own pins or through internal pins.
+//
+// Chips can also be implemented natively by the simulator (the 'BUILTIN' chips) this is both
+// done for performance reasons (e.g. the RAM16K) and for chips whose behavior cannot be described
+// in HDL (e.g. the DFF, the Screen and the Keyboard). The 'CLOCKED' pins of a chip are the ones
+// that are sampled only on the raising edge of the clock.
+
+// In memory representation of a chip, as declared in the '.hdl' file.
+type Chip struct {
+	Name    string // The name of the chip (e.g. 'And16')
+	Inputs  []Pin  // The list of input pins (or buses) of the chip
+	Outputs []Pin  // The list of output pins (or buses) of the chip
+
+	Parts   []Part   // The parts the chip is made of (empty for builtin chips)
+	BuiltIn string   // The name of the native implementation, if any (e.g. 'BUILTIN Nand;')
+	Clocked []string // The list of clocked input pins, if any (e.g. 'CLOCKED in, load;')
+}
+
+// A pin (or a bus when 'Width' > 1) declared in the chip interface.
+type Pin struct {
+	Name  string // The name of the pin (e.g. 'in', 'sel')
+	Width int    // The number of bits of the pin (e.g. 16 for 'in[16]')
+}
+
+// A part used inside the chip, with the wiring of its pins.
+type Part struct {
+	Name        string       // The name of the chip used as part (e.g. 'Nand', 'Mux16')
+	Connections []Connection // The list of 'pin=signal' connections of the part
+}
+
+// A single connection, binds the 'Pin' of the part to a 'Signal' of the enclosing chip.
+//
+// The 'Signal' can be either one of the chip's pins, an internal pin (implicitly declared by
+// the first part that outputs on it) or one of the constants 'true' and 'false'.
+type Connection struct {
+	Pin    Bus // The pin of the part being connected (e.g. 'a', 'out[0..7]')
+	Signal Bus // The signal of the enclosing chip it's connected to (e.g. 'in[3]', 'true')
+}
+
+// A reference to a pin, optionally restricted to a sub-bus (e.g. 'a', 'a[3]' or 'a[0..7]').
+type Bus struct {
+	Name   string // The name of the pin referenced
+	Sliced bool   // Whether only a slice of the pin is referenced, if not 'Start' and 'End' are ignored
+	Start  int    // The index of the first bit of the slice (inclusive)
+	End    int    // The index of the last bit of the slice (inclusive)
+}
+
+// Signal names with a special meaning: all bits of the connected pin are set to 1 (or 0).
+const (
+	True  = "true"
+	False = "false"
+)
@@ -0,0 +1,182 @@
+package hdl_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"its-hmny.dev/nand2tetris/pkg/hdl"
+)
+
+func TestParser(t *testing.T) {
+	test := func(source string, expected hdl.Chip, fail bool) {
+		parser := hdl.NewParser(strings.NewReader(source))
+		chip, err := parser.Parse()
+		if err != nil && !fail {
+			t.Fatalf("unexpected error parsing chip: %s", err)
+		}
+		if err == nil && fail {
+			t.Fatalf("expected error parsing chip, got %+v", chip)
+		}
+		if err == nil && !reflect.DeepEqual(chip, expected) {
+			t.Errorf("expected %+v, got %+v", expected, chip)
+		}
+	}
+
+	t.Run("Composite chip", func(t *testing.T) {
+		test(`/** Swaps the two bytes */
+		CHIP Swap {
+			IN in[16], // The input bus
+			   enable;
+			OUT out[16];
+
+			PARTS:
+			Mux16(a=in, b[0..7]=in[8..15], b[8..15]=in[0..7], sel=enable, out=out);
+		}`, hdl.Chip{
+			Name:    "Swap",
+			Inputs:  []hdl.Pin{{Name: "in", Width: 16}, {Name: "enable", Width: 1}},
+			Outputs: []hdl.Pin{{Name: "out", Width: 16}},
+			Parts: []hdl.Part{{Name: "Mux16", Connections: []hdl.Connection{
+				{Pin: hdl.Bus{Name: "a"}, Signal: hdl.Bus{Name: "in"}},
+				{Pin: hdl.Bus{Name: "b", Sliced: true, Start: 0, End: 7}, Signal: hdl.Bus{Name: "in", Sliced: true, Start: 8, End: 15}},
+				{Pin: hdl.Bus{Name: "b", Sliced: true, Start: 8, End: 15}, Signal: hdl.Bus{Name: "in", Sliced: true, Start: 0, End: 7}},
+				{Pin: hdl.Bus{Name: "sel"}, Signal: hdl.Bus{Name: "enable"}},
+				{Pin: hdl.Bus{Name: "out"}, Signal: hdl.Bus{Name: "out"}},
+			}}},
+		}, false)
+	})
+
+	t.Run("Builtin chip", func(t *testing.T) {
+		test("CHIP Bit { IN in, load; OUT out; BUILTIN Bit; CLOCKED in, load; }", hdl.Chip{
+			Name:    "Bit",
+			Inputs:  []hdl.Pin{{Name: "in", Width: 1}, {Name: "load", Width: 1}},
+			Outputs: []hdl.Pin{{Name: "out", Width: 1}},
+			Parts:   []hdl.Part{},
+			BuiltIn: "Bit",
+			Clocked: []string{"in", "load"},
+		}, false)
+	})
+
+	t.Run("Malformed chips", func(t *testing.T) {
+		test("CHIP Broken { IN a; OUT out; PARTS: Not(in=a, out=out) }", hdl.Chip{}, true)
+		test("CHIP Broken { IN a[17]; OUT out; }", hdl.Chip{}, true)
+		test("CHIP Broken { IN a[16]; OUT out; PARTS: Or8Way(in=a[7..0], out=out); }", hdl.Chip{}, true)
+	})
+}
+
+func TestSimulation(t *testing.T) {
+	// Parses the chip from source and instantiates it, resolving its parts with the builtins
+	build := func(source string) hdl.Component {
+		parser := hdl.NewParser(strings.NewReader(source))
+		chip, err := parser.Parse()
+		if err != nil {
+			t.Fatalf("unexpected error parsing chip: %s", err)
+		}
+
+		resolver := hdl.NewResolver(t.TempDir())
+		component, err := resolver.Build(chip)
+		if err != nil {
+			t.Fatalf("unexpected error building chip: %s", err)
+		}
+		return component
+	}
+
+	t.Run("Combinational logic", func(t *testing.T) {
+		// Parts are declared in reverse order on purpose, the evaluation must still converge
+		xor := build(`CHIP Xor { IN a, b; OUT out; PARTS:
+			Nand(a=nandAB, b=nandBX, out=out);
+			Nand(a=b, b=nand, out=nandBX);
+			Nand(a=a, b=nand, out=nandAB);
+			Nand(a=a, b=b, out=nand);
+		}`)
+
+		for _, tc := range []struct{ a, b, out uint16 }{{0, 0, 0}, {0, 1, 1}, {1, 0, 1}, {1, 1, 0}} {
+			xor.Set("a", tc.a)
+			xor.Set("b", tc.b)
+			xor.Eval()
+			if out := xor.Get("out"); out != tc.out {
+				t.Errorf("expected Xor(%d, %d) = %d, got %d", tc.a, tc.b, tc.out, out)
+			}
+		}
+	})
+
+	t.Run("Sub-buses and constants", func(t *testing.T) {
+		swap := build(`CHIP Swap { IN in[16]; OUT out[16], low[8], msb; PARTS:
+			Or16(a[0..7]=in[8..15], a[8..15]=in[0..7], b=false, out=out, out[0..7]=low, out[7]=msb);
+		}`)
+
+		swap.Set("in", 0x12F4)
+		swap.Eval()
+		if out, low, msb := swap.Get("out"), swap.Get("low"), swap.Get("msb"); out != 0xF412 || low != 0x12 || msb != 0 {
+			t.Errorf("unexpected outputs: out=%04X low=%02X msb=%d", out, low, msb)
+		}
+	})
+
+	t.Run("Sequential logic", func(t *testing.T) {
+		bit := build(`CHIP Bit { IN in, load; OUT out; PARTS:
+			Mux(a=prev, b=in, sel=load, out=next);
+			DFF(in=next, out=prev, out=out);
+		}`)
+
+		bit.Set("in", 1)
+		bit.Set("load", 1)
+		bit.Eval()
+		bit.Tick()
+		if out := bit.Get("out"); out != 0 {
+			t.Errorf("expected output to change only on the falling edge, got %d", out)
+		}
+		bit.Tock()
+		bit.Eval()
+		if out := bit.Get("out"); out != 1 {
+			t.Errorf("expected stored value to be 1, got %d", out)
+		}
+
+		bit.Set("in", 0)
+		bit.Set("load", 0)
+		bit.Eval()
+		bit.Tick()
+		bit.Tock()
+		bit.Eval()
+		if out := bit.Get("out"); out != 1 {
+			t.Errorf("expected stored value to be retained, got %d", out)
+		}
+	})
+
+	t.Run("Combinational loops", func(t *testing.T) {
+		// A loop through a clocked part (see 'Sequential logic') is fine, one without never settles
+		loop := build(`CHIP Loop { IN in; OUT out; PARTS:
+			And(a=in, b=inverted, out=and);
+			Not(in=and, out=inverted, out=out);
+		}`)
+
+		loop.Set("in", 0)
+		if err := loop.Eval(); err != nil {
+			t.Errorf("unexpected error with a settled loop: %s", err)
+		}
+		loop.Set("in", 1)
+		if err := loop.Eval(); err == nil || !strings.Contains(err.Error(), "circular data flow") {
+			t.Errorf("expected circular data flow error, got %v", err)
+		}
+	})
+
+	t.Run("Wiring errors", func(t *testing.T) {
+		for _, source := range []string{
+			"CHIP E { IN a; OUT out; PARTS: Missing(in=a, out=out); }",
+			"CHIP E { IN a; OUT out; PARTS: Not(in=a, wrong=out); }",
+			"CHIP E { IN a[16]; OUT out; PARTS: Not(in=a, out=out); }",
+			"CHIP E { IN a; OUT out; PARTS: Not(in=undeclared, out=out); }",
+			"CHIP E { IN a; OUT out; PARTS: Not(in=a, out=a); }",
+		} {
+			parser := hdl.NewParser(strings.NewReader(source))
+			chip, err := parser.Parse()
+			if err != nil {
+				t.Fatalf("unexpected error parsing chip: %s", err)
+			}
+
+			resolver := hdl.NewResolver(t.TempDir())
+			if _, err := resolver.Build(chip); err == nil {
+				t.Errorf("expected error building chip '%s'", source)
+			}
+		}
+	})
+}
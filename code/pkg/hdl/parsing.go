@@ -0,0 +1,259 @@
+package hdl
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	pc "github.com/prataprc/goparsec"
+)
+
+// ----------------------------------------------------------------------------
+// Parser Combinator(s)
+
+// This section defines the Parser Combinator for every token & statement of the HDL language.
+//
+// Each parser combinator either manages a section of the chip declaration (the pin declarations,
+// the parts, the builtin and clocked statements) or some pieces of it: pins, buses and tokens.
+// Since comments can appear literally everywhere (even between the pins of the same declaration)
+// they're treated as whitespace by the scanner instead of having a dedicated parser combinator.
+
+// Top level object, will generate the traversable AST based on the input plus the PCs below.
+var ast = pc.NewAST("hdl", 0)
+
+// Pattern used by the scanner to skip whitespaces, single line comments and multi line comments.
+const whitespaces = `^(\s|//[^\n]*|/\*(?s:.*?)\*/)+`
+
+var (
+	// Parser combinator for an entire HDL chip declaration
+	pChip = ast.And("chip", nil,
+		pc.Atom("CHIP", "CHIP"), pIdent, pc.Atom("{", "{"),
+		ast.Maybe("maybe-inputs", nil, pInputs),
+		ast.Maybe("maybe-outputs", nil, pOutputs),
+		ast.Kleene("statements", nil, ast.OrdChoice("statement", nil, pPartsHeader, pBuiltIn, pClocked, pPart)),
+		pc.Atom("}", "}"),
+	)
+
+	// Parser combinator for the input pins declaration
+	pInputs = ast.And("inputs", nil, pc.Atom("IN", "IN"), ast.Kleene("pins", nil, pPinDecl, pc.Atom(",", ",")), pc.Atom(";", ";"))
+	// Parser combinator for the output pins declaration
+	pOutputs = ast.And("outputs", nil, pc.Atom("OUT", "OUT"), ast.Kleene("pins", nil, pPinDecl, pc.Atom(",", ",")), pc.Atom(";", ";"))
+
+	// Parser combinator for the 'PARTS:' header, that precedes the list of parts
+	pPartsHeader = ast.And("parts-header", nil, pc.Atom("PARTS", "PARTS"), pc.Atom(":", ":"))
+	// Parser combinator for the 'BUILTIN' statement
+	pBuiltIn = ast.And("builtin", nil, pc.Atom("BUILTIN", "BUILTIN"), pIdent, pc.Atom(";", ";"))
+	// Parser combinator for the 'CLOCKED' statement
+	pClocked = ast.And("clocked", nil, pc.Atom("CLOCKED", "CLOCKED"), ast.Kleene("pins", nil, pIdent, pc.Atom(",", ",")), pc.Atom(";", ";"))
+	// Parser combinator for a part used in the chip implementation
+	pPart = ast.And("part", nil,
+		pIdent, pc.Atom("(", "("), ast.Kleene("connections", nil, pConnection, pc.Atom(",", ",")), pc.Atom(")", ")"), pc.Atom(";", ";"),
+	)
+)
+
+var (
+	// Generic identifier parser, used for chip and pin names
+	pIdent = pc.Token(`[A-Za-z_][0-9A-Za-z_]*`, "IDENT")
+
+	// Generic pin declaration parser, with an optional width (e.g. 'a' or 'in[16]')
+	pPinDecl = ast.And("pin", nil, pIdent, ast.Maybe("maybe-width", nil, ast.And("width", nil, pc.Atom("[", "["), pc.Int(), pc.Atom("]", "]"))))
+
+	// Generic connection parser (e.g. 'a=in[0..7]')
+	pConnection = ast.And("connection", nil, pBus, pc.Atom("=", "="), pBus)
+
+	// Generic bus parser, with an optional sub-bus slice (e.g. 'a', 'a[3]' or 'a[0..7]')
+	pBus = ast.And("bus", nil, pIdent, ast.Maybe("maybe-slice", nil, ast.And("slice", nil,
+		pc.Atom("[", "["), pc.Int(), ast.Maybe("maybe-end", nil, ast.And("end", nil, pc.Atom("..", ".."), pc.Int())), pc.Atom("]", "]"),
+	)))
+)
+
+// ----------------------------------------------------------------------------
+// HDL Parser
+
+// This section defines the Parser for the nand2tetris HDL language.
+//
+// It uses parser combinator(s) to obtain the AST from the source code (the latter can be provided)
+// in multiple ways using a generic io.Reader, the library reads up the feature flags (as env vars):
+// - PARSEC_DEBUG: Verbose logging to inspect which of the PCs gets triggered and match
+// - EXPORT_AST:   Exports in the DEBUG_FOLDER a Graphviz representation of the AST
+// - PRINT_AST:    Print on the stdout a textual representation of the AST
+type Parser struct{ reader io.Reader }
+
+// Initializes and returns to the caller a brand new 'Parser' struct.
+// Requires the argument io.Reader 'r' to be valid and usable.
+func NewParser(r io.Reader) Parser {
+	return Parser{reader: r}
+}
+
+// Parser entrypoint divides the 2 phases of the parsing pipeline
+// Text --> AST: This step is done using PCs and returns a generic traversable AST
+// AST --> IR: This step is done by traversing the AST and extracting the 'hdl.Chip'
+func (p *Parser) Parse() (Chip, error) {
+	content, err := io.ReadAll(p.reader)
+	if err != nil {
+		return Chip{}, fmt.Errorf("cannot read from 'io.Reader': %s", err)
+	}
+
+	ast, success := p.FromSource(content)
+	if !success {
+		return Chip{}, fmt.Errorf("failed to parse AST from input content")
+	}
+
+	return p.FromAST(ast)
+}
+
+// Scans the textual input stream coming from the 'reader' method and returns a traversable AST
+// (Abstract Syntax Tree) that can be eventually visited to extract/transform the info available.
+func (p *Parser) FromSource(source []byte) (pc.Queryable, bool) {
+
+	// Feature flag: Enable 'goparsec' library's debug logs
+	if os.Getenv("PARSEC_DEBUG") != "" {
+		ast.SetDebug()
+	}
+
+	// We generate the traversable Abstract Syntax Tree from the source content
+	root, scanner := ast.Parsewith(pChip, pc.NewScanner(source).SetWSPattern(whitespaces))
+
+	// Feature flag: Enables export of the AST as Dot file (debug.ast.fot)
+	if os.Getenv("EXPORT_AST") != "" {
+		file, _ := os.Create(fmt.Sprintf("%s/debug.ast.dot", os.Getenv("DEBUG_FOLDER")))
+		defer file.Close()
+
+		file.Write([]byte(ast.Dotstring("\"HDL AST\"")))
+	}
+
+	// Feature flag: Enables pretty printing of the AST on the console
+	if os.Getenv("PRINT_AST") != "" {
+		ast.Prettyprint()
+	}
+
+	// Success is based on the reaching of 'EOF' (once the trailing whitespaces are skipped)
+	_, scanner = scanner.SkipWS()
+	return root, root != nil && scanner.Endof()
+}
+
+// This function takes the root node of the raw parsed AST and does a DFS on it parsing
+// one by one each subtree and retuning a 'hdl.Chip' that can be used as in-memory and
+// type-safe AST not dependent on the parsing library used.
+func (p *Parser) FromAST(root pc.Queryable) (Chip, error) {
+	if root.GetName() != "chip" {
+		return Chip{}, fmt.Errorf("expected node 'chip', found %s", root.GetName())
+	}
+
+	children := root.GetChildren()
+	chip := Chip{Name: children[1].GetValue(), Inputs: []Pin{}, Outputs: []Pin{}, Parts: []Part{}}
+
+	if inputs := children[3]; inputs.GetName() == "inputs" {
+		pins, err := p.HandlePins(inputs.GetChildren()[1])
+		if err != nil {
+			return Chip{}, err
+		}
+		chip.Inputs = pins
+	}
+	if outputs := children[4]; outputs.GetName() == "outputs" {
+		pins, err := p.HandlePins(outputs.GetChildren()[1])
+		if err != nil {
+			return Chip{}, err
+		}
+		chip.Outputs = pins
+	}
+
+	for _, statement := range children[5].GetChildren() {
+		switch statement.GetName() {
+		case "part": // Part subtree, appends 'hdl.Part' to the chip's parts
+			part, err := p.HandlePart(statement)
+			if err != nil {
+				return Chip{}, err
+			}
+			chip.Parts = append(chip.Parts, part)
+
+		case "builtin": // Builtin statement, the chip is implemented natively by the simulator
+			chip.BuiltIn = statement.GetChildren()[1].GetValue()
+
+		case "clocked": // Clocked statement, lists the pins sampled on the clock's raising edge
+			for _, pin := range statement.GetChildren()[1].GetChildren() {
+				chip.Clocked = append(chip.Clocked, pin.GetValue())
+			}
+
+		case "parts-header": // The 'PARTS:' header is just skipped
+			continue
+
+		default: // Error case, unrecognized subtree in the AST
+			return Chip{}, fmt.Errorf("unrecognized node '%s'", statement.GetName())
+		}
+	}
+
+	return chip, nil
+}
+
+// Specialized function to convert a "pins" node to a list of 'hdl.Pin'.
+func (Parser) HandlePins(node pc.Queryable) ([]Pin, error) {
+	pins := []Pin{}
+
+	for _, child := range node.GetChildren() {
+		if child.GetName() != "pin" { // Prelude checks: inspects the node to verify it's a 'pin'
+			return nil, fmt.Errorf("expected node 'pin', found %s", child.GetName())
+		}
+
+		pin := Pin{Name: child.GetChildren()[0].GetValue(), Width: 1}
+		if width := child.GetChildren()[1]; width.GetName() == "width" {
+			pin.Width, _ = strconv.Atoi(width.GetChildren()[1].GetValue())
+		}
+		if pin.Width < 1 || pin.Width > 16 {
+			return nil, fmt.Errorf("pin '%s' has invalid width %d, expected between 1 and 16", pin.Name, pin.Width)
+		}
+
+		pins = append(pins, pin)
+	}
+
+	return pins, nil
+}
+
+// Specialized function to convert a "part" node to an 'hdl.Part'.
+func (p *Parser) HandlePart(node pc.Queryable) (Part, error) {
+	if node.GetName() != "part" { // Prelude checks: inspects the node to verify it's a 'part'
+		return Part{}, fmt.Errorf("expected node 'part', found %s", node.GetName())
+	}
+
+	part := Part{Name: node.GetChildren()[0].GetValue(), Connections: []Connection{}}
+	for _, child := range node.GetChildren()[2].GetChildren() {
+		pin, err := p.HandleBus(child.GetChildren()[0])
+		if err != nil {
+			return Part{}, err
+		}
+		signal, err := p.HandleBus(child.GetChildren()[2])
+		if err != nil {
+			return Part{}, err
+		}
+
+		part.Connections = append(part.Connections, Connection{Pin: pin, Signal: signal})
+	}
+
+	return part, nil
+}
+
+// Specialized function to convert a "bus" node to an 'hdl.Bus'.
+func (Parser) HandleBus(node pc.Queryable) (Bus, error) {
+	if node.GetName() != "bus" { // Prelude checks: inspects the node to verify it's a 'bus'
+		return Bus{}, fmt.Errorf("expected node 'bus', found %s", node.GetName())
+	}
+
+	bus := Bus{Name: node.GetChildren()[0].GetValue()}
+	slice := node.GetChildren()[1]
+	if slice.GetName() != "slice" { // No sub-bus, the whole pin is referenced
+		return bus, nil
+	}
+
+	bus.Sliced = true
+	bus.Start, _ = strconv.Atoi(slice.GetChildren()[1].GetValue())
+	bus.End = bus.Start
+	if end := slice.GetChildren()[2]; end.GetName() == "end" {
+		bus.End, _ = strconv.Atoi(end.GetChildren()[1].GetValue())
+	}
+	if bus.Start < 0 || bus.End < bus.Start || bus.End > 15 {
+		return Bus{}, fmt.Errorf("invalid sub-bus '%s[%d..%d]'", bus.Name, bus.Start, bus.End)
+	}
+
+	return bus, nil
+}
@@ -0,0 +1,229 @@
+package hdl
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+
+	"its-hmny.dev/nand2tetris/pkg/emulator"
+)
+
+// ----------------------------------------------------------------------------
+// Test script support
+
+// This section defines the Simulator, that implements the 'tst.Simulator' interface on top of
+// a simulation tree, this way the test scripts (.tst files) written for the official Hardware
+// Simulator can be run against our implementation.
+//
+// The variables available to the scripts are the pins (input, output and internal) of the chip
+// under test plus the internal state of its builtin parts, accessed with the 'Name[index]' syntax
+// (e.g. 'RAM16K[42]' or 'ARegister[]'), the first part with the given name is used.
+type Simulator struct {
+	chip    Component // The chip under test, nil until the first 'Load'
+	library []string  // Additional folders where to look for the parts (after the builtins)
+}
+
+// Matches the variables referring to the state of a part (e.g. 'RAM16K[42]' or 'PC[]').
+var stateRegex = regexp.MustCompile(`^([A-Za-z_][0-9A-Za-z_]*)\[([0-9]*)\]$`)
+
+// Initializes and returns to the caller a brand new 'Simulator' struct.
+// The optional 'library' folders are used to resolve the parts that are not found neither in the
+// folder of the chip under test nor in the builtin chips.
+func NewSimulator(library ...string) Simulator {
+	return Simulator{library: library}
+}
+
+// Returns the chip under test, nil if no chip has been loaded yet.
+func (s *Simulator) Chip() Component {
+	return s.chip
+}
+
+// Loads the chip declared at 'path' or, if 'target' is provided, loads the '.hack' program at
+// 'path' in the memory of the 'target' part (e.g. 'ROM32K load Add.hack').
+func (s *Simulator) Load(target, path string) error {
+	if target != "" {
+		return s.flash(target, path)
+	}
+
+	// The sibling folders (the other chips of the same project) are searched after the builtins
+	dir, library := filepath.Dir(path), []string{}
+	if entries, err := os.ReadDir(filepath.Dir(dir)); err == nil {
+		for _, entry := range entries {
+			if sibling := filepath.Join(filepath.Dir(dir), entry.Name()); entry.IsDir() && sibling != dir {
+				library = append(library, sibling)
+			}
+		}
+	}
+
+	resolver := NewResolver(dir, append(s.library, library...)...)
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	parser := NewParser(file)
+	chip, err := parser.Parse()
+	if err != nil {
+		return fmt.Errorf("unable to parse '%s': %w", path, err)
+	}
+
+	s.chip, err = resolver.Build(chip)
+	if err != nil {
+		return err
+	}
+
+	return s.chip.Eval()
+}
+
+// Flashes the '.hack' program at 'path' in the memory of the 'target' part.
+func (s *Simulator) flash(target, path string) error {
+	state, err := s.state(target)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	words, err := emulator.ReadHack(file)
+	if err != nil {
+		return err
+	}
+	if len(words) > len(state) {
+		return fmt.Errorf("program too big for '%s', got %d instructions (max %d)", target, len(words), len(state))
+	}
+
+	clear(state)
+	copy(state, words)
+	return nil
+}
+
+// Sets the pin or the part's state 'variable' to 'value'.
+func (s *Simulator) Set(variable string, value int16) error {
+	if s.chip == nil {
+		return fmt.Errorf("no chip loaded")
+	}
+	if _, found := widthOf(s.chip.Inputs(), variable); found {
+		s.chip.Set(variable, uint16(value))
+		return nil
+	}
+
+	location, err := s.locate(variable)
+	if err != nil {
+		return err
+	}
+	*location = uint16(value)
+	return nil
+}
+
+// Returns the current value of the pin or the part's state 'variable'.
+func (s *Simulator) Get(variable string) (int16, error) {
+	if s.chip == nil {
+		return 0, fmt.Errorf("no chip loaded")
+	}
+	if c, ok := s.chip.(*composite); ok {
+		if _, found := c.index[variable]; found {
+			return signed(c.Get(variable), c.widths[c.index[variable]]), nil
+		}
+	}
+	for _, pin := range append(s.chip.Inputs(), s.chip.Outputs()...) {
+		if pin.Name == variable {
+			return signed(s.chip.Get(variable), pin.Width), nil
+		}
+	}
+
+	location, err := s.locate(variable)
+	if err != nil {
+		return 0, err
+	}
+	return int16(*location), nil
+}
+
+// Evaluates the combinational logic of the chip under test.
+func (s *Simulator) Eval() error {
+	if s.chip == nil {
+		return fmt.Errorf("no chip loaded")
+	}
+	return s.chip.Eval()
+}
+
+// Raising edge of the clock: the inputs are propagated and then sampled by the clocked parts, the
+// outputs are not evaluated again since they're expected to change only on the falling edge.
+func (s *Simulator) Tick() error {
+	if s.chip == nil {
+		return fmt.Errorf("no chip loaded")
+	}
+	if err := s.chip.Eval(); err != nil {
+		return err
+	}
+	s.chip.Tick()
+	return nil
+}
+
+// Falling edge of the clock: the clocked parts commit their state that is then propagated.
+func (s *Simulator) Tock() error {
+	if s.chip == nil {
+		return fmt.Errorf("no chip loaded")
+	}
+	s.chip.Tock()
+	return s.chip.Eval()
+}
+
+// Resolves a 'Name[index]' variable to a pointer to the state of the first part named 'Name'.
+func (s *Simulator) locate(variable string) (*uint16, error) {
+	match := stateRegex.FindStringSubmatch(variable)
+	if match == nil {
+		return nil, fmt.Errorf("unrecognized variable '%s'", variable)
+	}
+
+	state, err := s.state(match[1])
+	if err != nil {
+		return nil, err
+	}
+
+	index := 0 // An empty index (e.g. 'PC[]') refers to the only register of the part
+	if match[2] != "" {
+		index, _ = strconv.Atoi(match[2])
+	}
+	if index >= len(state) {
+		return nil, fmt.Errorf("index %d out of bound for variable '%s'", index, variable)
+	}
+	return &state[index], nil
+}
+
+// Looks up (depth first) the first part named 'name' with an internal state and returns the latter.
+func (s *Simulator) state(name string) ([]uint16, error) {
+	var search func(c Component) []uint16
+	search = func(c Component) []uint16 {
+		if stateful, ok := c.(Stateful); ok && c.Name() == name && stateful.State() != nil {
+			return stateful.State()
+		}
+		if composite, ok := c.(*composite); ok {
+			for _, part := range composite.Parts() {
+				if state := search(part); state != nil {
+					return state
+				}
+			}
+		}
+		return nil
+	}
+
+	if state := search(s.chip); state != nil {
+		return state, nil
+	}
+	return nil, fmt.Errorf("no part named '%s' with an internal state", name)
+}
+
+// Converts a 'width' bits value to its signed representation, only 16 bit values can be negative.
+func signed(value uint16, width int) int16 {
+	if width < 16 {
+		return int16(value & mask(width))
+	}
+	return int16(value)
+}
@@ -0,0 +1,376 @@
+package hdl
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// ----------------------------------------------------------------------------
+// Components
+
+// This section defines the 'Component' interface, the runtime counterpart of an 'hdl.Chip'.
+//
+// A component is an instance of a chip in the simulation tree, it can either be implemented
+// natively (a 'builtin') or be a composition of other components (a 'composite') wired together
+// following the connections declared in the '.hdl' file. The simulation is driven from the top
+// component through three operations:
+//   - 'Eval': Propagates the input values through the combinational logic up to the outputs, it
+//     fails only when the logic doesn't settle (a combinational loop, without any clocked part)
+//   - 'Tick': Raising edge of the clock, the clocked parts sample their inputs
+//   - 'Tock': Falling edge of the clock, the clocked parts commit the sampled values
+type Component interface {
+	Name() string   // The name of the chip instantiated by the component
+	Inputs() []Pin  // The list of input pins of the component
+	Outputs() []Pin // The list of output pins of the component
+	Get(string) uint16
+	Set(string, uint16)
+	Eval() error
+	Tick()
+	Tock()
+}
+
+// Optional interface implemented by the components with an internal state (registers and memories),
+// the state is exposed to the test scripts with the 'Name[index]' syntax (e.g. 'RAM16K[42]', 'PC[]').
+type Stateful interface {
+	State() []uint16
+}
+
+// A single link between a pin of a part and a wire of the enclosing composite. Each link moves
+// 'width' bits, from 'pinStart' on the part side and from 'wireStart' on the composite side.
+type link struct {
+	pin       string // The name of the part's pin
+	pinStart  int    // The index of the first bit linked on the part's pin
+	wire      int    // The index of the wire in the composite, -1 for constant links
+	wireStart int    // The index of the first bit linked on the wire
+	width     int    // The number of bits linked
+	constant  uint16 // The value of the link, used only for constant links ('true' or 'false')
+}
+
+// Returns a mask with the lowest 'width' bits set.
+func mask(width int) uint16 { return uint16(1<<width - 1) }
+
+// A component made of other components (its parts) connected through wires.
+type composite struct {
+	chip   Chip           // The chip declaration the component has been built from
+	parts  []Component    // The parts of the chip, in order of declaration
+	wires  []uint16       // The value of every wire (input, output and internal pins)
+	widths []int          // The width of every wire
+	index  map[string]int // Maps the pin names to the wire index
+
+	feeds  [][]feed // For each part, the links that feed its input pins (grouped by pin)
+	drains [][]link // For each part, the links that read its output pins
+}
+
+// The list of links that, together, compute the value of an input pin of a part.
+type feed struct {
+	pin   string
+	links []link
+}
+
+func (c *composite) Name() string       { return c.chip.Name }
+func (c *composite) Inputs() []Pin      { return c.chip.Inputs }
+func (c *composite) Outputs() []Pin     { return c.chip.Outputs }
+func (c *composite) Parts() []Component { return c.parts }
+
+func (c *composite) Get(pin string) uint16 {
+	if wire, found := c.index[pin]; found {
+		return c.wires[wire]
+	}
+	return 0
+}
+
+func (c *composite) Set(pin string, value uint16) {
+	if wire, found := c.index[pin]; found {
+		c.wires[wire] = value & mask(c.widths[wire])
+	}
+}
+
+// Evaluates the parts, in order of declaration, until the values on the wires are stable.
+//
+// Since the parts can be declared in any order (and loops are allowed as long as they go through
+// a clocked part) we cannot evaluate them just once, instead we iterate until a full pass leaves
+// all the wires untouched. A combinational loop would never converge so the number of passes is
+// capped: with no loops each pass settles at least one more part, so 'len(parts)' are enough and
+// if the wires are still changing after that we report a circular data flow (like the official
+// Hardware Simulator does) instead of leaving the outputs in an unsettled state.
+func (c *composite) Eval() error {
+	for pass := 0; pass <= len(c.parts); pass++ {
+		changed := false
+
+		for i, part := range c.parts {
+			c.feed(i)
+			if err := part.Eval(); err != nil {
+				return err
+			}
+
+			for _, l := range c.drains[i] {
+				value := (part.Get(l.pin) >> l.pinStart) & mask(l.width)
+				old := c.wires[l.wire]
+				c.wires[l.wire] = old&^(mask(l.width)<<l.wireStart) | value<<l.wireStart
+				changed = changed || c.wires[l.wire] != old
+			}
+		}
+
+		if !changed {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("circular data flow in chip '%s', the outputs never settle", c.chip.Name)
+}
+
+func (c *composite) Tick() {
+	for i, part := range c.parts {
+		c.feed(i)
+		part.Tick()
+	}
+}
+
+func (c *composite) Tock() {
+	for _, part := range c.parts {
+		part.Tock()
+	}
+}
+
+// Computes the value of every input pin of the i-th part, from the wires it's connected to.
+func (c *composite) feed(i int) {
+	for _, f := range c.feeds[i] {
+		pin := uint16(0)
+		for _, l := range f.links {
+			value := l.constant & mask(l.width)
+			if l.wire >= 0 {
+				value = (c.wires[l.wire] >> l.wireStart) & mask(l.width)
+			}
+			pin |= value << l.pinStart
+		}
+		c.parts[i].Set(f.pin, pin)
+	}
+}
+
+// ----------------------------------------------------------------------------
+// Resolver
+
+// This section defines the Resolver, that instantiates the components of the simulation tree.
+//
+// The parts used by a chip are looked up (by name) in this order, mimicking what the official
+// Hardware Simulator does and extending it to allow the composition of chips across projects:
+//   - In the chip's own folder (e.g. a 'Mux.hdl' next to the 'Mux4Way16.hdl' under test)
+//   - In the set of builtin chips (e.g. 'Nand', 'DFF', 'RAM16K', 'Screen', 'Keyboard')
+//   - In the library folders, in the order provided (e.g. the sibling folders of the project)
+type Resolver struct {
+	dir     string          // The folder of the chip under test
+	library []string        // The folders used as fallback, for chips that aren't builtin
+	chips   map[string]Chip // Cache of the chips already parsed, indexed by name
+	stack   map[string]bool // The chips being built, used to detect recursive definitions
+}
+
+// Initializes and returns to the caller a brand new 'Resolver' struct.
+// Requires the folder 'dir' of the chip under test and optionally the 'library' folders.
+func NewResolver(dir string, library ...string) Resolver {
+	return Resolver{dir: dir, library: library, chips: map[string]Chip{}, stack: map[string]bool{}}
+}
+
+// Instantiates a new component for the chip 'name', looking it up as described above.
+func (r *Resolver) Resolve(name string) (Component, error) {
+	if chip, found := r.chips[name]; found {
+		return r.Build(chip)
+	}
+
+	if path := filepath.Join(r.dir, name+".hdl"); exists(path) {
+		return r.load(path)
+	}
+	if component, found := NewBuiltIn(name); found {
+		return component, nil
+	}
+	for _, dir := range r.library {
+		if path := filepath.Join(dir, name+".hdl"); exists(path) {
+			return r.load(path)
+		}
+	}
+
+	return nil, fmt.Errorf("chip '%s' not found, neither as '.hdl' file nor as builtin", name)
+}
+
+// Parses the chip declared in the '.hdl' file at 'path' and instantiates a component for it.
+func (r *Resolver) load(path string) (Component, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	parser := NewParser(file)
+	chip, err := parser.Parse()
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse '%s': %w", path, err)
+	}
+
+	r.chips[chip.Name] = chip
+	return r.Build(chip)
+}
+
+// Instantiates a new component for the given 'chip', recursively resolving all of its parts.
+func (r *Resolver) Build(chip Chip) (Component, error) {
+	if chip.BuiltIn != "" { // The chip is just the interface of a native implementation
+		component, found := NewBuiltIn(chip.BuiltIn)
+		if !found {
+			return nil, fmt.Errorf("chip '%s' declares unknown builtin '%s'", chip.Name, chip.BuiltIn)
+		}
+		return component, nil
+	}
+
+	if r.stack[chip.Name] {
+		return nil, fmt.Errorf("chip '%s' is recursively defined", chip.Name)
+	}
+	r.stack[chip.Name] = true
+	defer delete(r.stack, chip.Name)
+
+	c := &composite{chip: chip, index: map[string]int{}}
+	declare := func(name string, width int) {
+		c.index[name] = len(c.wires)
+		c.wires, c.widths = append(c.wires, 0), append(c.widths, width)
+	}
+	for _, pin := range chip.Inputs {
+		declare(pin.Name, pin.Width)
+	}
+	for _, pin := range chip.Outputs {
+		declare(pin.Name, pin.Width)
+	}
+
+	// First pass: instantiates the parts and wires their outputs, declaring the internal pins
+	for _, decl := range chip.Parts {
+		part, err := r.Resolve(decl.Name)
+		if err != nil {
+			return nil, err
+		}
+
+		drains := []link{}
+		for _, conn := range decl.Connections {
+			width, found := widthOf(part.Outputs(), conn.Pin.Name)
+			if !found {
+				continue // Not an output pin, will be linked in the second pass
+			}
+
+			l, err := c.connect(decl.Name, conn, width, true, declare)
+			if err != nil {
+				return nil, err
+			}
+			drains = append(drains, l)
+		}
+
+		c.parts, c.drains = append(c.parts, part), append(c.drains, drains)
+	}
+
+	// Second pass: wires the inputs of the parts, now that every internal pin is declared
+	for i, decl := range chip.Parts {
+		feeds := []feed{}
+		for _, conn := range decl.Connections {
+			if _, found := widthOf(c.parts[i].Outputs(), conn.Pin.Name); found {
+				continue // Output pin, already linked in the first pass
+			}
+
+			width, found := widthOf(c.parts[i].Inputs(), conn.Pin.Name)
+			if !found {
+				return nil, fmt.Errorf("part '%s' in chip '%s' has no pin named '%s'", decl.Name, chip.Name, conn.Pin.Name)
+			}
+
+			l, err := c.connect(decl.Name, conn, width, false, declare)
+			if err != nil {
+				return nil, err
+			}
+
+			// Links to the same pin (each one on a different sub-bus) are grouped together
+			grouped := false
+			for j := range feeds {
+				if feeds[j].pin == l.pin {
+					feeds[j].links, grouped = append(feeds[j].links, l), true
+				}
+			}
+			if !grouped {
+				feeds = append(feeds, feed{pin: l.pin, links: []link{l}})
+			}
+		}
+
+		c.feeds = append(c.feeds, feeds)
+	}
+
+	return c, nil
+}
+
+// Validates a single connection of a part and converts it to a link. The 'output' flag tells
+// whether the part's pin is an output (the signal is written) or an input (the signal is read).
+func (c *composite) connect(part string, conn Connection, width int, output bool, declare func(string, int)) (link, error) {
+	pinStart, pinWidth, err := span(conn.Pin, width)
+	if err != nil {
+		return link{}, fmt.Errorf("part '%s' in chip '%s': %w", part, c.chip.Name, err)
+	}
+
+	signal := conn.Signal.Name
+	if signal == True || signal == False {
+		if output {
+			return link{}, fmt.Errorf("part '%s' in chip '%s' cannot output to constant '%s'", part, c.chip.Name, signal)
+		}
+		value := uint16(0)
+		if signal == True {
+			value = 0xFFFF
+		}
+		return link{pin: conn.Pin.Name, pinStart: pinStart, wire: -1, width: pinWidth, constant: value}, nil
+	}
+
+	_, isInput := widthOf(c.chip.Inputs, signal)
+	_, isOutput := widthOf(c.chip.Outputs, signal)
+	if output && isInput {
+		return link{}, fmt.Errorf("part '%s' in chip '%s' cannot output to input pin '%s'", part, c.chip.Name, signal)
+	}
+	if !output && isOutput {
+		return link{}, fmt.Errorf("part '%s' in chip '%s' cannot read from output pin '%s'", part, c.chip.Name, signal)
+	}
+
+	wire, found := c.index[signal]
+	if !found && !output {
+		return link{}, fmt.Errorf("part '%s' in chip '%s' reads from undeclared pin '%s'", part, c.chip.Name, signal)
+	}
+	if !found { // First time we see this internal pin, its width is inferred from the part
+		declare(signal, pinWidth)
+		wire = c.index[signal]
+	}
+
+	wireStart, wireWidth, err := span(conn.Signal, c.widths[wire])
+	if err != nil {
+		return link{}, fmt.Errorf("part '%s' in chip '%s': %w", part, c.chip.Name, err)
+	}
+	if wireWidth != pinWidth {
+		return link{}, fmt.Errorf("part '%s' in chip '%s': width mismatch between '%s' (%d bits) and '%s' (%d bits)",
+			part, c.chip.Name, conn.Pin.Name, pinWidth, signal, wireWidth)
+	}
+
+	return link{pin: conn.Pin.Name, pinStart: pinStart, wire: wire, wireStart: wireStart, width: pinWidth}, nil
+}
+
+// Returns the first bit and the number of bits referenced by 'bus' on a pin 'width' bits wide.
+func span(bus Bus, width int) (int, int, error) {
+	if !bus.Sliced {
+		return 0, width, nil
+	}
+	if bus.End >= width {
+		return 0, 0, fmt.Errorf("sub-bus '%s[%d..%d]' out of bound, pin is %d bits wide", bus.Name, bus.Start, bus.End, width)
+	}
+	return bus.Start, bus.End - bus.Start + 1, nil
+}
+
+// Looks up the pin 'name' in 'pins', returning its width.
+func widthOf(pins []Pin, name string) (int, bool) {
+	for _, pin := range pins {
+		if pin.Name == name {
+			return pin.Width, true
+		}
+	}
+	return 0, false
+}
+
+// Checks whether a file exists at 'path'.
+func exists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
@@ -1,6 +1,11 @@
 package jack
 
-import "its-hmny.dev/nand2tetris/pkg/utils"
+import (
+	"sort"
+	"strconv"
+
+	"its-hmny.dev/nand2tetris/pkg/utils"
+)
 
 // ----------------------------------------------------------------------------
 // General information
@@ -14,12 +19,41 @@ import "its-hmny.dev/nand2tetris/pkg/utils"
 // - Subroutines: to declare containers of instruction (also used for class' methods)
 // - Statements: to perform a side effect, conditional jump or other program flow changes
 // - Expressions: to perform a calculation that produces a result (arithmetic ops and so on...)
+//
+// Every construct keeps track of its 'Position' in the source file, to be used when reporting errors.
 
 // A Jack Program is just a set of multiple classes, in the Jack spec each class is translated
 // to its own .vm file (just like Java .class file) so the class is to be considered the top-level
 // entity of the program and is mapped to a role equal to module or namespace in other languages.
 type Program map[string]Class
 
+// Returns the classes of the program sorted by name, so that it can be iterated in a deterministic order.
+//
+// ? Why do we convert from a jack.Program (wrapper type of a map[string]Class to an OrderedMap[string, Class]?
+// Without doing this is impossible to have reproducible builds (and also meaningful test cases) because
+// the Go built-in map is not ordered and non-deterministic, so the order of iteration of the classes can
+// change on different runs, then what happens is that the label declarations will be different too since
+// they are randomized with just a counter (the counter will have different values because it will be
+// incremented a different number of times based on the order of the classes). The same applies to the
+// order of the errors reported by the TypeChecker.
+//
+// The solution is simple: we order the map by its class name and store it in that order in the OrderedMap
+// so that the order we decided we'll be maintained throughout the entire process. The end result
+// is that for the same input code we obtain always the same output code.
+func (p Program) Sorted() utils.OrderedMap[string, Class] {
+	//* 1. From unsorted map to unsorted slice of MapEntry[string, Class] (used later bu OrderedMap)
+	classes := []utils.MapEntry[string, Class]{}
+	for _, class := range p {
+		classes = append(classes, utils.MapEntry[string, Class]{Key: class.Name, Value: class})
+	}
+
+	//* 2. We sort the slice by classname so that we have a reproducible order to use
+	sort.Slice(classes, func(i, j int) bool { return sort.StringsAreSorted([]string{classes[i].Key, classes[j].Key}) })
+
+	//* 3. From sorted slice we create an order map where the insertion order and the alphabetic are the same
+	return utils.NewOrderedMapFromList(classes)
+}
+
 // ----------------------------------------------------------------------------
 // Classes
 
@@ -30,7 +64,78 @@ type Program map[string]Class
 type Class struct {
 	Name        string                               // The class name or id, will also identify the instantiated object type
 	Fields      utils.OrderedMap[string, Variable]   // The variable (static ors not) associated to the class or object instance
+	Constants   utils.OrderedMap[string, Constant]   // The constants (declared w/ 'const' or 'enum') associated to the class
 	Subroutines utils.OrderedMap[string, Subroutine] // The subroutines (static or not) associated to the class or object instance
+
+	Position utils.Position // The location of the class name in the source code
+}
+
+// A Constant is a named value known at compile time, it's inlined where used and never allocated in memory.
+//
+// Constants are declared at class level either one by one (e.g. 'const int WIDTH = 512;') or as the
+// members of an enum (e.g. 'enum Direction { UP, DOWN }') that are 'int' constants numbered from 0
+// (or from the value given to the previous member, e.g. 'enum Key { LEFT = 130, UP, RIGHT, DOWN }').
+// In the declaring class they're referenced by name while the other classes use 'Class.NAME', the
+// members of an enum can also be qualified w/ the enum name ('Key.UP' or 'Class.Key.UP'). In the
+// declaring class the enum name can be used as type as well (e.g. 'var Key k;'), an alias of 'int'.
+type Constant struct {
+	Name     string     // The constant name, unique in the class along w/ the fields
+	DataType DataType   // The type of the constant ('int' for the members of an enum)
+	Value    Expression // The value assigned in the declaration, a literal (possibly negated)
+	Enum     string     // The name of the enum declaring the constant ("" for 'const' declarations)
+
+	Position utils.Position // The location of the constant name in its declaration
+}
+
+// Reports whether the value of the constant is known at compile time, that is an 'int' or 'char'
+// literal (see 'ConstantValue') or a 'boolean' one, any other expression is not allowed.
+func (c Constant) IsValid() bool {
+	if literal, isLiteral := c.Value.(LiteralExpr); isLiteral && literal.Type.Main == Bool {
+		return true
+	}
+	_, isConst := ConstantValue(c.Value)
+	return isConst
+}
+
+// Reports whether the class declares an enum named 'name'.
+func (c Class) HasEnum(name string) bool {
+	for constant := range c.Constants.Values() {
+		if constant.Enum != "" && constant.Enum == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the constant referenced by 'expr' in 'program', either by name ('jack.VarExpr') for the ones
+// declared in 'class' or w/ a qualified reference ('jack.ConstExpr'). A variable w/ the same name of
+// the constant takes precedence over the latter, so this has to be checked by the caller beforehand.
+// In the same way an enum of 'class' takes precedence over a class w/ the same name (e.g. 'Key.UP').
+func LookupConstant(program utils.OrderedMap[string, Class], class string, expr Expression) (Constant, bool) {
+	switch tExpr := expr.(type) {
+	case VarExpr:
+		class, found := program.Get(class)
+		if !found {
+			return Constant{}, false
+		}
+		return class.Constants.Get(tExpr.Var)
+	case ConstExpr:
+		owner, enum := tExpr.Class, tExpr.Enum
+		if current, found := program.Get(class); found && enum == "" && current.HasEnum(tExpr.Class) {
+			owner, enum = class, tExpr.Class
+		}
+
+		declaring, found := program.Get(owner)
+		if !found {
+			return Constant{}, false
+		}
+		constant, found := declaring.Constants.Get(tExpr.Name)
+		if !found || (enum != "" && constant.Enum != enum) {
+			return Constant{}, false
+		}
+		return constant, true
+	}
+	return Constant{}, false
 }
 
 // ----------------------------------------------------------------------------
@@ -49,6 +154,8 @@ type Subroutine struct {
 	Arguments []Variable // The set of arguments to be provided and used during the execution
 
 	Statements []Statement // The list of statements to be executed, a representation of the func program flow
+
+	Position utils.Position // The location of the subroutine name in the source code
 }
 
 type SubroutineType string // Enum to manage the different type allowed for a Subroutine
@@ -71,30 +178,74 @@ type Statement interface{}
 
 type DoStmt struct { // Unconditional jump, will call another subroutine and ignore its return value
 	FuncCall FuncCallExpr //The function to be called
+
+	Position utils.Position // The location of the statement in the source code
 }
 
 type VarStmt struct { // Variable declaration construct, will allocate a new var w/o a given value
 	Vars []Variable // The name or identifiers of the new local variables
+
+	Position utils.Position // The location of the statement in the source code
 }
 
 type LetStmt struct { // Variable assignment construct, will allocate a new var w/ a given value
 	Lhs Expression // The expression to be assigned the value (only VarExpr and ArrayExpr are allowed)
 	Rhs Expression // The expression to be evaluated and assigned to the LHS counterpart (all Expression are allowed)
+
+	Position utils.Position // The location of the statement in the source code
 }
 
 type ReturnStmt struct { // Unconditional jump, will go back to the caller and provide it an (optional) output
 	Expr Expression // The expression to be eval'd, casted to a the return value of the func
+
+	Position utils.Position // The location of the statement in the source code
 }
 
 type IfStmt struct { // Conditional jump construct, will have to fork the execution flow based on a condition
 	Condition Expression  // The expression to be eval'd, casted to a bool value
 	ThenBlock []Statement // The code block to be executed if the condition is met
-	ElseBlock []Statement // The code block to be executed if the condition is not met
+	ElseBlock []Statement // The code block to be executed if the condition is not met (nil w/o 'else')
+
+	Position utils.Position // The location of the statement in the source code
 }
 
 type WhileStmt struct { // Conditional iteration construct, will execute a block based on a condition
 	Condition Expression  // The expression to be eval'd, casted to a bool value
 	Block     []Statement // The code block to be executed if the condition is met
+
+	Position utils.Position // The location of the statement in the source code
+}
+
+type ForStmt struct { // Conditional iteration construct, like 'WhileStmt' but w/ an initialization and a step
+	Init      Statement   // The assignment executed once before the loop (nil if omitted)
+	Condition Expression  // The expression to be eval'd, casted to a bool value (nil if omitted, always true)
+	Step      Statement   // The assignment executed after each iteration (nil if omitted)
+	Block     []Statement // The code block to be executed if the condition is met
+
+	Position utils.Position // The location of the statement in the source code
+}
+
+type SwitchStmt struct { // Multi-way jump construct, will execute the block of the case matching the expression
+	Expr    Expression   // The expression to be eval'd and compared against the label of each case
+	Cases   []SwitchCase // The cases in the same order as in the source code
+	Default []Statement  // The code block to be executed if no case matches (nil if omitted)
+
+	Position utils.Position // The location of the statement in the source code
+}
+
+type SwitchCase struct { // A single case of a 'SwitchStmt', there's no fallthrough to the next one
+	Label Expression  // The constant value that selects the case (e.g. '1', 'a' or '-1')
+	Block []Statement // The code block to be executed if the label matches
+
+	Position utils.Position // The location of the case in the source code
+}
+
+type BreakStmt struct { // Unconditional jump, will exit from the innermost loop (or switch)
+	Position utils.Position // The location of the statement in the source code
+}
+
+type ContinueStmt struct { // Unconditional jump, will skip to the next iteration of the innermost loop
+	Position utils.Position // The location of the statement in the source code
 }
 
 // ----------------------------------------------------------------------------
@@ -109,32 +260,59 @@ type Expression interface{}
 
 type VarExpr struct { // Extracts the value contained in a variable
 	Var string // The name or identifier of the variable we want the value of
+
+	Parens   int            // The number of parentheses pairs wrapping the expression in the source code
+	Position utils.Position // The location of the expression in the source code
 }
 
 type LiteralExpr struct { // Extracts the value of a constant (also called literal)
 	Type  DataType // The literal type (string, int, char, ...)
 	Value string   // The constant value to be produced
+
+	Parens   int            // The number of parentheses pairs wrapping the expression in the source code
+	Position utils.Position // The location of the expression in the source code
 }
 
 type ArrayExpr struct { // Extracts the value of a single cell/element for an array
 	Var   string     // The name or identifier of the array we want the value from
 	Index Expression // The index of the value we want to extract
+
+	Parens   int            // The number of parentheses pairs wrapping the expression in the source code
+	Position utils.Position // The location of the expression in the source code
 }
 
 type CastExpr struct {
 	Type DataType
 	Rhs  Expression
+
+	Parens   int            // The number of parentheses pairs wrapping the expression in the source code
+	Position utils.Position // The location of the expression in the source code
 }
 
 type UnaryExpr struct { // Applies a transformation to 1 expression to produce a new value
 	Type ExprType   //  Here only 'Minus' and 'BoolNot' are allowed
 	Rhs  Expression // UnaryExpr do only apply to the expr on the Right Hand Side
+
+	Parens   int            // The number of parentheses pairs wrapping the expression in the source code
+	Position utils.Position // The location of the expression in the source code
 }
 
 type BinaryExpr struct { // Combines the value of 2 expression to produce a new value
 	Type ExprType   // Here only 'BoolNot' is not allowed
 	Lhs  Expression // The expression o the Left Hand Side (1st to be evaluated)
 	Rhs  Expression // The expression o the Right Hand Side (2nd to be evaluated)
+
+	Parens   int            // The number of parentheses pairs wrapping the expression in the source code
+	Position utils.Position // The location of the operator in the source code
+}
+
+type ConstExpr struct { // Reference to a constant declared in another class, e.g. 'Screen.WIDTH'
+	Class string // The class declaring the constant (or the enum, for the members of the current class)
+	Enum  string // The enum declaring the constant, only if qualified by both class and enum (e.g. 'Main.Key.UP')
+	Name  string // The name/id of the constant in the class
+
+	Parens   int            // The number of parentheses pairs wrapping the expression in the source code
+	Position utils.Position // The location of the expression in the source code
 }
 
 type FuncCallExpr struct { // Call another subroutine for a variable or inside the same class
@@ -143,6 +321,83 @@ type FuncCallExpr struct { // Call another subroutine for a variable or inside t
 	FuncName  string // The name/id of the desired subroutine we want to execute
 
 	Arguments []Expression // The arguments list to be passed (they are yet to be evaluated)
+
+	Parens   int            // The number of parentheses pairs wrapping the expression in the source code
+	Position utils.Position // The location of the expression in the source code
+}
+
+// Returns the value of 'expr' if it's an integer or char literal (possibly negated), that is the only
+// kind of constant expression allowed as value of the constants (and, along w/ them, as 'switch' label).
+func ConstantValue(expr Expression) (int16, bool) {
+	switch tExpr := expr.(type) {
+	case LiteralExpr:
+		if tExpr.Type.Main == Char && len(tExpr.Value) == 1 {
+			return int16(tExpr.Value[0]), true
+		}
+		if value, err := strconv.ParseInt(tExpr.Value, 10, 16); tExpr.Type.Main == Int && err == nil {
+			return int16(value), true
+		}
+	case UnaryExpr:
+		if value, isConst := ConstantValue(tExpr.Rhs); tExpr.Type == Negation && isConst {
+			return -value, true
+		}
+	}
+	return 0, false
+}
+
+// Returns the number of parentheses pairs wrapping 'expr' in the source code, they don't change
+// the semantic of the expression and they're recorded only to rebuild the source (see 'XMLEmitter').
+func parensOf(expr Expression) int {
+	switch tExpr := expr.(type) {
+	case VarExpr:
+		return tExpr.Parens
+	case LiteralExpr:
+		return tExpr.Parens
+	case ArrayExpr:
+		return tExpr.Parens
+	case CastExpr:
+		return tExpr.Parens
+	case UnaryExpr:
+		return tExpr.Parens
+	case BinaryExpr:
+		return tExpr.Parens
+	case ConstExpr:
+		return tExpr.Parens
+	case FuncCallExpr:
+		return tExpr.Parens
+	}
+	return 0
+}
+
+// Returns a copy of 'expr' wrapped by exactly 'parens' pairs of parentheses (see 'parensOf').
+func withParens(expr Expression, parens int) Expression {
+	switch tExpr := expr.(type) {
+	case VarExpr:
+		tExpr.Parens = parens
+		return tExpr
+	case LiteralExpr:
+		tExpr.Parens = parens
+		return tExpr
+	case ArrayExpr:
+		tExpr.Parens = parens
+		return tExpr
+	case CastExpr:
+		tExpr.Parens = parens
+		return tExpr
+	case UnaryExpr:
+		tExpr.Parens = parens
+		return tExpr
+	case BinaryExpr:
+		tExpr.Parens = parens
+		return tExpr
+	case ConstExpr:
+		tExpr.Parens = parens
+		return tExpr
+	case FuncCallExpr:
+		tExpr.Parens = parens
+		return tExpr
+	}
+	return expr
 }
 
 type ExprType string // Enum to manage the operation allowed for an ExprType
@@ -152,15 +407,22 @@ const (
 	Minus    ExprType = "minus" // Used both for subtraction (BinaryExpr)
 	Divide   ExprType = "divide"
 	Multiply ExprType = "multiply"
+	Modulo   ExprType = "modulo"   // Lowered inline to 'x - (x / y) * y', using 'Math.divide' and 'Math.multiply'
 	Negation ExprType = "negation" // Used for arithmetic negation (UnaryExpr)
 
 	BoolOr  ExprType = "bool_or"
 	BoolAnd ExprType = "bool_and"
 	BoolNot ExprType = "bool_neg"
 
-	Equal     ExprType = "equal"
-	LessThan  ExprType = "less_than"
-	GreatThan ExprType = "greater_than"
+	LogicalOr  ExprType = "logical_or"  // Short-circuit: the RHS is evaluated only if the LHS is false
+	LogicalAnd ExprType = "logical_and" // Short-circuit: the RHS is evaluated only if the LHS is true
+
+	Equal      ExprType = "equal"
+	NotEqual   ExprType = "not_equal"
+	LessThan   ExprType = "less_than"
+	LessEqual  ExprType = "less_equal"
+	GreatThan  ExprType = "greater_than"
+	GreatEqual ExprType = "greater_equal"
 )
 
 // ----------------------------------------------------------------------------
@@ -175,6 +437,8 @@ type Variable struct {
 	Name     string   // The var name, acts as identifier in the scope it is declared
 	VarType  VarType  // The variable type helps determine the scope of the variable
 	DataType DataType // The data type defines how to read or cast the value contained by the variable
+
+	Position utils.Position // The location of the variable name in its declaration
 }
 
 type VarType string // Enum to manage the operation allowed for an VarType
@@ -191,8 +455,16 @@ type DataType struct {
 	Subtype string   // Nested subtype: specific class name when Main = Object, else empty
 }
 
+// Returns the textual representation of the type as written in the source code (e.g. 'int' or 'Fraction').
+func (dt DataType) String() string {
+	if dt.Main == Object && dt.Subtype != "" {
+		return dt.Subtype
+	}
+	return string(dt.Main)
+}
+
 func (actual DataType) Matches(expected DataType) bool {
-	if actual.Main == Wildcard || expected.Main == Wildcard {
+	if actual.Main == Wildcard || expected.Main == Wildcard || actual.Main == Poisoned || expected.Main == Poisoned {
 		return true
 	}
 
@@ -210,4 +482,5 @@ const (
 	Object MainType = "Object"
 
 	Wildcard MainType = "*" // Used to skip/ignore typechecking validation (e.g. on null literals or array access)
+	Poisoned MainType = "?" // Used for expressions that failed typechecking, to avoid reporting cascading errors
 )
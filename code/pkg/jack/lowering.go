@@ -1,8 +1,9 @@
 package jack
 
 import (
+	"cmp"
 	"fmt"
-	"sort"
+	"math"
 	"strconv"
 	"strings"
 
@@ -22,33 +23,31 @@ type Lowerer struct {
 	program     utils.OrderedMap[string, Class] // The program to lower, it must be not nil nor empty
 	scopes      ScopeTable                      // Keeps track of the scopes and declared variables inside each one
 	nRandomizer uint                            // Counter to randomize 'vm.LabelDecl(s)' with same name
+	loops       []LoopLabels                    // The jump targets of the loops (and switches) being lowered, innermost last
 }
 
+// The targets of the 'break' and 'continue' statements inside a loop.
+//
+// The labels of a loop are numbered only after its block has been lowered (see 'nRandomizer'), so the
+// jumps are emitted w/ a placeholder label first and then resolved by the loop itself once the actual
+// labels are known (see 'resolveJumps'), this way the numbering is the same w/ or w/o these statements.
+type LoopLabels struct {
+	Break    string // The label right after the loop ('WHILE_END_n' or 'SWITCH_END_n')
+	Continue string // The label where the next iteration begins ('WHILE_START_n' or the for step)
+}
+
+// The min number of cases (and the min fraction of the range of the labels they cover) for a 'switch'
+// to be lowered w/ a binary search over its labels instead of comparing the value against each in turn.
+const (
+	BinarySearchCases    = 4
+	BinarySearchCoverage = 0.5
+)
+
 // Initializes and returns to the caller a brand new 'Lowerer' struct.
 // Requires the argument Program to be not nil nor empty.
 func NewLowerer(p Program) Lowerer {
-	// ? Why do we convert from a jack.Program (wrapper type of a map[string]Class to an OrderedMap[string, Class]?
-	// Without doing this is impossible to have reproducible builds (and also meaningful test cases) because
-	// the Go built-in map is not ordered and non-deterministic, so the order of iteration of the classes can
-	// change on different runs, then what happens is that the label declarations will be different too since
-	// they are randomized with just a counter (the counter will have different values because it will be
-	// incremented a different number of times based on the order of the classes).
-	//
-	// The solution is simple: we order the map by its class name and store it in that order in the OrderedMap
-	// so that the order we decided we'll be maintained throughout the entire lowering process. The end result
-	// is that for the same input code we obtain always the same output code.
-
-	//* 1. From unsorted map to unsorted slice of MapEntry[string, Class] (used later bu OrderedMap)
-	classes := []utils.MapEntry[string, Class]{}
-	for _, class := range p {
-		classes = append(classes, utils.MapEntry[string, Class]{Key: class.Name, Value: class})
-	}
-
-	//* 2. We sort the slice by classname so that we have a reproducible order to use// 	//  map to unsorted slice of MapEntry[string, Class] (used later bu OrderedMap)
-	sort.Slice(classes, func(i, j int) bool { return sort.StringsAreSorted([]string{classes[i].Key, classes[j].Key}) })
-
-	//* 3. From sorted slice we create an order map where the insertion order and the alphabetic are the same
-	return Lowerer{program: utils.NewOrderedMapFromList(classes), scopes: ScopeTable{}}
+	// The classes are sorted by name to have reproducible builds, see 'Program.Sorted' for more info
+	return Lowerer{program: p.Sorted(), scopes: ScopeTable{}}
 }
 
 // Triggers the lowering process. It iterates class by class and then statement by statement
@@ -87,6 +86,13 @@ func (l *Lowerer) HandleClass(class Class) ([]vm.Operation, error) {
 		operations = append(operations, ops...)
 	}
 
+	// The constants are inlined where used (see 'HandleConstExpr'), so no memory is allocated for them
+	for _, constant := range class.Constants.Entries() {
+		if !constant.IsValid() {
+			return nil, constant.Position.Errorf("value of constant '%s' should be a literal", constant.Name)
+		}
+	}
+
 	for _, subroutine := range class.Subroutines.Entries() {
 		ops, err := l.HandleSubroutine(subroutine)
 		if err != nil {
@@ -140,7 +146,7 @@ func (l *Lowerer) HandleSubroutine(subroutine Subroutine) ([]vm.Operation, error
 		className := strings.Split(l.scopes.GetScope(), ".")[0] // Get the class name from the scope
 		class, exists := l.program.Get(className)
 		if !exists {
-			return nil, fmt.Errorf("class '%s' not found", className)
+			return nil, subroutine.Position.Errorf("class '%s' not found", className)
 		}
 
 		nFields := uint16(0)
@@ -188,6 +194,20 @@ func (l *Lowerer) HandleStatement(stmt Statement) ([]vm.Operation, error) {
 		return l.HandleIfStmt(tStmt)
 	case WhileStmt:
 		return l.HandleWhileStmt(tStmt)
+	case ForStmt:
+		return l.HandleForStmt(tStmt)
+	case SwitchStmt:
+		return l.HandleSwitchStmt(tStmt)
+	case BreakStmt:
+		if len(l.loops) == 0 {
+			return nil, tStmt.Position.Errorf("'break' statement outside of a loop or switch")
+		}
+		return []vm.Operation{vm.GotoOp{Label: l.loops[len(l.loops)-1].Break, Jump: vm.Unconditional}}, nil
+	case ContinueStmt:
+		if len(l.loops) == 0 || l.loops[len(l.loops)-1].Continue == "" {
+			return nil, tStmt.Position.Errorf("'continue' statement outside of a loop")
+		}
+		return []vm.Operation{vm.GotoOp{Label: l.loops[len(l.loops)-1].Continue, Jump: vm.Unconditional}}, nil
 	case ReturnStmt:
 		return l.HandleReturnStmt(tStmt)
 	default:
@@ -229,7 +249,7 @@ func (l *Lowerer) HandleLetStmt(statement LetStmt) ([]vm.Operation, error) {
 	if expr, isVarExpr := statement.Lhs.(VarExpr); isVarExpr {
 		offset, variable, err := l.scopes.ResolveVariable(expr.Var)
 		if err != nil {
-			return nil, fmt.Errorf("error resolving variable '%s' in array expression: %w", expr.Var, err)
+			return nil, expr.Position.Errorf("error resolving variable '%s' in array expression: %w", expr.Var, err)
 		}
 
 		switch variable.VarType {
@@ -242,14 +262,14 @@ func (l *Lowerer) HandleLetStmt(statement LetStmt) ([]vm.Operation, error) {
 		case Static:
 			return append(rhsOps, vm.MemoryOp{Operation: vm.Pop, Segment: vm.Static, Offset: offset}), nil
 		default:
-			return nil, fmt.Errorf("variable type '%s' is not supported yet", variable.VarType)
+			return nil, expr.Position.Errorf("variable type '%s' is not supported yet", variable.VarType)
 		}
 	}
 
 	// For ArrayExpr instead we reuse the pointer + offset logic from HandleArrayExpr but after that we write
 	// a bit of glue code to save the RHS on temporary memory before loading the new address and writing it
 	if expr, isArrayExpr := statement.Lhs.(ArrayExpr); isArrayExpr {
-		baseOps, err := l.HandleVarExpr(VarExpr{Var: expr.Var})
+		baseOps, err := l.HandleVarExpr(VarExpr{Var: expr.Var, Position: expr.Position})
 		if err != nil {
 			return nil, fmt.Errorf("error handling base variable expression: %w", err)
 		}
@@ -273,7 +293,7 @@ func (l *Lowerer) HandleLetStmt(statement LetStmt) ([]vm.Operation, error) {
 		return append(append(refOps, rhsOps...), writeOps...), nil
 	}
 
-	return nil, fmt.Errorf("LHS expression must be either a 'VarExpr' or an 'ArrayExpr', got: %T", statement.Lhs)
+	return nil, statement.Position.Errorf("LHS expression must be either a 'VarExpr' or an 'ArrayExpr', got: %T", statement.Lhs)
 }
 
 // Specialized function to convert a 'jack.WhileStmt' to a list of 'vm.Operation'.
@@ -283,7 +303,7 @@ func (l *Lowerer) HandleWhileStmt(statement WhileStmt) ([]vm.Operation, error) {
 		return nil, fmt.Errorf("error handling while condition expression: %w", err)
 	}
 
-	blockOps := []vm.Operation{}
+	blockOps, placeholders := []vm.Operation{}, l.pushLoop()
 
 	for _, stmt := range statement.Block {
 		ops, err := l.HandleStatement(stmt)
@@ -293,8 +313,14 @@ func (l *Lowerer) HandleWhileStmt(statement WhileStmt) ([]vm.Operation, error) {
 		blockOps = append(blockOps, ops...)
 	}
 
+	l.loops = l.loops[:len(l.loops)-1]
 	defer func() { l.nRandomizer += 2 }() // ! Increment the randomizer for next use
 
+	blockOps = resolveJumps(blockOps, placeholders, LoopLabels{
+		Break:    fmt.Sprintf("WHILE_END_%d", l.nRandomizer+1),
+		Continue: fmt.Sprintf("WHILE_START_%d", l.nRandomizer),
+	})
+
 	return append(append(append(append(
 		[]vm.Operation{vm.LabelDecl{Name: fmt.Sprintf("WHILE_START_%d", l.nRandomizer)}},
 		condOps...),
@@ -306,6 +332,240 @@ func (l *Lowerer) HandleWhileStmt(statement WhileStmt) ([]vm.Operation, error) {
 	), nil
 }
 
+// Specialized function to convert a 'jack.ForStmt' to a list of 'vm.Operation'.
+//
+// It's lowered like a 'jack.WhileStmt' preceded by the initialization, while the step is executed at
+// the end of each iteration (after its own label, the target of the 'continue' statements).
+func (l *Lowerer) HandleForStmt(statement ForStmt) ([]vm.Operation, error) {
+	initOps, condOps, stepOps := []vm.Operation{}, []vm.Operation{}, []vm.Operation{}
+
+	if statement.Init != nil {
+		ops, err := l.HandleStatement(statement.Init)
+		if err != nil {
+			return nil, fmt.Errorf("error handling for initialization statement: %w", err)
+		}
+		initOps = ops
+	}
+
+	placeholders := l.pushLoop() // Also the condition exits the loop, the jump is resolved along w/ the others
+
+	if statement.Condition != nil {
+		ops, err := l.HandleExpression(statement.Condition)
+		if err != nil {
+			return nil, fmt.Errorf("error handling for condition expression: %w", err)
+		}
+		// W/o a condition the loop can be exited only w/ a 'break' (or 'return') statement
+		condOps = append(ops, vm.ArithmeticOp{Operation: vm.Not}, vm.GotoOp{Label: placeholders.Break, Jump: vm.Conditional})
+	}
+
+	if statement.Step != nil {
+		ops, err := l.HandleStatement(statement.Step)
+		if err != nil {
+			return nil, fmt.Errorf("error handling for step statement: %w", err)
+		}
+		stepOps = ops
+	}
+
+	blockOps := []vm.Operation{}
+
+	for _, stmt := range statement.Block {
+		ops, err := l.HandleStatement(stmt)
+		if err != nil {
+			return nil, fmt.Errorf("error handling statement in for block: %w", err)
+		}
+		blockOps = append(blockOps, ops...)
+	}
+
+	l.loops = l.loops[:len(l.loops)-1]
+	defer func() { l.nRandomizer += 3 }() // ! Increment the randomizer for next use
+
+	labels := LoopLabels{
+		Break:    fmt.Sprintf("WHILE_END_%d", l.nRandomizer+1),
+		Continue: fmt.Sprintf("WHILE_STEP_%d", l.nRandomizer+2),
+	}
+	condOps, blockOps = resolveJumps(condOps, placeholders, labels), resolveJumps(blockOps, placeholders, labels)
+
+	return append(append(append(append(append(append(
+		initOps,
+		vm.LabelDecl{Name: fmt.Sprintf("WHILE_START_%d", l.nRandomizer)}),
+		condOps...),
+		blockOps...),
+		vm.LabelDecl{Name: fmt.Sprintf("WHILE_STEP_%d", l.nRandomizer+2)}),
+		stepOps...),
+		vm.GotoOp{Label: fmt.Sprintf("WHILE_START_%d", l.nRandomizer), Jump: vm.Unconditional},
+		vm.LabelDecl{Name: fmt.Sprintf("WHILE_END_%d", l.nRandomizer+1)},
+	), nil
+}
+
+// Pushes a new loop on the stack, returning the placeholder labels used for its jumps until resolved.
+// The placeholders contain a character not allowed in the labels so they can't clash w/ the real ones.
+func (l *Lowerer) pushLoop() LoopLabels {
+	depth := len(l.loops)
+	placeholders := LoopLabels{Break: fmt.Sprintf("@BREAK_%d", depth), Continue: fmt.Sprintf("@CONTINUE_%d", depth)}
+	l.loops = append(l.loops, placeholders)
+	return placeholders
+}
+
+// Same as 'pushLoop' but for a switch, that has no next iteration: 'continue' targets the enclosing loop.
+func (l *Lowerer) pushSwitch() LoopLabels {
+	placeholders := LoopLabels{Break: fmt.Sprintf("@BREAK_%d", len(l.loops))}
+	if len(l.loops) > 0 {
+		placeholders.Continue = l.loops[len(l.loops)-1].Continue
+	}
+	l.loops = append(l.loops, placeholders)
+	return placeholders
+}
+
+// Returns the name of the class being lowered.
+func (l *Lowerer) class() string { return strings.Split(l.scopes.GetScope(), ".")[0] }
+
+// Replaces the jumps to the 'placeholders' labels in 'ops' w/ the jumps to the actual 'labels'.
+func resolveJumps(ops []vm.Operation, placeholders LoopLabels, labels LoopLabels) []vm.Operation {
+	for i, op := range ops {
+		if jump, isJump := op.(vm.GotoOp); isJump {
+			switch jump.Label {
+			case placeholders.Break:
+				jump.Label = labels.Break
+			case placeholders.Continue:
+				jump.Label = labels.Continue
+			}
+			ops[i] = jump
+		}
+	}
+	return ops
+}
+
+// Specialized function to convert a 'jack.SwitchStmt' to a list of 'vm.Operation'.
+//
+// The value of the expression is saved in 'temp 0' and then compared against the labels to jump to the
+// block of the matching case (or to the default one), at the end of each block there's a jump after the
+// whole statement since there is no fallthrough. When the labels are dense (see 'BinarySearchCases') a
+// binary search is used instead: after a bounds check the range of the labels is split in half at each
+// comparison, so each case is reached w/ a logarithmic number of comparisons instead of a linear one.
+// A real jump table is not an option since the VM has no indirect jumps (a 'goto' target is a label).
+func (l *Lowerer) HandleSwitchStmt(statement SwitchStmt) ([]vm.Operation, error) {
+	exprOps, err := l.HandleExpression(statement.Expr)
+	if err != nil {
+		return nil, fmt.Errorf("error handling switch expression: %w", err)
+	}
+
+	placeholders, values := l.pushSwitch(), map[int16]int{} // The index of the case for each label value
+	labels, blocks, defaultOps := make([]int16, len(statement.Cases)), make([][]vm.Operation, len(statement.Cases)), []vm.Operation{}
+
+	for i, sc := range statement.Cases {
+		value, isConst := l.labelValue(sc.Label)
+		if !isConst {
+			return nil, sc.Position.Errorf("case label should be an int or char constant")
+		}
+		if _, found := values[value]; found {
+			return nil, sc.Position.Errorf("duplicate case label %d", value)
+		}
+		labels[i], values[value] = value, i
+
+		for _, stmt := range sc.Block {
+			ops, err := l.HandleStatement(stmt)
+			if err != nil {
+				return nil, fmt.Errorf("error handling statement in case block: %w", err)
+			}
+			blocks[i] = append(blocks[i], ops...)
+		}
+	}
+
+	for _, stmt := range statement.Default {
+		ops, err := l.HandleStatement(stmt)
+		if err != nil {
+			return nil, fmt.Errorf("error handling statement in default block: %w", err)
+		}
+		defaultOps = append(defaultOps, ops...)
+	}
+
+	l.loops = l.loops[:len(l.loops)-1]
+
+	// Labels: 'SWITCH_END_n', 'SWITCH_DEFAULT_n+1', a 'SWITCH_CASE' for each case and the binary search ones
+	nLabels, end, fallback := 2+len(statement.Cases), fmt.Sprintf("SWITCH_END_%d", l.nRandomizer), fmt.Sprintf("SWITCH_DEFAULT_%d", l.nRandomizer+1)
+	defer func() { l.nRandomizer += uint(nLabels) }() // ! Increment the randomizer for next use
+
+	targets := map[int16]string{}
+	for value, i := range values {
+		targets[value] = fmt.Sprintf("SWITCH_CASE_%d", l.nRandomizer+2+uint(i))
+	}
+
+	ops := append(exprOps, vm.MemoryOp{Operation: vm.Pop, Segment: vm.Temp, Offset: 0})
+	compare := func(value int16, op vm.ArithOpType, label string) []vm.Operation {
+		return append(append([]vm.Operation{vm.MemoryOp{Operation: vm.Push, Segment: vm.Temp, Offset: 0}}, pushConstant(value)...),
+			vm.ArithmeticOp{Operation: op}, vm.GotoOp{Label: label, Jump: vm.Conditional})
+	}
+
+	if lo, hi, dense := denseRange(values); dense {
+		// Returns the jumps to the target of each value in the range [from, to], known to contain the value
+		var search func(from, to int16) []vm.Operation
+		search = func(from, to int16) []vm.Operation {
+			target := cmp.Or(targets[from], fallback)
+			for value := from + 1; value <= to && value > from; value++ {
+				if cmp.Or(targets[value], fallback) != target {
+					target = ""
+					break
+				}
+			}
+			if target != "" { // Every value left in the range jumps to the same block
+				return []vm.Operation{vm.GotoOp{Label: target, Jump: vm.Unconditional}}
+			}
+
+			mid, lower := from+(to-from+1)/2, fmt.Sprintf("SWITCH_SPLIT_%d", l.nRandomizer+uint(nLabels))
+			nLabels++
+			return append(append(append(compare(mid, vm.Lt, lower), search(mid, to)...), vm.LabelDecl{Name: lower}), search(from, mid-1)...)
+		}
+
+		ops = append(append(append(ops, compare(lo, vm.Lt, fallback)...), compare(hi, vm.Gt, fallback)...), search(lo, hi)...)
+	} else {
+		for _, value := range labels {
+			ops = append(ops, compare(value, vm.Eq, targets[value])...)
+		}
+		ops = append(ops, vm.GotoOp{Label: fallback, Jump: vm.Unconditional})
+	}
+
+	for i, value := range labels {
+		ops = append(append(append(ops, vm.LabelDecl{Name: targets[value]}), blocks[i]...), vm.GotoOp{Label: end, Jump: vm.Unconditional})
+	}
+	ops = append(append(append(ops, vm.LabelDecl{Name: fallback}), defaultOps...), vm.LabelDecl{Name: end})
+
+	return resolveJumps(ops, placeholders, LoopLabels{Break: end, Continue: placeholders.Continue}), nil
+}
+
+// Returns the value of a 'switch' label, either a literal or a reference to an 'int' or 'char' constant.
+func (l *Lowerer) labelValue(label Expression) (int16, bool) {
+	if variable, isVar := label.(VarExpr); isVar {
+		if _, _, err := l.scopes.ResolveVariable(variable.Var); err == nil {
+			return 0, false // Shadowed by a variable, not a constant anymore
+		}
+	}
+	if constant, found := LookupConstant(l.program, l.class(), label); found {
+		return ConstantValue(constant.Value)
+	}
+	return ConstantValue(label)
+}
+
+// Returns the range of the 'values' and whether they're dense enough for a binary search.
+func denseRange(values map[int16]int) (int16, int16, bool) {
+	if len(values) < BinarySearchCases {
+		return 0, 0, false
+	}
+
+	lo, hi := int16(math.MaxInt16), int16(math.MinInt16)
+	for value := range values {
+		lo, hi = min(lo, value), max(hi, value)
+	}
+	return lo, hi, float64(len(values)) >= BinarySearchCoverage*(float64(hi)-float64(lo)+1)
+}
+
+// Returns the operations to push 'value' on the stack, the constants are only positive in the VM.
+func pushConstant(value int16) []vm.Operation {
+	if value < 0 {
+		return []vm.Operation{vm.MemoryOp{Operation: vm.Push, Segment: vm.Constant, Offset: uint16(-int32(value))}, vm.ArithmeticOp{Operation: vm.Neg}}
+	}
+	return []vm.Operation{vm.MemoryOp{Operation: vm.Push, Segment: vm.Constant, Offset: uint16(value)}}
+}
+
 // Specialized function to convert a 'jack.IfStmt' to a list of 'vm.Operation'.
 func (l *Lowerer) HandleIfStmt(statement IfStmt) ([]vm.Operation, error) {
 	condOps, err := l.HandleExpression(statement.Condition)
@@ -392,6 +652,8 @@ func (l *Lowerer) HandleExpression(expr Expression) ([]vm.Operation, error) {
 		return l.HandleUnaryExpr(tExpr)
 	case BinaryExpr:
 		return l.HandleBinaryExpr(tExpr)
+	case ConstExpr:
+		return l.HandleConstExpr(tExpr)
 	case FuncCallExpr:
 		return l.HandleFuncCallExpr(tExpr)
 	default:
@@ -407,7 +669,11 @@ func (l *Lowerer) HandleVarExpr(expression VarExpr) ([]vm.Operation, error) {
 
 	offset, variable, err := l.scopes.ResolveVariable(expression.Var)
 	if err != nil {
-		return nil, fmt.Errorf("error resolving variable '%s' in array expression: %w", expression.Var, err)
+		// The constants declared in the class are inlined, only if there's no variable w/ the same name
+		if constant, found := LookupConstant(l.program, l.class(), expression); found {
+			return l.HandleExpression(constant.Value)
+		}
+		return nil, expression.Position.Errorf("error resolving variable '%s' in array expression: %w", expression.Var, err)
 	}
 
 	switch variable.VarType {
@@ -420,8 +686,18 @@ func (l *Lowerer) HandleVarExpr(expression VarExpr) ([]vm.Operation, error) {
 	case Static:
 		return []vm.Operation{vm.MemoryOp{Operation: vm.Push, Segment: vm.Static, Offset: offset}}, nil
 	default:
-		return nil, fmt.Errorf("variable type '%s' is not supported yet2", variable.VarType)
+		return nil, expression.Position.Errorf("variable type '%s' is not supported yet2", variable.VarType)
+	}
+}
+
+// Specialized function to convert a 'jack.ConstExpr' to a list of 'vm.Operation'.
+func (l *Lowerer) HandleConstExpr(expression ConstExpr) ([]vm.Operation, error) {
+	constant, found := LookupConstant(l.program, l.class(), expression)
+	if !found {
+		return nil, expression.Position.Errorf("constant '%s.%s' undeclared", expression.Class, expression.Name)
 	}
+	// The value is inlined (e.g. 'push constant 512'), the constants are never allocated in memory
+	return l.HandleExpression(constant.Value)
 }
 
 // Specialized function to convert a 'jack.LiteralExpr' to a list of 'vm.Operation'.
@@ -430,7 +706,7 @@ func (l *Lowerer) HandleLiteralExpr(expression LiteralExpr) ([]vm.Operation, err
 	case Int:
 		value, err := strconv.ParseUint(expression.Value, 10, 16)
 		if err != nil {
-			return nil, fmt.Errorf("error parsing integer literal '%s': %w", expression.Value, err)
+			return nil, expression.Position.Errorf("error parsing integer literal '%s': %w", expression.Value, err)
 		}
 
 		return []vm.Operation{vm.MemoryOp{Operation: vm.Push, Segment: vm.Constant, Offset: uint16(value)}}, nil
@@ -438,7 +714,7 @@ func (l *Lowerer) HandleLiteralExpr(expression LiteralExpr) ([]vm.Operation, err
 	case Bool:
 		value, err := strconv.ParseBool(expression.Value)
 		if err != nil {
-			return nil, fmt.Errorf("error parsing integer literal '%s': %w", expression.Value, err)
+			return nil, expression.Position.Errorf("error parsing integer literal '%s': %w", expression.Value, err)
 		}
 
 		mapping := map[bool]uint16{true: 1, false: 0}
@@ -446,7 +722,7 @@ func (l *Lowerer) HandleLiteralExpr(expression LiteralExpr) ([]vm.Operation, err
 
 	case Char:
 		if len(expression.Value) != 1 {
-			return nil, fmt.Errorf("error parsing char literal '%s'", expression.Value)
+			return nil, expression.Position.Errorf("error parsing char literal '%s'", expression.Value)
 		}
 
 		return []vm.Operation{vm.MemoryOp{Operation: vm.Push, Segment: vm.Constant, Offset: uint16(expression.Value[0])}}, nil
@@ -469,19 +745,19 @@ func (l *Lowerer) HandleLiteralExpr(expression LiteralExpr) ([]vm.Operation, err
 		}
 
 		if expression.Value != "null" {
-			return nil, fmt.Errorf("object literal are not supported '%s'", expression.Value)
+			return nil, expression.Position.Errorf("object literal are not supported '%s'", expression.Value)
 		}
 
 		return []vm.Operation{vm.MemoryOp{Operation: vm.Push, Segment: vm.Constant, Offset: 0}}, nil
 
 	default:
-		return nil, fmt.Errorf("unrecognized literal expression type: %s", expression.Type)
+		return nil, expression.Position.Errorf("unrecognized literal expression type: %s", expression.Type)
 	}
 }
 
 // Specialized function to convert a 'jack.ArrayExpr' to a list of 'vm.Operation'.
 func (l *Lowerer) HandleArrayExpr(expression ArrayExpr) ([]vm.Operation, error) {
-	baseOps, err := l.HandleVarExpr(VarExpr{Var: expression.Var})
+	baseOps, err := l.HandleVarExpr(VarExpr{Var: expression.Var, Position: expression.Position})
 	if err != nil {
 		return nil, fmt.Errorf("error handling base variable expression: %w", err)
 	}
@@ -519,7 +795,7 @@ func (l *Lowerer) HandleUnaryExpr(expression UnaryExpr) ([]vm.Operation, error)
 	case BoolNot:
 		return append(ops, vm.ArithmeticOp{Operation: vm.Not}), nil
 	default:
-		return nil, fmt.Errorf("unrecognized unary expression type: %s", expression.Type)
+		return nil, expression.Position.Errorf("unrecognized unary expression type: %s", expression.Type)
 	}
 }
 
@@ -544,6 +820,20 @@ func (l *Lowerer) HandleBinaryExpr(expression BinaryExpr) ([]vm.Operation, error
 		return append(append(lhsOps, rhsOps...), vm.FuncCallOp{Name: "Math.divide", NArgs: 2}), nil
 	case Multiply:
 		return append(append(lhsOps, rhsOps...), vm.FuncCallOp{Name: "Math.multiply", NArgs: 2}), nil
+	// The OS has no function for the remainder, so it's computed as 'x - y * (x / y)': both operands
+	// are needed twice but they're evaluated once and stashed in 'temp', that is read before any call.
+	case Modulo:
+		return append(append(lhsOps, rhsOps...),
+			vm.MemoryOp{Operation: vm.Pop, Segment: vm.Temp, Offset: 1},
+			vm.MemoryOp{Operation: vm.Pop, Segment: vm.Temp, Offset: 0},
+			vm.MemoryOp{Operation: vm.Push, Segment: vm.Temp, Offset: 0},
+			vm.MemoryOp{Operation: vm.Push, Segment: vm.Temp, Offset: 1},
+			vm.MemoryOp{Operation: vm.Push, Segment: vm.Temp, Offset: 0},
+			vm.MemoryOp{Operation: vm.Push, Segment: vm.Temp, Offset: 1},
+			vm.FuncCallOp{Name: "Math.divide", NArgs: 2},
+			vm.FuncCallOp{Name: "Math.multiply", NArgs: 2},
+			vm.ArithmeticOp{Operation: vm.Sub},
+		), nil
 	case BoolOr:
 		return append(append(lhsOps, rhsOps...), vm.ArithmeticOp{Operation: vm.Or}), nil
 	case BoolAnd:
@@ -556,8 +846,43 @@ func (l *Lowerer) HandleBinaryExpr(expression BinaryExpr) ([]vm.Operation, error
 		return append(append(lhsOps, rhsOps...), vm.ArithmeticOp{Operation: vm.Lt}), nil
 	case GreatThan:
 		return append(append(lhsOps, rhsOps...), vm.ArithmeticOp{Operation: vm.Gt}), nil
+	// The VM has no dedicated operation for these comparisons, the opposite one is negated instead
+	case NotEqual:
+		return append(append(lhsOps, rhsOps...), vm.ArithmeticOp{Operation: vm.Eq}, vm.ArithmeticOp{Operation: vm.Not}), nil
+	case LessEqual:
+		return append(append(lhsOps, rhsOps...), vm.ArithmeticOp{Operation: vm.Gt}, vm.ArithmeticOp{Operation: vm.Not}), nil
+	case GreatEqual:
+		return append(append(lhsOps, rhsOps...), vm.ArithmeticOp{Operation: vm.Lt}, vm.ArithmeticOp{Operation: vm.Not}), nil
+
+	// The RHS is skipped when the LHS is false (for 'LogicalAnd') or true (for 'LogicalOr') since it cannot
+	// change the result anymore, in that case 'false' (0) or 'true' (-1, like 'vm.Eq') is used as result.
+	case LogicalAnd:
+		defer func() { l.nRandomizer += 2 }() // ! Increment the randomizer for next use
+
+		return append(append(append(
+			lhsOps,
+			vm.GotoOp{Label: fmt.Sprintf("AND_RHS_%d", l.nRandomizer), Jump: vm.Conditional},
+			vm.MemoryOp{Operation: vm.Push, Segment: vm.Constant, Offset: 0},
+			vm.GotoOp{Label: fmt.Sprintf("AND_END_%d", l.nRandomizer+1), Jump: vm.Unconditional},
+			vm.LabelDecl{Name: fmt.Sprintf("AND_RHS_%d", l.nRandomizer)}),
+			rhsOps...),
+			vm.LabelDecl{Name: fmt.Sprintf("AND_END_%d", l.nRandomizer+1)},
+		), nil
+	case LogicalOr:
+		defer func() { l.nRandomizer += 2 }() // ! Increment the randomizer for next use
+
+		return append(append(append(
+			lhsOps,
+			vm.GotoOp{Label: fmt.Sprintf("OR_TRUE_%d", l.nRandomizer), Jump: vm.Conditional}),
+			rhsOps...),
+			vm.GotoOp{Label: fmt.Sprintf("OR_END_%d", l.nRandomizer+1), Jump: vm.Unconditional},
+			vm.LabelDecl{Name: fmt.Sprintf("OR_TRUE_%d", l.nRandomizer)},
+			vm.MemoryOp{Operation: vm.Push, Segment: vm.Constant, Offset: 0},
+			vm.ArithmeticOp{Operation: vm.Not},
+			vm.LabelDecl{Name: fmt.Sprintf("OR_END_%d", l.nRandomizer+1)},
+		), nil
 	default:
-		return nil, fmt.Errorf("unrecognized binary expression type: %s", expression.Type)
+		return nil, expression.Position.Errorf("unrecognized binary expression type: %s", expression.Type)
 	}
 }
 
@@ -581,11 +906,11 @@ func (l *Lowerer) HandleFuncCallExpr(expression FuncCallExpr) ([]vm.Operation, e
 		// Looks up whether the class and subroutine are defined and exists in the program.
 		class, exists := l.program.Get(className)
 		if !exists {
-			return nil, fmt.Errorf("class defintion not found for '%s'", className)
+			return nil, expression.Position.Errorf("class defintion not found for '%s'", className)
 		}
 		routine, exists := class.Subroutines.Get(expression.FuncName)
 		if !exists {
-			return nil, fmt.Errorf("subroutine '%s' not found in class '%s'", expression.FuncName, className)
+			return nil, expression.Position.Errorf("subroutine '%s' not found in class '%s'", expression.FuncName, className)
 		}
 
 		fName := fmt.Sprintf("%s.%s", className, expression.FuncName)
@@ -605,10 +930,10 @@ func (l *Lowerer) HandleFuncCallExpr(expression FuncCallExpr) ([]vm.Operation, e
 	// how to populate the 'this', given that we will call only subroutine of Type = Method in this code path..
 	if _, variable, _ := l.scopes.ResolveVariable(expression.Var); variable != (Variable{}) {
 		if variable.DataType.Main != Object {
-			return nil, fmt.Errorf("variable '%s' is not an object", expression.Var)
+			return nil, expression.Position.Errorf("variable '%s' is not an object", expression.Var)
 		}
 
-		thisArg, err := l.HandleVarExpr(VarExpr{Var: expression.Var})
+		thisArg, err := l.HandleVarExpr(VarExpr{Var: expression.Var, Position: expression.Position})
 		if err != nil {
 			return nil, fmt.Errorf("error handling variable expression for 'this' pointer: %w", err)
 		}
@@ -624,7 +949,7 @@ func (l *Lowerer) HandleFuncCallExpr(expression FuncCallExpr) ([]vm.Operation, e
 	if class, isClass := l.program.Get(expression.Var); expression.IsExtCall && isClass {
 		routine, exists := class.Subroutines.Get(expression.FuncName)
 		if !exists {
-			return nil, fmt.Errorf("subroutine '%s' not found in class '%s'", expression.FuncName, class.Name)
+			return nil, expression.Position.Errorf("subroutine '%s' not found in class '%s'", expression.FuncName, class.Name)
 		}
 
 		if routine.Type == Function {
@@ -637,8 +962,8 @@ func (l *Lowerer) HandleFuncCallExpr(expression FuncCallExpr) ([]vm.Operation, e
 			return append(argsInit, vm.FuncCallOp{Name: fName, NArgs: uint8(argsLen)}), nil
 		}
 
-		return nil, fmt.Errorf("subroutine '%s' in class '%s' is not a function or constructor, got %s", expression.FuncName, class.Name, routine.Type)
+		return nil, expression.Position.Errorf("subroutine '%s' in class '%s' is not a function or constructor, got %s", expression.FuncName, class.Name, routine.Type)
 	}
 
-	return nil, fmt.Errorf("unrecognized function call expression: %s", expression.FuncName)
+	return nil, expression.Position.Errorf("unrecognized function call expression: %s", expression.FuncName)
 }
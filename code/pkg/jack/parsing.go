@@ -3,7 +3,9 @@ package jack
 import (
 	"fmt"
 	"io"
+	"math"
 	"os"
+	"strconv"
 	"strings"
 
 	pc "github.com/prataprc/goparsec"
@@ -12,13 +14,17 @@ import (
 
 var ast = pc.NewAST("jack_program", 0)
 
+// Records the furthest failure of the PCs below, used to report syntax errors to the user.
+var syntax = utils.NewSyntaxTracker()
+
 var (
 	pClass = ast.And("class_decl", nil,
 		ast.Kleene("file_header", nil, pComment),
-		pc.Atom("class", "CLASS"), pIdent, pLBrace,
-		ast.Kleene("fields_or_comments", nil, ast.OrdChoice("items", nil, pField, pComment)),
+		syntax.Atom("class", "CLASS"), pIdent, pLBrace,
+		ast.Kleene("fields_or_comments", nil, ast.OrdChoice("items", nil, pField, pConst, pEnum, pComment)),
 		ast.Kleene("routines_or_comments", nil, ast.OrdChoice("items", nil, pRoutines, pComment)),
 		pRBrace,
+		ast.Kleene("file_footer", nil, pComment),
 	)
 
 	pField = ast.And("field_decl", nil,
@@ -29,6 +35,18 @@ var (
 		ast.Many("items", nil, pIdent, pComma), pSemi,
 	)
 
+	// ! The keywords are matched as whole words, otherwise 'const' would match the beginning of 'constructor'
+	pConst = ast.And("const_decl", nil,
+		syntax.Expect("'const'", pc.Token(`const\b`, "CONST")), pDataType, pIdent, syntax.Atom("=", "EQUAL"), &pExpr, pSemi,
+	)
+
+	pEnum = ast.And("enum_decl", nil,
+		syntax.Expect("'enum'", pc.Token(`enum\b`, "ENUM")), pIdent, pLBrace,
+		// Comma separated members, each one w/ an optional explicit value (e.g. 'enum Key { LEFT = 130, UP }')
+		ast.Many("members", nil, ast.And("member", nil, pIdent, ast.Maybe("value", nil, ast.And("init", nil, syntax.Atom("=", "EQUAL"), &pExpr))), pComma),
+		pRBrace,
+	)
+
 	pRoutines = ast.And("routine_decl", nil,
 		// Func keyword, return type and function/method name
 		pRoutineType, pDataType, pIdent,
@@ -56,29 +74,58 @@ var (
 		// Support both external method call and local method call syntax:
 		// - 'External': call to another class method (e.g. 'do X.ExtMethod()')
 		// - 'Local': call to same class/instance method (e.g. 'do InternalMethod()')
-		pc.Atom("do", "DO"), pFunCallExpr, pSemi,
+		syntax.Atom("do", "DO"), pFunCallExpr, pSemi,
 	)
 
-	pVarStmt = ast.And("var_stmt", nil, pc.Atom("var", "VAR"), pDataType, ast.Many("variables", nil, pIdent, pComma), pSemi)
+	pVarStmt = ast.And("var_stmt", nil, syntax.Atom("var", "VAR"), pDataType, ast.Many("variables", nil, pIdent, pComma), pSemi)
+
+	pLetStmt = ast.And("let_stmt", nil, syntax.Atom("let", "LET"), ast.OrdChoice("lhs", nil, pArrayExpr, pIdent), syntax.Atom("=", "EQUAL"), &pExpr, pSemi)
 
-	pLetStmt = ast.And("let_stmt", nil, pc.Atom("let", "LET"), ast.OrdChoice("lhs", nil, pArrayExpr, pIdent), pc.Atom("=", "EQUAL"), &pExpr, pSemi)
+	// Same as 'pLetStmt' but w/o the trailing semicolon, used for the initialization and step of 'pForStmt'
+	pLetClause = ast.And("let_clause", nil, syntax.Atom("let", "LET"), ast.OrdChoice("lhs", nil, pArrayExpr, pIdent), syntax.Atom("=", "EQUAL"), &pExpr)
 
-	pReturnStmt = ast.And("return_stmt", nil, pc.Atom("return", "RETURN"), ast.Maybe("expr", nil, &pExpr), pSemi)
+	pReturnStmt = ast.And("return_stmt", nil, syntax.Atom("return", "RETURN"), ast.Maybe("expr", nil, &pExpr), pSemi)
 
 	pIfStmt = ast.And("if_stmt", nil,
-		pc.Atom("if", "IF"), pLParen, &pExpr, pRParen, pLBrace,
+		syntax.Atom("if", "IF"), pLParen, &pExpr, pRParen, pLBrace,
 		ast.Kleene("statements_or_comments", nil, ast.OrdChoice("item", nil, &pStatement, pComment)), pRBrace,
 		ast.Maybe("else_opt", nil, ast.And("else_stmt", nil,
-			pc.Atom("else", "ELSE"), pLBrace,
+			// Comments are allowed between the 'then' block and the 'else' keyword
+			ast.Kleene("comments", nil, pComment), syntax.Atom("else", "ELSE"), pLBrace,
 			ast.Kleene("statements_or_comments", nil, ast.OrdChoice("item", nil, &pStatement, pComment)),
 			pRBrace,
 		)),
 	)
 
 	pWhileStmt = ast.And("while_stmt", nil,
-		pc.Atom("while", "WHILE"), pLParen, &pExpr, pRParen, pLBrace,
+		syntax.Atom("while", "WHILE"), pLParen, &pExpr, pRParen, pLBrace,
+		ast.Kleene("statements_or_comments", nil, ast.OrdChoice("item", nil, &pStatement, pComment)), pRBrace,
+	)
+
+	pForStmt = ast.And("for_stmt", nil,
+		// C-like header where each of the 3 clauses can be omitted (e.g. 'for (let i = 0; i < n; let i = i + 1)')
+		syntax.Atom("for", "FOR"), pLParen,
+		ast.Maybe("init", nil, pLetClause), pSemi, ast.Maybe("condition", nil, &pExpr), pSemi, ast.Maybe("step", nil, pLetClause),
+		pRParen, pLBrace,
 		ast.Kleene("statements_or_comments", nil, ast.OrdChoice("item", nil, &pStatement, pComment)), pRBrace,
 	)
+
+	pSwitchStmt = ast.And("switch_stmt", nil,
+		syntax.Atom("switch", "SWITCH"), pLParen, &pExpr, pRParen, pLBrace,
+		// Each case is a label followed by its block, the optional default case must be the last one
+		ast.Kleene("cases", nil, ast.And("case", nil,
+			syntax.Atom("case", "CASE"), &pExpr, pColon,
+			ast.Kleene("statements_or_comments", nil, ast.OrdChoice("item", nil, &pStatement, pComment)),
+		)),
+		ast.Maybe("default_opt", nil, ast.And("default", nil,
+			syntax.Atom("default", "DEFAULT"), pColon,
+			ast.Kleene("statements_or_comments", nil, ast.OrdChoice("item", nil, &pStatement, pComment)),
+		)),
+		pRBrace,
+	)
+
+	pBreakStmt    = ast.And("break_stmt", nil, syntax.Atom("break", "BREAK"), pSemi)
+	pContinueStmt = ast.And("continue_stmt", nil, syntax.Atom("continue", "CONTINUE"), pSemi)
 )
 
 var (
@@ -88,38 +135,51 @@ var (
 
 	// ! The order of this PCs is important: by putting Int() before Float() we'll not be able to parse a float
 	// !completely because the integer part will be picked up by the Int() PC before given back control to PExpr.
-	pLiteral = ast.OrdChoice("literal", nil,
+	pLiteral = syntax.Expect("literal", ast.OrdChoice("literal", nil,
 		// Basic literals (int, char and bool)
-		pc.Int(), pc.Char(), pc.Token("true", "TRUE"), pc.Token("false", "FALSE"),
+		// ! Jack has no negative literals, '-1' is parsed as a 'NEGATION' of the term '1' instead.
+		pc.Token(`[0-9]+`, "INT"), pc.Char(), pc.Token("true", "TRUE"), pc.Token("false", "FALSE"),
 		// also here we parse 'null' and 'this
 		pc.Token("null", "NULL"), pc.Token("this", "THIS"),
 		// finally we parse string literals
 		pc.Token(`"(?:\\.|[^"\\])*"`, "STRING"),
-	)
+	))
 
-	pArrayExpr = ast.And("array_expr", nil, pIdent, pc.Atom("[", "RSQUARE"), &pExpr, pc.Atom("]", "LSQUARE"))
+	pArrayExpr = ast.And("array_expr", nil, pIdent, syntax.Atom("[", "RSQUARE"), &pExpr, syntax.Atom("]", "LSQUARE"))
 
 	pCastExpr = ast.And("cast_expr", nil, pLSquare, pDataType, pRSquare, &pTerm)
 
 	pUnaryExpr = ast.And("unary_expr", nil,
 		// Unary operations supported by the Jack language (boolean and arithmetic negation)
-		ast.OrdChoice("op", nil, pc.Atom("-", "NEGATION"), pc.Atom("~", "BOOL_NEG")),
+		ast.OrdChoice("op", nil, syntax.Atom("-", "NEGATION"), syntax.Atom("~", "BOOL_NEG")),
 		&pTerm, // Nested subexpression or term to be evaluated
 	)
 
+	// ! A chain of binary operations (e.g. 'a + b * c') is parsed flat, as a list of terms separated
+	// ! by operators, the tree is built afterwards by 'HandleBinaryExpr' according to the precedence.
 	pBinaryExpr = ast.And("binary_expr", nil,
 		&pTerm, // Nested subexpression or term to be evaluated
-		ast.OrdChoice("op", nil,
-			// Bitwise binary operations
-			pc.Atom("|", "BOOL_OR"), pc.Atom("&", "BOOL_AND"),
-			// Comparison operations
-			pc.Atom("=", "EQUAL"), pc.Atom("<", "LESS_THAN"), pc.Atom(">", "GREATER_THAN"),
-			// Arithmetic operations
-			pc.Atom("+", "PLUS"), pc.Atom("-", "MINUS"), pc.Atom("/", "DIVIDE"), pc.Atom("*", "MULTIPLY"),
-		),
-		&pTerm, // Nested subexpression or term to be evaluated
+		ast.Kleene("operations", nil, ast.And("operation", nil,
+			syntax.Expect("operator", ast.OrdChoice("op", nil,
+				// ! The operators sharing a prefix are listed longest first (e.g. '&&' before '&', '<=' before '<')
+				// Logical (short-circuit) binary operations
+				pc.Atom("||", "LOGICAL_OR"), pc.Atom("&&", "LOGICAL_AND"),
+				// Bitwise binary operations
+				pc.Atom("|", "BOOL_OR"), pc.Atom("&", "BOOL_AND"),
+				// Comparison operations ('!=' and '~=' are interchangeable)
+				pc.Atom("<=", "LESS_EQUAL"), pc.Atom(">=", "GREATER_EQUAL"), pc.Atom("!=", "NOT_EQUAL"), pc.Atom("~=", "NOT_EQUAL"),
+				pc.Atom("=", "EQUAL"), pc.Atom("<", "LESS_THAN"), pc.Atom(">", "GREATER_THAN"),
+				// Arithmetic operations
+				pc.Atom("+", "PLUS"), pc.Atom("-", "MINUS"), pc.Atom("/", "DIVIDE"), pc.Atom("*", "MULTIPLY"), pc.Atom("%", "MODULO"),
+			)),
+			&pTerm, // Nested subexpression or term to be evaluated
+		)),
 	)
 
+	// Qualified reference to a constant declared in another class (e.g. 'Screen.WIDTH'), or to the
+	// member of an enum (e.g. 'Key.UP' in the declaring class and 'Main.Key.UP' in the other ones)
+	pConstExpr = ast.And("const_expr", nil, pIdent, pDot, pIdent, ast.Maybe("enum_member", nil, ast.And("member", nil, pDot, pIdent)))
+
 	pFunCallExpr = ast.And("funcall_expr", nil,
 		// Support both external method call and local method call syntax:
 		// - 'External': call to another class method (e.g. 'do X.ExtMethod()')
@@ -132,25 +192,28 @@ var (
 
 var (
 	// Generic Identifier parser (for label and function declaration)
-	// NOTE: An ident can be any sequence of letters, digits, and symbols (_, ., $, :).
+	// NOTE: An ident can be any sequence of letters, digits, and symbols (_, $).
 	// NOTE: An ident cannot begin with a leading digit (a symbol is indeed allowed).
-	pIdent = pc.Token(`[A-Za-z_$:][0-9a-zA-Z_$:]*`, "IDENT")
-
-	pDot     = pc.Atom(".", "DOT")
-	pSemi    = pc.Atom(";", "SEMI")
-	pComma   = pc.Atom(",", "COMMA")
-	pLParen  = pc.Atom("(", "LPAREN")
-	pRParen  = pc.Atom(")", "RPAREN")
-	pLBrace  = pc.Atom("{", "LBRACE")
-	pRBrace  = pc.Atom("}", "RBRACE")
-	pLSquare = pc.Atom("[", "LSQUARE")
-	pRSquare = pc.Atom("]", "RSQUARE")
+	// NOTE: Unlike the VM and Asm ones, ':' is not allowed since it terminates the 'case' labels.
+	pIdent = syntax.Expect("identifier", pName)
+	pName  = pc.Token(`[A-Za-z_$][0-9a-zA-Z_$]*`, "IDENT")
+
+	pDot     = syntax.Atom(".", "DOT")
+	pSemi    = syntax.Atom(";", "SEMI")
+	pComma   = syntax.Atom(",", "COMMA")
+	pColon   = syntax.Atom(":", "COLON")
+	pLParen  = syntax.Atom("(", "LPAREN")
+	pRParen  = syntax.Atom(")", "RPAREN")
+	pLBrace  = syntax.Atom("{", "LBRACE")
+	pRBrace  = syntax.Atom("}", "RBRACE")
+	pLSquare = syntax.Atom("[", "LSQUARE")
+	pRSquare = syntax.Atom("]", "RSQUARE")
 
 	// Different types of field declarations, each has its own meaning:
 	// - field: For classic OOP-like fields (accessed only by the object instance)
 	// - static: For Java-like static fields (accessed by all the object instances)
 	pFieldType = ast.OrdChoice("method_type", nil,
-		pc.Atom("field", "FIELD"), pc.Atom("static", "STATIC"),
+		syntax.Atom("field", "FIELD"), syntax.Atom("static", "STATIC"),
 	)
 
 	// Different types od routine declarations, each has its own meaning:
@@ -158,21 +221,22 @@ var (
 	// - function:  For Java-like static functions (w/o access to the object instance)
 	// - method: For classic OOP-like class methods (w/ access to the object instance)
 	pRoutineType = ast.OrdChoice("method_type", nil,
-		pc.Atom("constructor", "CONSTRUCTOR"), pc.Atom("function", "FUNCTION"), pc.Atom("method", "METHOD"),
+		syntax.Atom("constructor", "CONSTRUCTOR"), syntax.Atom("function", "FUNCTION"), syntax.Atom("method", "METHOD"),
 	)
 
 	// Built-in (also known as primitive) data types allowed/provided by the Jack language.
-	pDataType = ast.OrdChoice("data_type", nil,
+	// NOTE: Class names are matched by 'pName' so that only 'type' is reported in the syntax errors.
+	pDataType = syntax.Expect("type", ast.OrdChoice("data_type", nil,
 		pc.Atom("int", "INT"), pc.Atom("char", "CHAR"), pc.Atom("boolean", "BOOL"),
-		pc.Atom("null", "NULL"), pc.Atom("void", "VOID"), pIdent,
-	)
+		pc.Atom("null", "NULL"), pc.Atom("void", "VOID"), pName,
+	))
 )
 
 func init() {
-	pStatement = ast.OrdChoice("item", nil, pDoStmt, pVarStmt, pLetStmt, pIfStmt, pWhileStmt, pReturnStmt)
+	pStatement = ast.OrdChoice("item", nil, pDoStmt, pVarStmt, pLetStmt, pIfStmt, pWhileStmt, pForStmt, pSwitchStmt, pBreakStmt, pContinueStmt, pReturnStmt)
 
-	pExpr = ast.OrdChoice("expression", nil, pBinaryExpr, pUnaryExpr, pCastExpr, pFunCallExpr, pArrayExpr, pLiteral, pIdent, ast.And("subexpr", nil, pLParen, &pExpr, pRParen))
-	pTerm = ast.OrdChoice("term", nil, pFunCallExpr, pArrayExpr, pLiteral, pIdent, ast.And("subexpr", nil, pLParen, &pExpr, pRParen))
+	pExpr = pBinaryExpr // Every expression is a chain of terms, possibly of length one
+	pTerm = ast.OrdChoice("term", nil, pFunCallExpr, pArrayExpr, pLiteral, pConstExpr, pIdent, ast.And("subexpr", nil, pLParen, &pExpr, pRParen), pUnaryExpr, pCastExpr)
 }
 
 // ----------------------------------------------------------------------------
@@ -185,7 +249,23 @@ func init() {
 // - PARSEC_DEBUG: Verbose logging to inspect which of the PCs gets triggered and match
 // - EXPORT_AST:   Exports in the DEBUG_FOLDER a Graphviz representation of the AST
 // - PRINT_AST:    Print on the stdout a textual representation of the AST
-type Parser struct{ reader io.Reader }
+type Parser struct {
+	reader io.Reader        // The source of the class to be parsed
+	name   string           // The name of the source file, used in the position of the nodes
+	source utils.SourceFile // Maps the offsets of the nodes in the AST to their position
+
+	precedence map[ExprType]int // The precedence of the binary operators (nil for strict left-to-right)
+}
+
+// The C-like precedence of the binary operators, the higher the level the tighter the operator binds.
+// Operators on the same level are left-associative (e.g. 'a - b + c' is evaluated as '(a - b) + c').
+var StandardPrecedence = map[ExprType]int{
+	LogicalOr: 1, LogicalAnd: 2,
+	BoolOr: 3, BoolAnd: 3,
+	Equal: 4, NotEqual: 4, LessThan: 4, LessEqual: 4, GreatThan: 4, GreatEqual: 4,
+	Plus: 5, Minus: 5,
+	Multiply: 6, Divide: 6, Modulo: 6,
+}
 
 // Initializes and returns to the caller a brand new 'Parser' struct.
 // Requires the argument io.Reader 'r' to be valid and usable.
@@ -193,6 +273,19 @@ func NewParser(r io.Reader) Parser {
 	return Parser{reader: r}
 }
 
+// Initializes and returns to the caller a brand new 'Parser' struct, the constructs parsed
+// will reference 'name' as their source file (e.g. 'Main.jack:12:9') in their 'Position'.
+func NewFileParser(r io.Reader, name string) Parser {
+	return Parser{reader: r, name: name}
+}
+
+// Initializes and returns to the caller a brand new 'Parser' struct that, instead of evaluating the
+// chains of binary operations strictly from left to right like the nand2tetris reference compiler,
+// groups them according to the 'StandardPrecedence' (e.g. '1 + x * 2' is '1 + (x * 2)').
+func NewStandardParser(r io.Reader, name string) Parser {
+	return Parser{reader: r, name: name, precedence: StandardPrecedence}
+}
+
 // Parser entrypoint divides the 2 phases of the parsing pipeline
 // Text --> AST: This step is done using PCs and returns a generic traversable AST
 // AST --> IR: This step is done by traversing the AST and extracting the 'vm.Module'
@@ -202,9 +295,9 @@ func (p *Parser) Parse() (Class, error) {
 		return Class{}, fmt.Errorf("cannot read from 'io.Reader': %s", err)
 	}
 
-	ast, success := p.FromSource(content)
-	if !success {
-		return Class{}, fmt.Errorf("failed to parse AST from input content")
+	ast, err := p.FromSource(content)
+	if err != nil {
+		return Class{}, err
 	}
 
 	return p.FromAST(ast)
@@ -212,7 +305,8 @@ func (p *Parser) Parse() (Class, error) {
 
 // Scans the textual input stream coming from the 'reader' method and returns a traversable AST
 // (Abstract Syntax Tree) that can be eventually visited to extract/transform the info available.
-func (p *Parser) FromSource(source []byte) (pc.Queryable, bool) {
+// If the input is not consumed entirely a syntax error pointing to the offending token is returned.
+func (p *Parser) FromSource(source []byte) (pc.Queryable, error) {
 
 	// Feature flag: Enable 'goparsec' library's debug logs
 	if os.Getenv("PARSEC_DEBUG") != "" {
@@ -220,7 +314,9 @@ func (p *Parser) FromSource(source []byte) (pc.Queryable, bool) {
 	}
 
 	// We generate the traversable Abstract Syntax Tree from the source content
-	root, _ := ast.Parsewith(pClass, pc.NewScanner(source))
+	p.source = utils.NewSourceFile(p.name, source)
+	syntax.Reset()
+	root, scanner := ast.Parsewith(pClass, pc.NewScanner(source))
 
 	// Feature flag: Enables export of the AST as Dot file (debug.ast.fot)
 	if os.Getenv("EXPORT_AST") != "" {
@@ -235,7 +331,12 @@ func (p *Parser) FromSource(source []byte) (pc.Queryable, bool) {
 		ast.Prettyprint()
 	}
 
-	return root, true // Success is based on the reaching of 'EOF'
+	// Success is based on the reaching of 'EOF' (trailing whitespaces are allowed)
+	if _, scanner = scanner.SkipWS(); root == nil || !scanner.Endof() {
+		return nil, syntax.Error(p.source, scanner)
+	}
+
+	return root, nil
 }
 
 // This function takes the root node of the raw parsed AST and does a DFS on it parsing
@@ -245,21 +346,36 @@ func (p *Parser) FromAST(root pc.Queryable) (Class, error) {
 	if root.GetName() != "class_decl" {
 		return Class{}, fmt.Errorf("expected node 'class_decl', found %s", root.GetName())
 	}
-	if len(root.GetChildren()) != 7 {
-		return Class{}, fmt.Errorf("expected node with 7 leaf, got %d", len(root.GetChildren()))
+	if len(root.GetChildren()) != 8 {
+		return Class{}, fmt.Errorf("expected node with 8 leaf, got %d", len(root.GetChildren()))
 	}
 
 	class := Class{
 		Name:        root.GetChildren()[2].GetValue(),
 		Fields:      utils.OrderedMap[string, Variable]{},
+		Constants:   utils.OrderedMap[string, Constant]{},
 		Subroutines: utils.OrderedMap[string, Subroutine]{},
+		Position:    p.source.PositionOf(root.GetChildren()[2]),
 	}
 
-	// Field declaration subtree, appends 'jack.Variable' to 'class.Fields'
+	// Field declaration subtree, appends 'jack.Variable' to 'class.Fields' (and 'jack.Constant' to 'class.Constants')
 	for _, node := range root.GetChildren()[4].GetChildren() {
 		if node.GetName() == "sl_comment" || node.GetName() == "ml_comment" { // Skip comments
 			continue
 		}
+		if node.GetName() == "const_decl" || node.GetName() == "enum_decl" {
+			constants, err := p.HandleConstDecl(node)
+			if err != nil {
+				return Class{}, err
+			}
+			for _, constant := range constants { // Both constants and enum members share the same namespace
+				if other, found := class.Constants.Get(constant.Name); found {
+					return Class{}, constant.Position.Errorf("constant '%s' already declared at %s", constant.Name, other.Position)
+				}
+				class.Constants.Set(constant.Name, constant)
+			}
+			continue
+		}
 		fields, err := p.HandleFieldDecl(node)
 		if err != nil {
 			return Class{}, err
@@ -285,7 +401,7 @@ func (p *Parser) FromAST(root pc.Queryable) (Class, error) {
 }
 
 // Specialized function to convert a "field_decl" node to a '[]jack.Variable'.
-func (Parser) HandleFieldDecl(node pc.Queryable) ([]Variable, error) {
+func (p *Parser) HandleFieldDecl(node pc.Queryable) ([]Variable, error) {
 	if node.GetName() != "field_decl" {
 		return nil, fmt.Errorf("expected node 'field_decl', got %s", node.GetName())
 	}
@@ -308,16 +424,88 @@ func (Parser) HandleFieldDecl(node pc.Queryable) ([]Variable, error) {
 
 		// Primitive data types (int, char, bool) are handled differently than complex objects
 		if primitive := MainType(dataType); primitive == Int || primitive == Bool || primitive == Char || primitive == Array {
-			fields = append(fields, Variable{Name: child.GetValue(), VarType: fieldType, DataType: DataType{Main: primitive}})
+			fields = append(fields, Variable{Name: child.GetValue(), VarType: fieldType, DataType: DataType{Main: primitive}, Position: p.source.PositionOf(child)})
 			continue
 		}
 
-		fields = append(fields, Variable{Name: child.GetValue(), VarType: fieldType, DataType: DataType{Main: Object, Subtype: dataType}})
+		fields = append(fields, Variable{Name: child.GetValue(), VarType: fieldType, DataType: DataType{Main: Object, Subtype: dataType}, Position: p.source.PositionOf(child)})
 	}
 
 	return fields, nil
 }
 
+// Specialized function to convert a "const_decl" or "enum_decl" node to a '[]jack.Constant'.
+func (p *Parser) HandleConstDecl(node pc.Queryable) ([]Constant, error) {
+	switch node.GetName() {
+	case "const_decl":
+		if len(node.GetChildren()) != 6 {
+			return nil, fmt.Errorf("expected node with 6 leaf, got %d", len(node.GetChildren()))
+		}
+
+		dataType, name := node.GetChildren()[1].GetValue(), node.GetChildren()[2]
+		value, err := p.HandleExpression(node.GetChildren()[4])
+		if err != nil {
+			return nil, fmt.Errorf("failed to handle value of constant '%s': %w", name.GetValue(), err)
+		}
+
+		// Same as the fields, the primitive data types are handled differently than the objects
+		constant := Constant{Name: name.GetValue(), DataType: DataType{Main: Object, Subtype: dataType}, Value: value, Position: p.source.PositionOf(name)}
+		if primitive := MainType(dataType); primitive == Int || primitive == Bool || primitive == Char || primitive == Array {
+			constant.DataType = DataType{Main: primitive}
+		}
+		return []Constant{constant}, nil
+
+	case "enum_decl":
+		if len(node.GetChildren()) != 5 {
+			return nil, fmt.Errorf("expected node with 5 leaf, got %d", len(node.GetChildren()))
+		}
+
+		enum, constants, next := node.GetChildren()[1].GetValue(), []Constant{}, 0
+		for _, member := range node.GetChildren()[3].GetChildren() {
+			name := member.GetChildren()[0]
+			constant := Constant{Name: name.GetValue(), DataType: DataType{Main: Int}, Enum: enum, Position: p.source.PositionOf(name)}
+
+			// The value is either given explicitly or the one of the previous member plus one
+			if init := member.GetChildren()[1]; init.GetName() != "missing" {
+				value, err := p.HandleExpression(init.GetChildren()[1])
+				if err != nil {
+					return nil, fmt.Errorf("failed to handle value of enum member '%s': %w", name.GetValue(), err)
+				}
+				explicit, err := enumValue(value)
+				if err != nil {
+					return nil, p.source.PositionOf(init).Errorf("invalid value for enum member '%s': %w", name.GetValue(), err)
+				}
+				next = int(explicit)
+			}
+			if next > math.MaxInt16 { // Only the implicit values can go past the range of an 'int'
+				return nil, constant.Position.Errorf("value of enum member '%s' overflows, %d is past the max int %d", name.GetValue(), next, math.MaxInt16)
+			}
+
+			constant.Value = LiteralExpr{Type: DataType{Main: Int}, Value: strconv.Itoa(next), Position: constant.Position}
+			if next < 0 { // Jack has no negative literals, see 'pLiteral'
+				constant.Value = UnaryExpr{Type: Negation, Rhs: LiteralExpr{Type: DataType{Main: Int}, Value: strconv.Itoa(-next), Position: constant.Position}, Position: constant.Position}
+			}
+			constants, next = append(constants, constant), next+1
+		}
+		return constants, nil
+
+	default:
+		return nil, fmt.Errorf("expected node 'const_decl' or 'enum_decl', got %s", node.GetName())
+	}
+}
+
+// Returns the value of an enum member given explicitly, only 'int' literals (possibly negated) are allowed.
+func enumValue(expr Expression) (int16, error) {
+	if literal, isLiteral := expr.(LiteralExpr); isLiteral && literal.Type.Main != Int {
+		return 0, fmt.Errorf("expected an int literal, got %s literal", literal.Type)
+	}
+	value, isConst := ConstantValue(expr)
+	if !isConst {
+		return 0, fmt.Errorf("expected an int literal")
+	}
+	return value, nil
+}
+
 // Specialized function to convert a "routine_decl" node to a 'jack.Routine'.
 func (p *Parser) HandleSubroutineDecl(node pc.Queryable) (Subroutine, error) {
 	if node.GetName() != "routine_decl" {
@@ -329,6 +517,7 @@ func (p *Parser) HandleSubroutineDecl(node pc.Queryable) (Subroutine, error) {
 
 	routineType := SubroutineType(node.GetChildren()[0].GetValue())
 	routineName := node.GetChildren()[2].GetValue()
+	position := p.source.PositionOf(node.GetChildren()[2])
 
 	returnType, primitive := DataType{}, MainType(node.GetChildren()[1].GetValue())
 	if primitive == Int || primitive == Bool || primitive == Char || primitive == Array || primitive == Void {
@@ -339,21 +528,22 @@ func (p *Parser) HandleSubroutineDecl(node pc.Queryable) (Subroutine, error) {
 
 	// All constructors must be named 'new', so we actively check for that
 	if routineType == Constructor && routineName != "new" {
-		return Subroutine{}, fmt.Errorf("constructor method must be named 'new', got '%s'", routineName)
+		return Subroutine{}, position.Errorf("constructor method must be named 'new', got '%s'", routineName)
 	}
 
 	// Iterate on the nested possible n declarations to extract all the variable names
 	nested, arguments := node.GetChildren()[4].GetChildren(), []Variable{}
 	for _, child := range nested {
 		argType, argName := child.GetChildren()[0].GetValue(), child.GetChildren()[1].GetValue()
+		argPosition := p.source.PositionOf(child.GetChildren()[1])
 
 		// Primitive data types (int, char, bool) are handled differently than complex objects
 		if primitive := MainType(argType); primitive == Int || primitive == Bool || primitive == Char || primitive == Array {
-			arguments = append(arguments, Variable{Name: argName, VarType: Parameter, DataType: DataType{Main: primitive}})
+			arguments = append(arguments, Variable{Name: argName, VarType: Parameter, DataType: DataType{Main: primitive}, Position: argPosition})
 			continue
 		}
 
-		arguments = append(arguments, Variable{Name: argName, VarType: Parameter, DataType: DataType{Main: Object, Subtype: argType}})
+		arguments = append(arguments, Variable{Name: argName, VarType: Parameter, DataType: DataType{Main: Object, Subtype: argType}, Position: argPosition})
 	}
 
 	nested, statements := node.GetChildren()[7].GetChildren(), []Statement{}
@@ -370,7 +560,7 @@ func (p *Parser) HandleSubroutineDecl(node pc.Queryable) (Subroutine, error) {
 		}
 	}
 
-	return Subroutine{Name: routineName, Type: routineType, Return: returnType, Arguments: arguments, Statements: statements}, nil
+	return Subroutine{Name: routineName, Type: routineType, Return: returnType, Arguments: arguments, Statements: statements, Position: position}, nil
 }
 
 // Generalized function to dispatch and convert between multiple statements types returning a 'jack.Statement'.
@@ -411,6 +601,25 @@ func (p *Parser) HandleStatement(node pc.Queryable) (Statement, error) {
 		}
 		return stmt, nil
 
+	case "for_stmt":
+		stmt, err := p.HandleForStmt(node)
+		if err != nil {
+			return nil, fmt.Errorf("failed to handle 'for' statement: %w", err)
+		}
+		return stmt, nil
+
+	case "switch_stmt":
+		stmt, err := p.HandleSwitchStmt(node)
+		if err != nil {
+			return nil, fmt.Errorf("failed to handle 'switch' statement: %w", err)
+		}
+		return stmt, nil
+
+	case "break_stmt":
+		return BreakStmt{Position: p.source.PositionOf(node)}, nil
+	case "continue_stmt":
+		return ContinueStmt{Position: p.source.PositionOf(node)}, nil
+
 	case "return_stmt":
 		stmt, err := p.HandleReturnStmt(node)
 		if err != nil {
@@ -437,7 +646,7 @@ func (p *Parser) HandleDoStmt(node pc.Queryable) (Statement, error) {
 		return nil, fmt.Errorf("failed to handle nested function call expression: %w", err)
 	}
 
-	return DoStmt{FuncCall: expr.(FuncCallExpr)}, nil
+	return DoStmt{FuncCall: expr.(FuncCallExpr), Position: p.source.PositionOf(node)}, nil
 }
 
 // Specialized function to convert a "var_stmt" node to a 'jack.VarStmt'.
@@ -463,23 +672,23 @@ func (p *Parser) HandleVarStmt(node pc.Queryable) (Statement, error) {
 		}
 		// Primitive data types (int, char, bool) are handled differently than complex objects
 		if primitive := MainType(dataType); primitive == Int || primitive == Bool || primitive == Char || primitive == Array {
-			variables = append(variables, Variable{Name: child.GetValue(), VarType: Local, DataType: DataType{Main: primitive}})
+			variables = append(variables, Variable{Name: child.GetValue(), VarType: Local, DataType: DataType{Main: primitive}, Position: p.source.PositionOf(child)})
 			continue
 		}
 
-		variables = append(variables, Variable{Name: child.GetValue(), VarType: Local, DataType: DataType{Main: Object, Subtype: dataType}})
+		variables = append(variables, Variable{Name: child.GetValue(), VarType: Local, DataType: DataType{Main: Object, Subtype: dataType}, Position: p.source.PositionOf(child)})
 	}
 
-	return VarStmt{Vars: variables}, nil
+	return VarStmt{Vars: variables, Position: p.source.PositionOf(node)}, nil
 }
 
-// Specialized function to convert a "let_stmt" node to a 'jack.LetStmt'.
+// Specialized function to convert a "let_stmt" (or "let_clause") node to a 'jack.LetStmt'.
 func (p *Parser) HandleLetStmt(node pc.Queryable) (Statement, error) {
-	if node.GetName() != "let_stmt" {
+	if node.GetName() != "let_stmt" && node.GetName() != "let_clause" {
 		return nil, fmt.Errorf("expected node 'let_stmt', got %s", node.GetName())
 	}
-	if len(node.GetChildren()) != 5 {
-		return nil, fmt.Errorf("expected node with 5 leaf, got %d", len(node.GetChildren()))
+	if len(node.GetChildren()) < 4 { // The 'let_clause' has no trailing semicolon
+		return nil, fmt.Errorf("expected node with at least 4 leaf, got %d", len(node.GetChildren()))
 	}
 
 	lhs, err := p.HandleExpression(node.GetChildren()[1])
@@ -489,7 +698,7 @@ func (p *Parser) HandleLetStmt(node pc.Queryable) (Statement, error) {
 	_, isVarExpr := lhs.(VarExpr)
 	_, isArrayExpr := lhs.(ArrayExpr)
 	if !isVarExpr && !isArrayExpr { // Ensure 'lhs' is either 'ArrayExpr' or 'VarExpr'
-		return nil, fmt.Errorf("lhs expression can only be 'VarExpr' or 'ArrayExpr', got %T", lhs)
+		return nil, p.source.PositionOf(node.GetChildren()[1]).Errorf("lhs expression can only be 'VarExpr' or 'ArrayExpr', got %T", lhs)
 	}
 
 	rhs, err := p.HandleExpression(node.GetChildren()[3])
@@ -497,7 +706,7 @@ func (p *Parser) HandleLetStmt(node pc.Queryable) (Statement, error) {
 		return nil, fmt.Errorf("failed to parse right-hand side expression: %w", err)
 	}
 
-	return LetStmt{Lhs: lhs, Rhs: rhs}, nil
+	return LetStmt{Lhs: lhs, Rhs: rhs, Position: p.source.PositionOf(node)}, nil
 }
 
 // Specialized function to convert a "if_stmt" node to a 'jack.IfStmt'.
@@ -530,11 +739,11 @@ func (p *Parser) HandleIfStmt(node pc.Queryable) (Statement, error) {
 
 	// The else section of the if statement is optional and can be omitted
 	if node.GetChildren()[7].GetName() == "missing" {
-		return IfStmt{Condition: condition, ThenBlock: thenStmts, ElseBlock: []Statement{}}, nil
+		return IfStmt{Condition: condition, ThenBlock: thenStmts, ElseBlock: nil, Position: p.source.PositionOf(node)}, nil
 	}
 
 	nested, elseStmts := node.GetChildren()[7].GetChildren(), []Statement{}
-	for _, child := range nested[2].GetChildren() {
+	for _, child := range nested[3].GetChildren() {
 		switch child.GetName() {
 		case "sl_comment", "ml_comment": // Comment nodes in the AST are just skipped
 			continue
@@ -547,7 +756,7 @@ func (p *Parser) HandleIfStmt(node pc.Queryable) (Statement, error) {
 		}
 	}
 
-	return IfStmt{Condition: condition, ThenBlock: thenStmts, ElseBlock: elseStmts}, nil
+	return IfStmt{Condition: condition, ThenBlock: thenStmts, ElseBlock: elseStmts, Position: p.source.PositionOf(node)}, nil
 }
 
 // Specialized function to convert a "while_stmt" node to a 'jack.WhileStmt'.
@@ -578,7 +787,114 @@ func (p *Parser) HandleWhileStmt(node pc.Queryable) (Statement, error) {
 		}
 	}
 
-	return WhileStmt{Condition: condition, Block: statements}, nil
+	return WhileStmt{Condition: condition, Block: statements, Position: p.source.PositionOf(node)}, nil
+}
+
+// Specialized function to convert a "for_stmt" node to a 'jack.ForStmt'.
+func (p *Parser) HandleForStmt(node pc.Queryable) (Statement, error) {
+	if node.GetName() != "for_stmt" {
+		return nil, fmt.Errorf("expected node 'for_stmt', got %s", node.GetName())
+	}
+	if len(node.GetChildren()) != 11 {
+		return nil, fmt.Errorf("expected node with 11 leaf, got %d", len(node.GetChildren()))
+	}
+
+	// Each of the clauses in the header can be omitted, in that case they're left nil
+	stmt := ForStmt{Position: p.source.PositionOf(node)}
+
+	if init := node.GetChildren()[2]; init.GetName() != "missing" {
+		clause, err := p.HandleLetStmt(init)
+		if err != nil {
+			return nil, fmt.Errorf("failed to handle for initialization: %w", err)
+		}
+		stmt.Init = clause
+	}
+
+	if condition := node.GetChildren()[4]; condition.GetName() != "missing" {
+		expr, err := p.HandleExpression(condition)
+		if err != nil {
+			return nil, fmt.Errorf("failed to handle nested for expression: %w", err)
+		}
+		stmt.Condition = expr
+	}
+
+	if step := node.GetChildren()[6]; step.GetName() != "missing" {
+		clause, err := p.HandleLetStmt(step)
+		if err != nil {
+			return nil, fmt.Errorf("failed to handle for step: %w", err)
+		}
+		stmt.Step = clause
+	}
+
+	for _, child := range node.GetChildren()[9].GetChildren() {
+		switch child.GetName() {
+		case "sl_comment", "ml_comment": // Comment nodes in the AST are just skipped
+			continue
+		default:
+			nested, err := p.HandleStatement(child)
+			if err != nil {
+				return ForStmt{}, fmt.Errorf("failed to handle statement: %w", err)
+			}
+			stmt.Block = append(stmt.Block, nested)
+		}
+	}
+
+	return stmt, nil
+}
+
+// Specialized function to convert a "switch_stmt" node to a 'jack.SwitchStmt'.
+func (p *Parser) HandleSwitchStmt(node pc.Queryable) (Statement, error) {
+	if node.GetName() != "switch_stmt" {
+		return nil, fmt.Errorf("expected node 'switch_stmt', got %s", node.GetName())
+	}
+	if len(node.GetChildren()) != 8 {
+		return nil, fmt.Errorf("expected node with 8 leaf, got %d", len(node.GetChildren()))
+	}
+
+	expr, err := p.HandleExpression(node.GetChildren()[2])
+	if err != nil {
+		return nil, fmt.Errorf("failed to handle nested switch expression: %w", err)
+	}
+
+	// Converts the statements of a case (or of the default one) skipping the comments
+	block := func(nested []pc.Queryable) ([]Statement, error) {
+		statements := []Statement{}
+		for _, child := range nested {
+			switch child.GetName() {
+			case "sl_comment", "ml_comment": // Comment nodes in the AST are just skipped
+				continue
+			default:
+				stmt, err := p.HandleStatement(child)
+				if err != nil {
+					return nil, fmt.Errorf("failed to handle statement: %w", err)
+				}
+				statements = append(statements, stmt)
+			}
+		}
+		return statements, nil
+	}
+
+	stmt := SwitchStmt{Expr: expr, Cases: []SwitchCase{}, Position: p.source.PositionOf(node)}
+	for _, child := range node.GetChildren()[5].GetChildren() {
+		label, err := p.HandleExpression(child.GetChildren()[1])
+		if err != nil {
+			return nil, fmt.Errorf("failed to handle case label expression: %w", err)
+		}
+		statements, err := block(child.GetChildren()[3].GetChildren())
+		if err != nil {
+			return nil, fmt.Errorf("failed to handle case block: %w", err)
+		}
+		stmt.Cases = append(stmt.Cases, SwitchCase{Label: label, Block: statements, Position: p.source.PositionOf(child)})
+	}
+
+	// The default case is optional and can be omitted
+	if fallback := node.GetChildren()[6]; fallback.GetName() != "missing" {
+		if stmt.Default, err = block(fallback.GetChildren()[2].GetChildren()); err != nil {
+			return nil, fmt.Errorf("failed to handle default block: %w", err)
+		}
+	}
+
+	return stmt, nil
 }
 
 // Specialized function to convert a "return_stmt" node to a 'jack.ReturnStmt'.
@@ -592,7 +908,7 @@ func (p *Parser) HandleReturnStmt(node pc.Queryable) (Statement, error) {
 
 	// The return value/expression can be omitted (for example if the return type is void)
 	if node.GetChildren()[1].GetName() == "missing" {
-		return ReturnStmt{Expr: nil}, nil
+		return ReturnStmt{Expr: nil, Position: p.source.PositionOf(node)}, nil
 	}
 
 	expr, err := p.HandleExpression(node.GetChildren()[1])
@@ -600,7 +916,7 @@ func (p *Parser) HandleReturnStmt(node pc.Queryable) (Statement, error) {
 		return nil, fmt.Errorf("failed to handle nested expression: %w", err)
 	}
 
-	return ReturnStmt{Expr: expr}, nil
+	return ReturnStmt{Expr: expr, Position: p.source.PositionOf(node)}, nil
 }
 
 // Generalized function to dispatch and convert between multiple expression types returning a 'jack.Expression'.
@@ -634,6 +950,13 @@ func (p *Parser) HandleExpression(node pc.Queryable) (Expression, error) {
 		}
 		return expr, nil
 
+	case "const_expr":
+		children := node.GetChildren()
+		if member := children[3]; member.GetName() != "missing" {
+			return ConstExpr{Class: children[0].GetValue(), Enum: children[2].GetValue(), Name: member.GetChildren()[1].GetValue(), Position: p.source.PositionOf(node)}, nil
+		}
+		return ConstExpr{Class: children[0].GetValue(), Name: children[2].GetValue(), Position: p.source.PositionOf(node)}, nil
+
 	case "funcall_expr":
 		stmt, err := p.HandleFunCallExpr(node)
 		if err != nil {
@@ -646,23 +969,24 @@ func (p *Parser) HandleExpression(node pc.Queryable) (Expression, error) {
 		if err != nil {
 			return nil, fmt.Errorf("failed to handle 'nested' expression: %w", err)
 		}
-		return stmt, nil
+		// Tells apart '(a + b) + c' from 'a + b + c' (or '(x)' from 'x'), only needed to rebuild the source
+		return withParens(stmt, parensOf(stmt)+1), nil
 
 	case "IDENT":
-		return VarExpr{Var: node.GetValue()}, nil
+		return VarExpr{Var: node.GetValue(), Position: p.source.PositionOf(node)}, nil
 	case "THIS":
-		return VarExpr{Var: "this"}, nil
+		return VarExpr{Var: "this", Position: p.source.PositionOf(node)}, nil
 
 	case "INT":
-		return LiteralExpr{Type: DataType{Main: Int}, Value: node.GetValue()}, nil
-	case "CHAR":
-		return LiteralExpr{Type: DataType{Main: Char}, Value: node.GetValue()}, nil
+		return LiteralExpr{Type: DataType{Main: Int}, Value: node.GetValue(), Position: p.source.PositionOf(node)}, nil
+	case "CHAR": // The quotes are dropped just like for strings, the lowerer expects the single character
+		return LiteralExpr{Type: DataType{Main: Char}, Value: strings.TrimSuffix(strings.TrimPrefix(node.GetValue(), "'"), "'"), Position: p.source.PositionOf(node)}, nil
 	case "TRUE", "FALSE":
-		return LiteralExpr{Type: DataType{Main: Bool}, Value: node.GetValue()}, nil
+		return LiteralExpr{Type: DataType{Main: Bool}, Value: node.GetValue(), Position: p.source.PositionOf(node)}, nil
 	case "NULL":
-		return LiteralExpr{Type: DataType{Main: Object}, Value: node.GetValue()}, nil
+		return LiteralExpr{Type: DataType{Main: Object}, Value: node.GetValue(), Position: p.source.PositionOf(node)}, nil
 	case "STRING":
-		return LiteralExpr{Type: DataType{Main: Object, Subtype: "String"}, Value: strings.Trim(node.GetValue(), `"`)}, nil
+		return LiteralExpr{Type: DataType{Main: Object, Subtype: "String"}, Value: strings.Trim(node.GetValue(), `"`), Position: p.source.PositionOf(node)}, nil
 
 	default:
 		return nil, fmt.Errorf("unrecognized node '%s' in expression", node.GetName())
@@ -685,7 +1009,7 @@ func (p *Parser) HandleArrayExpr(node pc.Queryable) (Expression, error) {
 		return nil, fmt.Errorf("failed to handle nested array index expression: %w", err)
 	}
 
-	return ArrayExpr{Var: array, Index: expr}, nil
+	return ArrayExpr{Var: array, Index: expr, Position: p.source.PositionOf(node)}, nil
 }
 
 // Specialized function to convert a "cast_expr" node to a 'jack.CastExpr'.
@@ -710,7 +1034,7 @@ func (p *Parser) HandleCastExpr(node pc.Queryable) (Expression, error) {
 		return nil, fmt.Errorf("failed to handle left-hand side expression: %w", err)
 	}
 
-	return CastExpr{Type: cast, Rhs: rhs}, nil
+	return CastExpr{Type: cast, Rhs: rhs, Position: p.source.PositionOf(node)}, nil
 }
 
 // Specialized function to convert a "unary_expr" node to a 'jack.UnaryExpr'.
@@ -729,30 +1053,61 @@ func (p *Parser) HandleUnaryExpr(node pc.Queryable) (Expression, error) {
 		return nil, fmt.Errorf("failed to handle left-hand side expression: %w", err)
 	}
 
-	return UnaryExpr{Type: exprType, Rhs: rhs}, nil
+	return UnaryExpr{Type: exprType, Rhs: rhs, Position: p.source.PositionOf(node)}, nil
 }
 
 // Specialized function to convert a "binary_expr" node to a 'jack.BinaryExpr'.
+//
+// The node is a chain of terms separated by operators, each one is combined into a left-associative
+// tree of 'jack.BinaryExpr' where the operators with an higher precedence are grouped first (if no
+// precedence is set the tree is built strictly left to right, e.g. 'a + b * c' is '(a + b) * c').
+// A chain with a single term (and no operators) is returned as is.
 func (p *Parser) HandleBinaryExpr(node pc.Queryable) (Expression, error) {
 	if node.GetName() != "binary_expr" {
 		return nil, fmt.Errorf("expected node 'binary_expr', got %s", node.GetName())
 	}
-	if len(node.GetChildren()) != 3 {
-		return nil, fmt.Errorf("expected node with 3 leaf, got %d", len(node.GetChildren()))
+	if len(node.GetChildren()) != 2 {
+		return nil, fmt.Errorf("expected node with 2 leaf, got %d", len(node.GetChildren()))
 	}
+
 	lhs, err := p.HandleExpression(node.GetChildren()[0])
 	if err != nil {
 		return nil, fmt.Errorf("failed to handle left-hand side expression: %w", err)
 	}
 
-	exprType := ExprType(strings.ToLower((node.GetChildren()[1].GetName())))
+	// The pending operators are kept on a stack along with their operands: before pushing a new
+	// operator, the ones with the same or higher precedence are reduced to a single 'jack.BinaryExpr'
+	operands, operators := []Expression{lhs}, []BinaryExpr{}
+	reduce := func() {
+		top, last := operators[len(operators)-1], len(operands)-1
+		top.Lhs, top.Rhs = operands[last-1], operands[last]
+		operands, operators = append(operands[:last-1], top), operators[:len(operators)-1]
+	}
 
-	rhs, err := p.HandleExpression(node.GetChildren()[2])
-	if err != nil {
-		return nil, fmt.Errorf("failed to handle right-hand side expression: %w", err)
+	for _, child := range node.GetChildren()[1].GetChildren() {
+		if len(child.GetChildren()) != 2 {
+			return nil, fmt.Errorf("expected node with 2 leaf, got %d", len(child.GetChildren()))
+		}
+
+		opNode := child.GetChildren()[0]
+		exprType := ExprType(strings.ToLower(opNode.GetName()))
+
+		rhs, err := p.HandleExpression(child.GetChildren()[1])
+		if err != nil {
+			return nil, fmt.Errorf("failed to handle right-hand side expression: %w", err)
+		}
+
+		for len(operators) > 0 && p.precedence[operators[len(operators)-1].Type] >= p.precedence[exprType] {
+			reduce()
+		}
+		operands = append(operands, rhs)
+		operators = append(operators, BinaryExpr{Type: exprType, Position: p.source.PositionOf(opNode)})
 	}
 
-	return BinaryExpr{Type: exprType, Lhs: lhs, Rhs: rhs}, nil
+	for len(operators) > 0 {
+		reduce()
+	}
+	return operands[0], nil
 }
 
 // Specialized function to convert a "funcall_expr" node to a 'jack.FuncCallExpr'.
@@ -781,5 +1136,5 @@ func (p *Parser) HandleFunCallExpr(node pc.Queryable) (Expression, error) {
 		arguments = append(arguments, arg)
 	}
 
-	return FuncCallExpr{IsExtCall: external, Var: class, FuncName: method, Arguments: arguments}, nil
+	return FuncCallExpr{IsExtCall: external, Var: class, FuncName: method, Arguments: arguments, Position: p.source.PositionOf(node)}, nil
 }
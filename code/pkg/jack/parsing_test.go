@@ -0,0 +1,228 @@
+package jack_test
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"its-hmny.dev/nand2tetris/pkg/jack"
+)
+
+// Checks that a class that cannot be parsed entirely is rejected, pointing to the offending token.
+func TestSyntaxErrors(t *testing.T) {
+	test := func(source string, expected string) {
+		parser := jack.NewFileParser(strings.NewReader(source), "Main.jack")
+		_, err := parser.Parse()
+		if err == nil {
+			t.Fatalf("Expected syntax error, got nil")
+		}
+		if err.Error() != expected {
+			t.Fatalf("Expected error:\n%s\ngot:\n%s", expected, err)
+		}
+	}
+
+	t.Run("Missing semicolon", func(t *testing.T) {
+		test("class Main {\n  function void main() {\n    let x = 1\n    return;\n  }\n}",
+			"Main.jack:4:5: unexpected 'return', expected one of operator, ';'\n        return;\n        ^")
+	})
+	t.Run("Missing argument", func(t *testing.T) {
+		test("class Main {\n  function void main() {\n    do Output.print(;\n  }\n}",
+			"Main.jack:3:21: unexpected ';', expected one of identifier, literal, '(', '-', '~', '[', ')'\n        do Output.print(;\n                        ^")
+	})
+	t.Run("Unterminated class", func(t *testing.T) {
+		test("class Main {\n  field int x y;\n}",
+			"Main.jack:2:15: unexpected 'y', expected one of ',', ';'\n      field int x y;\n                  ^")
+	})
+	t.Run("Trailing content", func(t *testing.T) {
+		test("class Main {}\n}", "Main.jack:2:1: unexpected '}', expected end of file\n    }\n    ^")
+	})
+}
+
+// Checks that the chains of binary operations are grouped according to the precedence requested.
+func TestBinaryExpressions(t *testing.T) {
+	// Renders the expression fully parenthesized (e.g. '((a plus b) multiply c)') to compare the tree shape
+	var render func(expr jack.Expression) string
+	render = func(expr jack.Expression) string {
+		switch tExpr := expr.(type) {
+		case jack.BinaryExpr:
+			return "(" + render(tExpr.Lhs) + " " + string(tExpr.Type) + " " + render(tExpr.Rhs) + ")"
+		case jack.UnaryExpr:
+			return string(tExpr.Type) + " " + render(tExpr.Rhs)
+		case jack.VarExpr:
+			return tExpr.Var
+		case jack.LiteralExpr:
+			return tExpr.Value
+		default:
+			t.Fatalf("Unexpected expression %T", expr)
+			return ""
+		}
+	}
+
+	// Parses 'let x = <expr>;' with 'parser' and compares the tree of the rhs with the 'expected' one
+	test := func(newParser func(io.Reader, string) jack.Parser, expr string, expected string) {
+		source := "class Main {\n  function void main() {\n    let x = " + expr + ";\n    return;\n  }\n}"
+		parser := newParser(strings.NewReader(source), "Main.jack")
+		class, err := parser.Parse()
+		if err != nil {
+			t.Fatalf("Unexpected parsing error: %v", err)
+		}
+
+		main, _ := class.Subroutines.Get("main")
+		if got := render(main.Statements[0].(jack.LetStmt).Rhs); got != expected {
+			t.Fatalf("Expected '%s' to be parsed as:\n%s\ngot:\n%s", expr, expected, got)
+		}
+	}
+
+	t.Run("Left to right", func(t *testing.T) {
+		test(jack.NewFileParser, "a + b + c", "((a plus b) plus c)")
+		test(jack.NewFileParser, "128 - 20 + r", "((128 minus 20) plus r)")
+		test(jack.NewFileParser, "x * 2 + 1", "((x multiply 2) plus 1)")
+		test(jack.NewFileParser, "1 + x * 2", "((1 plus x) multiply 2)")
+		test(jack.NewFileParser, "a < b & c", "((a less_than b) bool_and c)")
+		test(jack.NewFileParser, "(a) & (b) & (c) & (d)", "(((a bool_and b) bool_and c) bool_and d)")
+		// Parentheses and unary operations are terms of the chain
+		test(jack.NewFileParser, "a - (b - c)", "(a minus (b minus c))")
+		test(jack.NewFileParser, "-x * 2 - ~y", "((negation x multiply 2) minus bool_neg y)")
+		test(jack.NewFileParser, "a <= b && c ~= d || e", "((((a less_equal b) logical_and c) not_equal d) logical_or e)")
+		test(jack.NewFileParser, "a >= b != c % d", "(((a greater_equal b) not_equal c) modulo d)")
+		test(jack.NewFileParser, "x", "x")
+	})
+
+	t.Run("Standard", func(t *testing.T) {
+		test(jack.NewStandardParser, "a + b + c", "((a plus b) plus c)")
+		test(jack.NewStandardParser, "128 - 20 + r", "((128 minus 20) plus r)")
+		test(jack.NewStandardParser, "x * 2 + 1", "((x multiply 2) plus 1)")
+		test(jack.NewStandardParser, "1 + x * 2", "(1 plus (x multiply 2))")
+		test(jack.NewStandardParser, "a < b & c", "((a less_than b) bool_and c)")
+		test(jack.NewStandardParser, "a | b = c + d * e", "(a bool_or (b equal (c plus (d multiply e))))")
+		test(jack.NewStandardParser, "a * b - c / d + e", "(((a multiply b) minus (c divide d)) plus e)")
+		// Parentheses still override the precedence
+		test(jack.NewStandardParser, "(1 + x) * 2", "((1 plus x) multiply 2)")
+		test(jack.NewStandardParser, "a <= b && c ~= d || e", "(((a less_equal b) logical_and (c not_equal d)) logical_or e)")
+		test(jack.NewStandardParser, "a || b && c & d", "(a logical_or (b logical_and (c bool_and d)))")
+		test(jack.NewStandardParser, "a >= b != c % d", "((a greater_equal b) not_equal (c modulo d))")
+		test(jack.NewStandardParser, "x", "x")
+	})
+}
+
+// Checks that each clause of a 'for' statement is optional and ends up in the right field.
+func TestForStatement(t *testing.T) {
+	// Parses 'statement' as the first one of a subroutine and returns it
+	parse := func(statement string) jack.ForStmt {
+		source := "class Main {\n  function void main() {\n    " + statement + "\n    return;\n  }\n}"
+		parser := jack.NewFileParser(strings.NewReader(source), "Main.jack")
+		class, err := parser.Parse()
+		if err != nil {
+			t.Fatalf("Unexpected parsing error: %v", err)
+		}
+
+		main, _ := class.Subroutines.Get("main")
+		return main.Statements[0].(jack.ForStmt)
+	}
+
+	t.Run("All clauses", func(t *testing.T) {
+		stmt := parse("for (let i = 0; i < 10; let i = i + 1) { if (i = 5) { break; } continue; }")
+		if init, ok := stmt.Init.(jack.LetStmt); !ok || init.Lhs.(jack.VarExpr).Var != "i" {
+			t.Fatalf("Unexpected initialization: %+v", stmt.Init)
+		}
+		if condition, ok := stmt.Condition.(jack.BinaryExpr); !ok || condition.Type != jack.LessThan {
+			t.Fatalf("Unexpected condition: %+v", stmt.Condition)
+		}
+		if step, ok := stmt.Step.(jack.LetStmt); !ok || step.Rhs.(jack.BinaryExpr).Type != jack.Plus {
+			t.Fatalf("Unexpected step: %+v", stmt.Step)
+		}
+		if len(stmt.Block) != 2 {
+			t.Fatalf("Expected 2 statements in the block, got %d", len(stmt.Block))
+		}
+		if _, ok := stmt.Block[1].(jack.ContinueStmt); !ok {
+			t.Fatalf("Expected a 'continue' statement, got %T", stmt.Block[1])
+		}
+	})
+
+	t.Run("No clauses", func(t *testing.T) {
+		stmt := parse("for (;;) { break; }")
+		if stmt.Init != nil || stmt.Condition != nil || stmt.Step != nil {
+			t.Fatalf("Expected no clauses, got %+v", stmt)
+		}
+		if _, ok := stmt.Block[0].(jack.BreakStmt); !ok {
+			t.Fatalf("Expected a 'break' statement, got %T", stmt.Block[0])
+		}
+	})
+}
+
+// Checks that the cases of a 'switch' statement keep their order and that the default one is optional.
+func TestSwitchStatement(t *testing.T) {
+	// Parses 'statement' as the first one of a subroutine and returns it
+	parse := func(statement string) jack.SwitchStmt {
+		source := "class Main {\n  function void main() {\n    " + statement + "\n    return;\n  }\n}"
+		parser := jack.NewFileParser(strings.NewReader(source), "Main.jack")
+		class, err := parser.Parse()
+		if err != nil {
+			t.Fatalf("Unexpected parsing error: %v", err)
+		}
+
+		main, _ := class.Subroutines.Get("main")
+		return main.Statements[0].(jack.SwitchStmt)
+	}
+
+	t.Run("Cases and default", func(t *testing.T) {
+		stmt := parse("switch (x) { case 1: let y = 1; break; case 'a': case -2: default: let y = 0; }")
+		if len(stmt.Cases) != 3 || len(stmt.Cases[0].Block) != 2 || len(stmt.Cases[1].Block) != 0 {
+			t.Fatalf("Unexpected cases: %+v", stmt.Cases)
+		}
+		for i, expected := range []int16{1, 'a', -2} {
+			if value, isConst := jack.ConstantValue(stmt.Cases[i].Label); !isConst || value != expected {
+				t.Fatalf("Expected label %d for case %d, got %d", expected, i, value)
+			}
+		}
+		if len(stmt.Default) != 1 {
+			t.Fatalf("Expected 1 statement in the default block, got %d", len(stmt.Default))
+		}
+	})
+
+	t.Run("No default", func(t *testing.T) {
+		stmt := parse("switch (x) { case x: return; }")
+		if _, isConst := jack.ConstantValue(stmt.Cases[0].Label); isConst || stmt.Default != nil {
+			t.Fatalf("Unexpected switch statement: %+v", stmt)
+		}
+	})
+}
+
+// Checks that the constants are declared apart from the fields and that the enum members are numbered.
+func TestConstantDeclarations(t *testing.T) {
+	source := "class Main {\n  static int count;\n  const int WIDTH = 512;\n  const char KEY = 'q';\n  enum Key { LEFT = 130, UP, RIGHT = -1, DOWN }\n  enum Direction { NORTH, SOUTH }\n  constructor Main new() {\n    return this;\n  }\n}"
+	parser := jack.NewFileParser(strings.NewReader(source), "Main.jack")
+	class, err := parser.Parse()
+	if err != nil {
+		t.Fatalf("Unexpected parsing error: %v", err)
+	}
+	if class.Fields.Size() != 1 || class.Constants.Size() != 8 || !class.Subroutines.Has("new") {
+		t.Fatalf("Unexpected class members: %+v", class)
+	}
+
+	expected := map[string]int16{"WIDTH": 512, "KEY": 'q', "LEFT": 130, "UP": 131, "RIGHT": -1, "DOWN": 0, "NORTH": 0, "SOUTH": 1}
+	for name, value := range expected {
+		constant, _ := class.Constants.Get(name)
+		if got, isConst := jack.ConstantValue(constant.Value); !isConst || got != value {
+			t.Errorf("Expected constant '%s' to be %d, got %d", name, value, got)
+		}
+	}
+	if constant, _ := class.Constants.Get("UP"); constant.Enum != "Key" || constant.DataType.Main != jack.Int {
+		t.Errorf("Unexpected enum member: %+v", constant)
+	}
+
+	// The value of the members must be an integer literal, an implicit one can't go past the max 'int'
+	invalid := map[string]string{
+		"class Main {\n  enum Key { LEFT = 'a' }\n}":                      "a char literal as enum value",
+		"class Main {\n  enum Key { LEFT = 32767, RIGHT }\n}":             "an enum value overflowing",
+		"class Main {\n  const int A = 1;\n  const int A = 2;\n}":         "a constant declared twice",
+		"class Main {\n  const int A = 1;\n  enum Letter { X, A }\n}":     "an enum member declared as constant",
+		"class Main {\n  enum Letter { A, B }\n  enum Other { B = 1 }\n}": "an enum member declared twice",
+	}
+	for source, reason := range invalid {
+		parser = jack.NewFileParser(strings.NewReader(source), "Main.jack")
+		if _, err := parser.Parse(); err == nil {
+			t.Errorf("Expected error for %s, got nil", reason)
+		}
+	}
+}
@@ -3,30 +3,125 @@ package jack
 import (
 	"fmt"
 	"strings"
+
+	"its-hmny.dev/nand2tetris/pkg/utils"
+)
+
+// ----------------------------------------------------------------------------
+// Diagnostics
+
+// A Diagnostic is a single problem found in the source code while typechecking the program.
+//
+// Other than the human readable message each diagnostic has a severity and a code that identifies
+// the class of the problem, this way tools (e.g. editors) can filter, group or link them to the docs.
+type Diagnostic struct {
+	Severity Severity       // How bad the problem is, only 'Error' makes the program invalid
+	Code     DiagnosticCode // The class of the problem (e.g. 'undeclared-variable')
+	Position utils.Position // The location of the construct that caused the problem
+	Message  string         // The human readable description of the problem
+}
+
+// Returns the textual representation of the diagnostic (e.g. 'Main.jack:4:17: error[type-mismatch]: ...').
+func (d Diagnostic) String() string {
+	if !d.Position.IsValid() {
+		return fmt.Sprintf("%s[%s]: %s", d.Severity, d.Code, d.Message)
+	}
+	return fmt.Sprintf("%s: %s[%s]: %s", d.Position, d.Severity, d.Code, d.Message)
+}
+
+type Severity string // Enum to manage the severity of a Diagnostic
+
+const (
+	Error   Severity = "error"
+	Warning Severity = "warning"
 )
 
+type DiagnosticCode string // Enum to manage the classes of problems reported by the TypeChecker
+
+const (
+	UndeclaredVariable   DiagnosticCode = "undeclared-variable"
+	UndeclaredClass      DiagnosticCode = "undeclared-class"
+	UndeclaredSubroutine DiagnosticCode = "undeclared-subroutine"
+	TypeMismatch         DiagnosticCode = "type-mismatch"
+	ArgumentCount        DiagnosticCode = "argument-count"
+	InvalidLiteral       DiagnosticCode = "invalid-literal"
+	InvalidCall          DiagnosticCode = "invalid-call"
+	InvalidAssignment    DiagnosticCode = "invalid-assignment"
+	InvalidJump          DiagnosticCode = "invalid-jump"
+	InvalidCase          DiagnosticCode = "invalid-case"
+	InvalidConstant      DiagnosticCode = "invalid-constant"
+)
+
+// Returns whether at least one of the 'diagnostics' is an error (and so the program is invalid).
+func HasErrors(diagnostics []Diagnostic) bool {
+	for _, diagnostic := range diagnostics {
+		if diagnostic.Severity == Error {
+			return true
+		}
+	}
+	return false
+}
+
+// ----------------------------------------------------------------------------
+// Jack TypeChecker
+
+// The TypeChecker validates the types of a 'jack.Program' before it gets lowered.
+//
+// Instead of bailing out on the first problem the TypeChecker records a 'Diagnostic' and carries on,
+// the expression that failed is given the 'Poisoned' type (that matches every other type) so that a
+// single mistake does not produce a cascade of bogus errors in the enclosing expressions/statements.
+// The classes are visited sorted by name so the diagnostics are always reported in the same order.
+//
+// The 'error' returned by the Handle* methods is reserved to the internal failures (e.g. unrecognized
+// nodes in the AST) that prevent the TypeChecker from continuing, and not to the user's mistakes.
 type TypeChecker struct {
-	program Program
-	scopes  ScopeTable // Keeps track of the scopes and declared variables inside each one
+	program     utils.OrderedMap[string, Class] // The program to typecheck, it must be not nil nor empty
+	scopes      ScopeTable                      // Keeps track of the scopes and declared variables inside each one
+	diagnostics []Diagnostic                    // The problems found so far, in the order they're encountered
+	loops       int                             // The number of loops enclosing the statement being checked
+	switches    int                             // The number of switches enclosing the statement being checked
 }
 
+// Initializes and returns to the caller a brand new 'TypeChecker' struct.
+// Requires the argument Program to be not nil nor empty.
 func NewTypeChecker(program Program) TypeChecker {
-	return TypeChecker{program: program}
+	return TypeChecker{program: program.Sorted()}
 }
 
-func (tc *TypeChecker) Check() (bool, error) {
-	if tc.program == nil {
-		return false, fmt.Errorf("the given 'program' is empty or nil")
+// Triggers the typechecking process, every class is visited (even after a problem is found) and all
+// the diagnostics are returned to the caller, use 'HasErrors' to know if the program is valid or not.
+func (tc *TypeChecker) Check() ([]Diagnostic, error) {
+	if tc.program.Size() == 0 {
+		return nil, fmt.Errorf("the given 'program' is empty or nil")
 	}
 
-	for name, class := range tc.program {
+	tc.diagnostics = []Diagnostic{}
+	for name, class := range tc.program.Entries() {
 		_, err := tc.HandleClass(class)
 		if err != nil {
-			return false, fmt.Errorf("error handling typechecking of class '%s': %w", name, err)
+			return nil, fmt.Errorf("error handling typechecking of class '%s': %w", name, err)
 		}
 	}
 
-	return true, nil
+	return tc.diagnostics, nil
+}
+
+// Records a new error diagnostic, the statement being checked is then reported as not valid.
+func (tc *TypeChecker) reject(code DiagnosticCode, position utils.Position, format string, args ...any) (bool, error) {
+	tc.report(code, position, format, args...)
+	return false, nil
+}
+
+// Records a new error diagnostic, the expression being checked is then given the 'Poisoned' type.
+func (tc *TypeChecker) poison(code DiagnosticCode, position utils.Position, format string, args ...any) (DataType, error) {
+	tc.report(code, position, format, args...)
+	return DataType{Main: Poisoned}, nil
+}
+
+// Records a new error diagnostic at the given 'position'.
+func (tc *TypeChecker) report(code DiagnosticCode, position utils.Position, format string, args ...any) {
+	message := fmt.Sprintf(format, args...)
+	tc.diagnostics = append(tc.diagnostics, Diagnostic{Severity: Error, Code: code, Position: position, Message: message})
 }
 
 // Specialized function to type-check a 'jack.Class' and nested fields.
@@ -34,6 +129,8 @@ func (tc *TypeChecker) HandleClass(class Class) (bool, error) {
 	tc.scopes.PushClassScope(class.Name) // Keep track of the current scope being processed
 	defer tc.scopes.PopClassScope()      // Reset the function name after processing
 
+	before := len(tc.diagnostics)
+
 	for _, field := range class.Fields.Entries() {
 		_, err := tc.HandleVarStmt(VarStmt{Vars: []Variable{field}})
 		if err != nil {
@@ -41,6 +138,13 @@ func (tc *TypeChecker) HandleClass(class Class) (bool, error) {
 		}
 	}
 
+	for _, constant := range class.Constants.Entries() {
+		_, err := tc.HandleConstant(constant)
+		if err != nil {
+			return false, fmt.Errorf("error handling constant '%s' in class '%s': %w", constant.Name, class.Name, err)
+		}
+	}
+
 	for _, subroutine := range class.Subroutines.Entries() {
 		_, err := tc.HandleSubroutine(subroutine)
 		if err != nil {
@@ -48,6 +152,35 @@ func (tc *TypeChecker) HandleClass(class Class) (bool, error) {
 		}
 	}
 
+	return len(tc.diagnostics) == before, nil
+}
+
+// Specialized function to type-check a 'jack.Constant' declaration.
+func (tc *TypeChecker) HandleConstant(constant Constant) (bool, error) {
+	class, _ := tc.program.Get(tc.class())
+	if field, found := class.Fields.Get(constant.Name); found {
+		return tc.reject(InvalidConstant, constant.Position, "constant '%s' already declared as %s at %s", constant.Name, field.VarType, field.Position)
+	}
+	if subroutine, found := class.Subroutines.Get(constant.Name); found {
+		return tc.reject(InvalidConstant, constant.Position, "constant '%s' already declared as subroutine at %s", constant.Name, subroutine.Position)
+	}
+
+	constant.DataType = tc.resolve(tc.class(), constant.DataType)
+	if main := constant.DataType.Main; main != Int && main != Char && main != Bool {
+		return tc.reject(InvalidConstant, constant.Position, "constant '%s' should be of type int, char or boolean, got %s", constant.Name, constant.DataType)
+	}
+	if !constant.IsValid() {
+		return tc.reject(InvalidConstant, constant.Position, "value of constant '%s' should be a literal", constant.Name)
+	}
+
+	value, err := tc.HandleExpression(constant.Value)
+	if err != nil {
+		return false, fmt.Errorf("error handling value of constant '%s': %w", constant.Name, err)
+	}
+	if !constant.DataType.Matches(value) {
+		return tc.reject(TypeMismatch, constant.Position, "expected constant '%s' to be of type %s, got %s", constant.Name, constant.DataType, value)
+	}
+
 	return true, nil
 }
 
@@ -56,11 +189,14 @@ func (tc *TypeChecker) HandleSubroutine(subroutine Subroutine) (bool, error) {
 	tc.scopes.PushSubRoutineScope(subroutine.Name) // Keep track of the current subroutine function being processed
 	defer tc.scopes.PopSubroutineScope()           // Reset the function name after processing
 
+	before := len(tc.diagnostics)
+
 	// We add to the current scope also all of the arguments of the subroutine
 	for _, arg := range subroutine.Arguments {
 		// Like this we're actually supporting shadowing of variables, so if a variable
 		// with the same name is already present in the current scope, we just temporarily
 		// override it with the most update one instead of returning an error (like Go does
+		arg.DataType = tc.resolve(tc.class(), arg.DataType)
 		tc.scopes.RegisterVariable(arg)
 	}
 
@@ -71,7 +207,7 @@ func (tc *TypeChecker) HandleSubroutine(subroutine Subroutine) (bool, error) {
 		}
 	}
 
-	return true, nil
+	return len(tc.diagnostics) == before, nil
 }
 
 // Generalized function to type-check multiple statements types.
@@ -87,6 +223,20 @@ func (tc *TypeChecker) HandleStatement(stmt Statement) (bool, error) {
 		return tc.HandleIfStmt(tStmt)
 	case WhileStmt:
 		return tc.HandleWhileStmt(tStmt)
+	case ForStmt:
+		return tc.HandleForStmt(tStmt)
+	case SwitchStmt:
+		return tc.HandleSwitchStmt(tStmt)
+	case BreakStmt:
+		if tc.loops == 0 && tc.switches == 0 {
+			return tc.reject(InvalidJump, tStmt.Position, "'break' statement outside of a loop or switch")
+		}
+		return true, nil
+	case ContinueStmt:
+		if tc.loops == 0 {
+			return tc.reject(InvalidJump, tStmt.Position, "'continue' statement outside of a loop")
+		}
+		return true, nil
 	case ReturnStmt:
 		return tc.HandleReturnStmt(tStmt)
 	default:
@@ -96,12 +246,12 @@ func (tc *TypeChecker) HandleStatement(stmt Statement) (bool, error) {
 
 // Specialized function to type-check a 'jack.DoStmt' and nested fields.
 func (tc *TypeChecker) HandleDoStmt(statement DoStmt) (bool, error) {
-	_, err := tc.HandleFuncCallExpr(statement.FuncCall)
+	ret, err := tc.HandleFuncCallExpr(statement.FuncCall)
 	if err != nil {
 		return false, fmt.Errorf("error handling nested function call expression: %w", err)
 	}
 
-	return true, nil // Since the return value is discarded type-checking will always succeed
+	return ret.Main != Poisoned, nil // Since the return value is discarded type-checking will always succeed
 }
 
 // Specialized function to type-check a 'jack.VarStmt' and nested fields.
@@ -110,11 +260,21 @@ func (tc *TypeChecker) HandleVarStmt(statement VarStmt) (bool, error) {
 		// Like this we're actually supporting shadowing of variables, so if a variable
 		// with the same name is already present in the current scope, we just temporarily
 		// override it with the most update one instead of returning an error (like Go does BTW).
+		variable.DataType = tc.resolve(tc.class(), variable.DataType)
 		tc.scopes.RegisterVariable(variable)
 	}
 	return true, nil // No type-checking needed for variable declaration, just return true
 }
 
+// Resolves the name of an enum used as type in the declaring 'class' (e.g. 'var Key k;') to 'int',
+// the type of its members, any other type is returned as is.
+func (tc *TypeChecker) resolve(class string, dataType DataType) DataType {
+	if declaring, found := tc.program.Get(class); found && dataType.Main == Object && declaring.HasEnum(dataType.Subtype) {
+		return DataType{Main: Int}
+	}
+	return dataType
+}
+
 // Specialized function to type-check a 'jack.LetStmt' and nested fields.
 func (tc *TypeChecker) HandleLetStmt(statement LetStmt) (bool, error) {
 	rhs, err := tc.HandleExpression(statement.Rhs)
@@ -125,49 +285,54 @@ func (tc *TypeChecker) HandleLetStmt(statement LetStmt) (bool, error) {
 	// If it's a VarExpr then we somewhat reuse the same logic as HandleVarExpr, but we need to write memory instead of reading
 	if expr, isVarExpr := statement.Lhs.(VarExpr); isVarExpr {
 		_, variable, err := tc.scopes.ResolveVariable(expr.Var)
+		if _, isConst := LookupConstant(tc.program, tc.class(), expr); err != nil && isConst {
+			return tc.reject(InvalidAssignment, expr.Position, "cannot assign to constant '%s'", expr.Var)
+		}
 		if err != nil {
-			return false, fmt.Errorf("error resolving variable '%s' in let expression: %w", expr.Var, err)
+			return tc.reject(UndeclaredVariable, expr.Position, "error resolving variable '%s' in let expression: %s", expr.Var, err)
 		}
 		if !variable.DataType.Matches(rhs) {
-			return false, fmt.Errorf("expected variable '%s' to be of type %s, got %s", expr.Var, variable.DataType, rhs)
+			return tc.reject(TypeMismatch, statement.Position, "expected variable '%s' to be of type %s, got %s", expr.Var, variable.DataType, rhs)
 		}
 
-		return true, nil
+		return rhs.Main != Poisoned, nil
 	}
 
 	// For ArrayExpr instead we reuse the pointer + offset logic from HandleArrayExpr but after that we write
 	// a bit of glue code to save the RHS on temporary memory before loading the new address and writing it
 	if expr, isArrayExpr := statement.Lhs.(ArrayExpr); isArrayExpr {
-		_, variable, err := tc.scopes.ResolveVariable(expr.Var)
+		index, err := tc.HandleExpression(expr.Index)
 		if err != nil {
-			return false, fmt.Errorf("error resolving variable '%s' in let expression: %w", expr.Var, err)
-		}
-		if !variable.DataType.Matches(DataType{Main: Array, Subtype: ""}) { // TODO (hmny): Array should be its own MainType and not a derived one
-			return false, fmt.Errorf("expected variable '%s' to be of type %s, got %s", expr.Var, variable.DataType, rhs)
+			return false, fmt.Errorf("error handling index expression: %w", err)
 		}
 
-		index, err := tc.HandleExpression(expr.Index)
+		_, variable, err := tc.scopes.ResolveVariable(expr.Var)
 		if err != nil {
-			return false, fmt.Errorf("error handling index expression: %w", err)
+			return tc.reject(UndeclaredVariable, expr.Position, "error resolving variable '%s' in let expression: %s", expr.Var, err)
+		}
+		if !variable.DataType.Matches(DataType{Main: Array, Subtype: ""}) { // TODO (hmny): Array should be its own MainType and not a derived one
+			return tc.reject(TypeMismatch, statement.Position, "expected variable '%s' to be of type %s, got %s", expr.Var, variable.DataType, rhs)
 		}
 		if !index.Matches(DataType{Main: Int}) {
-			return false, fmt.Errorf("array index expression must be 'int', got %s", expr.Index)
+			return tc.reject(TypeMismatch, expr.Position, "array index expression must be 'int', got %s", index)
 		}
 
-		return true, nil
+		return rhs.Main != Poisoned && index.Main != Poisoned, nil
 	}
 
-	return false, fmt.Errorf("LHS expression must be either a 'VarExpr' or an 'ArrayExpr', got: %T", statement.Lhs)
+	return tc.reject(InvalidAssignment, statement.Position, "LHS expression must be either a 'VarExpr' or an 'ArrayExpr', got: %T", statement.Lhs)
 }
 
 // Specialized function to type-check a 'jack.IfStmt' and nested fields.
 func (tc *TypeChecker) HandleIfStmt(statement IfStmt) (bool, error) {
+	before := len(tc.diagnostics)
+
 	cond, err := tc.HandleExpression(statement.Condition)
 	if err != nil {
 		return false, fmt.Errorf("error handling if condition expression: %w", err)
 	}
 	if !cond.Matches(DataType{Main: Bool}) {
-		return false, fmt.Errorf("if expression should be boolean expression, got %s", cond)
+		tc.report(TypeMismatch, statement.Position, "if expression should be boolean expression, got %s", cond)
 	}
 
 	for _, stmt := range statement.ThenBlock {
@@ -184,19 +349,24 @@ func (tc *TypeChecker) HandleIfStmt(statement IfStmt) (bool, error) {
 		}
 	}
 
-	return true, nil
+	return len(tc.diagnostics) == before, nil
 }
 
 // Specialized function to type-check a 'jack.WhileStmt' and nested fields.
 func (tc *TypeChecker) HandleWhileStmt(statement WhileStmt) (bool, error) {
+	before := len(tc.diagnostics)
+
 	cond, err := tc.HandleExpression(statement.Condition)
 	if err != nil {
 		return false, fmt.Errorf("error handling while condition expression: %w", err)
 	}
 	if !cond.Matches(DataType{Main: Bool}) {
-		return false, fmt.Errorf("while expression should be boolean expression, got %s", cond)
+		tc.report(TypeMismatch, statement.Position, "while expression should be boolean expression, got %s", cond)
 	}
 
+	tc.loops++ // The 'break' and 'continue' statements are allowed only inside the block
+	defer func() { tc.loops-- }()
+
 	for _, stmt := range statement.Block {
 		_, err := tc.HandleStatement(stmt)
 		if err != nil {
@@ -204,30 +374,133 @@ func (tc *TypeChecker) HandleWhileStmt(statement WhileStmt) (bool, error) {
 		}
 	}
 
-	return true, nil
+	return len(tc.diagnostics) == before, nil
+}
+
+// Specialized function to type-check a 'jack.ForStmt' and nested fields.
+func (tc *TypeChecker) HandleForStmt(statement ForStmt) (bool, error) {
+	before := len(tc.diagnostics)
+
+	if statement.Init != nil {
+		if _, err := tc.HandleStatement(statement.Init); err != nil {
+			return false, fmt.Errorf("error handling for initialization statement: %w", err)
+		}
+	}
+
+	if statement.Condition != nil {
+		cond, err := tc.HandleExpression(statement.Condition)
+		if err != nil {
+			return false, fmt.Errorf("error handling for condition expression: %w", err)
+		}
+		if !cond.Matches(DataType{Main: Bool}) {
+			tc.report(TypeMismatch, statement.Position, "for expression should be boolean expression, got %s", cond)
+		}
+	}
+
+	if statement.Step != nil {
+		if _, err := tc.HandleStatement(statement.Step); err != nil {
+			return false, fmt.Errorf("error handling for step statement: %w", err)
+		}
+	}
+
+	tc.loops++ // The 'break' and 'continue' statements are allowed only inside the block
+	defer func() { tc.loops-- }()
+
+	for _, stmt := range statement.Block {
+		_, err := tc.HandleStatement(stmt)
+		if err != nil {
+			return false, fmt.Errorf("error handling statement in for block: %w", err)
+		}
+	}
+
+	return len(tc.diagnostics) == before, nil
 }
 
+// Specialized function to type-check a 'jack.SwitchStmt' and nested fields.
+//
+// The expression can be either an 'int' or a 'char' and the labels can mix both types (e.g. the key
+// codes returned by 'Keyboard.keyPressed'), but each label must be a constant not used by another case.
+func (tc *TypeChecker) HandleSwitchStmt(statement SwitchStmt) (bool, error) {
+	before := len(tc.diagnostics)
+
+	expr, err := tc.HandleExpression(statement.Expr)
+	if err != nil {
+		return false, fmt.Errorf("error handling switch expression: %w", err)
+	}
+	if !expr.Matches(DataType{Main: Int}) && !expr.Matches(DataType{Main: Char}) {
+		tc.report(TypeMismatch, statement.Position, "switch expression should be int or char expression, got %s", expr)
+	}
+
+	tc.switches++ // The 'break' statements are allowed inside the cases, the 'continue' ones only in a loop
+	defer func() { tc.switches-- }()
+
+	labels := map[int16]SwitchCase{} // The cases already seen, indexed by their label value
+	for _, sc := range statement.Cases {
+		if value, isConst := tc.labelValue(sc.Label); !isConst {
+			tc.report(InvalidCase, sc.Position, "case label should be an int or char constant")
+		} else if other, found := labels[value]; found {
+			tc.report(InvalidCase, sc.Position, "duplicate case label %d, already used at %s", value, other.Position)
+		} else {
+			labels[value] = sc
+		}
+
+		for _, stmt := range sc.Block {
+			if _, err := tc.HandleStatement(stmt); err != nil {
+				return false, fmt.Errorf("error handling statement in case block: %w", err)
+			}
+		}
+	}
+
+	for _, stmt := range statement.Default {
+		if _, err := tc.HandleStatement(stmt); err != nil {
+			return false, fmt.Errorf("error handling statement in default block: %w", err)
+		}
+	}
+
+	return len(tc.diagnostics) == before, nil
+}
+
+// Returns the value of a 'switch' label, either a literal or a reference to an 'int' or 'char' constant.
+func (tc *TypeChecker) labelValue(label Expression) (int16, bool) {
+	if variable, isVar := label.(VarExpr); isVar {
+		if _, _, err := tc.scopes.ResolveVariable(variable.Var); err == nil {
+			return 0, false // Shadowed by a variable, not a constant anymore
+		}
+	}
+	if constant, found := LookupConstant(tc.program, tc.class(), label); found {
+		return ConstantValue(constant.Value)
+	}
+	return ConstantValue(label)
+}
+
+// Returns the name of the class being checked.
+func (tc *TypeChecker) class() string { return strings.Split(tc.scopes.GetScope(), ".")[0] }
+
 // Specialized function to type-check a 'jack.ReturnStmt' and nested fields.
 func (tc *TypeChecker) HandleReturnStmt(statement ReturnStmt) (bool, error) {
 	className := strings.Split(tc.scopes.GetScope(), ".")[0]
 	subroutineName := strings.Split(tc.scopes.GetScope(), ".")[1]
 
 	// Retrieve the current class and current subroutine information (checking for existence)
-	class, exists := tc.program[className]
+	class, exists := tc.program.Get(className)
 	if !exists {
-		return false, fmt.Errorf("class %s doesn't exists", className)
+		return tc.reject(UndeclaredClass, statement.Position, "class %s doesn't exists", className)
 	}
 	subroutine, exists := class.Subroutines.Get(subroutineName)
 	if !exists {
-		return false, fmt.Errorf("routine %s doesn't exists for class %s", subroutineName, className)
+		return tc.reject(UndeclaredSubroutine, statement.Position, "routine %s doesn't exists for class %s", subroutineName, className)
 	}
+	subroutine.Return = tc.resolve(className, subroutine.Return)
 
 	// No expression means just void and hence type check always pass
 	if subroutine.Return.Matches(DataType{Main: Void}) && statement.Expr == nil {
 		return true, nil
 	}
 	if subroutine.Return.Matches(DataType{Main: Void}) && statement.Expr != nil {
-		return false, fmt.Errorf("return type of function is void but an expr has been provided")
+		return tc.reject(TypeMismatch, statement.Position, "return type of function is void but an expr has been provided")
+	}
+	if statement.Expr == nil {
+		return tc.reject(TypeMismatch, statement.Position, "expected return type %s, got %s", subroutine.Return, DataType{Main: Void})
 	}
 
 	// When the subroutine has a return type defined we need to check it against the actual return expression
@@ -236,10 +509,10 @@ func (tc *TypeChecker) HandleReturnStmt(statement ReturnStmt) (bool, error) {
 		return false, fmt.Errorf("error handling return expression: %w", err)
 	}
 	if !subroutine.Return.Matches(ret) {
-		return false, fmt.Errorf("expected return type %s, got %s", subroutine.Return, ret)
+		return tc.reject(TypeMismatch, statement.Position, "expected return type %s, got %s", subroutine.Return, ret)
 	}
 
-	return true, nil
+	return ret.Main != Poisoned, nil
 }
 
 // Generalized function to type-check multiple expression their final 'jack.DataType'.
@@ -257,6 +530,8 @@ func (tc *TypeChecker) HandleExpression(expr Expression) (DataType, error) {
 		return tc.HandleUnaryExpr(tExpr)
 	case BinaryExpr:
 		return tc.HandleBinaryExpr(tExpr)
+	case ConstExpr:
+		return tc.HandleConstExpr(tExpr)
 	case FuncCallExpr:
 		return tc.HandleFuncCallExpr(tExpr)
 	default:
@@ -274,12 +549,35 @@ func (tc *TypeChecker) HandleVarExpr(expression VarExpr) (DataType, error) {
 
 	_, variable, err := tc.scopes.ResolveVariable(expression.Var)
 	if err != nil {
-		return DataType{}, fmt.Errorf("error resolving variable '%s' in array expression: %w", expression.Var, err)
+		// The constants declared in the class are visible, only if there's no variable w/ the same name
+		if constant, found := LookupConstant(tc.program, tc.class(), expression); found {
+			return constant.DataType, nil
+		}
+		return tc.poison(UndeclaredVariable, expression.Position, "error resolving variable '%s': %s", expression.Var, err)
 	}
 
 	return variable.DataType, nil
 }
 
+// Specialized function to extract the DataType of a 'jack.ConstExpr'.
+func (tc *TypeChecker) HandleConstExpr(expression ConstExpr) (DataType, error) {
+	if constant, found := LookupConstant(tc.program, tc.class(), expression); found {
+		return constant.DataType, nil
+	}
+
+	current, _ := tc.program.Get(tc.class())
+	switch {
+	case current.HasEnum(expression.Class) && expression.Enum == "":
+		return tc.poison(UndeclaredVariable, expression.Position, "constant '%s' undeclared in enum %s", expression.Name, expression.Class)
+	case !tc.program.Has(expression.Class):
+		return tc.poison(UndeclaredClass, expression.Position, "class %s doesn't exists", expression.Class)
+	case expression.Enum != "":
+		return tc.poison(UndeclaredVariable, expression.Position, "constant '%s' undeclared in enum %s.%s", expression.Name, expression.Class, expression.Enum)
+	default:
+		return tc.poison(UndeclaredVariable, expression.Position, "constant '%s' undeclared in class %s", expression.Name, expression.Class)
+	}
+}
+
 // Specialized function to extract the DataType of a 'jack.LiteralExpr'.
 func (tc *TypeChecker) HandleLiteralExpr(expression LiteralExpr) (DataType, error) {
 	switch expression.Type.Main {
@@ -290,31 +588,32 @@ func (tc *TypeChecker) HandleLiteralExpr(expression LiteralExpr) (DataType, erro
 			return expression.Type, nil
 		}
 		if expression.Value != "null" {
-			return DataType{}, fmt.Errorf("object literal are not supported '%s'", expression.Value)
+			return tc.poison(InvalidLiteral, expression.Position, "object literal are not supported '%s'", expression.Value)
 		}
 		return DataType{Main: Wildcard}, nil // TODO (hmny): Not sure if this is the correct way to handle null literal tbh
 	default:
-		return DataType{}, fmt.Errorf("unrecognized literal expression type: %s", expression.Type)
+		return tc.poison(InvalidLiteral, expression.Position, "unrecognized literal expression type: %s", expression.Type)
 	}
 }
 
 // Specialized function to extract the DataType of a 'jack.ArrayExpr'.
 func (tc *TypeChecker) HandleArrayExpr(expression ArrayExpr) (DataType, error) {
-	array, err := tc.HandleVarExpr(VarExpr{Var: expression.Var})
+	array, err := tc.HandleVarExpr(VarExpr{Var: expression.Var, Position: expression.Position})
 	if err != nil {
 		return DataType{}, fmt.Errorf("error handling base variable expression: %w", err)
 	}
-	if !array.Matches(DataType{Main: Array, Subtype: ""}) {
-		return DataType{}, fmt.Errorf("variable %s must be an array, got %s", expression.Var, array.Main)
-	}
 
 	// Handle the index expression to get the offset of the array element
 	index, err := tc.HandleExpression(expression.Index)
 	if err != nil {
 		return DataType{}, fmt.Errorf("error handling index expression: %w", err)
 	}
+
+	if !array.Matches(DataType{Main: Array, Subtype: ""}) {
+		return tc.poison(TypeMismatch, expression.Position, "variable %s must be an array, got %s", expression.Var, array.Main)
+	}
 	if !index.Matches(DataType{Main: Int}) {
-		return DataType{}, fmt.Errorf("array index expression must be 'int', got %s", index)
+		return tc.poison(TypeMismatch, expression.Position, "array index expression must be 'int', got %s", index)
 	}
 
 	return DataType{Main: Wildcard}, nil
@@ -327,7 +626,7 @@ func (tc *TypeChecker) HandleCastExpr(expression CastExpr) (DataType, error) {
 		return DataType{}, fmt.Errorf("error handling nested expression: %w", err)
 	}
 
-	return expression.Type, nil
+	return tc.resolve(tc.class(), expression.Type), nil
 }
 
 // Specialized function to extract the DataType of a 'jack.UnaryExpr'.
@@ -340,7 +639,7 @@ func (tc *TypeChecker) HandleUnaryExpr(expression UnaryExpr) (DataType, error) {
 	switch expression.Type {
 	case Negation:
 		if !nested.Matches(DataType{Main: Int}) {
-			return DataType{}, fmt.Errorf("nested expression must be 'int', got %s", nested)
+			return tc.poison(TypeMismatch, expression.Position, "nested expression must be 'int', got %s", nested)
 		}
 		return DataType{Main: Int}, nil
 	case BoolNot:
@@ -363,15 +662,25 @@ func (tc *TypeChecker) HandleBinaryExpr(expression BinaryExpr) (DataType, error)
 	}
 
 	if !rhs.Matches(lhs) {
-		return DataType{}, fmt.Errorf("RHS and LHS should have same type, got %s and %s", rhs, lhs)
+		return tc.poison(TypeMismatch, expression.Position, "RHS and LHS should have same type, got %s and %s", rhs, lhs)
+	}
+
+	// When one of the two sides is poisoned the other one is the best guess for the result type
+	if rhs.Main == Poisoned {
+		rhs = lhs
+	}
+
+	// The short-circuit operators only make sense on 'boolean' operands (unlike '&' and '|' that are bitwise)
+	if (expression.Type == LogicalOr || expression.Type == LogicalAnd) && rhs.Main != Bool && rhs.Main != Poisoned {
+		return tc.poison(TypeMismatch, expression.Position, "operands of '%s' should be of type boolean, got %s", binaryOperators[expression.Type], rhs)
 	}
 
 	switch expression.Type {
-	case Plus, Minus, Divide, Multiply:
+	case Plus, Minus, Divide, Multiply, Modulo:
 		return rhs, nil // Also lhs should be fine since they are the same DataType
 	case BoolOr, BoolAnd, BoolNot:
 		return rhs, nil
-	case Equal, LessThan, GreatThan:
+	case Equal, NotEqual, LessThan, LessEqual, GreatThan, GreatEqual, LogicalOr, LogicalAnd:
 		return DataType{Main: Bool}, nil
 	default:
 		return DataType{}, fmt.Errorf("unrecognized binary expression type: %s", expression.Type)
@@ -380,45 +689,56 @@ func (tc *TypeChecker) HandleBinaryExpr(expression BinaryExpr) (DataType, error)
 
 // Specialized function to extract the DataType of a 'jack.FuncCallExpr'.
 func (tc *TypeChecker) HandleFuncCallExpr(expression FuncCallExpr) (DataType, error) {
+	// The arguments are checked first, this way the errors inside them are reported in any case
+	args := []DataType{}
+	for _, expr := range expression.Arguments {
+		arg, err := tc.HandleExpression(expr)
+		if err != nil {
+			return DataType{}, fmt.Errorf("error handling argument expression: %w", err)
+		}
+		args = append(args, arg)
+	}
+
 	className := ""
 
 	if _, variable, _ := tc.scopes.ResolveVariable(expression.Var); expression.IsExtCall && variable != (Variable{}) {
 		// 1. We're calling a method of a specific object instance (e.g. a variable not a class name)
 		if variable.DataType.Main != Object {
-			return DataType{}, fmt.Errorf("variable '%s' is not an object type", expression.Var)
+			return tc.poison(InvalidCall, expression.Position, "variable '%s' is not an object type", expression.Var)
 		}
 		className = variable.DataType.Subtype
 
-	} else if class, isClass := tc.program[expression.Var]; expression.IsExtCall && isClass {
+	} else if class, isClass := tc.program.Get(expression.Var); expression.IsExtCall && isClass {
 		// 2. We're calling a function or constructor (static method) of a specific class
 		className = class.Name
 	} else if !expression.IsExtCall {
 		// 3. Internal call to another method for the same class instance
 		className = strings.Split(tc.scopes.GetScope(), ".")[0]
 	} else {
-		return DataType{}, fmt.Errorf("unsupported function call expression")
+		return tc.poison(UndeclaredClass, expression.Position, "unsupported function call expression, '%s' is neither a variable nor a class", expression.Var)
 	}
 
 	// Retrieve the current class and current subroutine information (checking for existence)
-	class, exists := tc.program[className]
+	class, exists := tc.program.Get(className)
 	if !exists {
-		return DataType{}, fmt.Errorf("class %s doesn't exists", className)
+		return tc.poison(UndeclaredClass, expression.Position, "class %s doesn't exists", className)
 	}
 	subroutine, exists := class.Subroutines.Get(expression.FuncName)
 	if !exists {
-		return DataType{}, fmt.Errorf("subroutine %s doesn't exists for class %s", expression.FuncName, className)
+		return tc.poison(UndeclaredSubroutine, expression.Position, "subroutine %s doesn't exists for class %s", expression.FuncName, className)
 	}
 
-	for idx, expr := range expression.Arguments {
-		arg, err := tc.HandleExpression(expr)
-		if err != nil {
-			return DataType{}, fmt.Errorf("error handling argument expression: %w", err)
-		}
+	ret := tc.resolve(className, subroutine.Return)
+	if len(args) != len(subroutine.Arguments) {
+		tc.report(ArgumentCount, expression.Position, "subroutine %s.%s expects %d arguments, got %d", className, subroutine.Name, len(subroutine.Arguments), len(args))
+		return ret, nil // The return type is known anyway, no need to poison the expression
+	}
 
-		if expected := subroutine.Arguments[idx].DataType; !arg.Matches(expected) {
-			return DataType{}, fmt.Errorf("error handling arg no. %d, expected %s but got %s", idx, expected, arg)
+	for idx, arg := range args {
+		if expected := tc.resolve(className, subroutine.Arguments[idx].DataType); !arg.Matches(expected) {
+			tc.report(TypeMismatch, expression.Position, "error handling arg no. %d, expected %s but got %s", idx, expected, arg)
 		}
 	}
 
-	return subroutine.Return, nil
+	return ret, nil
 }
@@ -0,0 +1,180 @@
+package jack_test
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"its-hmny.dev/nand2tetris/pkg/jack"
+	"its-hmny.dev/nand2tetris/pkg/utils"
+)
+
+// Checks that the errors reported by both the TypeChecker and the Lowerer point to the exact
+// location (file, line and column) of the construct that caused them in the source code.
+func TestErrorPosition(t *testing.T) {
+	test := func(source string, expected string) {
+		parser := jack.NewFileParser(strings.NewReader(source), "Main.jack")
+		class, err := parser.Parse()
+		if err != nil {
+			t.Fatalf("Unexpected parsing error: %s", err)
+		}
+		program := jack.Program{class.Name: class}
+
+		checker := jack.NewTypeChecker(program)
+		diagnostics, err := checker.Check()
+		if err != nil || len(diagnostics) != 1 {
+			t.Fatalf("Expected exactly one diagnostic, got: %v (error: %v)", diagnostics, err)
+		}
+		if diagnostics[0].Position.String() != expected {
+			t.Fatalf("Expected diagnostic at '%s', got '%s'", expected, diagnostics[0].Position)
+		}
+
+		lowerer := jack.NewLowerer(program)
+		_, err = lowerer.Lowerer()
+		var located utils.PositionError
+		if !errors.As(err, &located) {
+			t.Fatalf("Expected an error with a position, got: %v", err)
+		}
+		if located.Position.String() != expected {
+			t.Fatalf("Expected error at '%s', got '%s'", expected, located.Position)
+		}
+	}
+
+	t.Run("Undeclared variable", func(t *testing.T) {
+		test("class Main {\n  function void main() {\n    var int a;\n    let a = 1 + b;\n    return;\n  }\n}", "Main.jack:4:17")
+	})
+	t.Run("Undeclared array", func(t *testing.T) {
+		test("class Main {\n  function int main() {\n    return arr[1];\n  }\n}", "Main.jack:3:12")
+	})
+	t.Run("Undeclared subroutine", func(t *testing.T) {
+		test("class Main {\n  function void main() {\n    if (true) {\n      do Main.missing();\n    }\n    return;\n  }\n}", "Main.jack:4:10")
+	})
+}
+
+// Checks that the TypeChecker reports every problem (across all the classes and in a deterministic
+// order) without stopping at the first one nor reporting the errors caused by a previous one.
+func TestDiagnostics(t *testing.T) {
+	test := func(sources []string, expected []string) {
+		program := jack.Program{}
+		for idx, source := range sources {
+			parser := jack.NewFileParser(strings.NewReader(source), fmt.Sprintf("File%d.jack", idx))
+			class, err := parser.Parse()
+			if err != nil {
+				t.Fatalf("Unexpected parsing error: %s", err)
+			}
+			program[class.Name] = class
+		}
+
+		for run := 0; run < 10; run++ { // Map iteration order is random, the output must not be
+			checker := jack.NewTypeChecker(program)
+			diagnostics, err := checker.Check()
+			if err != nil {
+				t.Fatalf("Unexpected typechecking error: %s", err)
+			}
+			if len(diagnostics) != len(expected) {
+				t.Fatalf("Expected %d diagnostics, got %d: %v", len(expected), len(diagnostics), diagnostics)
+			}
+			for idx, diagnostic := range diagnostics {
+				if diagnostic.String() != expected[idx] {
+					t.Fatalf("Expected diagnostic '%s', got '%s'", expected[idx], diagnostic)
+				}
+			}
+		}
+	}
+
+	t.Run("Multiple classes", func(t *testing.T) {
+		test([]string{
+			"class Zeta {\n  function int run() {\n    return true;\n  }\n}",
+			"class Alpha {\n  function void run() {\n    let a = 1;\n    let b = 2;\n    return;\n  }\n}",
+		}, []string{
+			"File1.jack:3:9: error[undeclared-variable]: error resolving variable 'a' in let expression: variable 'a' undeclared, not found in any scope",
+			"File1.jack:4:9: error[undeclared-variable]: error resolving variable 'b' in let expression: variable 'b' undeclared, not found in any scope",
+			"File0.jack:3:5: error[type-mismatch]: expected return type int, got boolean",
+		})
+	})
+
+	t.Run("Poisoned expressions", func(t *testing.T) {
+		// The undeclared 'x' should not cause the type mismatch errors on the sum, 'let' and 'if'
+		test([]string{
+			"class Main {\n  function void main() {\n    var int y;\n    let y = x + 1;\n    if (x) {\n      return;\n    }\n    return;\n  }\n}",
+		}, []string{
+			"File0.jack:4:13: error[undeclared-variable]: error resolving variable 'x': variable 'x' undeclared, not found in any scope",
+			"File0.jack:5:9: error[undeclared-variable]: error resolving variable 'x': variable 'x' undeclared, not found in any scope",
+		})
+	})
+
+	t.Run("Argument count", func(t *testing.T) {
+		test([]string{
+			"class Main {\n  function int f(int a) {\n    return a;\n  }\n  function void main() {\n    do Main.f(1, 2);\n    do Main.f();\n    return;\n  }\n}",
+		}, []string{
+			"File0.jack:6:8: error[argument-count]: subroutine Main.f expects 1 arguments, got 2",
+			"File0.jack:7:8: error[argument-count]: subroutine Main.f expects 1 arguments, got 0",
+		})
+	})
+
+	t.Run("Comparison and logical operators", func(t *testing.T) {
+		// The comparisons and the short-circuit operators are 'boolean', while '%' is an 'int'
+		test([]string{
+			"class Main {\n  function void main() {\n    var int x;\n    var boolean b;\n    let b = (x <= 1) && (x >= 0) || (x != 5);\n    let x = x % 3;\n    let x = x ~= 3;\n    let b = x && 1;\n    return;\n  }\n}",
+		}, []string{
+			"File0.jack:7:5: error[type-mismatch]: expected variable 'x' to be of type int, got boolean",
+			"File0.jack:8:15: error[type-mismatch]: operands of '&&' should be of type boolean, got int",
+		})
+	})
+
+	t.Run("Jumps outside of a loop", func(t *testing.T) {
+		// The 'break' inside the 'for' is fine, the ones after it are not (and neither the one in 'if')
+		test([]string{
+			"class Main {\n  function void main() {\n    var int i;\n    for (let i = 0; i < 3; let i = i + 1) {\n      break;\n    }\n    if (i = 3) {\n      continue;\n    }\n    break;\n    return;\n  }\n}",
+		}, []string{
+			"File0.jack:8:7: error[invalid-jump]: 'continue' statement outside of a loop",
+			"File0.jack:10:5: error[invalid-jump]: 'break' statement outside of a loop or switch",
+		})
+	})
+
+	t.Run("Switch labels", func(t *testing.T) {
+		// The labels can mix 'int' and 'char' constants, 'a' is the same as 97 and 'x' is not a constant
+		test([]string{
+			"class Main {\n  function void main() {\n    var int x;\n    switch (x) {\n      case 97: break;\n      case -1: continue;\n      case 'a': let x = 1;\n      case x: let x = 2;\n    }\n    switch (x = 1) {\n    }\n    return;\n  }\n}",
+		}, []string{
+			"File0.jack:6:16: error[invalid-jump]: 'continue' statement outside of a loop",
+			"File0.jack:7:7: error[invalid-case]: duplicate case label 97, already used at File0.jack:5:7",
+			"File0.jack:8:7: error[invalid-case]: case label should be an int or char constant",
+			"File0.jack:10:5: error[type-mismatch]: switch expression should be int or char expression, got boolean",
+		})
+	})
+
+	t.Run("Constants", func(t *testing.T) {
+		// The constants are typed, can't be assigned, can't clash w/ other members and must be declared w/ a literal value
+		test([]string{
+			"class Main {\n  const int SIZE = 4;\n  const boolean DEBUG = 1;\n  const int NEXT = SIZE + 1;\n  const Array DATA = null;\n  function void main() {\n    var boolean b;\n    let b = Screen.WIDTH;\n    let b = Screen.HEIGHT + Keys.UP;\n    let SIZE = 2;\n    return;\n  }\n}",
+			"class Screen {\n  field int WIDTH;\n  const int WIDTH = 512;\n  enum Color { WHITE, BLACK }\n  static int DEPTH;\n  const int DEPTH = 1;\n  const int clear = 0;\n  function void clear() {\n    switch (BLACK) {\n      case WHITE: return;\n      case Screen.BLACK: return;\n    }\n    return;\n  }\n}",
+		}, []string{
+			"File0.jack:3:17: error[type-mismatch]: expected constant 'DEBUG' to be of type boolean, got int",
+			"File0.jack:4:13: error[invalid-constant]: value of constant 'NEXT' should be a literal",
+			"File0.jack:5:15: error[invalid-constant]: constant 'DATA' should be of type int, char or boolean, got Array",
+			"File0.jack:8:5: error[type-mismatch]: expected variable 'b' to be of type boolean, got int",
+			"File0.jack:9:13: error[undeclared-variable]: constant 'HEIGHT' undeclared in class Screen",
+			"File0.jack:9:29: error[undeclared-class]: class Keys doesn't exists",
+			"File0.jack:10:9: error[invalid-assignment]: cannot assign to constant 'SIZE'",
+			"File1.jack:3:13: error[invalid-constant]: constant 'WIDTH' already declared as field at File1.jack:2:13",
+			"File1.jack:6:13: error[invalid-constant]: constant 'DEPTH' already declared as static at File1.jack:5:14",
+			"File1.jack:7:13: error[invalid-constant]: constant 'clear' already declared as subroutine at File1.jack:8:17",
+		})
+	})
+
+	t.Run("Enums", func(t *testing.T) {
+		// The enum name qualifies its members and, in the declaring class only, is an alias of 'int'
+		test([]string{
+			"class Main {\n  enum Dir { UP, DOWN }\n  function Dir flip(Dir d) {\n    if (d = Dir.UP) {\n      return Dir.DOWN;\n    }\n    return Main.Dir.UP;\n  }\n  function void main() {\n    var Dir d;\n    var boolean b;\n    let d = Main.DOWN;\n    let d = Main.flip(d) + 1;\n    let b = Dir.LEFT;\n    let b = Main.Dir.LEFT;\n    let b = Color.WHITE;\n    let b = Screen.Color.WHITE;\n    let b = Screen.Dir.UP;\n    return;\n  }\n}",
+			"class Screen {\n  enum Color { WHITE, BLACK }\n}",
+		}, []string{
+			"File0.jack:14:13: error[undeclared-variable]: constant 'LEFT' undeclared in enum Dir",
+			"File0.jack:15:13: error[undeclared-variable]: constant 'LEFT' undeclared in enum Main.Dir",
+			"File0.jack:16:13: error[undeclared-class]: class Color doesn't exists",
+			"File0.jack:17:5: error[type-mismatch]: expected variable 'b' to be of type boolean, got int",
+			"File0.jack:18:13: error[undeclared-variable]: constant 'UP' undeclared in enum Screen.Dir",
+		})
+	})
+}
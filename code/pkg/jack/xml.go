@@ -0,0 +1,583 @@
+package jack
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ----------------------------------------------------------------------------
+// Syntax Analyzer XML
+
+// This section converts a 'jack.Class' to the XML format used in the nand2tetris project 10.
+//
+// The format has two flavours: a flat list of the tokens found in the source ('xxxT.xml') and the
+// parse tree of the class ('xxx.xml') where every non-terminal of the Jack grammar (class, statements,
+// expression, ...) nests the terminals (keyword, symbol, identifier, ...) and non-terminals it contains.
+//
+// Since the tree is rebuilt from the 'jack.Class' (and not from the source code) some details are
+// reconstructed with a best-effort approach:
+// - Fields of the same kind and type declared on the same line are grouped in a single 'classVarDec'
+// - Parentheses are emitted only when required by the grammar, redundant ones (e.g. 'let x = (y);') are lost
+type Element struct {
+	Tag      string    // The element name (e.g. 'keyword', 'symbol' or 'whileStatement')
+	Token    string    // The token value, used only by terminal elements
+	Children []Element // The nested elements, used only by non-terminal elements
+}
+
+// Returns whether the element is a terminal of the grammar, a.k.a. a token.
+func (e Element) IsTerminal() bool {
+	switch e.Tag {
+	case "keyword", "symbol", "identifier", "integerConstant", "stringConstant":
+		return true
+	default:
+		return false
+	}
+}
+
+// Returns the terminal elements (the tokens) in the subtree, in the order they appear in the source.
+func (e Element) Tokens() []Element {
+	if e.IsTerminal() {
+		return []Element{e}
+	}
+
+	tokens := []Element{}
+	for _, child := range e.Children {
+		tokens = append(tokens, child.Tokens()...)
+	}
+	return tokens
+}
+
+// Returns the textual XML representation of the element, where 'indent' is the indentation to
+// apply at each nesting level (the parse tree uses 2 spaces while the token list uses none).
+func (e Element) XML(indent string) string {
+	builder := strings.Builder{}
+	e.write(&builder, indent, 0)
+	return builder.String()
+}
+
+// Escapes the characters reserved by XML that can appear in Jack tokens (e.g. the '<' operator).
+var xmlEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;")
+
+func (e Element) write(builder *strings.Builder, indent string, depth int) {
+	prefix := strings.Repeat(indent, depth)
+	if e.IsTerminal() {
+		fmt.Fprintf(builder, "%s<%s> %s </%s>\n", prefix, e.Tag, xmlEscaper.Replace(e.Token), e.Tag)
+		return
+	}
+
+	fmt.Fprintf(builder, "%s<%s>\n", prefix, e.Tag)
+	for _, child := range e.Children {
+		child.write(builder, indent, depth+1)
+	}
+	fmt.Fprintf(builder, "%s</%s>\n", prefix, e.Tag)
+}
+
+// Shorthands to create the terminal elements.
+func keyword(token string) Element    { return Element{Tag: "keyword", Token: token} }
+func symbol(token string) Element     { return Element{Tag: "symbol", Token: token} }
+func identifier(token string) Element { return Element{Tag: "identifier", Token: token} }
+
+// Takes a 'jack.Class' and converts it to the parse tree of the project 10 XML format.
+//
+// The conversion is stateless, the emitter just walks the class w/ a DFS and creates one 'Element'
+// for each construct visited, errors are returned for the constructs w/o a counterpart in the format.
+type XMLEmitter struct {
+	class Class // The class to convert in the project 10 XML format
+}
+
+// Initializes and returns to the caller a brand new 'XMLEmitter' struct.
+func NewXMLEmitter(c Class) XMLEmitter {
+	return XMLEmitter{class: c}
+}
+
+// Emits the parse tree of the class, its tokens can be obtained w/ 'Element.Tokens()'.
+func (e *XMLEmitter) Emit() (Element, error) {
+	class := Element{Tag: "class", Children: []Element{keyword("class"), identifier(e.class.Name), symbol("{")}}
+
+	// Fields are grouped back to their declaration, we know that they share kind, type and line
+	var declaration *Element
+	var last Variable
+	for _, field := range e.class.Fields.Entries() {
+		if declaration != nil && field.VarType == last.VarType && field.DataType == last.DataType && field.Position.Line == last.Position.Line {
+			declaration.Children = append(declaration.Children, symbol(","), identifier(field.Name))
+			last = field
+			continue
+		}
+
+		if declaration != nil {
+			class.Children = append(class.Children, e.closeDeclaration(*declaration))
+		}
+		declaration = &Element{Tag: "classVarDec", Children: []Element{keyword(string(field.VarType)), e.HandleDataType(field.DataType), identifier(field.Name)}}
+		last = field
+	}
+	if declaration != nil {
+		class.Children = append(class.Children, e.closeDeclaration(*declaration))
+	}
+
+	constants, err := e.HandleConstants()
+	if err != nil {
+		return Element{}, err
+	}
+	class.Children = append(class.Children, constants...)
+
+	for _, subroutine := range e.class.Subroutines.Entries() {
+		element, err := e.HandleSubroutine(subroutine)
+		if err != nil {
+			return Element{}, err
+		}
+		class.Children = append(class.Children, element)
+	}
+
+	class.Children = append(class.Children, symbol("}"))
+	return class, nil
+}
+
+// Converts the constants of the class to 'classConstDec' and 'enumDec' elements (not part of the project
+// 10 grammar), the members of an enum are grouped back and their value is omitted if it's the implicit one.
+func (e *XMLEmitter) HandleConstants() ([]Element, error) {
+	elements, enum, next := []Element{}, (*Element)(nil), int16(0)
+	for _, constant := range e.class.Constants.Entries() {
+		if constant.Enum == "" {
+			value, err := e.HandleExpression(constant.Value)
+			if err != nil {
+				return nil, err
+			}
+			elements = append(elements, e.closeDeclaration(Element{Tag: "classConstDec", Children: []Element{
+				keyword("const"), e.HandleDataType(constant.DataType), identifier(constant.Name), symbol("="), value,
+			}}))
+			enum = nil
+			continue
+		}
+
+		if enum == nil || enum.Children[1].Token != constant.Enum {
+			elements = append(elements, Element{Tag: "enumDec", Children: []Element{keyword("enum"), identifier(constant.Enum), symbol("{")}})
+			enum, next = &elements[len(elements)-1], 0
+		} else {
+			enum.Children = append(enum.Children, symbol(","))
+		}
+
+		enum.Children = append(enum.Children, identifier(constant.Name))
+		if value, _ := ConstantValue(constant.Value); value != next {
+			expr, err := e.HandleExpression(constant.Value)
+			if err != nil {
+				return nil, err
+			}
+			enum.Children, next = append(enum.Children, symbol("="), expr), value
+		}
+		next++
+	}
+
+	for i := range elements {
+		if elements[i].Tag == "enumDec" {
+			elements[i].Children = append(elements[i].Children, symbol("}"))
+		}
+	}
+	return elements, nil
+}
+
+func (e *XMLEmitter) closeDeclaration(declaration Element) Element {
+	declaration.Children = append(declaration.Children, symbol(";"))
+	return declaration
+}
+
+// Specialized function to convert a 'jack.Subroutine' to a 'subroutineDec' element.
+func (e *XMLEmitter) HandleSubroutine(subroutine Subroutine) (Element, error) {
+	parameters := Element{Tag: "parameterList", Children: []Element{}}
+	for i, arg := range subroutine.Arguments {
+		if i > 0 {
+			parameters.Children = append(parameters.Children, symbol(","))
+		}
+		parameters.Children = append(parameters.Children, e.HandleDataType(arg.DataType), identifier(arg.Name))
+	}
+
+	// In the Jack grammar the 'var' declarations are part of the body but not of the statements
+	body, statements := Element{Tag: "subroutineBody", Children: []Element{symbol("{")}}, subroutine.Statements
+	for len(statements) > 0 {
+		declaration, isVarStmt := statements[0].(VarStmt)
+		if !isVarStmt {
+			break
+		}
+		body.Children = append(body.Children, e.HandleVarStmt(declaration))
+		statements = statements[1:]
+	}
+
+	nested, err := e.HandleStatements(statements)
+	if err != nil {
+		return Element{}, err
+	}
+	body.Children = append(body.Children, nested, symbol("}"))
+
+	return Element{Tag: "subroutineDec", Children: []Element{
+		keyword(string(subroutine.Type)), e.HandleDataType(subroutine.Return), identifier(subroutine.Name),
+		symbol("("), parameters, symbol(")"), body,
+	}}, nil
+}
+
+// Specialized function to convert a 'jack.DataType' to either a 'keyword' or an 'identifier' element.
+func (e *XMLEmitter) HandleDataType(dataType DataType) Element {
+	switch dataType.Main {
+	case Int, Char, Bool, Void:
+		return keyword(string(dataType.Main))
+	default: // Both 'Array' and the other classes are identifiers
+		return identifier(dataType.String())
+	}
+}
+
+// Specialized function to convert a 'jack.VarStmt' to a 'varDec' element.
+func (e *XMLEmitter) HandleVarStmt(statement VarStmt) Element {
+	declaration := Element{Tag: "varDec", Children: []Element{keyword("var")}}
+	for i, variable := range statement.Vars {
+		if i == 0 {
+			declaration.Children = append(declaration.Children, e.HandleDataType(variable.DataType))
+		} else {
+			declaration.Children = append(declaration.Children, symbol(","))
+		}
+		declaration.Children = append(declaration.Children, identifier(variable.Name))
+	}
+	return e.closeDeclaration(declaration)
+}
+
+// Specialized function to convert a list of 'jack.Statement' to a 'statements' element.
+func (e *XMLEmitter) HandleStatements(statements []Statement) (Element, error) {
+	element := Element{Tag: "statements", Children: []Element{}}
+	for _, statement := range statements {
+		nested, err := e.HandleStatement(statement)
+		if err != nil {
+			return Element{}, err
+		}
+		element.Children = append(element.Children, nested)
+	}
+	return element, nil
+}
+
+// Generalized function to dispatch and convert between multiple statements types returning an 'Element'.
+func (e *XMLEmitter) HandleStatement(statement Statement) (Element, error) {
+	switch tStatement := statement.(type) {
+	case DoStmt:
+		call, err := e.HandleFuncCallExpr(tStatement.FuncCall)
+		if err != nil {
+			return Element{}, err
+		}
+		return Element{Tag: "doStatement", Children: append(append([]Element{keyword("do")}, call...), symbol(";"))}, nil
+
+	case VarStmt:
+		return Element{}, tStatement.Position.Errorf("'var' declarations must precede the statements of the subroutine")
+
+	case LetStmt:
+		return e.HandleLetStmt(tStatement)
+
+	case IfStmt:
+		return e.HandleIfStmt(tStatement)
+
+	case WhileStmt:
+		condition, err := e.HandleExpression(tStatement.Condition)
+		if err != nil {
+			return Element{}, err
+		}
+		block, err := e.HandleStatements(tStatement.Block)
+		if err != nil {
+			return Element{}, err
+		}
+		return Element{Tag: "whileStatement", Children: []Element{
+			keyword("while"), symbol("("), condition, symbol(")"), symbol("{"), block, symbol("}"),
+		}}, nil
+
+	case ForStmt: // Not part of the original grammar (as 'break' and 'continue'), emitted as in the source code
+		return e.HandleForStmt(tStatement)
+
+	case SwitchStmt:
+		return e.HandleSwitchStmt(tStatement)
+
+	case BreakStmt:
+		return Element{Tag: "breakStatement", Children: []Element{keyword("break"), symbol(";")}}, nil
+	case ContinueStmt:
+		return Element{Tag: "continueStatement", Children: []Element{keyword("continue"), symbol(";")}}, nil
+
+	case ReturnStmt:
+		element := Element{Tag: "returnStatement", Children: []Element{keyword("return")}}
+		if tStatement.Expr != nil {
+			expr, err := e.HandleExpression(tStatement.Expr)
+			if err != nil {
+				return Element{}, err
+			}
+			element.Children = append(element.Children, expr)
+		}
+		element.Children = append(element.Children, symbol(";"))
+		return element, nil
+
+	default:
+		return Element{}, fmt.Errorf("unrecognized statement: %T", statement)
+	}
+}
+
+// Specialized function to convert a 'jack.LetStmt' to a 'letStatement' element.
+func (e *XMLEmitter) HandleLetStmt(statement LetStmt) (Element, error) {
+	element := Element{Tag: "letStatement", Children: []Element{keyword("let")}}
+
+	switch tLhs := statement.Lhs.(type) {
+	case VarExpr:
+		element.Children = append(element.Children, identifier(tLhs.Var))
+	case ArrayExpr:
+		index, err := e.HandleExpression(tLhs.Index)
+		if err != nil {
+			return Element{}, err
+		}
+		element.Children = append(element.Children, identifier(tLhs.Var), symbol("["), index, symbol("]"))
+	default:
+		return Element{}, statement.Position.Errorf("lhs expression can only be 'VarExpr' or 'ArrayExpr', got %T", statement.Lhs)
+	}
+
+	rhs, err := e.HandleExpression(statement.Rhs)
+	if err != nil {
+		return Element{}, err
+	}
+	element.Children = append(element.Children, symbol("="), rhs, symbol(";"))
+	return element, nil
+}
+
+// Specialized function to convert a 'jack.ForStmt' to a 'forStatement' element, the initialization
+// and the step are emitted as 'letStatement' elements w/o the trailing semicolon.
+func (e *XMLEmitter) HandleForStmt(statement ForStmt) (Element, error) {
+	element := Element{Tag: "forStatement", Children: []Element{keyword("for"), symbol("(")}}
+
+	for i, clause := range []Statement{statement.Init, statement.Condition, statement.Step} {
+		if i > 0 {
+			element.Children = append(element.Children, symbol(";"))
+		}
+
+		switch tClause := clause.(type) {
+		case nil: // The clause has been omitted
+		case LetStmt:
+			let, err := e.HandleLetStmt(tClause)
+			if err != nil {
+				return Element{}, err
+			}
+			let.Children = let.Children[:len(let.Children)-1]
+			element.Children = append(element.Children, let)
+		default:
+			condition, err := e.HandleExpression(tClause)
+			if err != nil {
+				return Element{}, err
+			}
+			element.Children = append(element.Children, condition)
+		}
+	}
+
+	block, err := e.HandleStatements(statement.Block)
+	if err != nil {
+		return Element{}, err
+	}
+	element.Children = append(element.Children, symbol(")"), symbol("{"), block, symbol("}"))
+	return element, nil
+}
+
+// Specialized function to convert a 'jack.SwitchStmt' to a 'switchStatement' element, each case
+// (and the default one) is nested in its own 'switchCase' element along w/ its statements.
+func (e *XMLEmitter) HandleSwitchStmt(statement SwitchStmt) (Element, error) {
+	expr, err := e.HandleExpression(statement.Expr)
+	if err != nil {
+		return Element{}, err
+	}
+	element := Element{Tag: "switchStatement", Children: []Element{keyword("switch"), symbol("("), expr, symbol(")"), symbol("{")}}
+
+	for _, sc := range statement.Cases {
+		label, err := e.HandleExpression(sc.Label)
+		if err != nil {
+			return Element{}, err
+		}
+		block, err := e.HandleStatements(sc.Block)
+		if err != nil {
+			return Element{}, err
+		}
+		element.Children = append(element.Children, Element{Tag: "switchCase", Children: []Element{keyword("case"), label, symbol(":"), block}})
+	}
+
+	if statement.Default != nil {
+		block, err := e.HandleStatements(statement.Default)
+		if err != nil {
+			return Element{}, err
+		}
+		element.Children = append(element.Children, Element{Tag: "switchCase", Children: []Element{keyword("default"), symbol(":"), block}})
+	}
+
+	element.Children = append(element.Children, symbol("}"))
+	return element, nil
+}
+
+// Specialized function to convert a 'jack.IfStmt' to an 'ifStatement' element.
+func (e *XMLEmitter) HandleIfStmt(statement IfStmt) (Element, error) {
+	condition, err := e.HandleExpression(statement.Condition)
+	if err != nil {
+		return Element{}, err
+	}
+	then, err := e.HandleStatements(statement.ThenBlock)
+	if err != nil {
+		return Element{}, err
+	}
+
+	element := Element{Tag: "ifStatement", Children: []Element{
+		keyword("if"), symbol("("), condition, symbol(")"), symbol("{"), then, symbol("}"),
+	}}
+	if statement.ElseBlock == nil { // The 'else' keyword was omitted entirely
+		return element, nil
+	}
+
+	otherwise, err := e.HandleStatements(statement.ElseBlock)
+	if err != nil {
+		return Element{}, err
+	}
+	element.Children = append(element.Children, keyword("else"), symbol("{"), otherwise, symbol("}"))
+	return element, nil
+}
+
+// Specialized function to convert a 'jack.Expression' to an 'expression' element.
+//
+// In the project 10 grammar an expression is a flat sequence of terms separated by operators, instead
+// the 'jack.BinaryExpr' is a tree: the chain on the left-hand side is flattened (since the reference
+// evaluates it strictly from left to right) unless wrapped by parentheses in the source code, while a
+// binary expression on the right-hand side is always emitted between parentheses by 'HandleTerm'.
+func (e *XMLEmitter) HandleExpression(expression Expression) (Element, error) {
+	binary, isBinary := expression.(BinaryExpr)
+	if !isBinary || binary.Parens > 0 {
+		term, err := e.HandleTerm(expression)
+		if err != nil {
+			return Element{}, err
+		}
+		return Element{Tag: "expression", Children: []Element{term}}, nil
+	}
+
+	lhs, err := e.HandleExpression(binary.Lhs)
+	if err != nil {
+		return Element{}, err
+	}
+	rhs, err := e.HandleTerm(binary.Rhs)
+	if err != nil {
+		return Element{}, err
+	}
+
+	operator, found := binaryOperators[binary.Type]
+	if !found {
+		return Element{}, binary.Position.Errorf("unrecognized binary operator '%s'", binary.Type)
+	}
+	return Element{Tag: "expression", Children: append(lhs.Children, symbol(operator), rhs)}, nil
+}
+
+// Maps each 'ExprType' allowed in a 'BinaryExpr' to the symbol used in the source code.
+// The operators not part of the project 10 grammar are emitted as they appear in the source code.
+var binaryOperators = map[ExprType]string{
+	Plus: "+", Minus: "-", Multiply: "*", Divide: "/", Modulo: "%",
+	BoolAnd: "&", BoolOr: "|", Equal: "=", LessThan: "<", GreatThan: ">",
+	LogicalAnd: "&&", LogicalOr: "||", NotEqual: "!=", LessEqual: "<=", GreatEqual: ">=",
+}
+
+// Specialized function to convert a 'jack.Expression' to a 'term' element.
+// Every pair of parentheses in the source code becomes a nested '( expression )' term.
+func (e *XMLEmitter) HandleTerm(expression Expression) (Element, error) {
+	if parens := parensOf(expression); parens > 0 {
+		nested, err := e.HandleExpression(withParens(expression, parens-1))
+		if err != nil {
+			return Element{}, err
+		}
+		return Element{Tag: "term", Children: []Element{symbol("("), nested, symbol(")")}}, nil
+	}
+
+	switch tExpression := expression.(type) {
+	case BinaryExpr: // Only allowed as term if wrapped by parentheses
+		nested, err := e.HandleExpression(tExpression)
+		if err != nil {
+			return Element{}, err
+		}
+		return Element{Tag: "term", Children: []Element{symbol("("), nested, symbol(")")}}, nil
+
+	case UnaryExpr:
+		operator, found := map[ExprType]string{Negation: "-", BoolNot: "~"}[tExpression.Type]
+		if !found {
+			return Element{}, tExpression.Position.Errorf("unrecognized unary operator '%s'", tExpression.Type)
+		}
+		rhs, err := e.HandleTerm(tExpression.Rhs)
+		if err != nil {
+			return Element{}, err
+		}
+		return Element{Tag: "term", Children: []Element{symbol(operator), rhs}}, nil
+
+	case CastExpr: // Not part of the original grammar, emitted as it appears in the source code
+		rhs, err := e.HandleTerm(tExpression.Rhs)
+		if err != nil {
+			return Element{}, err
+		}
+		return Element{Tag: "term", Children: []Element{symbol("["), e.HandleDataType(tExpression.Type), symbol("]"), rhs}}, nil
+
+	case FuncCallExpr:
+		call, err := e.HandleFuncCallExpr(tExpression)
+		if err != nil {
+			return Element{}, err
+		}
+		return Element{Tag: "term", Children: call}, nil
+
+	case ArrayExpr:
+		index, err := e.HandleExpression(tExpression.Index)
+		if err != nil {
+			return Element{}, err
+		}
+		return Element{Tag: "term", Children: []Element{identifier(tExpression.Var), symbol("["), index, symbol("]")}}, nil
+
+	case VarExpr:
+		if tExpression.Var == "this" {
+			return Element{Tag: "term", Children: []Element{keyword("this")}}, nil
+		}
+		return Element{Tag: "term", Children: []Element{identifier(tExpression.Var)}}, nil
+	case ConstExpr:
+		if tExpression.Enum != "" {
+			return Element{Tag: "term", Children: []Element{identifier(tExpression.Class), symbol("."), identifier(tExpression.Enum), symbol("."), identifier(tExpression.Name)}}, nil
+		}
+		return Element{Tag: "term", Children: []Element{identifier(tExpression.Class), symbol("."), identifier(tExpression.Name)}}, nil
+
+	case LiteralExpr:
+		literal, err := e.HandleLiteralExpr(tExpression)
+		if err != nil {
+			return Element{}, err
+		}
+		return Element{Tag: "term", Children: []Element{literal}}, nil
+
+	default:
+		return Element{}, fmt.Errorf("unrecognized expression: %T", expression)
+	}
+}
+
+// Specialized function to convert a 'jack.LiteralExpr' to its terminal element.
+func (e *XMLEmitter) HandleLiteralExpr(expression LiteralExpr) (Element, error) {
+	switch {
+	case expression.Type.Main == Int:
+		return Element{Tag: "integerConstant", Token: expression.Value}, nil
+	case expression.Type.Main == Bool, expression.Value == "null":
+		return keyword(expression.Value), nil
+	case expression.Type.Main == Object && expression.Type.Subtype == "String":
+		return Element{Tag: "stringConstant", Token: expression.Value}, nil
+	default:
+		return Element{}, expression.Position.Errorf("%s literal '%s' is not part of the project 10 grammar", expression.Type, expression.Value)
+	}
+}
+
+// Specialized function to convert a 'jack.FuncCallExpr' to the elements of the subroutine call.
+//
+// The subroutine call is not a non-terminal on its own: its elements are inlined in the parent.
+func (e *XMLEmitter) HandleFuncCallExpr(expression FuncCallExpr) ([]Element, error) {
+	call := []Element{}
+	if expression.IsExtCall {
+		call = append(call, identifier(expression.Var), symbol("."))
+	}
+
+	arguments := Element{Tag: "expressionList", Children: []Element{}}
+	for i, arg := range expression.Arguments {
+		if i > 0 {
+			arguments.Children = append(arguments.Children, symbol(","))
+		}
+		nested, err := e.HandleExpression(arg)
+		if err != nil {
+			return nil, err
+		}
+		arguments.Children = append(arguments.Children, nested)
+	}
+
+	return append(call, identifier(expression.FuncName), symbol("("), arguments, symbol(")")), nil
+}
@@ -0,0 +1,379 @@
+package tst
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	pc "github.com/prataprc/goparsec"
+)
+
+// ----------------------------------------------------------------------------
+// Parser Combinator(s)
+
+// This section defines the Parser Combinator for every command of the test script language.
+//
+// Each command is made of a leading keyword followed by its arguments and it's terminated by
+// one of the separators (',' ';' '!'), the separators are meaningful only for the interactive
+// execution of the script (they mark the steps at which the simulator pauses) so they're simply
+// discarded here. Also we manage both single line and multi line comments inside the script.
+
+// Top level object, will generate the traversable AST based on the input plus the PCs below.
+var ast = pc.NewAST("test-script", 0)
+
+var (
+	// Parser combinator for an entire test script (a sequence of comments and commands)
+	pScript = ast.ManyUntil("script", nil, pItem, pc.End())
+
+	// Parser combinator for a generic item of the script (either a comment or a command)
+	pItem = ast.OrdChoice("item", nil, pComment, &pCommand)
+	// Parser combinator for both single line and multi line comments
+	pComment = ast.OrdChoice("comment", nil, pc.Token(`//[^\n]*`, "COMMENT"), pc.Token(`(?s)/\*.*?\*/`, "COMMENT"))
+
+	// Parser combinator for a generic command (followed by its separator), since commands
+	// can be nested (e.g. 'repeat' and 'while' bodies) it's initialized in the 'init' function.
+	pCommand pc.Parser
+)
+
+var (
+	// Parser combinator for the 'load' command (with or without a target part)
+	pLoad = ast.OrdChoice("load", nil,
+		ast.And("load-default", nil, pc.Atom("load", "LOAD"), ast.Maybe("maybe-file", nil, pFile)),
+		ast.And("load-target", nil, pIdentifier, pc.Atom("load", "LOAD"), ast.Maybe("maybe-file", nil, pFile)),
+	)
+	// Parser combinator for the 'output-file' command
+	pOutputFile = ast.And("output-file", nil, pc.Atom("output-file", "OUTPUT-FILE"), pFile)
+	// Parser combinator for the 'compare-to' command
+	pCompareTo = ast.And("compare-to", nil, pc.Atom("compare-to", "COMPARE-TO"), pFile)
+	// Parser combinator for the 'output-list' command
+	pOutputList = ast.And("output-list", nil, pc.Atom("output-list", "OUTPUT-LIST"), ast.Kleene("columns", nil, pColumn))
+
+	// Parser combinator for the 'set' command
+	pSet = ast.And("set", nil, pc.Atom("set", "SET"), pVariable, pValue)
+	// Parser combinator for the simulation commands without arguments
+	pTickTock  = pc.Atom("ticktock", "TICKTOCK")
+	pTick      = pc.Atom("tick", "TICK")
+	pTock      = pc.Atom("tock", "TOCK")
+	pEval      = pc.Atom("eval", "EVAL")
+	pVMStep    = pc.Atom("vmstep", "VMSTEP")
+	pOutput    = pc.Atom("output", "OUTPUT")
+	pClearEcho = pc.Atom("clear-echo", "CLEAR-ECHO")
+	// Parser combinator for the 'echo' command
+	pEcho = ast.And("echo", nil, pc.Atom("echo", "ECHO"), pc.Token(`"[^"]*"`, "STRING"))
+
+	// Parser combinator for the 'repeat' command
+	pRepeat = ast.And("repeat", nil,
+		pc.Atom("repeat", "REPEAT"), ast.Maybe("maybe-times", nil, pc.Int()),
+		pc.Atom("{", "{"), ast.ManyUntil("body", nil, pItem, pc.Atom("}", "}")), pc.Atom("}", "}"),
+	)
+	// Parser combinator for the 'while' command
+	pWhile = ast.And("while", nil,
+		pc.Atom("while", "WHILE"), pVariable, pComparison, pValue,
+		pc.Atom("{", "{"), ast.ManyUntil("body", nil, pItem, pc.Atom("}", "}")), pc.Atom("}", "}"),
+	)
+)
+
+var (
+	// Generic separator parser, marks the end of a command
+	pSeparator = ast.OrdChoice("separator", nil, pc.Atom(",", ","), pc.Atom(";", ";"), pc.Atom("!", "!"))
+
+	// Generic identifier parser, used for part names (e.g. 'ROM32K')
+	pIdentifier = pc.Token(`[A-Za-z_][0-9A-Za-z_]*`, "IDENT")
+	// Generic variable parser, it can optionally be indexed (e.g. 'RAM[0]', 'ARegister[]')
+	pVariable = pc.Token(`[A-Za-z_][0-9A-Za-z_]*(\[[0-9]*\])?`, "VARIABLE")
+	// Generic file name parser, anything until the first whitespace or separator
+	pFile = pc.Token(`[^\s,;!]+`, "FILE")
+	// Generic value parser, either a plain decimal or a number prefixed by its format (e.g. '%X00FF')
+	pValue = pc.Token(`%[BXD]-?[0-9A-Fa-f]+|-?[0-9]+`, "VALUE")
+	// Generic output column parser, a variable followed by its format (e.g. 'RAM[0]%D2.6.2')
+	pColumn = pc.Token(`[A-Za-z_][0-9A-Za-z_]*(\[[0-9]*\])?%[BXDS][0-9]+\.[0-9]+\.[0-9]+`, "COLUMN")
+
+	// Generic comparison parser (while condition)
+	// NOTE: As for the commands, the longer operators must be tried before their prefixes.
+	pComparison = ast.OrdChoice("comparison", nil,
+		pc.Atom("<>", "<>"), pc.Atom("<=", "<="), pc.Atom(">=", ">="),
+		pc.Atom("=", "="), pc.Atom("<", "<"), pc.Atom(">", ">"),
+	)
+)
+
+func init() {
+	// NOTE: Since each Atom is matched as a prefix the longer keywords must be tried first (e.g.
+	// 'ticktock' before 'tick' or 'output-list' before 'output') else the latter would always match.
+	pCommand = ast.And("command", nil,
+		ast.OrdChoice("statement", nil,
+			pRepeat, pWhile, pOutputList, pOutputFile, pCompareTo, pOutput,
+			pSet, pTickTock, pTick, pTock, pEval, pVMStep, pEcho, pClearEcho, pLoad,
+		),
+		ast.Maybe("maybe-separator", nil, pSeparator),
+	)
+}
+
+// Splits an output column (e.g. 'RAM[0]%D2.6.2') in the variable name and its format specifiers.
+var columnRegex = regexp.MustCompile(`^(.+)%([BXDS])([0-9]+)\.([0-9]+)\.([0-9]+)$`)
+
+// ----------------------------------------------------------------------------
+// Test Script Parser
+
+// This section defines the Parser for the nand2tetris test script language.
+//
+// It uses parser combinator(s) to obtain the AST from the source code (the latter can be provided)
+// in multiple ways using a generic io.Reader, the library reads up the feature flags (as env vars):
+// - PARSEC_DEBUG: Verbose logging to inspect which of the PCs gets triggered and match
+// - EXPORT_AST:   Exports in the DEBUG_FOLDER a Graphviz representation of the AST
+// - PRINT_AST:    Print on the stdout a textual representation of the AST
+type Parser struct{ reader io.Reader }
+
+// Initializes and returns to the caller a brand new 'Parser' struct.
+// Requires the argument io.Reader 'r' to be valid and usable.
+func NewParser(r io.Reader) Parser {
+	return Parser{reader: r}
+}
+
+// Parser entrypoint divides the 2 phases of the parsing pipeline
+// Text --> AST: This step is done using PCs and returns a generic traversable AST
+// AST --> IR: This step is done by traversing the AST and extracting the 'tst.Command'
+func (p *Parser) Parse() (Script, error) {
+	content, err := io.ReadAll(p.reader)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read from 'io.Reader': %s", err)
+	}
+
+	ast, success := p.FromSource(content)
+	if !success {
+		return nil, fmt.Errorf("failed to parse AST from input content")
+	}
+
+	return p.FromAST(ast)
+}
+
+// Scans the textual input stream coming from the 'reader' method and returns a traversable AST
+// (Abstract Syntax Tree) that can be eventually visited to extract/transform the info available.
+func (p *Parser) FromSource(source []byte) (pc.Queryable, bool) {
+
+	// Feature flag: Enable 'goparsec' library's debug logs
+	if os.Getenv("PARSEC_DEBUG") != "" {
+		ast.SetDebug()
+	}
+
+	// We generate the traversable Abstract Syntax Tree from the source content
+	root, scanner := ast.Parsewith(pScript, pc.NewScanner(source))
+
+	// Feature flag: Enables export of the AST as Dot file (debug.ast.fot)
+	if os.Getenv("EXPORT_AST") != "" {
+		file, _ := os.Create(fmt.Sprintf("%s/debug.ast.dot", os.Getenv("DEBUG_FOLDER")))
+		defer file.Close()
+
+		file.Write([]byte(ast.Dotstring("\"Test Script AST\"")))
+	}
+
+	// Feature flag: Enables pretty printing of the AST on the console
+	if os.Getenv("PRINT_AST") != "" {
+		ast.Prettyprint()
+	}
+
+	// Success is based on the reaching of 'EOF' (once the trailing whitespaces are skipped)
+	_, scanner = scanner.SkipWS()
+	return root, root != nil && scanner.Endof()
+}
+
+// This function takes the root node of the raw parsed AST and does a DFS on it parsing
+// one by one each subtree and retuning a 'tst.Script' that can be used as in-memory and
+// type-safe AST not dependent on the parsing library used.
+func (p *Parser) FromAST(root pc.Queryable) (Script, error) {
+	if root.GetName() != "script" {
+		return nil, fmt.Errorf("expected node 'script', found %s", root.GetName())
+	}
+
+	return p.HandleBody(root)
+}
+
+// Specialized function to convert a list of "command" nodes (either the whole script or the body
+// of a 'repeat' or 'while' command) to a list of 'tst.Command'.
+func (p *Parser) HandleBody(body pc.Queryable) ([]Command, error) {
+	commands := []Command{}
+
+	for _, child := range body.GetChildren() {
+		switch child.GetName() {
+		case "command": // Command subtree, appends the 'tst.Command' to 'commands'
+			command, err := p.HandleCommand(child.GetChildren()[0])
+			if command == nil || err != nil {
+				return nil, err
+			}
+			commands = append(commands, command)
+
+		case "COMMENT": // Comment nodes in the AST are just skipped
+			continue
+
+		default: // Error case, unrecognized subtree in the AST
+			return nil, fmt.Errorf("unrecognized node '%s'", child.GetName())
+		}
+	}
+
+	return commands, nil
+}
+
+// Specialized function to convert a single command node to its 'tst.Command' counterpart.
+func (p *Parser) HandleCommand(node pc.Queryable) (Command, error) {
+	switch node.GetName() {
+	case "TICKTOCK":
+		return TickTockCmd{}, nil
+	case "TICK":
+		return TickCmd{}, nil
+	case "TOCK":
+		return TockCmd{}, nil
+	case "EVAL":
+		return EvalCmd{}, nil
+	case "VMSTEP":
+		return VMStepCmd{}, nil
+	case "OUTPUT":
+		return OutputCmd{}, nil
+	case "CLEAR-ECHO":
+		return ClearEchoCmd{}, nil
+
+	case "echo":
+		return EchoCmd{Text: strings.Trim(node.GetChildren()[1].GetValue(), `"`)}, nil
+	case "output-file":
+		return OutputFileCmd{File: node.GetChildren()[1].GetValue()}, nil
+	case "compare-to":
+		return CompareToCmd{File: node.GetChildren()[1].GetValue()}, nil
+
+	case "load-default", "load-target":
+		return p.HandleLoad(node)
+	case "output-list":
+		return p.HandleOutputList(node)
+	case "set":
+		return p.HandleSet(node)
+	case "repeat":
+		return p.HandleRepeat(node)
+	case "while":
+		return p.HandleWhile(node)
+
+	default: // Error case, unrecognized subtree in the AST
+		return nil, fmt.Errorf("unrecognized command '%s'", node.GetName())
+	}
+}
+
+// Specialized function to convert a "load-default" or "load-target" node to a 'tst.LoadCmd'.
+func (Parser) HandleLoad(node pc.Queryable) (Command, error) {
+	children, command := node.GetChildren(), LoadCmd{}
+
+	if node.GetName() == "load-target" { // The target part is provided before the keyword
+		command.Target, children = children[0].GetValue(), children[1:]
+	}
+	if file := children[1]; file.GetName() == "FILE" {
+		command.File = file.GetValue()
+	}
+
+	return command, nil
+}
+
+// Specialized function to convert a "output-list" node to a 'tst.OutputListCmd'.
+func (Parser) HandleOutputList(node pc.Queryable) (Command, error) {
+	if node.GetName() != "output-list" { // Prelude checks: inspects the node to verify it's an 'output-list'
+		return nil, fmt.Errorf("expected node 'output-list', found %s", node.GetName())
+	}
+
+	columns := []Column{}
+	for _, child := range node.GetChildren()[1].GetChildren() {
+		match := columnRegex.FindStringSubmatch(child.GetValue())
+		if match == nil {
+			return nil, fmt.Errorf("malformed output column '%s'", child.GetValue())
+		}
+
+		lpad, _ := strconv.Atoi(match[3])
+		width, _ := strconv.Atoi(match[4])
+		rpad, _ := strconv.Atoi(match[5])
+		columns = append(columns, Column{
+			Variable: match[1], Format: FormatType(match[2]), LPad: lpad, Width: width, RPad: rpad,
+		})
+	}
+
+	return OutputListCmd{Columns: columns}, nil
+}
+
+// Specialized function to convert a "set" node to a 'tst.SetCmd'.
+func (Parser) HandleSet(node pc.Queryable) (Command, error) {
+	if node.GetName() != "set" { // Prelude checks: inspects the node to verify it's a 'set'
+		return nil, fmt.Errorf("expected node 'set', found %s", node.GetName())
+	}
+
+	variable, raw := node.GetChildren()[1].GetValue(), node.GetChildren()[2].GetValue()
+	value, err := ParseValue(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	return SetCmd{Variable: variable, Value: value}, nil
+}
+
+// Specialized function to convert a "repeat" node to a 'tst.RepeatCmd'.
+func (p *Parser) HandleRepeat(node pc.Queryable) (Command, error) {
+	if node.GetName() != "repeat" { // Prelude checks: inspects the node to verify it's a 'repeat'
+		return nil, fmt.Errorf("expected node 'repeat', found %s", node.GetName())
+	}
+
+	times := 0 // When the counter is omitted the body is repeated forever
+	if counter := node.GetChildren()[1]; counter.GetName() == "INT" {
+		times, _ = strconv.Atoi(counter.GetValue())
+	}
+
+	body, err := p.HandleBody(node.GetChildren()[3])
+	if err != nil {
+		return nil, err
+	}
+
+	return RepeatCmd{Times: times, Body: body}, nil
+}
+
+// Specialized function to convert a "while" node to a 'tst.WhileCmd'.
+func (p *Parser) HandleWhile(node pc.Queryable) (Command, error) {
+	if node.GetName() != "while" { // Prelude checks: inspects the node to verify it's a 'while'
+		return nil, fmt.Errorf("expected node 'while', found %s", node.GetName())
+	}
+
+	children := node.GetChildren()
+	value, err := ParseValue(children[3].GetValue())
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := p.HandleBody(children[5])
+	if err != nil {
+		return nil, err
+	}
+
+	condition := Condition{Variable: children[1].GetValue(), Operator: ComparisonType(children[2].GetValue()), Value: value}
+	return WhileCmd{Condition: condition, Body: body}, nil
+}
+
+// Converts the textual representation of a value (e.g. '-1', '%B0101', '%XFFFF') to its 16 bit
+// counterpart, the binary and hex formats are interpreted as unsigned (two's complement) values.
+func ParseValue(raw string) (int16, error) {
+	base, digits := 10, raw
+	if strings.HasPrefix(raw, "%") {
+		switch FormatType(raw[1:2]) {
+		case Binary:
+			base = 2
+		case Hex:
+			base = 16
+		}
+		digits = raw[2:]
+	}
+
+	if base == 10 {
+		value, err := strconv.ParseInt(digits, 10, 16)
+		if err != nil {
+			return 0, fmt.Errorf("value '%s' is not a valid 16 bit integer", raw)
+		}
+		return int16(value), nil
+	}
+
+	value, err := strconv.ParseUint(digits, base, 16)
+	if err != nil {
+		return 0, fmt.Errorf("value '%s' is not a valid 16 bit integer", raw)
+	}
+	return int16(value), nil
+}
@@ -0,0 +1,326 @@
+package tst
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ----------------------------------------------------------------------------
+// Simulator
+
+// This section defines the interface every simulated component must implement to be driven by
+// a test script, this way the same 'Runner' can be shared by the CPU Emulator, the Hardware
+// Simulator and the VM Emulator without knowing anything about their internals.
+//
+// Variables are always exchanged as 16 bit values, it's up to the 'Runner' to format them as
+// requested by the output list. The 'time' variable is never forwarded to the simulator since
+// the clock is managed directly by the 'Runner' that keeps track of ticks and tocks.
+type Simulator interface {
+	// Loads the given file (or the whole folder if 'path' is a directory) in the 'target' part,
+	// an empty 'target' means that the simulator should load the file in its default location.
+	Load(target, path string) error
+	// Sets the value of the given variable (e.g. 'RAM[0]', 'PC', an input pin).
+	Set(variable string, value int16) error
+	// Gets the current value of the given variable (e.g. 'RAM[0]', 'PC', an output pin).
+	Get(variable string) (int16, error)
+	// Evaluates the combinational logic of the simulated component.
+	Eval() error
+	// Advances the clock from the low phase to the high phase.
+	Tick() error
+	// Advances the clock from the high phase to the low phase.
+	Tock() error
+}
+
+// Optional interface implemented by the simulators that support the 'vmstep' command.
+type VMStepper interface {
+	VMStep() error
+}
+
+// ----------------------------------------------------------------------------
+// Runner
+
+// This section defines the Runner, the interpreter of the test script language.
+//
+// The Runner executes the commands one after the other against the given 'Simulator' and
+// records the output table, if a compare file is provided each output line is compared with
+// its counterpart as soon as it's produced so that the execution stops at the first mismatch.
+// Just like the official simulators, a '*' in the compare file matches any character.
+type Runner struct {
+	script Script    // The test script to execute
+	sim    Simulator // The component under test, driven by the script
+	dir    string    // The folder used to resolve the relative paths inside the script
+
+	columns []Column // The current output list, as declared by the last 'output-list' command
+	output  []string // The output table produced so far (header included)
+	compare []string // The lines of the compare file (if any) the output is checked against
+
+	time int  // The number of clock cycles elapsed since the start of the script
+	high bool // Whether the clock is in the high phase (after a 'tick' and before a 'tock')
+}
+
+// Initializes and returns to the caller a brand new 'Runner' struct.
+// Requires the 'script' to run, the simulator 'sim' to drive and the folder 'dir' where the
+// files referenced by the script (programs, chips, compare files) are located.
+func NewRunner(script Script, sim Simulator, dir string) Runner {
+	return Runner{script: script, sim: sim, dir: dir}
+}
+
+// Runs the whole test script, returns an error either if one of the commands cannot be executed
+// or if the output table doesn't match the compare file (when one is specified by the script).
+func (r *Runner) Run() error {
+	return r.RunCommands(r.script)
+}
+
+// Returns the output table produced by the script, line by line (header included).
+func (r *Runner) Output() []string {
+	return r.output
+}
+
+// Executes the given list of commands, one after the other, stopping at the first error.
+func (r *Runner) RunCommands(commands []Command) error {
+	for _, command := range commands {
+		if err := r.RunCommand(command); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Executes a single command, dispatching it to the simulator or managing it internally.
+func (r *Runner) RunCommand(command Command) error {
+	switch cmd := command.(type) {
+	case LoadCmd:
+		path := r.dir // With no file, the simulator decides what to load from the folder
+		if cmd.File != "" {
+			path = filepath.Join(r.dir, cmd.File)
+		}
+		if err := r.sim.Load(cmd.Target, path); err != nil {
+			return fmt.Errorf("unable to load '%s': %w", cmd.File, err)
+		}
+		return nil
+
+	case OutputFileCmd: // The output table is kept in memory, see 'Output'
+		return nil
+
+	case CompareToCmd:
+		return r.HandleCompareTo(cmd)
+
+	case OutputListCmd:
+		r.columns = cmd.Columns
+		return r.emit(r.header())
+
+	case OutputCmd:
+		line, err := r.row()
+		if err != nil {
+			return err
+		}
+		return r.emit(line)
+
+	case SetCmd:
+		return r.sim.Set(cmd.Variable, cmd.Value)
+
+	case EvalCmd:
+		return r.sim.Eval()
+
+	case TickCmd:
+		r.high = true
+		return r.sim.Tick()
+
+	case TockCmd:
+		r.time, r.high = r.time+1, false
+		return r.sim.Tock()
+
+	case TickTockCmd:
+		if err := r.RunCommand(TickCmd{}); err != nil {
+			return err
+		}
+		return r.RunCommand(TockCmd{})
+
+	case VMStepCmd:
+		stepper, ok := r.sim.(VMStepper)
+		if !ok {
+			return fmt.Errorf("command 'vmstep' is not supported by the simulator")
+		}
+		return stepper.VMStep()
+
+	case EchoCmd, ClearEchoCmd: // Messages for the user are meaningless when running headless
+		return nil
+
+	case RepeatCmd:
+		for i := 0; cmd.Times <= 0 || i < cmd.Times; i++ {
+			if err := r.RunCommands(cmd.Body); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case WhileCmd:
+		for {
+			met, err := r.evaluate(cmd.Condition)
+			if err != nil || !met {
+				return err
+			}
+			if err := r.RunCommands(cmd.Body); err != nil {
+				return err
+			}
+		}
+
+	default:
+		return fmt.Errorf("unrecognized command '%T'", command)
+	}
+}
+
+// Specialized function to load the compare file, declared by a 'compare-to' command.
+func (r *Runner) HandleCompareTo(cmd CompareToCmd) error {
+	file, err := os.Open(filepath.Join(r.dir, cmd.File))
+	if err != nil {
+		return fmt.Errorf("unable to open compare file '%s': %w", cmd.File, err)
+	}
+	defer file.Close()
+
+	r.compare = []string{}
+	for scanner := bufio.NewScanner(file); scanner.Scan(); {
+		r.compare = append(r.compare, scanner.Text())
+	}
+
+	return nil
+}
+
+// Appends 'line' to the output table, comparing it to the expected one (if any).
+func (r *Runner) emit(line string) error {
+	r.output = append(r.output, line)
+	if r.compare == nil {
+		return nil
+	}
+
+	n := len(r.output)
+	if n > len(r.compare) {
+		return fmt.Errorf("comparison failure at line %d: compare file has only %d lines", n, len(r.compare))
+	}
+
+	expected, actual := strings.TrimRight(r.compare[n-1], " \t\r"), strings.TrimRight(line, " \t\r")
+	if !matches(expected, actual) {
+		return fmt.Errorf("comparison failure at line %d: expected '%s', got '%s'", n, expected, actual)
+	}
+
+	return nil
+}
+
+// Checks that the 'actual' output line matches the 'expected' one, '*' matches any character.
+func matches(expected, actual string) bool {
+	if len(expected) != len(actual) {
+		return false
+	}
+	for i := range expected {
+		if expected[i] != '*' && expected[i] != actual[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Evaluates the condition of a 'while' command against the current state of the simulator.
+func (r *Runner) evaluate(c Condition) (bool, error) {
+	value, err := r.sim.Get(c.Variable)
+	if err != nil {
+		return false, err
+	}
+
+	switch c.Operator {
+	case Eq:
+		return value == c.Value, nil
+	case Neq:
+		return value != c.Value, nil
+	case Lt:
+		return value < c.Value, nil
+	case Gt:
+		return value > c.Value, nil
+	case Le:
+		return value <= c.Value, nil
+	case Ge:
+		return value >= c.Value, nil
+	default:
+		return false, fmt.Errorf("unrecognized comparison operator '%s'", c.Operator)
+	}
+}
+
+// ----------------------------------------------------------------------------
+// Output formatting
+
+// Builds the header of the output table, each variable name is centered in its column and
+// eventually truncated if it doesn't fit the whole column width (padding included).
+func (r *Runner) header() string {
+	var sb strings.Builder
+
+	sb.WriteString("|")
+	for _, column := range r.columns {
+		total, name := column.LPad+column.Width+column.RPad, column.Variable
+		if len(name) > total {
+			name = name[:total]
+		}
+
+		left := (total - len(name)) / 2
+		sb.WriteString(strings.Repeat(" ", left) + name + strings.Repeat(" ", total-left-len(name)) + "|")
+	}
+
+	return sb.String()
+}
+
+// Builds a row of the output table using the current values of the variables in the output list.
+func (r *Runner) row() (string, error) {
+	var sb strings.Builder
+
+	sb.WriteString("|")
+	for _, column := range r.columns {
+		value, err := r.format(column)
+		if err != nil {
+			return "", err
+		}
+		sb.WriteString(strings.Repeat(" ", column.LPad) + value + strings.Repeat(" ", column.RPad) + "|")
+	}
+
+	return sb.String(), nil
+}
+
+// Renders the value of the variable in the given column with the requested format and width.
+func (r *Runner) format(column Column) (string, error) {
+	if column.Variable == "time" { // The clock is managed by the runner, not by the simulator
+		time := strconv.Itoa(r.time)
+		if r.high {
+			time += "+"
+		}
+		return fmt.Sprintf("%-*s", column.Width, time), nil
+	}
+
+	value, err := r.sim.Get(column.Variable)
+	if err != nil {
+		return "", err
+	}
+
+	switch column.Format {
+	case Binary: // Fixed width, only the least significant 'Width' bits are rendered
+		bits := fmt.Sprintf("%016b", uint16(value))
+		if column.Width < len(bits) {
+			return bits[len(bits)-column.Width:], nil
+		}
+		return fmt.Sprintf("%0*s", column.Width, bits), nil
+	case Hex: // Fixed width, only the least significant 'Width' digits are rendered
+		digits := fmt.Sprintf("%04X", uint16(value))
+		if column.Width < len(digits) {
+			return digits[len(digits)-column.Width:], nil
+		}
+		return fmt.Sprintf("%0*s", column.Width, digits), nil
+	case Decimal:
+		return fmt.Sprintf("%*d", column.Width, value), nil
+	case String:
+		return fmt.Sprintf("%-*d", column.Width, value), nil
+	default:
+		return "", fmt.Errorf("unrecognized format '%s' for variable '%s'", column.Format, column.Variable)
+	}
+}
@@ -0,0 +1,126 @@
+package tst
+
+// ----------------------------------------------------------------------------
+// General information
+
+// This section contains some general information about the nand2tetris test script language.
+//
+// Test scripts (.tst files) are used by all the nand2tetris simulators (Hardware Simulator,
+// CPU Emulator and VM Emulator) to drive the simulated component: they load the program or
+// the chip to test, set its inputs, advance the clock and then record the values of a list of
+// variables in an output table that is compared line by line against a '.cmp' file.
+//
+// We declare a shared 'Command' interface for every command available in the language, the
+// script is then just a linear list of commands (some of which can have nested commands).
+
+// A test script is just a linear and contiguous sequence of commands.
+type Script []Command
+
+// Just used to put together all the commands in the same datatype, use type switch to disambiguate.
+type Command interface{}
+
+// ----------------------------------------------------------------------------
+// Setup commands
+
+// Loads the program or the chip to test from 'File', when 'Target' is provided (e.g. 'ROM32K load
+// Add.hack') the file is loaded directly into the given part instead. 'File' can be empty, in that
+// case the simulator decides what to load (e.g. the VM Emulator loads every file in the folder).
+type LoadCmd struct {
+	Target string // The (optional) name of the part that should load the file
+	File   string // The (optional) path of the file, relative to the script folder
+}
+
+// Declares the file where the output table will be written to.
+type OutputFileCmd struct{ File string }
+
+// Declares the file the output table will be compared to, line by line.
+type CompareToCmd struct{ File string }
+
+// Declares the list of variables (and their format) that will be recorded by each 'OutputCmd'.
+type OutputListCmd struct{ Columns []Column }
+
+// A single column of the output table, the format specification follows the '%F{L}.{W}.{R}'
+// syntax where 'F' is the format (binary, hex, decimal or string) and 'L', 'W' and 'R' are
+// respectively the left padding, the width and the right padding of the column.
+type Column struct {
+	Variable string     // The name of the variable to output (e.g. 'RAM[0]', 'out', 'time')
+	Format   FormatType // How the value of the variable should be rendered
+	LPad     int        // Number of whitespace before the value
+	Width    int        // Number of characters used to render the value
+	RPad     int        // Number of whitespace after the value
+}
+
+type FormatType string // Enum to manage the formats allowed for an output column
+
+const (
+	Binary  FormatType = "B"
+	Hex     FormatType = "X"
+	Decimal FormatType = "D"
+	String  FormatType = "S"
+)
+
+// ----------------------------------------------------------------------------
+// Simulation commands
+
+// Sets the 'Variable' (an input pin, a register or a memory location) to the given 'Value'.
+type SetCmd struct {
+	Variable string // The name of the variable to set (e.g. 'RAM[0]', 'in', 'PC')
+	Value    int16  // The value to set, already converted from its textual format
+}
+
+// Advances the clock of half a cycle, from the low phase to the high phase.
+type TickCmd struct{}
+
+// Advances the clock of half a cycle, from the high phase to the low phase.
+type TockCmd struct{}
+
+// Advances the clock of a whole cycle, it's just a shorthand for 'tick, tock'.
+type TickTockCmd struct{}
+
+// Evaluates the combinational logic of the simulated component (no clock advancement).
+type EvalCmd struct{}
+
+// Executes a single VM operation (only supported by the VM Emulator).
+type VMStepCmd struct{}
+
+// Records the current values of the variables (as declared by 'OutputListCmd') in the output table.
+type OutputCmd struct{}
+
+// Displays a message to the user, used by interactive scripts (e.g. 'Press a key to continue').
+type EchoCmd struct{ Text string }
+
+// Clears the message previously displayed with 'EchoCmd'.
+type ClearEchoCmd struct{}
+
+// ----------------------------------------------------------------------------
+// Control flow commands
+
+// Executes the 'Body' command list 'Times' times, a non positive value means forever.
+type RepeatCmd struct {
+	Times int       // How many times the body should be executed
+	Body  []Command // The commands to execute at each iteration
+}
+
+// Executes the 'Body' command list as long as the 'Condition' is met.
+type WhileCmd struct {
+	Condition Condition // The condition checked before each iteration
+	Body      []Command // The commands to execute at each iteration
+}
+
+// A comparison between a variable and a constant value (e.g. 'out <> 75').
+type Condition struct {
+	Variable string         // The name of the variable to compare
+	Operator ComparisonType // The comparison operator to use
+	Value    int16          // The value to compare the variable against
+}
+
+type ComparisonType string // Enum to manage the comparisons allowed for a Condition
+
+const (
+	Eq  ComparisonType = "="
+	Neq ComparisonType = "<>"
+	Lt  ComparisonType = "<"
+	Gt  ComparisonType = ">"
+	Le  ComparisonType = "<="
+	Ge  ComparisonType = ">="
+)
@@ -0,0 +1,171 @@
+package tst_test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"its-hmny.dev/nand2tetris/pkg/emulator"
+	"its-hmny.dev/nand2tetris/pkg/hdl"
+	"its-hmny.dev/nand2tetris/pkg/tst"
+)
+
+func TestParser(t *testing.T) {
+	test := func(source string, expected tst.Script, fail bool) {
+		parser := tst.NewParser(strings.NewReader(source))
+		script, err := parser.Parse()
+		if err != nil && !fail {
+			t.Fatalf("unexpected error parsing script: %s", err)
+		}
+		if err == nil && fail {
+			t.Fatalf("expected error parsing script, got %+v", script)
+		}
+		if err == nil && !reflect.DeepEqual(script, expected) {
+			t.Errorf("expected %+v, got %+v", expected, script)
+		}
+	}
+
+	t.Run("Setup commands", func(t *testing.T) {
+		test("load Mult.hack,\noutput-file Mult.out,\ncompare-to Mult.cmp,\noutput-list RAM[0]%D2.6.2 time%S1.4.1;",
+			tst.Script{
+				tst.LoadCmd{File: "Mult.hack"},
+				tst.OutputFileCmd{File: "Mult.out"},
+				tst.CompareToCmd{File: "Mult.cmp"},
+				tst.OutputListCmd{Columns: []tst.Column{
+					{Variable: "RAM[0]", Format: tst.Decimal, LPad: 2, Width: 6, RPad: 2},
+					{Variable: "time", Format: tst.String, LPad: 1, Width: 4, RPad: 1},
+				}},
+			}, false)
+		test("load, // loads the whole folder\nROM32K load Add.hack;",
+			tst.Script{tst.LoadCmd{}, tst.LoadCmd{Target: "ROM32K", File: "Add.hack"}}, false)
+	})
+
+	t.Run("Simulation commands", func(t *testing.T) {
+		test("set in %B0000000000000101, set address %X00FF, set RAM[0] -1,\n/* comment */ eval, tick, tock, output;",
+			tst.Script{
+				tst.SetCmd{Variable: "in", Value: 5},
+				tst.SetCmd{Variable: "address", Value: 255},
+				tst.SetCmd{Variable: "RAM[0]", Value: -1},
+				tst.EvalCmd{}, tst.TickCmd{}, tst.TockCmd{}, tst.OutputCmd{},
+			}, false)
+		test("set in %XFFFF; ticktock; vmstep; echo \"Hello, world\"; clear-echo;",
+			tst.Script{
+				tst.SetCmd{Variable: "in", Value: -1},
+				tst.TickTockCmd{}, tst.VMStepCmd{}, tst.EchoCmd{Text: "Hello, world"}, tst.ClearEchoCmd{},
+			}, false)
+	})
+
+	t.Run("Control flow commands", func(t *testing.T) {
+		test("repeat 10 {\n  ticktock;\n}\nwhile out <> 75 {\n  eval,\n}",
+			tst.Script{
+				tst.RepeatCmd{Times: 10, Body: []tst.Command{tst.TickTockCmd{}}},
+				tst.WhileCmd{
+					Condition: tst.Condition{Variable: "out", Operator: tst.Neq, Value: 75},
+					Body:      []tst.Command{tst.EvalCmd{}},
+				},
+			}, false)
+	})
+
+	t.Run("Malformed scripts", func(t *testing.T) {
+		test("set RAM[0];", nil, true)
+		test("repeat 10 { ticktock;", nil, true)
+		test("output-list RAM[0]%Q1.6.1;", nil, true)
+	})
+}
+
+func TestRunner(t *testing.T) {
+	parse := func(path string) tst.Script {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("Error reading input file: %s", err)
+		}
+
+		parser := tst.NewParser(strings.NewReader(string(content)))
+		script, err := parser.Parse()
+		if err != nil {
+			t.Fatalf("Error parsing test script: %s", err)
+		}
+		return script
+	}
+
+	test := func(path string, sim tst.Simulator) {
+		runner := tst.NewRunner(parse(path), sim, filepath.Dir(path))
+		if err := runner.Run(); err != nil {
+			t.Fatalf("Error running test script: %s", err)
+		}
+	}
+
+	t.Run("Mult.tst", func(t *testing.T) {
+		cpu := emulator.NewCPU()
+		test("../../../projects/04 - Machine Language/01 - Mult/Mult.tst", &cpu)
+	})
+
+	t.Run("FillAutomatic.tst", func(t *testing.T) {
+		cpu := emulator.NewCPU()
+		test("../../../projects/04 - Machine Language/02 - Fill/FillAutomatic.tst", &cpu)
+	})
+
+	// NOTE: 'Fill.tst' is only parsed since it runs forever, waiting for the user to press keys
+	t.Run("Fill.tst", func(t *testing.T) {
+		script := parse("../../../projects/04 - Machine Language/02 - Fill/Fill.tst")
+		if repeat, ok := script[len(script)-1].(tst.RepeatCmd); !ok || repeat.Times != 0 {
+			t.Errorf("expected an endless 'repeat' command, got %+v", script[len(script)-1])
+		}
+	})
+
+	// The 'Computer' chip is simulated at gate level, running the programs loaded in its ROM
+	scripts, _ := filepath.Glob("../../../projects/05 - Computer Architecture/03 - Computer/Computer*.tst")
+	for _, script := range scripts {
+		t.Run(filepath.Base(script), func(t *testing.T) {
+			sim := hdl.NewSimulator()
+			test(script, &sim)
+		})
+	}
+
+	t.Run("Output formatting", func(t *testing.T) {
+		dir := t.TempDir()
+		os.WriteFile(filepath.Join(dir, "Test.asm"), []byte("@42\nD=A\n@0\nM=D"), 0644)
+		os.WriteFile(filepath.Join(dir, "Test.cmp"), []byte(strings.Join([]string{
+			"| time |RAM[0]|   D    | A  |",
+			"| 0    |     0|00000000|0000|",
+			"| 4    |    42|00101010|****|",
+		}, "\n")), 0644)
+
+		parser := tst.NewParser(strings.NewReader(
+			"load Test.asm, compare-to Test.cmp, output-list time%S1.4.1 RAM[0]%D0.6.0 D%B0.8.0 A%X0.4.0;\n" +
+				"output; repeat 4 { ticktock; } output;",
+		))
+		script, err := parser.Parse()
+		if err != nil {
+			t.Fatalf("Error parsing test script: %s", err)
+		}
+
+		cpu := emulator.NewCPU()
+		runner := tst.NewRunner(script, &cpu, dir)
+		if err := runner.Run(); err != nil {
+			t.Fatalf("Error running test script: %s", err)
+		}
+		if output := runner.Output(); len(output) != 3 || output[2] != "| 4    |    42|00101010|0000|" {
+			t.Errorf("unexpected output table: %q", output)
+		}
+	})
+
+	t.Run("Comparison failure", func(t *testing.T) {
+		dir := t.TempDir()
+		os.WriteFile(filepath.Join(dir, "Test.cmp"), []byte("|RAM[0]|\n|     1|"), 0644)
+
+		script := tst.Script{
+			tst.CompareToCmd{File: "Test.cmp"},
+			tst.OutputListCmd{Columns: []tst.Column{{Variable: "RAM[0]", Format: tst.Decimal, Width: 6}}},
+			tst.OutputCmd{},
+		}
+
+		cpu := emulator.NewCPU()
+		runner := tst.NewRunner(script, &cpu, dir)
+		if err := runner.Run(); err == nil {
+			t.Errorf("expected comparison failure, got none")
+		}
+	})
+}
@@ -0,0 +1,139 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	pc "github.com/prataprc/goparsec"
+)
+
+// Position identifies a location (file, line and column) in the source code of a program.
+//
+// Both line and column are 1-based (as most editors do) while the column is counted in bytes,
+// the zero value is used for the nodes that do not come from a source file (e.g. generated code).
+type Position struct {
+	File   string // The name of the source file, may be empty if unknown
+	Line   int    // The line number, starting from 1
+	Column int    // The column number (in bytes), starting from 1
+}
+
+// Returns whether the position points to an actual location in the source code.
+func (p Position) IsValid() bool { return p.Line > 0 }
+
+// Returns the textual representation of the position (e.g. 'Main.jack:12:9').
+func (p Position) String() string {
+	switch {
+	case !p.IsValid():
+		return p.File
+	case p.File == "":
+		return fmt.Sprintf("%d:%d", p.Line, p.Column)
+	default:
+		return fmt.Sprintf("%s:%d:%d", p.File, p.Line, p.Column)
+	}
+}
+
+// Attaches the position to 'err', if the error (or one of the errors it wraps) already carries a
+// position then the latter is kept since it's expected to be the most specific one available.
+func (p Position) Wrap(err error) error {
+	var located PositionError
+	if err == nil || !p.IsValid() || errors.As(err, &located) {
+		return err
+	}
+	return PositionError{Position: p, Err: err}
+}
+
+// Formats the error message according to 'format' and attaches the position to it.
+func (p Position) Errorf(format string, args ...any) error {
+	return p.Wrap(fmt.Errorf(format, args...))
+}
+
+// PositionError is an error that occurred at a specific location in the source code.
+type PositionError struct {
+	Position Position // Where the error occurred
+	Err      error    // The underlying error
+}
+
+func (e PositionError) Error() string { return fmt.Sprintf("%s: %s", e.Position, e.Err) }
+func (e PositionError) Unwrap() error { return e.Err }
+
+// Formats an error occurred during the compilation 'pass' to be shown to the user, whenever the
+// error carries a position the latter is used as prefix (e.g. 'Main.jack:12:9: ERROR: ...').
+func FormatError(pass string, err error) string {
+	var located PositionError
+	if errors.As(err, &located) {
+		return fmt.Sprintf("%s: ERROR: Unable to complete '%s' pass: %s", located.Position, pass, located.Err)
+	}
+	return fmt.Sprintf("ERROR: Unable to complete '%s' pass: %s", pass, err)
+}
+
+// ----------------------------------------------------------------------------
+// Source files
+
+// SourceFile maps the byte offsets of a source file to their human readable 'Position'.
+//
+// The offsets of the beginning of each line are computed once, this way the line of each
+// offset can be found with a binary search instead of scanning the content every time.
+type SourceFile struct {
+	name    string // The name of the file, used as 'Position.File'
+	content []byte // The content of the file, used to quote the source in the error messages
+	lines   []int  // The byte offset of the beginning of each line
+}
+
+// Initializes and returns to the caller a brand new 'SourceFile' struct.
+func NewSourceFile(name string, content []byte) SourceFile {
+	lines := []int{0}
+	for offset, char := range content {
+		if char == '\n' {
+			lines = append(lines, offset+1)
+		}
+	}
+	return SourceFile{name: name, content: content, lines: lines}
+}
+
+// Converts a byte offset in the file to its 'Position', negative offsets are unknown positions.
+func (f SourceFile) Position(offset int) Position {
+	if offset < 0 || len(f.lines) == 0 {
+		return Position{File: f.name}
+	}
+
+	line := sort.Search(len(f.lines), func(i int) bool { return f.lines[i] > offset }) - 1
+	return Position{File: f.name, Line: line + 1, Column: offset - f.lines[line] + 1}
+}
+
+// Returns the content of the line (without the line terminator) that contains the byte 'offset'.
+func (f SourceFile) Line(offset int) string {
+	position := f.Position(offset)
+	if !position.IsValid() {
+		return ""
+	}
+
+	start, end := f.lines[position.Line-1], len(f.content)
+	if position.Line < len(f.lines) {
+		end = f.lines[position.Line] - 1
+	}
+	return strings.TrimRight(string(f.content[start:end]), "\r")
+}
+
+// Returns the 'Position' of the first token matched by a node of the AST produced by 'goparsec'.
+func (f SourceFile) PositionOf(node pc.Queryable) Position {
+	return f.Position(offsetOf(node))
+}
+
+// Looks up (depth first) the offset of the first token matched by 'node', the optional nodes not
+// matched (named 'missing') and the empty repetitions do not have an offset so they're skipped.
+func offsetOf(node pc.Queryable) int {
+	if node == nil {
+		return -1
+	}
+	if node.IsTerminal() {
+		return node.GetPosition()
+	}
+	for _, child := range node.GetChildren() {
+		if offset := offsetOf(child); offset >= 0 {
+			return offset
+		}
+	}
+	return -1
+}
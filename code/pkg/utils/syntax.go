@@ -0,0 +1,136 @@
+package utils
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	pc "github.com/prataprc/goparsec"
+)
+
+// ----------------------------------------------------------------------------
+// Syntax errors
+
+// This section implements the error reporting for the parser combinators built with 'goparsec'.
+//
+// The library only tells whether a parser matched or not, so when a program contains a typo the
+// parsing either stops early (leaving the rest of the input unconsumed) or fails without any clue
+// about where and why. To overcome this the terminals of each grammar are wrapped by a 'SyntaxTracker'
+// that records the furthest offset where a terminal failed to match and the terminals expected there:
+// this is the most likely location of the error (the classic 'furthest failure' heuristic).
+
+// Matches the offending token in the source code, either a word-like token or a single symbol.
+var tokenRegex = regexp.MustCompile(`^([A-Za-z0-9_$.:]+|\S)`)
+
+// SyntaxTracker records the furthest failure of the terminals of a grammar while parsing.
+type SyntaxTracker struct {
+	furthest int      // The furthest offset where a terminal failed to match
+	expected []string // The description of the terminals expected at 'furthest' (no duplicates)
+}
+
+// Initializes and returns to the caller a brand new 'SyntaxTracker' struct.
+func NewSyntaxTracker() *SyntaxTracker {
+	return &SyntaxTracker{furthest: -1}
+}
+
+// Forgets the failures recorded so far, to be called before parsing a new source file.
+func (t *SyntaxTracker) Reset() { t.furthest, t.expected = -1, nil }
+
+// Wraps 'parser' so that its failures are recorded, 'description' is shown to the user as the
+// expected token (e.g. 'identifier' or 'segment') whenever it's the furthest failure in the source.
+func (t *SyntaxTracker) Expect(description string, parser pc.Parser) pc.Parser {
+	return func(s pc.Scanner) (pc.ParsecNode, pc.Scanner) {
+		node, news := parser(s)
+		if node == nil {
+			t.record(description, s)
+		}
+		return node, news
+	}
+}
+
+// Same as 'pc.Atom' but the failures are recorded, the expected token is the quoted 'match'.
+func (t *SyntaxTracker) Atom(match string, name string) pc.Parser {
+	return t.Expect(fmt.Sprintf("'%s'", match), pc.Atom(match, name))
+}
+
+// Records the failure of a terminal (described by 'description') starting from scanner 's'.
+func (t *SyntaxTracker) record(description string, s pc.Scanner) {
+	ws := s.Clone() // Terminals skip the leading whitespaces before matching
+	ws.SkipWS()
+
+	switch offset := ws.GetCursor(); {
+	case offset > t.furthest:
+		t.furthest, t.expected = offset, []string{description}
+	case offset == t.furthest:
+		for _, other := range t.expected {
+			if other == description {
+				return
+			}
+		}
+		t.expected = append(t.expected, description)
+	}
+}
+
+// Returns the error for a source that could not be parsed entirely, 's' is the scanner returned by
+// the parser, if the parser did not match at all the scanner is expected to be at the beginning.
+func (t *SyntaxTracker) Error(source SourceFile, s pc.Scanner) error {
+	ws := s.Clone()
+	ws.SkipWS()
+
+	// The furthest failure is preferred since the parser usually gives up on a larger construct
+	// (e.g. a statement) before the actual offending token, otherwise the input should have ended.
+	offset, expected := ws.GetCursor(), []string{"end of file"}
+	if t.furthest >= offset {
+		offset, expected = t.furthest, t.expected
+	}
+
+	token := "end of file"
+	if offset < len(source.content) {
+		token = fmt.Sprintf("'%s'", tokenRegex.Find(source.content[offset:]))
+	}
+
+	position := source.Position(offset)
+	return position.Wrap(SyntaxError{
+		Token:    token,
+		Expected: append([]string(nil), expected...),
+		Line:     source.Line(offset),
+		Column:   position.Column,
+	})
+}
+
+// SyntaxError is the error reported when the source code does not conform to the grammar.
+type SyntaxError struct {
+	Token    string   // The offending token (quoted) or 'end of file'
+	Expected []string // The description of the tokens that were expected instead, may be empty
+	Line     string   // The line of source code where the offending token is found
+	Column   int      // The column (in bytes, starting from 1) of the token in 'Line'
+}
+
+// Returns the error message along with a snippet of the source that points to the offending token.
+//
+//	unexpected 'Q', expected computation
+//	    D=Q
+//	      ^
+func (e SyntaxError) Error() string {
+	message := fmt.Sprintf("unexpected %s", e.Token)
+	switch len(e.Expected) {
+	case 0:
+	case 1:
+		message += fmt.Sprintf(", expected %s", e.Expected[0])
+	default:
+		message += fmt.Sprintf(", expected one of %s", strings.Join(e.Expected, ", "))
+	}
+
+	if e.Column < 1 || e.Column > len(e.Line)+1 {
+		return message
+	}
+
+	// The caret is aligned by reusing the tabs of the line, their width depends on the terminal
+	caret := []byte(e.Line[:e.Column-1])
+	for i, char := range caret {
+		if char != '\t' {
+			caret[i] = ' '
+		}
+	}
+	return fmt.Sprintf("%s\n    %s\n    %s^", message, e.Line, caret)
+}
@@ -0,0 +1,560 @@
+package vm
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"its-hmny.dev/nand2tetris/pkg/emulator"
+)
+
+// ----------------------------------------------------------------------------
+// Interpreter
+
+// This section defines the Interpreter, that executes a 'vm.Program' directly without lowering it.
+//
+// The Interpreter acts as the reference semantics for the VM language: it works on the same RAM
+// layout assumed by the 'vm.Lowerer' (and by the official VM Emulator) so that the state of the
+// memory after the execution can be compared 1:1 with the one produced by the translated program
+// running on the Hack CPU. Notably, the following locations are used:
+//   - 'SP', 'LCL', 'ARG', 'THIS' and 'THAT': The segment pointers at the RAM locations 0 to 4
+//   - 'temp': The 8 words starting at RAM location 5
+//   - 'static': Allocated from RAM location 16 onwards, a word for every (module, offset) pair
+//
+// Before the execution the modules are linked together (in alphabetical order) in a single flat
+// list of operations, labels are resolved to the index of the operation that follows them so they
+// don't take any step to be executed (the same happens for labels in the translated program).
+// The return addresses pushed on the stack by 'call' are indexes in such list of operations.
+type Interpreter struct {
+	RAM []uint16 // The data memory, same size and layout of the Hack computer's one
+	PC  uint16   // The index of the next operation to be executed
+
+	code    []Operation       // The linked program, every module's operations one after the other
+	modules []string          // The module each operation belongs to (used to scope the statics)
+	labels  map[string]uint16 // The index of every label ('Function$label') and function declared
+	statics map[string]uint16 // The RAM location allocated to every static ('Module.offset')
+
+	builtins map[string]Builtin // The native functions in use, they take precedence over the VM code
+	kernel   kernel             // The internal state of the native functions (heap, cursor, etc.)
+
+	Steps uint64 // The number of operations executed since the beginning
+}
+
+// Well-known RAM locations used by the VM implementation.
+const (
+	SP   uint16 = 0  // Location of the Stack Pointer
+	LCL  uint16 = 1  // Location of the base pointer of the 'local' segment
+	ARG  uint16 = 2  // Location of the base pointer of the 'argument' segment
+	THIS uint16 = 3  // Location of the base pointer of the 'this' segment
+	THAT uint16 = 4  // Location of the base pointer of the 'that' segment
+	TEMP uint16 = 5  // Base location of the 'temp' segment
+	GP   uint16 = 16 // Base location of the 'static' segment
+
+	RAMSize uint16 = 1 << 15 // Number of words addressable in the RAM
+)
+
+// Special return addresses, being outside of the program they both stop the execution.
+const (
+	haltAddress   uint16 = 0xFFFF // Used when the control is never given back (e.g. after 'Sys.halt')
+	invokeAddress uint16 = 0xFFFE // Used when a function is invoked natively (see 'Invoke')
+)
+
+// Initializes and returns to the caller a brand new 'Interpreter' struct.
+// The program is linked immediately, an error is returned if the same label is declared twice in
+// the same function or a function is declared twice in the whole program.
+func NewInterpreter(p Program) (Interpreter, error) {
+	i := Interpreter{
+		RAM:      make([]uint16, RAMSize),
+		labels:   map[string]uint16{},
+		statics:  map[string]uint16{},
+		builtins: map[string]Builtin{},
+	}
+
+	names := make([]string, 0, len(p))
+	for name := range p {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		scope := "global" // Same scoping used by the 'vm.Lowerer' for labels outside of functions
+		for _, op := range p[name] {
+			switch tOp := op.(type) {
+			case LabelDecl: // Labels are resolved to the next operation, they're not executed
+				label := fmt.Sprintf("%s$%s", scope, tOp.Name)
+				if _, found := i.labels[label]; found {
+					return Interpreter{}, tOp.Position.Errorf("label '%s' declared twice in '%s'", tOp.Name, scope)
+				}
+				i.labels[label] = uint16(len(i.code))
+				continue
+
+			case FuncDecl:
+				if _, found := i.labels[tOp.Name]; found {
+					return Interpreter{}, tOp.Position.Errorf("function '%s' declared twice", tOp.Name)
+				}
+				scope, i.labels[tOp.Name] = tOp.Name, uint16(len(i.code))
+
+			case MemoryOp: // Allocates the statics in order of appearance, like the assembler does
+				static := fmt.Sprintf("%s.%d", name, tOp.Offset)
+				if _, found := i.statics[static]; !found && tOp.Segment == Static {
+					i.statics[static] = GP + uint16(len(i.statics))
+				}
+
+			case GotoOp: // Goto(s) are stored with the label already scoped to the function
+				op = GotoOp{Label: fmt.Sprintf("%s$%s", scope, tOp.Label), Jump: tOp.Jump, Position: tOp.Position}
+			}
+
+			i.code, i.modules = append(i.code, op), append(i.modules, name)
+		}
+	}
+
+	return i, nil
+}
+
+// Bootstraps the execution like the 'bootstrap' code of the 'vm_translator' does: sets the
+// Stack Pointer to 261 (leaving space for the 'entrypoint' caller frame) and jumps to it.
+// If the 'entrypoint' is a native function (e.g. 'Sys.init') it's executed straight away.
+func (i *Interpreter) Bootstrap(entrypoint string) error {
+	i.RAM[SP] = 261
+	if _, found := i.builtins[entrypoint]; found {
+		_, err := i.Invoke(entrypoint)
+		return err
+	}
+
+	address, found := i.labels[entrypoint]
+	if !found {
+		return fmt.Errorf("undefined entrypoint function '%s'", entrypoint)
+	}
+
+	i.PC = address
+	return nil
+}
+
+// Enables the native implementation of every function of the given standard library 'classes',
+// calls to those functions are intercepted even if the program declares them in its VM code.
+func (i *Interpreter) EnableBuiltIns(classes ...string) error {
+	if i.builtins == nil {
+		i.builtins = map[string]Builtin{}
+	}
+
+	for _, class := range classes {
+		functions, found := StandardLibrary[class]
+		if !found {
+			return fmt.Errorf("no native implementation available for class '%s'", class)
+		}
+		for name, builtin := range functions {
+			i.builtins[fmt.Sprintf("%s.%s", class, name)] = builtin
+		}
+	}
+
+	return nil
+}
+
+// Disables the native implementation of the given standard library 'classes', from now on calls
+// to their functions are resolved against the VM code of the program.
+func (i *Interpreter) DisableBuiltIns(classes ...string) {
+	for _, class := range classes {
+		for name := range StandardLibrary[class] {
+			delete(i.builtins, fmt.Sprintf("%s.%s", class, name))
+		}
+	}
+}
+
+// Invokes the function 'name' with the given arguments and runs it until it returns, this is
+// how native functions call other functions (e.g. 'Output.printString' calls 'String.charAt')
+// regardless of them being implemented natively or in VM code.
+func (i *Interpreter) Invoke(name string, args ...uint16) (uint16, error) {
+	if builtin, found := i.builtins[name]; found {
+		value, err := i.native(name, builtin, args)
+		if errors.Is(err, ErrBlocked) {
+			return 0, fmt.Errorf("'%s' cannot wait for input when invoked natively", name)
+		}
+		if err != nil {
+			return 0, fmt.Errorf("%s: %w", name, err)
+		}
+		i.kernel.transferred = false
+		return value, nil
+	}
+
+	pc := i.PC
+	for _, arg := range args {
+		if err := i.push(arg); err != nil {
+			return 0, err
+		}
+	}
+	if err := i.call(name, uint16(len(args)), invokeAddress); err != nil {
+		return 0, err
+	}
+
+	for i.PC != invokeAddress {
+		if i.Halted() {
+			return 0, fmt.Errorf("program halted during the invocation of '%s'", name)
+		}
+		if err := i.Step(); err != nil {
+			return 0, err
+		}
+		if i.kernel.blocked {
+			return 0, fmt.Errorf("'%s' cannot wait for input when invoked natively", name)
+		}
+	}
+
+	i.PC = pc
+	return i.pop()
+}
+
+// Returns true when there are no more operations to be executed.
+func (i *Interpreter) Halted() bool {
+	return int(i.PC) >= len(i.code)
+}
+
+// Executes 'n' operations, stopping at the first one that returns an error or when halted.
+func (i *Interpreter) Run(n int) error {
+	for step := 0; step < n && !i.Halted(); step++ {
+		if err := i.Step(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Runs both the Interpreter and the translated program loaded on the Hack 'cpu' (made of 'size'
+// instructions) then compares their RAM, an error is returned at the first mismatch found.
+//
+// Programs with 'Sys.init' never halt (they loop forever at the end of the execution) so both are
+// run for a bounded amount of steps. The stack above the SP contains garbage (e.g. different return
+// addresses) so it's ignored as well as R13, R14 and R15 since they're used internally by the
+// translated program.
+func (i *Interpreter) CrossCheck(cpu *emulator.CPU, size int) error {
+	if err := i.Run(10_000); err != nil {
+		return fmt.Errorf("unexpected interpreter error: %w", err)
+	}
+	for cycle := 0; cycle < 1_000_000 && int(cpu.PC) < size; cycle++ {
+		if err := cpu.Step(); err != nil {
+			return fmt.Errorf("unexpected emulator error: %w", err)
+		}
+	}
+
+	for address := range cpu.RAM {
+		if (address >= int(cpu.RAM[SP]) && address < 2048) || (address >= 13 && address < 16) {
+			continue
+		}
+		if cpu.RAM[address] != i.RAM[address] {
+			return fmt.Errorf("mismatch at RAM[%d]: emulator %d, interpreter %d", address, int16(cpu.RAM[address]), int16(i.RAM[address]))
+		}
+	}
+	return nil
+}
+
+// Executes the operation pointed by the PC. Returns an error if the operation is not a valid one
+// or if it tries to access a location outside of the RAM. Once halted this is a no-op, just like
+// the Hack CPU spinning in the infinite loop at the end of a program.
+func (i *Interpreter) Step() error {
+	if i.Halted() {
+		return nil
+	}
+
+	op, module := i.code[i.PC], i.modules[i.PC]
+	i.PC, i.Steps = i.PC+1, i.Steps+1
+
+	switch tOp := op.(type) { // The errors are bound to the position of the operation (if known)
+	case MemoryOp:
+		return tOp.Position.Wrap(i.ExecMemoryOp(tOp, module))
+	case ArithmeticOp:
+		return tOp.Position.Wrap(i.ExecArithmeticOp(tOp))
+	case GotoOp:
+		return tOp.Position.Wrap(i.ExecGotoOp(tOp))
+	case FuncDecl:
+		return tOp.Position.Wrap(i.ExecFuncDecl(tOp))
+	case FuncCallOp:
+		return tOp.Position.Wrap(i.ExecFuncCallOp(tOp))
+	case ReturnOp:
+		return tOp.Position.Wrap(i.ExecReturnOp(tOp))
+	default: // Error case, unrecognized operation type
+		return fmt.Errorf("unrecognized operation '%T'", tOp)
+	}
+}
+
+// Specialized function to execute a 'vm.MemoryOp', moves a word between the stack and a segment.
+func (i *Interpreter) ExecMemoryOp(op MemoryOp, module string) error {
+	if op.Operation == Pop && op.Segment == Constant {
+		return fmt.Errorf("cannot pop to the 'constant' segment")
+	}
+
+	if op.Operation == Push && op.Segment == Constant {
+		return i.push(op.Offset)
+	}
+
+	address, err := i.address(op.Segment, op.Offset, module)
+	if err != nil {
+		return err
+	}
+
+	switch op.Operation {
+	case Push:
+		value, err := i.read(address)
+		if err != nil {
+			return err
+		}
+		return i.push(value)
+
+	case Pop:
+		value, err := i.pop()
+		if err != nil {
+			return err
+		}
+		return i.write(address, value)
+	}
+
+	return fmt.Errorf("unrecognized memory operation '%s'", op.Operation)
+}
+
+// Specialized function to execute a 'vm.ArithmeticOp', replaces the operands with the result.
+// Just like in the Hack platform 'true' is represented as -1 (all bits set) and 'false' as 0.
+func (i *Interpreter) ExecArithmeticOp(op ArithmeticOp) error {
+	y, err := i.pop()
+	if err != nil {
+		return err
+	}
+
+	switch op.Operation { // Unary operations only need one operand
+	case Neg:
+		return i.push(-y)
+	case Not:
+		return i.push(^y)
+	}
+
+	x, err := i.pop()
+	if err != nil {
+		return err
+	}
+
+	switch op.Operation {
+	case Add:
+		return i.push(x + y)
+	case Sub:
+		return i.push(x - y)
+	case And:
+		return i.push(x & y)
+	case Or:
+		return i.push(x | y)
+	case Eq:
+		return i.push(boolean(x == y))
+	case Gt:
+		return i.push(boolean(int16(x) > int16(y)))
+	case Lt:
+		return i.push(boolean(int16(x) < int16(y)))
+	}
+
+	return fmt.Errorf("unrecognized arithmetic operation '%s'", op.Operation)
+}
+
+// Specialized function to execute a 'vm.GotoOp', the conditional jump consumes the stack's top.
+func (i *Interpreter) ExecGotoOp(op GotoOp) error {
+	address, found := i.labels[op.Label]
+	if !found {
+		return fmt.Errorf("undefined label '%s'", op.Label)
+	}
+
+	switch op.Jump {
+	case Unconditional:
+		i.PC = address
+		return nil
+
+	case Conditional:
+		value, err := i.pop()
+		if err != nil {
+			return err
+		}
+		if value != 0 {
+			i.PC = address
+		}
+		return nil
+	}
+
+	return fmt.Errorf("unrecognized jump type '%s'", op.Jump)
+}
+
+// Specialized function to execute a 'vm.FuncDecl', initializes the 'local' segment to zeroes.
+func (i *Interpreter) ExecFuncDecl(op FuncDecl) error {
+	for range op.NLocal {
+		if err := i.push(0); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Specialized function to execute a 'vm.FuncCallOp', saves the caller frame on the stack (return
+// address, 'LCL', 'ARG', 'THIS' and 'THAT') and transfers the control to the callee function.
+// Native functions instead are executed in a single step, replacing the arguments with the result.
+func (i *Interpreter) ExecFuncCallOp(op FuncCallOp) error {
+	builtin, found := i.builtins[op.Name]
+	if !found {
+		return i.call(op.Name, uint16(op.NArgs), i.PC)
+	}
+
+	args := make([]uint16, op.NArgs)
+	for n := range args {
+		value, err := i.read(i.RAM[SP] - uint16(op.NArgs) + uint16(n))
+		if err != nil {
+			return err
+		}
+		args[n] = value
+	}
+
+	value, err := i.native(op.Name, builtin, args)
+	i.kernel.blocked = errors.Is(err, ErrBlocked)
+	switch {
+	case i.kernel.blocked: // The same call is executed again on the next step
+		i.PC--
+		return nil
+	case err != nil:
+		return fmt.Errorf("%s: %w", op.Name, err)
+	case i.kernel.transferred: // The native function has already moved the control elsewhere
+		i.kernel.transferred = false
+		return nil
+	}
+
+	i.RAM[SP] -= uint16(op.NArgs)
+	return i.push(value)
+}
+
+// Specialized function to execute a 'vm.ReturnOp', moves the return value in place of the first
+// argument, restores the caller frame and transfers the control back to the return address.
+func (i *Interpreter) ExecReturnOp(op ReturnOp) error {
+	frame := i.RAM[LCL]
+	saved := make([]uint16, 5) // The caller frame: 'THAT', 'THIS', 'ARG', 'LCL' and return address
+	for offset := range saved {
+		value, err := i.read(frame - uint16(offset) - 1)
+		if err != nil {
+			return err
+		}
+		saved[offset] = value
+	}
+
+	value, err := i.pop()
+	if err != nil {
+		return err
+	}
+	if err := i.write(i.RAM[ARG], value); err != nil {
+		return err
+	}
+
+	i.RAM[SP] = i.RAM[ARG] + 1
+	i.RAM[THAT], i.RAM[THIS], i.RAM[ARG], i.RAM[LCL] = saved[0], saved[1], saved[2], saved[3]
+	i.PC = saved[4]
+	return nil
+}
+
+// Executes the native function 'name', a call with a number of arguments different from the one
+// declared in 'StandardLibraryArity' is reported as an error (instead of reaching the builtin).
+func (i *Interpreter) native(name string, builtin Builtin, args []uint16) (uint16, error) {
+	class, function, _ := strings.Cut(name, ".")
+	if arity := StandardLibraryArity[class][function]; len(args) != arity {
+		return 0, fmt.Errorf("invalid call with %d arguments, expected %d", len(args), arity)
+	}
+
+	return builtin(i, args)
+}
+
+// Pushes the caller frame on the stack (with 'ret' as return address) and jumps to the function
+// 'name', the 'nArgs' arguments are expected to be already on the stack.
+func (i *Interpreter) call(name string, nArgs uint16, ret uint16) error {
+	address, found := i.labels[name]
+	if !found {
+		return fmt.Errorf("undefined function '%s'", name)
+	}
+
+	for _, value := range []uint16{ret, i.RAM[LCL], i.RAM[ARG], i.RAM[THIS], i.RAM[THAT]} {
+		if err := i.push(value); err != nil {
+			return err
+		}
+	}
+
+	i.RAM[ARG] = i.RAM[SP] - 5 - nArgs
+	i.RAM[LCL] = i.RAM[SP]
+	i.PC = address
+	return nil
+}
+
+// Resolves the RAM location referenced by 'offset' in the given 'segment'.
+func (i *Interpreter) address(segment SegmentType, offset uint16, module string) (uint16, error) {
+	switch segment {
+	case Local:
+		return i.RAM[LCL] + offset, nil
+	case Argument:
+		return i.RAM[ARG] + offset, nil
+	case This:
+		return i.RAM[THIS] + offset, nil
+	case That:
+		return i.RAM[THAT] + offset, nil
+
+	case Pointer:
+		if offset > 1 {
+			return 0, fmt.Errorf("offset %d out of bound for 'pointer' segment (max 1)", offset)
+		}
+		return THIS + offset, nil
+
+	case Temp:
+		if offset > 7 {
+			return 0, fmt.Errorf("offset %d out of bound for 'temp' segment (max 7)", offset)
+		}
+		return TEMP + offset, nil
+
+	case Static:
+		address, found := i.statics[fmt.Sprintf("%s.%d", module, offset)]
+		if !found {
+			return 0, fmt.Errorf("static '%d' not allocated for module '%s'", offset, module)
+		}
+		return address, nil
+	}
+
+	return 0, fmt.Errorf("unrecognized segment '%s'", segment)
+}
+
+// Reads the word at the given RAM location.
+func (i *Interpreter) read(address uint16) (uint16, error) {
+	if address >= RAMSize {
+		return 0, fmt.Errorf("address %d out of bound for RAM (max %d)", address, RAMSize-1)
+	}
+	return i.RAM[address], nil
+}
+
+// Writes the word at the given RAM location.
+func (i *Interpreter) write(address, value uint16) error {
+	if address >= RAMSize {
+		return fmt.Errorf("address %d out of bound for RAM (max %d)", address, RAMSize-1)
+	}
+	i.RAM[address] = value
+	return nil
+}
+
+// Pushes 'value' on top of the stack.
+func (i *Interpreter) push(value uint16) error {
+	if err := i.write(i.RAM[SP], value); err != nil {
+		return fmt.Errorf("stack overflow: %w", err)
+	}
+	i.RAM[SP]++
+	return nil
+}
+
+// Pops the value on top of the stack.
+func (i *Interpreter) pop() (uint16, error) {
+	i.RAM[SP]--
+	value, err := i.read(i.RAM[SP])
+	if err != nil {
+		return 0, fmt.Errorf("stack underflow: %w", err)
+	}
+	return value, nil
+}
+
+// Converts a boolean to its VM representation (-1 for 'true', 0 for 'false').
+func boolean(b bool) uint16 {
+	if b {
+		return 0xFFFF
+	}
+	return 0
+}
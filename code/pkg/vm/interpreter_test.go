@@ -0,0 +1,188 @@
+package vm_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"its-hmny.dev/nand2tetris/pkg/asm"
+	"its-hmny.dev/nand2tetris/pkg/emulator"
+	"its-hmny.dev/nand2tetris/pkg/tst"
+	"its-hmny.dev/nand2tetris/pkg/vm"
+)
+
+func TestInterpreter(t *testing.T) {
+	test := func(ops vm.Module, expected []uint16, fail bool) {
+		interpreter, err := vm.NewInterpreter(vm.Program{"Test.vm": ops})
+		if err != nil {
+			t.Fatalf("Unexpected linking error: %s", err)
+		}
+		interpreter.RAM[vm.SP] = 256
+
+		err = interpreter.Run(len(ops))
+		if fail && err == nil {
+			t.Fatalf("Expected error, got nil")
+		}
+		if !fail && err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		for i, value := range expected { // Compares the stack content from its base
+			if interpreter.RAM[256+i] != value {
+				t.Fatalf("Mismatch at stack[%d]: expected %d got %d", i, value, interpreter.RAM[256+i])
+			}
+		}
+	}
+
+	t.Run("Arithmetic", func(t *testing.T) {
+		push := func(n uint16) vm.Operation { return vm.MemoryOp{Operation: vm.Push, Segment: vm.Constant, Offset: n} }
+		test(vm.Module{push(7), push(8), vm.ArithmeticOp{Operation: vm.Add}}, []uint16{15}, false)
+		test(vm.Module{push(7), push(8), vm.ArithmeticOp{Operation: vm.Sub}}, []uint16{0xFFFF}, false)
+		test(vm.Module{push(7), push(8), vm.ArithmeticOp{Operation: vm.Lt}}, []uint16{0xFFFF}, false)
+		test(vm.Module{push(7), vm.ArithmeticOp{Operation: vm.Neg}, push(8), vm.ArithmeticOp{Operation: vm.Gt}}, []uint16{0}, false)
+		test(vm.Module{push(7), push(7), vm.ArithmeticOp{Operation: vm.Eq}, vm.ArithmeticOp{Operation: vm.Not}}, []uint16{0}, false)
+	})
+
+	t.Run("Memory", func(t *testing.T) {
+		test(vm.Module{
+			vm.MemoryOp{Operation: vm.Push, Segment: vm.Constant, Offset: 3000},
+			vm.MemoryOp{Operation: vm.Pop, Segment: vm.Pointer, Offset: 0},
+			vm.MemoryOp{Operation: vm.Push, Segment: vm.Constant, Offset: 42},
+			vm.MemoryOp{Operation: vm.Pop, Segment: vm.This, Offset: 2},
+			vm.MemoryOp{Operation: vm.Push, Segment: vm.This, Offset: 2},
+			vm.MemoryOp{Operation: vm.Pop, Segment: vm.Static, Offset: 4},
+			vm.MemoryOp{Operation: vm.Push, Segment: vm.Static, Offset: 4},
+			vm.MemoryOp{Operation: vm.Push, Segment: vm.Pointer, Offset: 0},
+		}, []uint16{42, 3000}, false)
+	})
+
+	t.Run("Invalid operations", func(t *testing.T) {
+		test(vm.Module{vm.MemoryOp{Operation: vm.Pop, Segment: vm.Constant, Offset: 1}}, nil, true)
+		test(vm.Module{vm.MemoryOp{Operation: vm.Push, Segment: vm.Temp, Offset: 8}}, nil, true)
+		test(vm.Module{vm.GotoOp{Label: "MISSING", Jump: vm.Unconditional}}, nil, true)
+		test(vm.Module{vm.FuncCallOp{Name: "Missing.function", NArgs: 0}}, nil, true)
+	})
+}
+
+// Runs the test scripts written for the official VM Emulator against the 'vm.Interpreter'.
+func TestVMEmulatorScripts(t *testing.T) {
+	scripts, _ := filepath.Glob("../../../projects/0[78] - */*/*VME.tst")
+	if len(scripts) == 0 {
+		t.Fatalf("No test script found")
+	}
+
+	for _, script := range scripts {
+		t.Run(filepath.Base(script), func(t *testing.T) {
+			file, err := os.Open(script)
+			if err != nil {
+				t.Fatalf("Error opening the '%s' test file: %s", script, err)
+			}
+			defer file.Close()
+
+			parser := tst.NewParser(file)
+			commands, err := parser.Parse()
+			if err != nil {
+				t.Fatalf("Error parsing the '%s' test file: %s", script, err)
+			}
+
+			interpreter := vm.Interpreter{}
+			runner := tst.NewRunner(commands, &interpreter, filepath.Dir(script))
+			if err := runner.Run(); err != nil {
+				t.Fatalf("Error while running the '%s' test file: %s", script, err)
+			}
+		})
+	}
+}
+
+// Cross-checks the 'vm.Lowerer' against the 'vm.Interpreter': the same program is both interpreted
+// and translated to run on the Hack CPU emulator, at the end the two RAM(s) have to match.
+func TestLoweringCrossCheck(t *testing.T) {
+	crossCheck := func(dir string, bootstrap bool, newLowerer func(vm.Program) vm.Lowerer) {
+		interpreter := vm.Interpreter{}
+		if err := interpreter.Load("", dir); err != nil {
+			t.Fatalf("Error loading the '%s' program: %s", dir, err)
+		}
+
+		program := vm.Program{}
+		files, _ := filepath.Glob(filepath.Join(dir, "*.vm"))
+		for _, file := range files {
+			content, _ := os.Open(file)
+			defer content.Close()
+
+			parser := vm.NewParser(content)
+			module, err := parser.Parse()
+			if err != nil {
+				t.Fatalf("Error parsing the '%s' module: %s", file, err)
+			}
+			program[filepath.Base(file)] = module
+		}
+
+		lowerer := newLowerer(program)
+		asmProgram, err := lowerer.Lowerer()
+		if err != nil {
+			t.Fatalf("Error lowering the '%s' program: %s", dir, err)
+		}
+		if bootstrap { // Same bootstrap code included by the 'vm_translator'
+			asmProgram = append([]asm.Instruction{
+				asm.AInstruction{Location: "261"},
+				asm.CInstruction{Dest: "D", Comp: "A"},
+				asm.AInstruction{Location: "SP"},
+				asm.CInstruction{Dest: "M", Comp: "D"},
+				asm.AInstruction{Location: "Sys.init"},
+				asm.CInstruction{Comp: "0", Jump: "JMP"},
+			}, asmProgram...)
+		}
+		asmLowerer := asm.NewLowerer(asmProgram)
+		hackProgram, table, err := asmLowerer.Lower()
+		if err != nil {
+			t.Fatalf("Error lowering the '%s' program: %s", dir, err)
+		}
+
+		cpu := emulator.NewCPU()
+		if err := cpu.LoadProgram(hackProgram, table); err != nil {
+			t.Fatalf("Error loading the '%s' program: %s", dir, err)
+		}
+
+		// Both are initialized with the same segments (and arguments) for the programs without 'Sys.init'
+		for address, value := range []uint16{256, 300, 400, 3000, 3010} {
+			cpu.RAM[address], interpreter.RAM[address] = value, value
+		}
+		for address, value := range []uint16{6, 3000} {
+			cpu.RAM[400+address], interpreter.RAM[400+address] = value, value
+		}
+		if bootstrap {
+			interpreter.Bootstrap("Sys.init")
+		}
+
+		if err := interpreter.CrossCheck(&cpu, len(hackProgram)); err != nil {
+			t.Fatalf("Error cross-checking the '%s' program: %s", dir, err)
+		}
+	}
+
+	test := func(dir string, bootstrap bool) { crossCheck(dir, bootstrap, vm.NewLowerer) }
+
+	t.Run("SimpleAdd", func(t *testing.T) { test("../../../projects/07 - VM I: Stack Arithmetic/01 - SimpleAdd", false) })
+	t.Run("StackTest", func(t *testing.T) { test("../../../projects/07 - VM I: Stack Arithmetic/02 - StackTest", false) })
+	t.Run("BasicTest", func(t *testing.T) { test("../../../projects/07 - VM I: Stack Arithmetic/03 - BasicTest", false) })
+	t.Run("PointerTest", func(t *testing.T) { test("../../../projects/07 - VM I: Stack Arithmetic/04 - PointerTest", false) })
+	t.Run("StaticTest", func(t *testing.T) { test("../../../projects/07 - VM I: Stack Arithmetic/05 - StaticTest", false) })
+	t.Run("BasicLoop", func(t *testing.T) { test("../../../projects/08 - VM II: Program Flow/01 - BasicLoop", false) })
+	t.Run("FibonacciSeries", func(t *testing.T) { test("../../../projects/08 - VM II: Program Flow/02 - FibonacciSeries", false) })
+	t.Run("NestedCall", func(t *testing.T) { test("../../../projects/08 - VM II: Program Flow/04 - NestedCall", true) })
+	t.Run("FibonacciElement", func(t *testing.T) { test("../../../projects/08 - VM II: Program Flow/05 - FibonacciElement", true) })
+	t.Run("StaticsTest", func(t *testing.T) { test("../../../projects/08 - VM II: Program Flow/06 - StaticsTest", true) })
+
+	// The routines shared in compact mode have to behave exactly like the inlined code
+	t.Run("StackTest (compact)", func(t *testing.T) {
+		crossCheck("../../../projects/07 - VM I: Stack Arithmetic/02 - StackTest", false, vm.NewCompactLowerer)
+	})
+	t.Run("NestedCall (compact)", func(t *testing.T) {
+		crossCheck("../../../projects/08 - VM II: Program Flow/04 - NestedCall", true, vm.NewCompactLowerer)
+	})
+	t.Run("FibonacciElement (compact)", func(t *testing.T) {
+		crossCheck("../../../projects/08 - VM II: Program Flow/05 - FibonacciElement", true, vm.NewCompactLowerer)
+	})
+	t.Run("StaticsTest (compact)", func(t *testing.T) {
+		crossCheck("../../../projects/08 - VM II: Program Flow/06 - StaticsTest", true, vm.NewCompactLowerer)
+	})
+}
@@ -2,6 +2,8 @@ package vm
 
 import (
 	"fmt"
+	"regexp"
+	"sort"
 
 	"its-hmny.dev/nand2tetris/pkg/asm"
 )
@@ -414,25 +416,55 @@ type Lowerer struct {
 	vmScope string
 
 	nRandomizer uint // Counter to randomize 'asm.LabelDecl(s)' with same name
+
+	// In compact mode calls, returns and comparisons jump to a routine shared by the whole program
+	// instead of being inlined, keeps track of the routines used so that only those are emitted
+	compact  bool
+	routines map[string]bool
 }
 
+// The shared routines used in compact mode for the comparison operations (see 'NewCompactLowerer').
+var ComparisonRoutines = map[ArithOpType]string{Eq: "__EQ", Gt: "__GT", Lt: "__LT"}
+
+// The return addresses follow the standard 'Function$ret.n' naming scheme, that only uses characters
+// valid in an asm symbol, so the user labels with the same shape are reserved to avoid any clash.
+var returnLabelRegex = regexp.MustCompile(`^ret\.[0-9]+$`)
+
 // Initializes and returns to the caller a brand new 'Lowerer' struct.
 // Requires the argument Program to be not nil nor empty.
 func NewLowerer(p Program) Lowerer {
 	return Lowerer{program: p, vmScope: "global"}
 }
 
+// Initializes and returns to the caller a brand new 'Lowerer' struct in compact mode.
+// Each function call, return and comparison only loads its operands in the registers and jumps
+// to a routine ('__CALL', '__RETURN', '__EQ', '__GT' or '__LT') emitted once for the whole program,
+// the result is a way smaller program (at the expense of some clock cycles for the extra jumps).
+func NewCompactLowerer(p Program) Lowerer {
+	return Lowerer{program: p, vmScope: "global", compact: true}
+}
+
 // Triggers the lowering process. It iterates operation by operation and recursively calls
 // the specified helper function based on the operation type (much like a recursive
 // descend parser but for lowering), this means the AST is visited in DFS order.
 func (l *Lowerer) Lowerer() (asm.Program, error) {
 	program := []asm.Instruction{}
+	l.routines = map[string]bool{}
 
 	if l.program == nil || len(l.program) == 0 {
 		return nil, fmt.Errorf("the given 'program' is empty")
 	}
 
-	for name, module := range l.program {
+	// Modules are lowered in alphabetical order, this way the output is deterministic and the
+	// statics get allocated at the same locations across runs (and by the 'vm.Interpreter')
+	names := make([]string, 0, len(l.program))
+	for name := range l.program {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		module := l.program[name]
 		l.vmModule = name // Updates the tracker, signaling we're lowering another module
 
 		for _, op := range module {
@@ -493,6 +525,10 @@ func (l *Lowerer) Lowerer() (asm.Program, error) {
 		}
 	}
 
+	if len(l.routines) > 0 { // The shared routines are placed (and skipped over) at the beginning
+		program = append(l.HandleRoutines(), program...)
+	}
+
 	return program, nil
 }
 
@@ -504,13 +540,13 @@ func (l *Lowerer) HandleMemoryOp(op MemoryOp) ([]asm.Instruction, error) {
 	case Pop:
 		// Can't pop data onto the 'Constant' segment (is readonly of course)
 		if op.Segment == Constant {
-			return nil, fmt.Errorf("cannot push on read-only segment 'constant'")
+			return nil, op.Position.Errorf("cannot push on read-only segment 'constant'")
 		}
 
 		// Retrieves the specific lowerer implementation based on the op.Segment
 		generator, found := PopTable[op.Segment]
 		if !found {
-			return nil, fmt.Errorf("cannot find entry '%s' in lowering table", op.Segment)
+			return nil, op.Position.Errorf("cannot find entry '%s' in lowering table", op.Segment)
 		}
 
 		// This is the set of operations that is common to every pop on the stack.
@@ -530,7 +566,7 @@ func (l *Lowerer) HandleMemoryOp(op MemoryOp) ([]asm.Instruction, error) {
 		// Retrieves the specific lowerer implementation based on the op.Segment
 		generator, found := PushTable[op.Segment]
 		if !found {
-			return nil, fmt.Errorf("cannot find entry '%s' in lowering table", op.Segment)
+			return nil, op.Position.Errorf("cannot find entry '%s' in lowering table", op.Segment)
 		}
 
 		// This is the set of operations that is common to every push on the stack.
@@ -550,7 +586,7 @@ func (l *Lowerer) HandleMemoryOp(op MemoryOp) ([]asm.Instruction, error) {
 		), nil
 
 	default:
-		return nil, fmt.Errorf("unrecognized MemoryOp instruction %s", op.Operation)
+		return nil, op.Position.Errorf("unrecognized MemoryOp instruction %s", op.Operation)
 	}
 }
 
@@ -588,7 +624,23 @@ func (l *Lowerer) HandleArithmeticOp(op ArithmeticOp) ([]asm.Instruction, error)
 	// Retrieves the specific lowerer implementation based on the op.Operation
 	generator, found := ArithmeticTable[op.Operation]
 	if !found {
-		return nil, fmt.Errorf("could not map %s to Asm instructions", op.Operation)
+		return nil, op.Position.Errorf("could not map %s to Asm instructions", op.Operation)
+	}
+
+	// In compact mode the comparisons jump to the shared routine, that saves the result on R15 as well
+	if routine, found := ComparisonRoutines[op.Operation]; found && l.compact {
+		ret := l.returnLabel()
+		generator = func(uint) []asm.Instruction {
+			return []asm.Instruction{
+				// Passes the return address in the D reg and jumps to the routine
+				asm.AInstruction{Location: ret},
+				asm.CInstruction{Dest: "D", Comp: "A"},
+				asm.AInstruction{Location: routine},
+				asm.CInstruction{Comp: "0", Jump: "JMP"},
+				asm.LabelDecl{Name: ret},
+			}
+		}
+		l.routines[routine] = true
 	}
 
 	// The 'postlude' section takes the value in R15 and push it onto the Stack
@@ -615,10 +667,13 @@ func (l *Lowerer) HandleArithmeticOp(op ArithmeticOp) ([]asm.Instruction, error)
 // during the lowering to the asm counterpart by prepending the label with the scope name.
 func (l *Lowerer) HandleLabelDecl(op LabelDecl) ([]asm.Instruction, error) {
 	if op.Name == "" { // Invariant: the label name should always be provided
-		return nil, fmt.Errorf("unexpected empty label value")
+		return nil, op.Position.Errorf("unexpected empty label value")
+	}
+	if returnLabelRegex.MatchString(op.Name) { // Would clash with the return addresses of the calls
+		return nil, op.Position.Errorf("label '%s' is reserved for the return addresses", op.Name)
 	}
 	if l.vmScope == "" { // Invariant: the scope name should always be provided
-		return nil, fmt.Errorf("unexpected empty 'vmScope' value")
+		return nil, op.Position.Errorf("unexpected empty 'vmScope' value")
 	}
 
 	// The vm.LabelDecl is scoped to either the function or the global scope, by appending the name
@@ -632,10 +687,13 @@ func (l *Lowerer) HandleLabelDecl(op LabelDecl) ([]asm.Instruction, error) {
 // during the lowering to the asm counterpart by prepending the label with the scope name.
 func (l *Lowerer) HandleGotoOp(op GotoOp) ([]asm.Instruction, error) {
 	if op.Label == "" { // Invariant: the label name should always be provided
-		return nil, fmt.Errorf("unexpected empty label value")
+		return nil, op.Position.Errorf("unexpected empty label value")
+	}
+	if returnLabelRegex.MatchString(op.Label) { // Would jump to the return address of a call
+		return nil, op.Position.Errorf("label '%s' is reserved for the return addresses", op.Label)
 	}
 	if l.vmScope == "" { // Invariant: the scope name should always be provided
-		return nil, fmt.Errorf("unexpected empty 'vmScope' value")
+		return nil, op.Position.Errorf("unexpected empty 'vmScope' value")
 	}
 
 	if op.Jump == Conditional {
@@ -660,7 +718,7 @@ func (l *Lowerer) HandleGotoOp(op GotoOp) ([]asm.Instruction, error) {
 		}, nil
 	}
 
-	return nil, fmt.Errorf("unrecognized jump type, got %s", op.Jump)
+	return nil, op.Position.Errorf("unrecognized jump type, got %s", op.Jump)
 }
 
 // Specialized function to convert a 'vm.FuncDecl' node to a list of 'asm.Instruction'.
@@ -669,7 +727,7 @@ func (l *Lowerer) HandleGotoOp(op GotoOp) ([]asm.Instruction, error) {
 // local variables has to be predefined in the function declaration itself 'op.NLocals').
 func (l *Lowerer) HandleFuncDecl(op FuncDecl) ([]asm.Instruction, error) {
 	if op.Name == "" {
-		return nil, fmt.Errorf("unexpected empty function name value")
+		return nil, op.Position.Errorf("unexpected empty function name value")
 	}
 
 	// First, allocates the label for the function entrypoint
@@ -698,6 +756,19 @@ func (l *Lowerer) HandleFuncDecl(op FuncDecl) ([]asm.Instruction, error) {
 // When returning from a function call we have to restore all memory segments pointer
 // back to the one used by the caller, while also pushing the return value on the stack.
 func (l *Lowerer) HandleReturnOp(op ReturnOp) ([]asm.Instruction, error) {
+	if l.compact { // The whole sequence is shared, there's nothing to pass to it
+		l.routines["__RETURN"] = true
+		return []asm.Instruction{
+			asm.AInstruction{Location: "__RETURN"},
+			asm.CInstruction{Comp: "0", Jump: "JMP"},
+		}, nil
+	}
+
+	return returnSequence(), nil
+}
+
+// Returns the instructions that give the control back to the caller, see 'HandleReturnOp'.
+func returnSequence() []asm.Instruction {
 	translated := []asm.Instruction{
 		// We save the base frame pointer on R13 (the beginning of the call frame)
 		asm.AInstruction{Location: "LCL"},
@@ -749,14 +820,37 @@ func (l *Lowerer) HandleReturnOp(op ReturnOp) ([]asm.Instruction, error) {
 		asm.CInstruction{Comp: "0", Jump: "JMP"},
 	)
 
-	return translated, nil
+	return translated
 }
 
+// Specialized function to convert a 'vm.FuncCallOp' node to a list of 'asm.Instruction'.
+// Saves the caller frame on the stack, repositions the 'argument' and 'local' segments for
+// the callee and then jumps to it, the return address is declared right after the jump.
 func (l *Lowerer) HandleFuncCallOp(op FuncCallOp) ([]asm.Instruction, error) {
 	l.nRandomizer++
+	if l.compact { // Passes the return address in R13, the nArgs in R14 and the callee in the D reg
+		l.routines["__CALL"] = true
+		return []asm.Instruction{
+			asm.AInstruction{Location: l.returnLabel()},
+			asm.CInstruction{Dest: "D", Comp: "A"},
+			asm.AInstruction{Location: "R13"},
+			asm.CInstruction{Dest: "M", Comp: "D"},
+			asm.AInstruction{Location: fmt.Sprint(op.NArgs)},
+			asm.CInstruction{Dest: "D", Comp: "A"},
+			asm.AInstruction{Location: "R14"},
+			asm.CInstruction{Dest: "M", Comp: "D"},
+			asm.AInstruction{Location: op.Name},
+			asm.CInstruction{Dest: "D", Comp: "A"},
+			asm.AInstruction{Location: "__CALL"},
+			asm.CInstruction{Comp: "0", Jump: "JMP"},
+			// Declare a label that will reference the caller's return address
+			asm.LabelDecl{Name: l.returnLabel()},
+		}, nil
+	}
+
 	return []asm.Instruction{
 		// Takes the return address for the caller and push it on the stack
-		asm.AInstruction{Location: fmt.Sprintf("%s-ret-%d", l.vmScope, l.nRandomizer)},
+		asm.AInstruction{Location: l.returnLabel()},
 		asm.CInstruction{Dest: "D", Comp: "A"},
 		asm.AInstruction{Location: "SP"},
 		asm.CInstruction{Dest: "A", Comp: "M"},
@@ -813,6 +907,99 @@ func (l *Lowerer) HandleFuncCallOp(op FuncCallOp) ([]asm.Instruction, error) {
 		asm.AInstruction{Location: op.Name},
 		asm.CInstruction{Comp: "0", Jump: "JMP"},
 		// Declare a label that will reference the caller's return address
-		asm.LabelDecl{Name: fmt.Sprintf("%s-ret-%d", l.vmScope, l.nRandomizer)},
+		asm.LabelDecl{Name: l.returnLabel()},
 	}, nil
 }
+
+// Generates the routines shared by the whole program in compact mode (only the ones used), they're
+// placed at the beginning of the program preceded by a jump over them, so that the execution still
+// starts from the first instruction of the program. Each one gets the return address from the caller:
+//   - '__CALL': Takes the return address in R13, the nArgs in R14 and the callee entrypoint in the D reg
+//   - '__RETURN': Takes the return address from the frame of the callee, like the inlined return does
+//   - '__EQ', '__GT' and '__LT': Take the return address in the D reg and the operands in R13 and R14
+func (l *Lowerer) HandleRoutines() []asm.Instruction {
+	routines := []asm.Instruction{
+		asm.AInstruction{Location: "__START"},
+		asm.CInstruction{Comp: "0", Jump: "JMP"},
+	}
+
+	if l.routines["__CALL"] {
+		routines = append(routines,
+			asm.LabelDecl{Name: "__CALL"},
+			// Saves the callee entrypoint on R15, to jump there at the end
+			asm.AInstruction{Location: "R15"},
+			asm.CInstruction{Dest: "M", Comp: "D"},
+			// Takes the return address for the caller and push it on the stack
+			asm.AInstruction{Location: "R13"},
+			asm.CInstruction{Dest: "D", Comp: "M"},
+			asm.AInstruction{Location: "SP"},
+			asm.CInstruction{Dest: "A", Comp: "M"},
+			asm.CInstruction{Dest: "M", Comp: "D"},
+			asm.AInstruction{Location: "SP"},
+			asm.CInstruction{Dest: "M", Comp: "M+1"},
+		)
+		// Takes the current segment pointers for the caller and push them on the stack
+		for _, segment := range []string{"LCL", "ARG", "THIS", "THAT"} {
+			routines = append(routines,
+				asm.AInstruction{Location: segment},
+				asm.CInstruction{Dest: "D", Comp: "M"},
+				asm.AInstruction{Location: "SP"},
+				asm.CInstruction{Dest: "A", Comp: "M"},
+				asm.CInstruction{Dest: "M", Comp: "D"},
+				asm.AInstruction{Location: "SP"},
+				asm.CInstruction{Dest: "M", Comp: "M+1"},
+			)
+		}
+		routines = append(routines,
+			// Sets the callee function 'argument' segment pointer to its location
+			asm.AInstruction{Location: "SP"},
+			asm.CInstruction{Dest: "D", Comp: "M"},
+			asm.AInstruction{Location: "5"},
+			asm.CInstruction{Dest: "D", Comp: "D-A"},
+			asm.AInstruction{Location: "R14"},
+			asm.CInstruction{Dest: "D", Comp: "D-M"},
+			asm.AInstruction{Location: "ARG"},
+			asm.CInstruction{Dest: "M", Comp: "D"},
+			// Sets the callee function 'local' segment pointer to its location
+			asm.AInstruction{Location: "SP"},
+			asm.CInstruction{Dest: "D", Comp: "M"},
+			asm.AInstruction{Location: "LCL"},
+			asm.CInstruction{Dest: "M", Comp: "D"},
+			// Transfer the execution control to the callee function with a jump to its entrypoint
+			asm.AInstruction{Location: "R15"},
+			asm.CInstruction{Dest: "A", Comp: "M"},
+			asm.CInstruction{Comp: "0", Jump: "JMP"},
+		)
+	}
+
+	if l.routines["__RETURN"] {
+		routines = append(append(routines, asm.LabelDecl{Name: "__RETURN"}), returnSequence()...)
+	}
+
+	// The return address is saved on the stack (the operands have been popped already) since
+	// the comparison makes use of all the internal registers, then it's popped back at the end
+	for _, operation := range []ArithOpType{Eq, Gt, Lt} {
+		if routine := ComparisonRoutines[operation]; l.routines[routine] {
+			l.nRandomizer++
+			routines = append(append(append(routines,
+				asm.LabelDecl{Name: routine},
+				asm.AInstruction{Location: "SP"},
+				asm.CInstruction{Dest: "M", Comp: "M+1"},
+				asm.CInstruction{Dest: "A", Comp: "M-1"},
+				asm.CInstruction{Dest: "M", Comp: "D"},
+			), ArithmeticTable[operation](l.nRandomizer)...),
+				asm.AInstruction{Location: "SP"},
+				asm.CInstruction{Dest: "AM", Comp: "M-1"},
+				asm.CInstruction{Dest: "A", Comp: "M"},
+				asm.CInstruction{Comp: "0", Jump: "JMP"},
+			)
+		}
+	}
+
+	return append(routines, asm.LabelDecl{Name: "__START"})
+}
+
+// Returns the label of the return address for the current call (or comparison in compact mode).
+func (l *Lowerer) returnLabel() string {
+	return fmt.Sprintf("%s$ret.%d", l.vmScope, l.nRandomizer)
+}
@@ -1,6 +1,7 @@
 package vm
 
 import (
+	"bytes"
 	"fmt"
 	"io"
 	"log"
@@ -8,6 +9,7 @@ import (
 	"strconv"
 
 	pc "github.com/prataprc/goparsec"
+	"its-hmny.dev/nand2tetris/pkg/utils"
 )
 
 // ----------------------------------------------------------------------------
@@ -22,6 +24,9 @@ import (
 // Top level object, will generate the traversable AST based on the input plus the PCs below.
 var ast = pc.NewAST("virtual_machine", 0)
 
+// Records the furthest failure of the PCs below, used to report syntax errors to the user.
+var syntax = utils.NewSyntaxTracker()
+
 var (
 	// Parser combinator for a VM module/class, in the nand2tetris VM there's a Java like
 	// behavior where a program is composed of multiple '.vm' file ('.class' in Java) where
@@ -39,51 +44,53 @@ var (
 	)
 
 	// Memory operation, compliant with the following syntax: "{push|pop} {segment} {index}"
-	pMemoryOp = ast.And("memory_op", nil, pMemOpType, pSegment, pc.Int())
+	pMemoryOp = ast.And("memory_op", nil, pMemOpType, pSegment, pInt)
 	// Arithmetic operation, could either be binary or unary (modifies only the Stack Pointer)
 	pArithmeticOp = ast.And("arithmetic_op", nil, pArithOpType)
 
 	// Label declaration, compliant with the following syntax: "label {symbol}"s
-	pLabelDecl = ast.And("label_decl", nil, pc.Atom("label", "LABEL"), pIdent)
+	pLabelDecl = ast.And("label_decl", nil, syntax.Atom("label", "LABEL"), pIdent)
 	// Jump operation, compliant with the following syntax: "{if-goto|goto} {symbol}"
 	pGotoOp = ast.And("goto_op", nil, pJumpType, pIdent)
 
 	// Function declaration, compliant with the following syntax: "function {name} {n_args}"
-	pFuncDecl = ast.And("func_decl", nil, pc.Atom("function", "FUNC"), pIdent, pc.Int())
+	pFuncDecl = ast.And("func_decl", nil, syntax.Atom("function", "FUNC"), pIdent, pInt)
 	// Function call operation, compliant with the following syntax: "call {name} {n_args}"
-	pFunCallOp = ast.And("func_call", nil, pc.Atom("call", "CALL"), pIdent, pc.Int())
+	pFunCallOp = ast.And("func_call", nil, syntax.Atom("call", "CALL"), pIdent, pInt)
 	// Return operation, compliant with the following syntax: "return"
-	pReturnOp = ast.And("return_op", nil, pc.Atom("return", "RETURN"))
+	pReturnOp = ast.And("return_op", nil, syntax.Atom("return", "RETURN"))
 )
 
 var (
 	// Generic Identifier parser (for label and function declaration)
 	// NOTE: An ident can be any sequence of letters, digits, and symbols (_, ., $, :).
 	// NOTE: An ident cannot begin with a leading digit (a symbol is indeed allowed).
-	pIdent = pc.Token(`[A-Za-z_.$:][0-9a-zA-Z_.$:]*`, "IDENT")
+	pIdent = syntax.Expect("identifier", pc.Token(`[A-Za-z_.$:][0-9a-zA-Z_.$:]*`, "IDENT"))
+	// Generic integer parser (for segment offsets and number of arguments/locals)
+	pInt = syntax.Expect("integer", pc.Int())
 
 	// Available memory operation type (only push and pop since it's stack based)
-	pMemOpType = ast.OrdChoice("mem_op_type", nil, pc.Atom("push", "PUSH"), pc.Atom("pop", "POP"))
+	pMemOpType = ast.OrdChoice("mem_op_type", nil, syntax.Atom("push", "PUSH"), syntax.Atom("pop", "POP"))
 	// Available heap segments (they act as registers and are used alongside the stack)
-	pSegment = ast.OrdChoice("mem_segment", nil,
+	pSegment = syntax.Expect("segment", ast.OrdChoice("mem_segment", nil,
 		pc.Atom("argument", "ARGUMENT"), pc.Atom("local", "LOCAL"),
 		pc.Atom("static", "STATIC"), pc.Atom("constant", "CONSTANT"),
 		pc.Atom("this", "THIS"), pc.Atom("that", "THAT"),
 		pc.Atom("temp", "TEMP"), pc.Atom("pointer", "POINTER"),
-	)
+	))
 
 	// Available arithmetic operation types (more functionality will be provided in the next phases)
-	pArithOpType = ast.OrdChoice("operations", nil,
+	pArithOpType = syntax.Expect("arithmetic operation", ast.OrdChoice("operations", nil,
 		// Comparison operations available on the VM bytecode
 		pc.Atom("eq", "EQ"), pc.Atom("gt", "GT"), pc.Atom("lt", "LT"),
 		// Arithmetic operations available on the VM bytecode
 		pc.Atom("add", "ADD"), pc.Atom("sub", "SUB"), pc.Atom("neg", "NEG"),
 		// Bit-a-bit operations available on the VM bytecode
 		pc.Atom("not", "NOT"), pc.Atom("and", "AND"), pc.Atom("or", "OR"),
-	)
+	))
 
 	// Jump types can either be conditional (if-goto) or unconditional (goto).
-	pJumpType = ast.OrdChoice("jump_type", nil, pc.Atom("goto", "GOTO"), pc.Atom("if-goto", "IF-GOTO"))
+	pJumpType = ast.OrdChoice("jump_type", nil, syntax.Atom("goto", "GOTO"), syntax.Atom("if-goto", "IF-GOTO"))
 )
 
 // ----------------------------------------------------------------------------
@@ -96,7 +103,11 @@ var (
 // - PARSEC_DEBUG: Verbose logging to inspect which of the PCs gets triggered and match
 // - EXPORT_AST:   Exports in the DEBUG_FOLDER a Graphviz representation of the AST
 // - PRINT_AST:    Print on the stdout a textual representation of the AST
-type Parser struct{ reader io.Reader }
+type Parser struct {
+	reader io.Reader        // The source of the module to be parsed
+	name   string           // The name of the source file, used in the position of the nodes
+	source utils.SourceFile // Maps the offsets of the nodes in the AST to their position
+}
 
 // Initializes and returns to the caller a brand new 'Parser' struct.
 // Requires the argument io.Reader 'r' to be valid and usable.
@@ -104,6 +115,12 @@ func NewParser(r io.Reader) Parser {
 	return Parser{reader: r}
 }
 
+// Initializes and returns to the caller a brand new 'Parser' struct, the operations parsed
+// will reference 'name' as their source file (e.g. 'Main.vm:3:1') in their 'Position'.
+func NewFileParser(r io.Reader, name string) Parser {
+	return Parser{reader: r, name: name}
+}
+
 // Parser entrypoint divides the 2 phases of the parsing pipeline
 // Text --> AST: This step is done using PCs and returns a generic traversable AST
 // AST --> IR: This step is done by traversing the AST and extracting the 'vm.Module'
@@ -113,9 +130,9 @@ func (p *Parser) Parse() (Module, error) {
 		return nil, fmt.Errorf("cannot read from 'io.Reader': %s", err)
 	}
 
-	ast, success := p.FromSource(content)
-	if !success {
-		return nil, fmt.Errorf("failed to parse AST from input content")
+	ast, err := p.FromSource(content)
+	if err != nil {
+		return nil, err
 	}
 
 	return p.FromAST(ast)
@@ -123,7 +140,8 @@ func (p *Parser) Parse() (Module, error) {
 
 // Scans the textual input stream coming from the 'reader' method and returns a traversable AST
 // (Abstract Syntax Tree) that can be eventually visited to extract/transform the info available.
-func (p *Parser) FromSource(source []byte) (pc.Queryable, bool) {
+// If the input is not consumed entirely a syntax error pointing to the offending token is returned.
+func (p *Parser) FromSource(source []byte) (pc.Queryable, error) {
 
 	// Feature flag: Enable 'goparsec' library's debug logs
 	if os.Getenv("PARSEC_DEBUG") != "" {
@@ -131,7 +149,9 @@ func (p *Parser) FromSource(source []byte) (pc.Queryable, bool) {
 	}
 
 	// We generate the traversable Abstract Syntax Tree from the source content
-	root, _ := ast.Parsewith(pModule, pc.NewScanner(source))
+	p.source = utils.NewSourceFile(p.name, source)
+	syntax.Reset()
+	root, scanner := ast.Parsewith(pModule, pc.NewScanner(source))
 
 	// Feature flag: Enables export of the AST as Dot file (debug.ast.fot)
 	if os.Getenv("EXPORT_AST") != "" {
@@ -146,7 +166,13 @@ func (p *Parser) FromSource(source []byte) (pc.Queryable, bool) {
 		ast.Prettyprint()
 	}
 
-	return root, true // Success is based on the reaching of 'EOF'
+	// Success is based on the reaching of 'EOF' (trailing whitespaces are allowed), an empty
+	// source has no AST at all since the 'module' node requires at least one child to be produced
+	if _, scanner = scanner.SkipWS(); !scanner.Endof() || (root == nil && len(bytes.TrimSpace(source)) > 0) {
+		return nil, syntax.Error(p.source, scanner)
+	}
+
+	return root, nil
 }
 
 // This function takes the root node of the raw parsed AST and does a DFS on it parsing
@@ -155,6 +181,9 @@ func (p *Parser) FromSource(source []byte) (pc.Queryable, bool) {
 func (p *Parser) FromAST(root pc.Queryable) (Module, error) {
 	module := []Operation{}
 
+	if root == nil { // Empty source file, see 'FromSource'
+		return Module{}, nil
+	}
 	if root.GetName() != "module" {
 		return nil, fmt.Errorf("expected node 'module', found %s", root.GetName())
 	}
@@ -222,7 +251,7 @@ func (p *Parser) FromAST(root pc.Queryable) (Module, error) {
 }
 
 // Specialized function to convert a "memory_op" node to a 'vm.MemoryOp'.
-func (Parser) HandleMemoryOp(node pc.Queryable) (Operation, error) {
+func (p Parser) HandleMemoryOp(node pc.Queryable) (Operation, error) {
 	if node.GetName() != "memory_op" {
 		return nil, fmt.Errorf("expected node 'memory_op', got %s", node.GetName())
 	}
@@ -234,14 +263,14 @@ func (Parser) HandleMemoryOp(node pc.Queryable) (Operation, error) {
 	segment := SegmentType(node.GetChildren()[1].GetValue())
 	offset, err := strconv.ParseUint(node.GetChildren()[2].GetValue(), 10, 16)
 	if err != nil {
-		log.Fatalf("failed to parse 'offset' in MemoryOp, got '%s'", node.GetChildren()[2].GetValue())
+		return nil, p.source.PositionOf(node.GetChildren()[2]).Errorf("failed to parse 'offset' in MemoryOp, got '%s'", node.GetChildren()[2].GetValue())
 	}
 
-	return MemoryOp{Operation: operation, Segment: segment, Offset: uint16(offset)}, nil
+	return MemoryOp{Operation: operation, Segment: segment, Offset: uint16(offset), Position: p.source.PositionOf(node)}, nil
 }
 
 // Specialized function to convert a "arithmetic_op" node to a 'vm.ArithmeticOp'.
-func (Parser) HandleArithmeticOp(node pc.Queryable) (Operation, error) {
+func (p Parser) HandleArithmeticOp(node pc.Queryable) (Operation, error) {
 	if node.GetName() != "arithmetic_op" {
 		log.Fatalf("expected node 'arithmetic_op', got %s ", node.GetName())
 	}
@@ -249,11 +278,11 @@ func (Parser) HandleArithmeticOp(node pc.Queryable) (Operation, error) {
 		log.Fatalf("expected node 'arithmetic_op' with 1 leaf, got %d", len(node.GetChildren()))
 	}
 
-	return ArithmeticOp{Operation: ArithOpType(node.GetChildren()[0].GetValue())}, nil
+	return ArithmeticOp{Operation: ArithOpType(node.GetChildren()[0].GetValue()), Position: p.source.PositionOf(node)}, nil
 }
 
 // Specialized function to convert a "label_decl" node to a 'vm.LabelDecl'.
-func (Parser) HandleLabelDecl(node pc.Queryable) (Operation, error) {
+func (p Parser) HandleLabelDecl(node pc.Queryable) (Operation, error) {
 	if node.GetName() != "label_decl" {
 		log.Fatalf("expected node 'label_decl', got %s ", node.GetName())
 	}
@@ -261,11 +290,11 @@ func (Parser) HandleLabelDecl(node pc.Queryable) (Operation, error) {
 		log.Fatalf("expected node 'label_decl' with 2 leaf, got %d", len(node.GetChildren()))
 	}
 
-	return LabelDecl{Name: node.GetChildren()[1].GetValue()}, nil
+	return LabelDecl{Name: node.GetChildren()[1].GetValue(), Position: p.source.PositionOf(node)}, nil
 }
 
 // Specialized function to convert a "goto_op" node to a 'vm.GotoOp'.
-func (Parser) HandleGotoOp(node pc.Queryable) (Operation, error) {
+func (p Parser) HandleGotoOp(node pc.Queryable) (Operation, error) {
 	if node.GetName() != "goto_op" {
 		log.Fatalf("expected node 'goto_op', got %s ", node.GetName())
 	}
@@ -276,11 +305,11 @@ func (Parser) HandleGotoOp(node pc.Queryable) (Operation, error) {
 	jump := JumpType(node.GetChildren()[0].GetValue())
 	label := node.GetChildren()[1].GetValue()
 
-	return GotoOp{Jump: jump, Label: label}, nil
+	return GotoOp{Jump: jump, Label: label, Position: p.source.PositionOf(node)}, nil
 }
 
 // Specialized function to convert a "func_decl" node to a 'vm.FuncDecl'.
-func (Parser) HandleFuncDecl(node pc.Queryable) (Operation, error) {
+func (p Parser) HandleFuncDecl(node pc.Queryable) (Operation, error) {
 	if node.GetName() != "func_decl" {
 		log.Fatalf("expected node 'func_decl', got %s ", node.GetName())
 	}
@@ -291,14 +320,14 @@ func (Parser) HandleFuncDecl(node pc.Queryable) (Operation, error) {
 	name := node.GetChildren()[1].GetValue()
 	args, err := strconv.ParseUint(node.GetChildren()[2].GetValue(), 10, 8)
 	if err != nil {
-		log.Fatalf("failed to parse 'args' in FuncDecl, got '%s'", node.GetChildren()[2].GetValue())
+		return nil, p.source.PositionOf(node.GetChildren()[2]).Errorf("failed to parse 'args' in FuncDecl, got '%s'", node.GetChildren()[2].GetValue())
 	}
 
-	return FuncDecl{Name: name, NLocal: uint8(args)}, nil
+	return FuncDecl{Name: name, NLocal: uint8(args), Position: p.source.PositionOf(node)}, nil
 }
 
 // Specialized function to convert a "return_op" node to a 'vm.ReturnOp'.
-func (Parser) HandleReturnOp(node pc.Queryable) (Operation, error) {
+func (p Parser) HandleReturnOp(node pc.Queryable) (Operation, error) {
 	if node.GetName() != "return_op" {
 		log.Fatalf("expected node 'return_op', got %s ", node.GetName())
 	}
@@ -306,11 +335,11 @@ func (Parser) HandleReturnOp(node pc.Queryable) (Operation, error) {
 		log.Fatalf("expected node 'return_op' with 1 leaf, got %d", len(node.GetChildren()))
 	}
 
-	return ReturnOp{}, nil
+	return ReturnOp{Position: p.source.PositionOf(node)}, nil
 }
 
 // Specialized function to convert a "func_call" node to a 'vm.FuncCallOp'.
-func (Parser) HandleFuncCall(node pc.Queryable) (Operation, error) {
+func (p Parser) HandleFuncCall(node pc.Queryable) (Operation, error) {
 	if node.GetName() != "func_call" {
 		log.Fatalf("expected node 'func_call', got %s ", node.GetName())
 	}
@@ -321,8 +350,8 @@ func (Parser) HandleFuncCall(node pc.Queryable) (Operation, error) {
 	name := node.GetChildren()[1].GetValue()
 	args, err := strconv.ParseUint(node.GetChildren()[2].GetValue(), 10, 8)
 	if err != nil {
-		log.Fatalf("failed to parse 'args' in FuncCallOp, got '%s'", node.GetChildren()[2].GetValue())
+		return nil, p.source.PositionOf(node.GetChildren()[2]).Errorf("failed to parse 'args' in FuncCallOp, got '%s'", node.GetChildren()[2].GetValue())
 	}
 
-	return FuncCallOp{Name: name, NArgs: uint8(args)}, nil
+	return FuncCallOp{Name: name, NArgs: uint8(args), Position: p.source.PositionOf(node)}, nil
 }
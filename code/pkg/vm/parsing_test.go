@@ -0,0 +1,42 @@
+package vm_test
+
+import (
+	"strings"
+	"testing"
+
+	"its-hmny.dev/nand2tetris/pkg/vm"
+)
+
+// Checks that a module that cannot be parsed entirely is rejected, pointing to the offending token.
+func TestSyntaxErrors(t *testing.T) {
+	test := func(source string, expected string) {
+		parser := vm.NewFileParser(strings.NewReader(source), "Test.vm")
+		_, err := parser.Parse()
+		if err == nil {
+			t.Fatalf("Expected syntax error, got nil")
+		}
+		if err.Error() != expected {
+			t.Fatalf("Expected error:\n%s\ngot:\n%s", expected, err)
+		}
+	}
+
+	t.Run("Invalid segment", func(t *testing.T) {
+		test("push constant 1\npush constnt 2\nadd", "Test.vm:2:6: unexpected 'constnt', expected segment\n    push constnt 2\n         ^")
+	})
+	t.Run("Missing argument", func(t *testing.T) {
+		test("call Foo.bar\nreturn", "Test.vm:2:1: unexpected 'return', expected integer\n    return\n    ^")
+	})
+	t.Run("Unknown operation", func(t *testing.T) {
+		test("lable LOOP", "Test.vm:1:1: unexpected 'lable', expected one of 'push', 'pop', arithmetic operation, "+
+			"'label', 'goto', 'if-goto', 'function', 'call', 'return'\n    lable LOOP\n    ^")
+	})
+}
+
+// Checks that empty (or blank) modules are accepted, they simply contain no operation.
+func TestEmptyModule(t *testing.T) {
+	parser := vm.NewFileParser(strings.NewReader(" \n\n"), "Test.vm")
+	module, err := parser.Parse()
+	if err != nil || len(module) != 0 {
+		t.Fatalf("Expected empty module, got %v (error: %v)", module, err)
+	}
+}
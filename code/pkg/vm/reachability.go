@@ -0,0 +1,116 @@
+package vm
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// ----------------------------------------------------------------------------
+// Dead function elimination
+
+// The Pruner takes a 'vm.Program' and removes the functions that can never be called.
+//
+// The program is seen as a call graph where each function declared is a node and each 'vm.FuncCallOp'
+// in its body is an edge, starting from the 'entrypoints' (e.g. 'Sys.init') the graph is visited and
+// each function not reached is unused. The VM language has no indirect calls, so this is exact.
+// The operations outside of any function (at the top of a module) are always kept, and the calls
+// found there are used as additional starting points of the visit.
+type Pruner struct {
+	program     Program
+	entrypoints []string
+}
+
+// Initializes and returns to the caller a brand new 'Pruner' struct.
+// Requires the argument Program to be not nil nor empty and at least one entrypoint.
+func NewPruner(p Program, entrypoints ...string) Pruner {
+	return Pruner{program: p, entrypoints: entrypoints}
+}
+
+// Returns the set of functions reachable from the entrypoints (the entrypoints included), an error
+// is returned if any entrypoint is not declared or if a function is declared more than once.
+func (p *Pruner) Reachable() (map[string]bool, error) {
+	if len(p.program) == 0 {
+		return nil, fmt.Errorf("the given 'program' is empty")
+	}
+
+	declared := map[string]FuncDecl{} // The functions declared in the program
+	calls := map[string][]string{}    // The functions called by each function ('' is the global scope)
+
+	for _, name := range p.modules() {
+		scope := "" // Same as the 'vm.Lowerer', the operations before the first declaration are global
+		for _, op := range p.program[name] {
+			switch tOp := op.(type) {
+			case FuncDecl:
+				if other, found := declared[tOp.Name]; found {
+					return nil, tOp.Position.Errorf("function '%s' already declared at %s", tOp.Name, other.Position)
+				}
+				declared[tOp.Name], scope = tOp, tOp.Name
+			case FuncCallOp:
+				calls[scope] = append(calls[scope], tOp.Name)
+			}
+		}
+	}
+
+	if len(p.entrypoints) == 0 {
+		return nil, fmt.Errorf("no entrypoint function provided")
+	}
+	for _, entrypoint := range p.entrypoints {
+		if _, found := declared[entrypoint]; !found {
+			return nil, fmt.Errorf("undefined entrypoint function '%s'", entrypoint)
+		}
+	}
+
+	// Visits the call graph (in DFS order), calls to undeclared functions are left to the later passes
+	reachable, stack := map[string]bool{}, append(slices.Clone(p.entrypoints), calls[""]...)
+	for len(stack) > 0 {
+		function := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		if _, found := declared[function]; !found || reachable[function] {
+			continue
+		}
+		reachable[function] = true
+		stack = append(stack, calls[function]...)
+	}
+
+	return reachable, nil
+}
+
+// Triggers the elimination process. Returns the program without the body of the unused functions
+// (the operations from their declaration up to the next one) and their declarations, sorted by name.
+func (p *Pruner) Prune() (Program, []FuncDecl, error) {
+	reachable, err := p.Reachable()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	pruned, removed := Program{}, []FuncDecl{}
+	for _, name := range p.modules() {
+		module, keep := Module{}, true
+		for _, op := range p.program[name] {
+			if decl, isDecl := op.(FuncDecl); isDecl {
+				if keep = reachable[decl.Name]; !keep {
+					removed = append(removed, decl)
+				}
+			}
+			if keep {
+				module = append(module, op)
+			}
+		}
+		pruned[name] = module
+	}
+
+	slices.SortFunc(removed, func(a, b FuncDecl) int { return strings.Compare(a.Name, b.Name) })
+	return pruned, removed, nil
+}
+
+// Returns the name of the modules in alphabetical order, the same used by the 'vm.Lowerer'.
+func (p *Pruner) modules() []string {
+	names := make([]string, 0, len(p.program))
+	for name := range p.program {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
@@ -0,0 +1,134 @@
+package vm_test
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"its-hmny.dev/nand2tetris/pkg/vm"
+)
+
+func TestPruner(t *testing.T) {
+	// Parses each module source and prunes the resulting program starting from 'entrypoint' (and 'others')
+	prune := func(entrypoint string, sources map[string]string, others ...string) (vm.Program, []vm.FuncDecl, error) {
+		program := vm.Program{}
+		for name, source := range sources {
+			parser := vm.NewParser(strings.NewReader(source))
+			module, err := parser.Parse()
+			if err != nil {
+				t.Fatalf("Unexpected parsing error: %v", err)
+			}
+			program[name] = module
+		}
+
+		pruner := vm.NewPruner(program, append([]string{entrypoint}, others...)...)
+		return pruner.Prune()
+	}
+
+	// Returns the name of the functions declared in the program, sorted
+	functions := func(program vm.Program) []string {
+		names := []string{}
+		for _, module := range program {
+			for _, op := range module {
+				if decl, isDecl := op.(vm.FuncDecl); isDecl {
+					names = append(names, decl.Name)
+				}
+			}
+		}
+		slices.Sort(names)
+		return names
+	}
+
+	sources := map[string]string{
+		"Sys.vm": `
+			function Sys.init 0
+			call Main.main 0
+			label END
+			goto END
+			function Sys.unused 0
+			call Main.helper 0
+			return`,
+		"Main.vm": `
+			function Main.main 0
+			call Main.helper 0
+			call Main.main 0
+			return
+			function Main.helper 0
+			push constant 1
+			return
+			function Main.dead 1
+			call Sys.unused 0
+			return`,
+	}
+
+	t.Run("Reachable functions", func(t *testing.T) {
+		pruned, removed, err := prune("Sys.init", sources)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if names := functions(pruned); !slices.Equal(names, []string{"Main.helper", "Main.main", "Sys.init"}) {
+			t.Fatalf("Unexpected functions left: %v", names)
+		}
+		if len(removed) != 2 || removed[0].Name != "Main.dead" || removed[1].Name != "Sys.unused" {
+			t.Fatalf("Unexpected functions removed: %+v", removed)
+		}
+		// The whole body is removed along with the declaration
+		if len(pruned["Main.vm"]) != 7 || len(pruned["Sys.vm"]) != 4 {
+			t.Fatalf("Unexpected operations left: %+v", pruned)
+		}
+	})
+
+	t.Run("Custom entrypoint", func(t *testing.T) {
+		pruned, _, err := prune("Main.dead", sources)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if names := functions(pruned); !slices.Equal(names, []string{"Main.dead", "Main.helper", "Sys.unused"}) {
+			t.Fatalf("Unexpected functions left: %v", names)
+		}
+	})
+
+	t.Run("Multiple entrypoints", func(t *testing.T) {
+		pruned, removed, err := prune("Main.dead", sources, "Sys.init")
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if len(removed) != 0 || len(functions(pruned)) != 5 {
+			t.Fatalf("Unexpected functions removed: %+v", removed)
+		}
+		if _, _, err := prune("Main.dead", sources, "Missing.init"); err == nil {
+			t.Fatalf("Expected error for an undefined entrypoint, got nil")
+		}
+	})
+
+	t.Run("Global scope", func(t *testing.T) {
+		// The operations outside of any function are kept, with the functions called from there
+		pruned, removed, err := prune("Main.helper", map[string]string{
+			"Main.vm": `
+				push constant 1
+				call Main.main 1
+				function Main.main 0
+				return
+				function Main.helper 0
+				return`,
+		})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if len(removed) != 0 || len(pruned["Main.vm"]) != 6 {
+			t.Fatalf("Unexpected functions removed: %+v", removed)
+		}
+	})
+
+	t.Run("Invalid programs", func(t *testing.T) {
+		if _, _, err := prune("Missing.init", sources); err == nil {
+			t.Fatalf("Expected error for an undefined entrypoint, got nil")
+		}
+		if _, _, err := prune("Main.main", map[string]string{"Main.vm": "function Main.main 0\nreturn\nfunction Main.main 0\nreturn"}); err == nil {
+			t.Fatalf("Expected error for a function declared twice, got nil")
+		}
+		if _, _, err := prune("Sys.init", map[string]string{}); err == nil {
+			t.Fatalf("Expected error for an empty program, got nil")
+		}
+	})
+}
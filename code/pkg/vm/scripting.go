@@ -0,0 +1,149 @@
+package vm
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+)
+
+// ----------------------------------------------------------------------------
+// Test script support
+
+// This section implements the 'tst.Simulator' interface on top of the Interpreter, this way the
+// test scripts written for the official VM Emulator (the '*VME.tst' files) can be run against it.
+//
+// The variables available to the scripts are the same ones exposed by the official emulator:
+//   - 'sp', 'local', 'argument', 'this' and 'that': The segment pointers (RAM locations 0 to 4)
+//   - 'local[n]', 'argument[n]', 'this[n]', 'that[n]' and 'temp[n]': A word in the segment
+//   - 'RAM[n]': The content of the RAM at the given address 'n'
+//
+// Each 'vmstep' executes a single operation, just like the official emulator does.
+
+// Matches the indexed variables (e.g. 'RAM[42]' or 'argument[1]') extracting name and index.
+var indexedRegex = regexp.MustCompile(`^(RAM|local|argument|this|that|temp)\[([0-9]+)\]$`)
+
+// Loads either a single '.vm' file or, if 'path' is a folder, all the '.vm' files in it. The
+// execution is bootstrapped from 'Sys.init' if available, otherwise starts from the first operation.
+func (i *Interpreter) Load(target, path string) error {
+	if target != "" {
+		return fmt.Errorf("unable to load program into '%s', no target is supported", target)
+	}
+
+	files := []string{path}
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		files, _ = filepath.Glob(filepath.Join(path, "*.vm"))
+	}
+
+	program := Program{}
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+
+		parser := NewFileParser(bytes.NewReader(content), filepath.Base(file))
+		program[filepath.Base(file)], err = parser.Parse()
+		if err != nil {
+			return fmt.Errorf("unable to parse '%s': %w", file, err)
+		}
+	}
+
+	interpreter, err := NewInterpreter(program)
+	if err != nil {
+		return err
+	}
+	if i.builtins != nil { // The native functions enabled are preserved across loads
+		interpreter.builtins = i.builtins
+	}
+	*i = interpreter
+
+	_, native := i.builtins["Sys.init"]
+	if _, found := i.labels["Sys.init"]; found || native {
+		return i.Bootstrap("Sys.init")
+	}
+	return nil
+}
+
+// Sets the segment pointer or memory location 'variable' to 'value'.
+func (i *Interpreter) Set(variable string, value int16) error {
+	location, err := i.locate(variable)
+	if err != nil {
+		return err
+	}
+
+	*location = uint16(value)
+	return nil
+}
+
+// Returns the current value of the segment pointer or memory location 'variable'.
+func (i *Interpreter) Get(variable string) (int16, error) {
+	location, err := i.locate(variable)
+	if err != nil {
+		return 0, err
+	}
+
+	return int16(*location), nil
+}
+
+// The Interpreter has no notion of clock, so this is a no-op.
+func (i *Interpreter) Eval() error { return nil }
+
+// The Interpreter has no notion of clock, so this is a no-op.
+func (i *Interpreter) Tick() error { return nil }
+
+// Executes the next operation, treating a full clock cycle as a single step.
+func (i *Interpreter) Tock() error { return i.Step() }
+
+// Executes the next operation, implements the 'vmstep' command of the test scripts.
+func (i *Interpreter) VMStep() error { return i.Step() }
+
+// Resolves the name of a segment pointer or memory location to a pointer to its content.
+func (i *Interpreter) locate(variable string) (*uint16, error) {
+	switch variable {
+	case "sp":
+		return &i.RAM[SP], nil
+	case "local":
+		return &i.RAM[LCL], nil
+	case "argument":
+		return &i.RAM[ARG], nil
+	case "this":
+		return &i.RAM[THIS], nil
+	case "that":
+		return &i.RAM[THAT], nil
+	}
+
+	match := indexedRegex.FindStringSubmatch(variable)
+	if match == nil {
+		return nil, fmt.Errorf("unrecognized variable '%s'", variable)
+	}
+
+	index, err := strconv.ParseUint(match[2], 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("index '%s' out of bound for variable '%s'", match[2], variable)
+	}
+
+	address := uint16(index)
+	switch match[1] {
+	case "local":
+		address += i.RAM[LCL]
+	case "argument":
+		address += i.RAM[ARG]
+	case "this":
+		address += i.RAM[THIS]
+	case "that":
+		address += i.RAM[THAT]
+	case "temp":
+		if index > 7 {
+			return nil, fmt.Errorf("index %d out of bound for variable '%s' (max 7)", index, variable)
+		}
+		address += TEMP
+	}
+
+	if address >= RAMSize {
+		return nil, fmt.Errorf("address %d out of bound for variable '%s'", address, variable)
+	}
+	return &i.RAM[address], nil
+}
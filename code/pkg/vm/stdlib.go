@@ -0,0 +1,752 @@
+package vm
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// ----------------------------------------------------------------------------
+// Standard library
+
+// This section defines the native (Go) implementation of the Jack standard library (the OS).
+//
+// Emulating the OS classes operation by operation is slow (e.g. a single 'Math.multiply' takes
+// hundreds of steps) so the Interpreter can intercept the calls to their functions and execute
+// them natively in a single step. The set of functions is the same one declared in the ABI of the
+// Jack compiler ('jack.StandardLibraryABI') and they can be enabled on a per class basis, this
+// way a Jack implementation of one class (e.g. 'Memory.jack') can be tested against the others.
+//
+// The native functions work on the same RAM the VM code uses, notably the heap (from 2048 to 16383)
+// and the memory maps of the Screen and the Keyboard are shared with the rest of the program. When
+// a native function needs another OS function (e.g. 'String.new' needs 'Memory.alloc') it calls it
+// through 'Interpreter.Invoke', so that it doesn't matter how the latter is implemented.
+
+// A native function, takes the arguments (the 'this' pointer first for methods) and returns the
+// result (0 for void functions) or an error that stops the execution of the program.
+type Builtin func(i *Interpreter, args []uint16) (uint16, error)
+
+// Returned by the native functions that are waiting for an input (e.g. 'Keyboard.readChar'), the
+// call is executed again at the next step like the VM code would do by looping on the Keyboard.
+var ErrBlocked = errors.New("waiting for input")
+
+// Well-known locations and sizes of the Hack platform used by the standard library.
+const (
+	heapBase     uint16 = 2048  // First word of the heap
+	heapSize     uint16 = 14336 // Number of words of the heap (up to the Screen memory map)
+	screenBase   uint16 = 16384 // First word of the Screen memory map
+	screenSize   uint16 = 8192  // Number of words of the Screen memory map (512x256 pixels)
+	keyboardBase uint16 = 24576 // The Keyboard memory map
+
+	newLine     uint16 = 128 // Key code of the 'enter' key
+	backSpace   uint16 = 129 // Key code of the 'backspace' key
+	doubleQuote uint16 = 34  // Character code of '"'
+)
+
+// The internal state of the native functions, each Interpreter has its own.
+type kernel struct {
+	transferred bool // Set by the functions that move the control flow elsewhere (e.g. 'Sys.halt')
+	blocked     bool // Set when the last function executed is waiting for an input
+
+	free  []block           // The free blocks of the heap, sorted by address
+	sizes map[uint16]uint16 // The size of every allocated block, indexed by its address
+
+	row, col uint16                // The position of the Output cursor (in characters)
+	glyphs   map[uint16][11]uint16 // The characters redefined with 'Output.create'
+	maps     map[uint16]uint16     // The arrays returned by 'Output.getMap', indexed by character
+
+	white bool // The color used by the Screen functions, black by default
+
+	key     uint16   // The key pressed but not yet released, 0 if none
+	reading bool     // Whether 'Keyboard.readLine' has already printed its message
+	line    []uint16 // The characters read so far by 'Keyboard.readLine'
+}
+
+// A contiguous free area of the heap.
+type block struct{ address, size uint16 }
+
+// The native implementation of every function of the standard library, indexed by class.
+var StandardLibrary = map[string]map[string]Builtin{
+	"Array": {
+		"new": func(i *Interpreter, args []uint16) (uint16, error) {
+			if int16(args[0]) <= 0 {
+				return 0, fmt.Errorf("array size must be positive, got %d", int16(args[0]))
+			}
+			return i.Invoke("Memory.alloc", args[0])
+		},
+		"dispose": func(i *Interpreter, args []uint16) (uint16, error) {
+			return i.Invoke("Memory.deAlloc", args[0])
+		},
+	},
+
+	"Keyboard": {
+		"init":       func(i *Interpreter, args []uint16) (uint16, error) { return 0, nil },
+		"keyPressed": func(i *Interpreter, args []uint16) (uint16, error) { return i.RAM[keyboardBase], nil },
+		"readChar": func(i *Interpreter, args []uint16) (uint16, error) {
+			key, ok := i.readKey()
+			if !ok {
+				return 0, ErrBlocked
+			}
+			_, err := i.Invoke("Output.printChar", key)
+			return key, err
+		},
+		"readLine": func(i *Interpreter, args []uint16) (uint16, error) { return i.readLine(args[0]) },
+		"readInt": func(i *Interpreter, args []uint16) (uint16, error) {
+			line, err := i.readLine(args[0])
+			if err != nil {
+				return 0, err
+			}
+			value, err := i.Invoke("String.intValue", line)
+			if err != nil {
+				return 0, err
+			}
+			_, err = i.Invoke("String.dispose", line)
+			return value, err
+		},
+	},
+
+	"Math": {
+		"init":     func(i *Interpreter, args []uint16) (uint16, error) { return 0, nil },
+		"multiply": func(i *Interpreter, args []uint16) (uint16, error) { return args[0] * args[1], nil },
+		"divide": func(i *Interpreter, args []uint16) (uint16, error) {
+			if args[1] == 0 {
+				return 0, fmt.Errorf("division by zero")
+			}
+			return uint16(int16(args[0]) / int16(args[1])), nil
+		},
+		"sqrt": func(i *Interpreter, args []uint16) (uint16, error) {
+			if int16(args[0]) < 0 {
+				return 0, fmt.Errorf("cannot compute the square root of %d", int16(args[0]))
+			}
+			root := uint16(0)
+			for (root+1)*(root+1) <= args[0] {
+				root++
+			}
+			return root, nil
+		},
+		"abs": func(i *Interpreter, args []uint16) (uint16, error) {
+			if int16(args[0]) < 0 {
+				return -args[0], nil
+			}
+			return args[0], nil
+		},
+		"min": func(i *Interpreter, args []uint16) (uint16, error) {
+			return uint16(min(int16(args[0]), int16(args[1]))), nil
+		},
+		"max": func(i *Interpreter, args []uint16) (uint16, error) {
+			return uint16(max(int16(args[0]), int16(args[1]))), nil
+		},
+	},
+
+	"Memory": {
+		"init": func(i *Interpreter, args []uint16) (uint16, error) {
+			i.kernel.free, i.kernel.sizes = []block{{heapBase, heapSize}}, map[uint16]uint16{}
+			return 0, nil
+		},
+		"peek": func(i *Interpreter, args []uint16) (uint16, error) { return i.read(args[0]) },
+		"poke": func(i *Interpreter, args []uint16) (uint16, error) { return 0, i.write(args[0], args[1]) },
+		"alloc": func(i *Interpreter, args []uint16) (uint16, error) {
+			if int16(args[0]) <= 0 {
+				return 0, fmt.Errorf("allocated size must be positive, got %d", int16(args[0]))
+			}
+			return i.alloc(args[0])
+		},
+		"deAlloc": func(i *Interpreter, args []uint16) (uint16, error) { return 0, i.deAlloc(args[0]) },
+	},
+
+	"Output": {
+		"init": func(i *Interpreter, args []uint16) (uint16, error) {
+			i.kernel.row, i.kernel.col = 0, 0
+			return 0, nil
+		},
+		"initMap": func(i *Interpreter, args []uint16) (uint16, error) { return 0, nil },
+		"create": func(i *Interpreter, args []uint16) (uint16, error) {
+			if i.kernel.glyphs == nil {
+				i.kernel.glyphs = map[uint16][11]uint16{}
+			}
+			i.kernel.glyphs[args[0]] = [11]uint16(args[1:12])
+			return 0, nil
+		},
+		"getMap": func(i *Interpreter, args []uint16) (uint16, error) {
+			if address, found := i.kernel.maps[args[0]]; found {
+				return address, nil
+			}
+			address, err := i.Invoke("Memory.alloc", 11)
+			if err != nil {
+				return 0, err
+			}
+			glyph := i.glyph(args[0])
+			copy(i.RAM[address:], glyph[:])
+			if i.kernel.maps == nil {
+				i.kernel.maps = map[uint16]uint16{}
+			}
+			i.kernel.maps[args[0]] = address
+			return address, nil
+		},
+		"moveCursor": func(i *Interpreter, args []uint16) (uint16, error) {
+			if args[0] >= 23 || args[1] >= 64 {
+				return 0, fmt.Errorf("cursor position (%d, %d) out of bound", int16(args[0]), int16(args[1]))
+			}
+			i.kernel.row, i.kernel.col = args[0], args[1]
+			return 0, nil
+		},
+		"printChar": func(i *Interpreter, args []uint16) (uint16, error) {
+			i.printChar(args[0])
+			return 0, nil
+		},
+		"printString": func(i *Interpreter, args []uint16) (uint16, error) {
+			length, err := i.Invoke("String.length", args[0])
+			if err != nil {
+				return 0, err
+			}
+			for j := uint16(0); j < length; j++ {
+				c, err := i.Invoke("String.charAt", args[0], j)
+				if err != nil {
+					return 0, err
+				}
+				i.printChar(c)
+			}
+			return 0, nil
+		},
+		"printInt": func(i *Interpreter, args []uint16) (uint16, error) {
+			for _, c := range strconv.Itoa(int(int16(args[0]))) {
+				i.printChar(uint16(c))
+			}
+			return 0, nil
+		},
+		"println": func(i *Interpreter, args []uint16) (uint16, error) {
+			i.printChar(newLine)
+			return 0, nil
+		},
+		"backSpace": func(i *Interpreter, args []uint16) (uint16, error) {
+			i.printChar(backSpace)
+			return 0, nil
+		},
+	},
+
+	"Screen": {
+		"init": func(i *Interpreter, args []uint16) (uint16, error) {
+			i.kernel.white = false
+			return 0, nil
+		},
+		"clearScreen": func(i *Interpreter, args []uint16) (uint16, error) {
+			clear(i.RAM[screenBase : screenBase+screenSize])
+			return 0, nil
+		},
+		"setColor": func(i *Interpreter, args []uint16) (uint16, error) {
+			i.kernel.white = args[0] == 0
+			return 0, nil
+		},
+		"drawPixel": func(i *Interpreter, args []uint16) (uint16, error) {
+			return 0, i.drawLine(int16(args[0]), int16(args[1]), int16(args[0]), int16(args[1]))
+		},
+		"drawLine": func(i *Interpreter, args []uint16) (uint16, error) {
+			return 0, i.drawLine(int16(args[0]), int16(args[1]), int16(args[2]), int16(args[3]))
+		},
+		"drawRectangle": func(i *Interpreter, args []uint16) (uint16, error) {
+			x1, y1, x2, y2 := int16(args[0]), int16(args[1]), int16(args[2]), int16(args[3])
+			if x1 > x2 || y1 > y2 {
+				return 0, fmt.Errorf("invalid rectangle (%d, %d) to (%d, %d)", x1, y1, x2, y2)
+			}
+			for y := y1; y <= y2; y++ {
+				if err := i.drawLine(x1, y, x2, y); err != nil {
+					return 0, err
+				}
+			}
+			return 0, nil
+		},
+		"drawCircle": func(i *Interpreter, args []uint16) (uint16, error) {
+			x, y, r := int16(args[0]), int16(args[1]), int16(args[2])
+			if r < 0 || x-r < 0 || x+r >= 512 || y-r < 0 || y+r >= 256 {
+				return 0, fmt.Errorf("circle (%d, %d) with radius %d is outside of the screen", x, y, r)
+			}
+
+			// Midpoint circle algorithm, every octant found is drawn as 4 horizontal lines
+			octant := func(dx, dy int16) {
+				i.drawLine(x-dx, y-dy, x+dx, y-dy)
+				i.drawLine(x-dx, y+dy, x+dx, y+dy)
+				i.drawLine(x-dy, y-dx, x+dy, y-dx)
+				i.drawLine(x-dy, y+dx, x+dy, y+dx)
+			}
+
+			dx, dy, diff := int16(0), r, 1-r
+			for octant(dx, dy); dy > dx; octant(dx, dy) {
+				if diff < 0 {
+					diff += 2*dx + 3
+				} else {
+					diff, dy = diff+2*(dx-dy)+5, dy-1
+				}
+				dx++
+			}
+			return 0, nil
+		},
+	},
+
+	"String": {
+		"new": func(i *Interpreter, args []uint16) (uint16, error) {
+			if int16(args[0]) < 0 {
+				return 0, fmt.Errorf("string max length must be non negative, got %d", int16(args[0]))
+			}
+			address, err := i.Invoke("Memory.alloc", args[0]+2)
+			if err != nil {
+				return 0, err
+			}
+			i.RAM[address], i.RAM[address+1] = args[0], 0
+			return address, nil
+		},
+		"dispose": func(i *Interpreter, args []uint16) (uint16, error) {
+			return i.Invoke("Memory.deAlloc", args[0])
+		},
+		"length": func(i *Interpreter, args []uint16) (uint16, error) {
+			s, err := i.str(args[0])
+			if err != nil {
+				return 0, err
+			}
+			return s[1], nil
+		},
+		"charAt": func(i *Interpreter, args []uint16) (uint16, error) {
+			s, err := i.str(args[0])
+			if err != nil || args[1] >= s[1] {
+				return 0, errors.Join(err, fmt.Errorf("index %d out of bound", int16(args[1])))
+			}
+			return s[2+args[1]], nil
+		},
+		"setCharAt": func(i *Interpreter, args []uint16) (uint16, error) {
+			s, err := i.str(args[0])
+			if err != nil || args[1] >= s[1] {
+				return 0, errors.Join(err, fmt.Errorf("index %d out of bound", int16(args[1])))
+			}
+			s[2+args[1]] = args[2]
+			return 0, nil
+		},
+		"appendChar": func(i *Interpreter, args []uint16) (uint16, error) {
+			s, err := i.str(args[0])
+			if err != nil || s[1] >= s[0] {
+				return 0, errors.Join(err, fmt.Errorf("string is full"))
+			}
+			s[2+s[1]], s[1] = args[1], s[1]+1
+			return args[0], nil
+		},
+		"eraseLastChar": func(i *Interpreter, args []uint16) (uint16, error) {
+			s, err := i.str(args[0])
+			if err != nil || s[1] == 0 {
+				return 0, errors.Join(err, fmt.Errorf("string is empty"))
+			}
+			s[1]--
+			return 0, nil
+		},
+		"intValue": func(i *Interpreter, args []uint16) (uint16, error) {
+			s, err := i.str(args[0])
+			if err != nil {
+				return 0, err
+			}
+			value, chars := uint16(0), s[2:2+s[1]]
+			negative := len(chars) > 0 && chars[0] == '-'
+			if negative {
+				chars = chars[1:]
+			}
+			for _, c := range chars { // Stops at the first non digit character
+				if c < '0' || c > '9' {
+					break
+				}
+				value = value*10 + (c - '0')
+			}
+			if negative {
+				return -value, nil
+			}
+			return value, nil
+		},
+		"setInt": func(i *Interpreter, args []uint16) (uint16, error) {
+			s, err := i.str(args[0])
+			digits := strconv.Itoa(int(int16(args[1])))
+			if err != nil || len(digits) > int(s[0]) {
+				return 0, errors.Join(err, fmt.Errorf("string too short for %d", int16(args[1])))
+			}
+			for n, c := range digits {
+				s[2+n] = uint16(c)
+			}
+			s[1] = uint16(len(digits))
+			return 0, nil
+		},
+		"backSpace":   func(i *Interpreter, args []uint16) (uint16, error) { return backSpace, nil },
+		"doubleQuote": func(i *Interpreter, args []uint16) (uint16, error) { return doubleQuote, nil },
+		"newLine":     func(i *Interpreter, args []uint16) (uint16, error) { return newLine, nil },
+	},
+
+	"Sys": {
+		"init": func(i *Interpreter, args []uint16) (uint16, error) {
+			for _, class := range []string{"Memory", "Math", "Screen", "Output", "Keyboard"} {
+				if _, err := i.Invoke(fmt.Sprintf("%s.init", class)); err != nil {
+					return 0, err
+				}
+			}
+			// Gives the control to 'Main.main', the program halts as soon as it returns
+			i.kernel.transferred = true
+			return 0, i.call("Main.main", 0, haltAddress)
+		},
+		"halt": func(i *Interpreter, args []uint16) (uint16, error) {
+			i.kernel.transferred, i.PC = true, haltAddress
+			return 0, nil
+		},
+		"wait": func(i *Interpreter, args []uint16) (uint16, error) {
+			if int16(args[0]) < 0 {
+				return 0, fmt.Errorf("duration must be non negative, got %d", int16(args[0]))
+			}
+			return 0, nil
+		},
+		"error": func(i *Interpreter, args []uint16) (uint16, error) {
+			return 0, fmt.Errorf("error code %d", int16(args[0]))
+		},
+	},
+}
+
+// The number of arguments (the 'this' pointer included for methods) of every native function, it's
+// the same one declared by 'jack.StandardLibraryABI' and it's checked before each native call since
+// the VM code is free to push any number of arguments.
+var StandardLibraryArity = map[string]map[string]int{
+	"Array":    {"dispose": 1, "new": 1},
+	"Keyboard": {"init": 0, "keyPressed": 0, "readChar": 0, "readInt": 1, "readLine": 1},
+	"Math":     {"abs": 1, "divide": 2, "init": 0, "max": 2, "min": 2, "multiply": 2, "sqrt": 1},
+	"Memory":   {"alloc": 1, "deAlloc": 1, "init": 0, "peek": 1, "poke": 2},
+	"Output": {"backSpace": 0, "create": 12, "getMap": 1, "init": 0, "initMap": 0, "moveCursor": 2,
+		"printChar": 1, "printInt": 1, "printString": 1, "println": 0},
+	"Screen": {"clearScreen": 0, "drawCircle": 3, "drawLine": 4, "drawPixel": 2, "drawRectangle": 4,
+		"init": 0, "setColor": 1},
+	"String": {"appendChar": 2, "backSpace": 0, "charAt": 2, "dispose": 1, "doubleQuote": 0, "eraseLastChar": 1,
+		"intValue": 1, "length": 1, "new": 1, "newLine": 0, "setCharAt": 3, "setInt": 2},
+	"Sys": {"error": 1, "halt": 0, "init": 0, "wait": 1},
+}
+
+// ----------------------------------------------------------------------------
+// Standard library helpers
+
+// Returns the String object at 'this' as a slice of the RAM: the max length, the length and then
+// the characters. Since the String class is switched as a whole the layout is private to it.
+func (i *Interpreter) str(this uint16) ([]uint16, error) {
+	if this < heapBase || this >= heapBase+heapSize-1 || this+2+i.RAM[this] > heapBase+heapSize {
+		return nil, fmt.Errorf("invalid string reference %d", this)
+	}
+	s := i.RAM[this : this+2+i.RAM[this]]
+	if s[1] > s[0] {
+		return nil, fmt.Errorf("invalid string reference %d", this)
+	}
+	return s, nil
+}
+
+// Allocates a block of 'size' words on the heap using a first fit strategy.
+func (i *Interpreter) alloc(size uint16) (uint16, error) {
+	if i.kernel.sizes == nil { // The heap is initialized lazily if 'Memory.init' has not been called
+		i.kernel.free, i.kernel.sizes = []block{{heapBase, heapSize}}, map[uint16]uint16{}
+	}
+
+	for n, free := range i.kernel.free {
+		if free.size < size {
+			continue
+		}
+		if free.size == size {
+			i.kernel.free = append(i.kernel.free[:n], i.kernel.free[n+1:]...)
+		} else {
+			i.kernel.free[n] = block{free.address + size, free.size - size}
+		}
+		i.kernel.sizes[free.address] = size
+		return free.address, nil
+	}
+
+	return 0, fmt.Errorf("heap exhausted, cannot allocate %d words", size)
+}
+
+// Releases the block at 'address' on the heap, merging it with the adjacent free blocks (if any).
+func (i *Interpreter) deAlloc(address uint16) error {
+	size, found := i.kernel.sizes[address]
+	if !found {
+		return fmt.Errorf("address %d is not an allocated block", address)
+	}
+	delete(i.kernel.sizes, address)
+
+	free := append(i.kernel.free, block{address, size})
+	sort.Slice(free, func(a, b int) bool { return free[a].address < free[b].address })
+
+	i.kernel.free = free[:1]
+	for _, next := range free[1:] {
+		last := &i.kernel.free[len(i.kernel.free)-1]
+		if last.address+last.size == next.address {
+			last.size += next.size
+		} else {
+			i.kernel.free = append(i.kernel.free, next)
+		}
+	}
+	return nil
+}
+
+// Returns the bitmap of the character 'c', non printable characters are shown as a black square.
+func (i *Interpreter) glyph(c uint16) [11]uint16 {
+	if glyph, found := i.kernel.glyphs[c]; found {
+		return glyph
+	}
+	if glyph, found := font[c]; found {
+		return glyph
+	}
+	return font[0]
+}
+
+// Prints the character 'c' at the cursor position and advances the cursor, both 'newLine' and
+// 'backSpace' are handled as special characters that only move the cursor (and erase).
+func (i *Interpreter) printChar(c uint16) {
+	k := &i.kernel
+
+	switch c {
+	case newLine:
+		k.row, k.col = (k.row+1)%23, 0
+
+	case backSpace:
+		if k.col > 0 {
+			k.col--
+		} else if k.row > 0 {
+			k.row, k.col = k.row-1, 63
+		}
+		i.drawChar(' ')
+
+	default:
+		i.drawChar(c)
+		if k.col++; k.col == 64 {
+			k.row, k.col = (k.row+1)%23, 0
+		}
+	}
+}
+
+// Draws the character 'c' at the cursor position, without moving the cursor. Just like in the
+// official implementation the first row of pixels of the screen is left blank.
+func (i *Interpreter) drawChar(c uint16) {
+	row, col := i.kernel.row, i.kernel.col
+	for y, bits := range i.glyph(c) {
+		address := screenBase + (row*11+uint16(y)+1)*32 + col/2
+		if col%2 == 0 { // Even columns are the lower byte of the word, odd ones the upper byte
+			i.RAM[address] = i.RAM[address]&0xFF00 | bits
+		} else {
+			i.RAM[address] = i.RAM[address]&0x00FF | bits<<8
+		}
+	}
+}
+
+// Draws a line between the given points (inclusive) with the current color, using the same
+// algorithm of the official OS. Returns an error if any of the points is outside of the Screen.
+func (i *Interpreter) drawLine(x1, y1, x2, y2 int16) error {
+	for _, point := range [][2]int16{{x1, y1}, {x2, y2}} {
+		if point[0] < 0 || point[0] >= 512 || point[1] < 0 || point[1] >= 256 {
+			return fmt.Errorf("point (%d, %d) is outside of the screen", point[0], point[1])
+		}
+	}
+
+	// The line is drawn along its major axis 'a' (one pixel per step) while the minor axis 'b' is
+	// moved when the error 'epsilon' says so, the points are swapped to always increment 'a'
+	dx, dy, steep := max(x2-x1, x1-x2), max(y2-y1, y1-y2), false
+	if steep = dx < dy; (steep && y2 < y1) || (!steep && x2 < x1) {
+		x1, y1, x2, y2 = x2, y2, x1, y1
+	}
+
+	a, b, end, decrement := x1, y1, x2, y1 > y2
+	if steep {
+		a, b, end, decrement, dx, dy = y1, x1, y2, x1 > x2, dy, dx
+	}
+
+	plot := func() {
+		if steep {
+			i.drawPixel(b, a)
+		} else {
+			i.drawPixel(a, b)
+		}
+	}
+
+	plot()
+	for epsilon := 2*dy - dx; a < end; {
+		if epsilon < 0 {
+			epsilon += 2 * dy
+		} else {
+			epsilon += 2 * (dy - dx)
+			if decrement {
+				b--
+			} else {
+				b++
+			}
+		}
+		a++
+		plot()
+	}
+	return nil
+}
+
+// Draws the pixel at the given coordinates with the current color.
+func (i *Interpreter) drawPixel(x, y int16) {
+	address, bit := screenBase+uint16(y)*32+uint16(x)/16, uint16(1)<<(x%16)
+	if i.kernel.white {
+		i.RAM[address] &^= bit
+	} else {
+		i.RAM[address] |= bit
+	}
+}
+
+// Returns the key pressed once it has been released, without blocking (false if there's none).
+func (i *Interpreter) readKey() (uint16, bool) {
+	pressed := i.RAM[keyboardBase]
+	if pressed != 0 {
+		i.kernel.key = pressed
+		return 0, false
+	}
+
+	key := i.kernel.key
+	i.kernel.key = 0
+	return key, key != 0
+}
+
+// Prints the 'message' and then reads a line (echoing the characters) until 'newLine' is pressed,
+// the line read is returned as a new String. Every call processes at most one key press.
+func (i *Interpreter) readLine(message uint16) (uint16, error) {
+	k := &i.kernel
+	if !k.reading {
+		if _, err := i.Invoke("Output.printString", message); err != nil {
+			return 0, err
+		}
+		k.reading, k.line = true, nil
+	}
+
+	key, ok := i.readKey()
+	switch {
+	case !ok:
+		return 0, ErrBlocked
+
+	case key == backSpace && len(k.line) == 0:
+		return 0, ErrBlocked
+
+	case key == backSpace:
+		k.line = k.line[:len(k.line)-1]
+		if _, err := i.Invoke("Output.backSpace"); err != nil {
+			return 0, err
+		}
+		return 0, ErrBlocked
+
+	case key != newLine:
+		k.line = append(k.line, key)
+		if _, err := i.Invoke("Output.printChar", key); err != nil {
+			return 0, err
+		}
+		return 0, ErrBlocked
+	}
+
+	k.reading = false
+	if _, err := i.Invoke("Output.println"); err != nil {
+		return 0, err
+	}
+
+	line, err := i.Invoke("String.new", uint16(len(k.line)))
+	if err != nil {
+		return 0, err
+	}
+	for _, c := range k.line {
+		if _, err := i.Invoke("String.appendChar", line, c); err != nil {
+			return 0, err
+		}
+	}
+	return line, nil
+}
+
+// The bitmaps of the characters used by 'Output', the same font of the official implementation:
+// every character is 11 rows of 8 bits (the 2 rightmost bits are left blank for spacing).
+var font = map[uint16][11]uint16{
+	0:   {63, 63, 63, 63, 63, 63, 63, 63, 63, 0, 0},  // black square
+	32:  {0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0},           // space
+	33:  {12, 30, 30, 30, 12, 12, 0, 12, 12, 0, 0},   // '!'
+	34:  {54, 54, 20, 0, 0, 0, 0, 0, 0, 0, 0},        // '"'
+	35:  {0, 18, 18, 63, 18, 18, 63, 18, 18, 0, 0},   // '#'
+	36:  {12, 30, 51, 3, 30, 48, 51, 30, 12, 12, 0},  // '$'
+	37:  {0, 0, 35, 51, 24, 12, 6, 51, 49, 0, 0},     // '%'
+	38:  {12, 30, 30, 12, 54, 27, 27, 27, 54, 0, 0},  // '&'
+	39:  {12, 12, 6, 0, 0, 0, 0, 0, 0, 0, 0},         // '\''
+	40:  {24, 12, 6, 6, 6, 6, 6, 12, 24, 0, 0},       // '('
+	41:  {6, 12, 24, 24, 24, 24, 24, 12, 6, 0, 0},    // ')'
+	42:  {0, 0, 0, 51, 30, 63, 30, 51, 0, 0, 0},      // '*'
+	43:  {0, 0, 0, 12, 12, 63, 12, 12, 0, 0, 0},      // '+'
+	44:  {0, 0, 0, 0, 0, 0, 0, 12, 12, 6, 0},         // ','
+	45:  {0, 0, 0, 0, 0, 63, 0, 0, 0, 0, 0},          // '-'
+	46:  {0, 0, 0, 0, 0, 0, 0, 12, 12, 0, 0},         // '.'
+	47:  {0, 0, 32, 48, 24, 12, 6, 3, 1, 0, 0},       // '/'
+	48:  {12, 30, 51, 51, 51, 51, 51, 30, 12, 0, 0},  // '0'
+	49:  {12, 14, 15, 12, 12, 12, 12, 12, 63, 0, 0},  // '1'
+	50:  {30, 51, 48, 24, 12, 6, 3, 51, 63, 0, 0},    // '2'
+	51:  {30, 51, 48, 48, 28, 48, 48, 51, 30, 0, 0},  // '3'
+	52:  {16, 24, 28, 26, 25, 63, 24, 24, 60, 0, 0},  // '4'
+	53:  {63, 3, 3, 31, 48, 48, 48, 51, 30, 0, 0},    // '5'
+	54:  {28, 6, 3, 3, 31, 51, 51, 51, 30, 0, 0},     // '6'
+	55:  {63, 49, 48, 48, 24, 12, 12, 12, 12, 0, 0},  // '7'
+	56:  {30, 51, 51, 51, 30, 51, 51, 51, 30, 0, 0},  // '8'
+	57:  {30, 51, 51, 51, 62, 48, 48, 24, 14, 0, 0},  // '9'
+	58:  {0, 0, 12, 12, 0, 0, 12, 12, 0, 0, 0},       // ':'
+	59:  {0, 0, 12, 12, 0, 0, 12, 12, 6, 0, 0},       // ';'
+	60:  {0, 0, 24, 12, 6, 3, 6, 12, 24, 0, 0},       // '<'
+	61:  {0, 0, 0, 63, 0, 0, 63, 0, 0, 0, 0},         // '='
+	62:  {0, 0, 3, 6, 12, 24, 12, 6, 3, 0, 0},        // '>'
+	64:  {30, 51, 51, 59, 59, 59, 27, 3, 30, 0, 0},   // '@'
+	63:  {30, 51, 51, 24, 12, 12, 0, 12, 12, 0, 0},   // '?'
+	65:  {12, 30, 51, 51, 63, 51, 51, 51, 51, 0, 0},  // 'A'
+	66:  {31, 51, 51, 51, 31, 51, 51, 51, 31, 0, 0},  // 'B'
+	67:  {28, 54, 35, 3, 3, 3, 35, 54, 28, 0, 0},     // 'C'
+	68:  {15, 27, 51, 51, 51, 51, 51, 27, 15, 0, 0},  // 'D'
+	69:  {63, 51, 35, 11, 15, 11, 35, 51, 63, 0, 0},  // 'E'
+	70:  {63, 51, 35, 11, 15, 11, 3, 3, 3, 0, 0},     // 'F'
+	71:  {28, 54, 35, 3, 59, 51, 51, 54, 44, 0, 0},   // 'G'
+	72:  {51, 51, 51, 51, 63, 51, 51, 51, 51, 0, 0},  // 'H'
+	73:  {30, 12, 12, 12, 12, 12, 12, 12, 30, 0, 0},  // 'I'
+	74:  {60, 24, 24, 24, 24, 24, 27, 27, 14, 0, 0},  // 'J'
+	75:  {51, 51, 51, 27, 15, 27, 51, 51, 51, 0, 0},  // 'K'
+	76:  {3, 3, 3, 3, 3, 3, 35, 51, 63, 0, 0},        // 'L'
+	77:  {33, 51, 63, 63, 51, 51, 51, 51, 51, 0, 0},  // 'M'
+	78:  {51, 51, 55, 55, 63, 59, 59, 51, 51, 0, 0},  // 'N'
+	79:  {30, 51, 51, 51, 51, 51, 51, 51, 30, 0, 0},  // 'O'
+	80:  {31, 51, 51, 51, 31, 3, 3, 3, 3, 0, 0},      // 'P'
+	81:  {30, 51, 51, 51, 51, 51, 63, 59, 30, 48, 0}, // 'Q'
+	82:  {31, 51, 51, 51, 31, 27, 51, 51, 51, 0, 0},  // 'R'
+	83:  {30, 51, 51, 6, 28, 48, 51, 51, 30, 0, 0},   // 'S'
+	84:  {63, 63, 45, 12, 12, 12, 12, 12, 30, 0, 0},  // 'T'
+	85:  {51, 51, 51, 51, 51, 51, 51, 51, 30, 0, 0},  // 'U'
+	86:  {51, 51, 51, 51, 51, 30, 30, 12, 12, 0, 0},  // 'V'
+	87:  {51, 51, 51, 51, 51, 63, 63, 63, 18, 0, 0},  // 'W'
+	88:  {51, 51, 30, 30, 12, 30, 30, 51, 51, 0, 0},  // 'X'
+	89:  {51, 51, 51, 51, 30, 12, 12, 12, 30, 0, 0},  // 'Y'
+	90:  {63, 51, 49, 24, 12, 6, 35, 51, 63, 0, 0},   // 'Z'
+	91:  {30, 6, 6, 6, 6, 6, 6, 6, 30, 0, 0},         // '['
+	92:  {0, 0, 1, 3, 6, 12, 24, 48, 32, 0, 0},       // '\\'
+	93:  {30, 24, 24, 24, 24, 24, 24, 24, 30, 0, 0},  // ']'
+	94:  {8, 28, 54, 0, 0, 0, 0, 0, 0, 0, 0},         // '^'
+	95:  {0, 0, 0, 0, 0, 0, 0, 0, 0, 63, 0},          // '_'
+	96:  {6, 12, 24, 0, 0, 0, 0, 0, 0, 0, 0},         // '`'
+	97:  {0, 0, 0, 14, 24, 30, 27, 27, 54, 0, 0},     // 'a'
+	98:  {3, 3, 3, 15, 27, 51, 51, 51, 30, 0, 0},     // 'b'
+	99:  {0, 0, 0, 30, 51, 3, 3, 51, 30, 0, 0},       // 'c'
+	100: {48, 48, 48, 60, 54, 51, 51, 51, 30, 0, 0},  // 'd'
+	101: {0, 0, 0, 30, 51, 63, 3, 51, 30, 0, 0},      // 'e'
+	102: {28, 54, 38, 6, 15, 6, 6, 6, 15, 0, 0},      // 'f'
+	103: {0, 0, 30, 51, 51, 51, 62, 48, 51, 30, 0},   // 'g'
+	104: {3, 3, 3, 27, 55, 51, 51, 51, 51, 0, 0},     // 'h'
+	105: {12, 12, 0, 14, 12, 12, 12, 12, 30, 0, 0},   // 'i'
+	106: {48, 48, 0, 56, 48, 48, 48, 48, 51, 30, 0},  // 'j'
+	107: {3, 3, 3, 51, 27, 15, 15, 27, 51, 0, 0},     // 'k'
+	108: {14, 12, 12, 12, 12, 12, 12, 12, 30, 0, 0},  // 'l'
+	109: {0, 0, 0, 29, 63, 43, 43, 43, 43, 0, 0},     // 'm'
+	110: {0, 0, 0, 29, 51, 51, 51, 51, 51, 0, 0},     // 'n'
+	111: {0, 0, 0, 30, 51, 51, 51, 51, 30, 0, 0},     // 'o'
+	112: {0, 0, 0, 30, 51, 51, 51, 31, 3, 3, 0},      // 'p'
+	113: {0, 0, 0, 30, 51, 51, 51, 62, 48, 48, 0},    // 'q'
+	114: {0, 0, 0, 29, 55, 51, 3, 3, 7, 0, 0},        // 'r'
+	115: {0, 0, 0, 30, 51, 6, 24, 51, 30, 0, 0},      // 's'
+	116: {4, 6, 6, 15, 6, 6, 6, 54, 28, 0, 0},        // 't'
+	117: {0, 0, 0, 27, 27, 27, 27, 27, 54, 0, 0},     // 'u'
+	118: {0, 0, 0, 51, 51, 51, 51, 30, 12, 0, 0},     // 'v'
+	119: {0, 0, 0, 51, 51, 51, 63, 63, 18, 0, 0},     // 'w'
+	120: {0, 0, 0, 51, 30, 12, 12, 30, 51, 0, 0},     // 'x'
+	121: {0, 0, 0, 51, 51, 51, 62, 48, 24, 15, 0},    // 'y'
+	122: {0, 0, 0, 63, 27, 12, 6, 51, 63, 0, 0},      // 'z'
+	123: {56, 12, 12, 12, 7, 12, 12, 12, 56, 0, 0},   // '{'
+	124: {12, 12, 12, 12, 12, 12, 12, 12, 12, 0, 0},  // '|'
+	125: {7, 12, 12, 12, 56, 12, 12, 12, 7, 0, 0},    // '}'
+	126: {38, 45, 25, 0, 0, 0, 0, 0, 0, 0, 0},        // '~'
+}
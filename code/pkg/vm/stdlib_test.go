@@ -0,0 +1,236 @@
+package vm_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"its-hmny.dev/nand2tetris/pkg/jack"
+	"its-hmny.dev/nand2tetris/pkg/tst"
+	"its-hmny.dev/nand2tetris/pkg/vm"
+)
+
+// The classes of the standard library, as implemented in 'projects/12 - Operating System'.
+var classes = []string{"Array", "Keyboard", "Math", "Memory", "Output", "Screen", "String", "Sys"}
+
+// Wraps an Interpreter with the program already loaded, so that the 'load' command is ignored.
+type preloaded struct{ *vm.Interpreter }
+
+func (preloaded) Load(target, path string) error { return nil }
+
+// Loads the 'Main.vm' of the given test program plus the VM code of the OS found in 'osDir' and
+// enables the native implementation of the 'native' classes.
+func loadOSTest(t *testing.T, dir, osDir string, native ...string) *vm.Interpreter {
+	program := vm.Program{}
+	files, _ := filepath.Glob(filepath.Join(osDir, "*.vm"))
+	for _, file := range append(files, filepath.Join(dir, "Main.vm")) {
+		content, err := os.Open(file)
+		if err != nil {
+			t.Fatalf("Error opening the '%s' module: %s", file, err)
+		}
+		defer content.Close()
+
+		parser := vm.NewParser(content)
+		if program[filepath.Base(file)], err = parser.Parse(); err != nil {
+			t.Fatalf("Error parsing the '%s' module: %s", file, err)
+		}
+	}
+
+	interpreter, err := vm.NewInterpreter(program)
+	if err != nil {
+		t.Fatalf("Error linking the '%s' program: %s", dir, err)
+	}
+	if err := interpreter.EnableBuiltIns(native...); err != nil {
+		t.Fatalf("Error enabling the builtins: %s", err)
+	}
+	if err := interpreter.Bootstrap("Sys.init"); err != nil {
+		t.Fatalf("Error bootstrapping the '%s' program: %s", dir, err)
+	}
+	return &interpreter
+}
+
+func TestStandardLibraryABI(t *testing.T) {
+	for class, functions := range jack.StandardLibraryABI {
+		for name, subroutine := range functions {
+			if _, found := vm.StandardLibrary[class][name]; !found {
+				t.Errorf("Missing native implementation for '%s.%s'", class, name)
+			}
+
+			arity := len(subroutine.Arguments)
+			if subroutine.Type == jack.Method {
+				arity++ // The 'this' pointer is passed as first argument
+			}
+			if native, found := vm.StandardLibraryArity[class][name]; !found || native != arity {
+				t.Errorf("Native arity of '%s.%s' is %d, expected %d", class, name, native, arity)
+			}
+		}
+	}
+	for class, functions := range vm.StandardLibrary {
+		for name := range functions {
+			if _, found := jack.StandardLibraryABI[class][name]; !found {
+				t.Errorf("Native implementation for '%s.%s' not declared in the ABI", class, name)
+			}
+		}
+	}
+
+	// The official OS (from the 'tools/OS' folder) can be linked in place of ours, so it has the same ABI
+	declared := map[string]bool{}
+	files, _ := filepath.Glob("../../../tools/OS/*.vm")
+	for _, file := range files {
+		content, err := os.Open(file)
+		if err != nil {
+			t.Fatalf("Error opening the '%s' module: %s", file, err)
+		}
+		defer content.Close()
+
+		parser := vm.NewParser(content)
+		module, err := parser.Parse()
+		if err != nil {
+			t.Fatalf("Error parsing the '%s' module: %s", file, err)
+		}
+		for _, op := range module {
+			if decl, isDecl := op.(vm.FuncDecl); isDecl {
+				declared[decl.Name] = true
+			}
+		}
+	}
+	for class, functions := range jack.StandardLibraryABI {
+		for name := range functions {
+			if !declared[class+"."+name] {
+				t.Errorf("Missing '%s.%s' in the official OS", class, name)
+			}
+		}
+	}
+}
+
+// Runs the test scripts of the OS classes with every class implemented natively, with none of them
+// and with only one of them at a time (testing it against the Jack implementation of the others).
+func TestStandardLibrary(t *testing.T) {
+	test := func(script string, native ...string) {
+		file, err := os.Open(script)
+		if err != nil {
+			t.Fatalf("Error opening the '%s' test file: %s", script, err)
+		}
+		defer file.Close()
+
+		parser := tst.NewParser(file)
+		commands, err := parser.Parse()
+		if err != nil {
+			t.Fatalf("Error parsing the '%s' test file: %s", script, err)
+		}
+
+		interpreter := loadOSTest(t, filepath.Dir(script), "../../../projects/12 - Operating System", native...)
+		runner := tst.NewRunner(commands, preloaded{interpreter}, filepath.Dir(script))
+		if err := runner.Run(); err != nil {
+			t.Fatalf("Error while running the '%s' test file: %s", script, err)
+		}
+	}
+
+	scripts, _ := filepath.Glob("../../../projects/12 - Operating System/*/*.tst")
+	for _, script := range scripts {
+		t.Run(filepath.Base(script), func(t *testing.T) {
+			test(script, classes...)
+			test(script)
+			for _, class := range classes {
+				test(script, class)
+			}
+		})
+	}
+}
+
+// Runs the test programs that draw on the screen both natively and with the official OS (from the
+// 'tools/OS' folder), the final content of the Screen memory map has to be the same. The 'Sys'
+// class is always native since 'Sys.halt' is the only way to know that the program is terminated.
+func TestStandardLibraryScreen(t *testing.T) {
+	test := func(dir string) {
+		native := loadOSTest(t, dir, "../../../tools/OS", classes...)
+		reference := loadOSTest(t, dir, "../../../tools/OS", "Sys")
+		for _, interpreter := range []*vm.Interpreter{native, reference} {
+			if err := interpreter.Run(200_000_000); err != nil {
+				t.Fatalf("Unexpected error running '%s': %s", dir, err)
+			}
+			if !interpreter.Halted() {
+				t.Fatalf("Program '%s' did not halt", dir)
+			}
+		}
+
+		for address := 16384; address < 24576; address++ {
+			if native.RAM[address] != reference.RAM[address] {
+				t.Fatalf("Mismatch at RAM[%d]: native %016b, reference %016b", address, native.RAM[address], reference.RAM[address])
+			}
+		}
+	}
+
+	t.Run("OutputTest", func(t *testing.T) { test("../../../projects/12 - Operating System/OutputTest") })
+	t.Run("ScreenTest", func(t *testing.T) { test("../../../projects/12 - Operating System/ScreenTest") })
+	t.Run("StringTest", func(t *testing.T) { test("../../../projects/12 - Operating System/StringTest") })
+}
+
+// Reads an integer from the keyboard with the native 'Keyboard.readInt', the keys are pressed (and
+// released) by writing directly in the Keyboard memory map in between the steps.
+func TestStandardLibraryKeyboard(t *testing.T) {
+	interpreter, err := vm.NewInterpreter(vm.Program{"Main.vm": vm.Module{
+		vm.FuncDecl{Name: "Main.main", NLocal: 0},
+		vm.MemoryOp{Operation: vm.Push, Segment: vm.Constant, Offset: 0},
+		vm.FuncCallOp{Name: "String.new", NArgs: 1},
+		vm.FuncCallOp{Name: "Keyboard.readInt", NArgs: 1},
+		vm.MemoryOp{Operation: vm.Pop, Segment: vm.Static, Offset: 0},
+		vm.MemoryOp{Operation: vm.Push, Segment: vm.Constant, Offset: 0},
+		vm.ReturnOp{},
+	}})
+	if err != nil {
+		t.Fatalf("Unexpected linking error: %s", err)
+	}
+	if err := interpreter.EnableBuiltIns(classes...); err != nil {
+		t.Fatalf("Error enabling the builtins: %s", err)
+	}
+	if err := interpreter.Bootstrap("Sys.init"); err != nil {
+		t.Fatalf("Error bootstrapping the program: %s", err)
+	}
+
+	for _, key := range []uint16{'-', '4', '3', 129, '2', 128} {
+		if err := interpreter.Run(10); err != nil || interpreter.Halted() {
+			t.Fatalf("Expected the program to wait for input, got error: %v", err)
+		}
+		interpreter.RAM[24576] = key
+		interpreter.Run(10)
+		interpreter.RAM[24576] = 0
+	}
+
+	if err := interpreter.Run(10); err != nil || !interpreter.Halted() {
+		t.Fatalf("Expected the program to be halted, got error: %v", err)
+	}
+	if value := int16(interpreter.RAM[16]); value != -42 {
+		t.Fatalf("Expected -42 to be read, got %d", value)
+	}
+}
+
+// Calls the native functions with the wrong number of arguments, the call must fail cleanly.
+func TestStandardLibraryArity(t *testing.T) {
+	test := func(name string, nArgs int, expected string) {
+		module := vm.Module{vm.FuncDecl{Name: "Sys.init", NLocal: 0}}
+		for range nArgs {
+			module = append(module, vm.MemoryOp{Operation: vm.Push, Segment: vm.Constant, Offset: 1})
+		}
+		module = append(module, vm.FuncCallOp{Name: name, NArgs: uint8(nArgs)}, vm.ReturnOp{})
+
+		interpreter, err := vm.NewInterpreter(vm.Program{"Sys.vm": module})
+		if err != nil {
+			t.Fatalf("Unexpected linking error: %s", err)
+		}
+		if err := interpreter.EnableBuiltIns("Math", "Memory"); err != nil {
+			t.Fatalf("Error enabling the builtins: %s", err)
+		}
+		if err := interpreter.Bootstrap("Sys.init"); err != nil {
+			t.Fatalf("Error bootstrapping the program: %s", err)
+		}
+
+		if err := interpreter.Run(10); err == nil || err.Error() != expected {
+			t.Errorf("Expected error '%s', got %v", expected, err)
+		}
+	}
+
+	test("Math.multiply", 1, "Math.multiply: invalid call with 1 arguments, expected 2")
+	test("Memory.peek", 0, "Memory.peek: invalid call with 0 arguments, expected 1")
+	test("Math.abs", 3, "Math.abs: invalid call with 3 arguments, expected 1")
+}
@@ -1,5 +1,7 @@
 package vm
 
+import "its-hmny.dev/nand2tetris/pkg/utils"
+
 // ----------------------------------------------------------------------------
 // General information
 
@@ -8,7 +10,8 @@ package vm
 // We declare a shared 'Operation' interface for every macro operation available for the
 // language and we define some other useful top-level struct such as Program and Module.
 // Is important to note that a VM program can be composed of multiple translation units
-// that can be also referenced as file or modules or also classes.
+// that can be also referenced as file or modules or also classes. Every operation keeps track
+// of its 'Position' in the source file, to be used when reporting errors.
 
 // A VM Program is just a set of multiple modules/files, in the VM spec each Jack class is
 // translated to its own .vm file (just like Java .class file) that can be handled as its
@@ -33,6 +36,8 @@ type MemoryOp struct {
 	Operation OperationType // The type of operation, either 'push' or 'pop'
 	Segment   SegmentType   // The named memory segment to use (this, that, temp, ...)
 	Offset    uint16        // The specific location/offset inside of the memory segment
+
+	Position utils.Position // The location of the operation in the source code
 }
 
 type OperationType string // Enum to manage the operation allowed for a MemoryOp
@@ -65,7 +70,10 @@ const (
 // In the VM intermediate language there are just a handful of operation available.
 // In particular each operation acts directly on the top of the stack, of course we have both unary
 // and binary operation, the specific management of each op will be handled in the codegen phase.
-type ArithmeticOp struct{ Operation ArithOpType }
+type ArithmeticOp struct {
+	Operation ArithOpType    // The type of operation to perform on the stack top
+	Position  utils.Position // The location of the operation in the source code
+}
 
 type ArithOpType string // Enum to manage the operation allowed for an ArithmeticOp
 
@@ -91,7 +99,10 @@ const (
 // In the VM intermediate language is possible to define a function scoped label that can be used to
 // make both conditional and unconditional jump allowing the user to implement looping and conditional.
 // Is important to note that the label is available only from within the function that declares it.
-type LabelDecl struct{ Name string }
+type LabelDecl struct {
+	Name     string         // The name of the label, scoped to the function that declares it
+	Position utils.Position // The location of the declaration in the source code
+}
 
 // ----------------------------------------------------------------------------
 // Goto Op
@@ -103,6 +114,8 @@ type LabelDecl struct{ Name string }
 type GotoOp struct {
 	Label string   // The label (memory reference) where we should jump
 	Jump  JumpType // The type of jump (conditional or unconditional)
+
+	Position utils.Position // The location of the operation in the source code
 }
 
 type JumpType string // Enum to manage the operation allowed for an ArithmeticOp
@@ -123,6 +136,8 @@ const (
 type FuncDecl struct {
 	Name   string // The function name/identifier
 	NLocal uint8  // How many local variable does the function need (the Frame size)
+
+	Position utils.Position // The location of the declaration in the source code
 }
 
 // ----------------------------------------------------------------------------
@@ -132,7 +147,9 @@ type FuncDecl struct {
 //
 // In the VM intermediate language is possible to return early or at the end of the function
 // execution with (optionally) the output of the computation (that has to be on the stack top).
-type ReturnOp struct{}
+type ReturnOp struct {
+	Position utils.Position // The location of the operation in the source code
+}
 
 // ----------------------------------------------------------------------------
 // Function Call Op
@@ -145,4 +162,6 @@ type ReturnOp struct{}
 type FuncCallOp struct {
 	Name  string // The function name/identifier
 	NArgs uint8  // How many arguments we have provided on the call Frame
+
+	Position utils.Position // The location of the operation in the source code
 }
@@ -4,71 +4,6 @@ D=A
 M=D
 @Sys.init
 0;JMP
-(Sys.init)
-@4
-D=A
-@R13
-M=D
-@R13
-D=M
-@SP
-A=M
-M=D
-@SP
-M=M+1
-@Sys.init-ret-1
-D=A
-@SP
-A=M
-M=D
-@SP
-M=M+1
-@LCL
-D=M
-@SP
-A=M
-M=D
-@SP
-M=M+1
-@ARG
-D=M
-@SP
-A=M
-M=D
-@SP
-M=M+1
-@THIS
-D=M
-@SP
-A=M
-M=D
-@SP
-M=M+1
-@THAT
-D=M
-@SP
-A=M
-M=D
-@SP
-M=M+1
-@SP
-D=M
-@5
-D=D-A
-@1
-D=D-A
-@ARG
-M=D
-@SP
-D=M
-@LCL
-M=D
-@Main.fibonacci
-0;JMP
-(Sys.init-ret-1)
-(Sys.init$END)
-@Sys.init$END
-0;JMP
 (Main.fibonacci)
 @ARG
 D=M
@@ -109,14 +44,14 @@ M=D
 D=M
 @R14
 D=D-M
-@LESS_2
+@LESS_1
 D;JGT
 D=0
-@END_2
+@END_1
 0;JMP
-(LESS_2)
+(LESS_1)
 D=-1
-(END_2)
+(END_1)
 @R15
 M=D
 @R15
@@ -251,7 +186,7 @@ A=M
 M=D
 @SP
 M=M+1
-@Main.fibonacci-ret-3
+@Main.fibonacci$ret.2
 D=A
 @SP
 A=M
@@ -300,7 +235,7 @@ D=M
 M=D
 @Main.fibonacci
 0;JMP
-(Main.fibonacci-ret-3)
+(Main.fibonacci$ret.2)
 @ARG
 D=M
 @0
@@ -349,7 +284,7 @@ A=M
 M=D
 @SP
 M=M+1
-@Main.fibonacci-ret-4
+@Main.fibonacci$ret.3
 D=A
 @SP
 A=M
@@ -398,7 +333,7 @@ D=M
 M=D
 @Main.fibonacci
 0;JMP
-(Main.fibonacci-ret-4)
+(Main.fibonacci$ret.3)
 @SP
 AM=M-1
 D=M
@@ -476,3 +411,68 @@ M=D
 @R14
 A=M
 0;JMP
+(Sys.init)
+@4
+D=A
+@R13
+M=D
+@R13
+D=M
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@Sys.init$ret.4
+D=A
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@LCL
+D=M
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@ARG
+D=M
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@THIS
+D=M
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@THAT
+D=M
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@SP
+D=M
+@5
+D=D-A
+@1
+D=D-A
+@ARG
+M=D
+@SP
+D=M
+@LCL
+M=D
+@Main.fibonacci
+0;JMP
+(Sys.init$ret.4)
+(Sys.init$END)
+@Sys.init$END
+0;JMP
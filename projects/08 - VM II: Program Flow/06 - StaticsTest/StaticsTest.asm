@@ -4,20 +4,12 @@ D=A
 M=D
 @Sys.init
 0;JMP
-(Sys.init)
-@6
-D=A
-@R13
-M=D
-@R13
+(Class1.set)
+@ARG
+D=M
+@0
+A=D+A
 D=M
-@SP
-A=M
-M=D
-@SP
-M=M+1
-@8
-D=A
 @R13
 M=D
 @R13
@@ -27,60 +19,32 @@ A=M
 M=D
 @SP
 M=M+1
-@Sys.init-ret-1
+@Class1.vm.0
 D=A
-@SP
-A=M
+@R13
 M=D
 @SP
-M=M+1
-@LCL
+AM=M-1
 D=M
-@SP
+@R13
 A=M
 M=D
-@SP
-M=M+1
 @ARG
 D=M
-@SP
-A=M
-M=D
-@SP
-M=M+1
-@THIS
+@1
+A=D+A
 D=M
-@SP
-A=M
+@R13
 M=D
-@SP
-M=M+1
-@THAT
+@R13
 D=M
 @SP
 A=M
 M=D
 @SP
 M=M+1
-@SP
-D=M
-@5
-D=D-A
-@2
-D=D-A
-@ARG
-M=D
-@SP
-D=M
-@LCL
-M=D
-@Class1.set
-0;JMP
-(Sys.init-ret-1)
-@5
+@Class1.vm.1
 D=A
-@0
-D=D+A
 @R13
 M=D
 @SP
@@ -89,7 +53,7 @@ D=M
 @R13
 A=M
 M=D
-@23
+@0
 D=A
 @R13
 M=D
@@ -100,131 +64,101 @@ A=M
 M=D
 @SP
 M=M+1
-@15
-D=A
+@LCL
+D=M
 @R13
 M=D
-@R13
+@5
+A=D-A
 D=M
-@SP
-A=M
+@R14
 M=D
-@SP
-M=M+1
-@Sys.init-ret-2
-D=A
-@SP
-A=M
+@ARG
+D=M
+@R13
 M=D
 @SP
-M=M+1
-@LCL
+AM=M-1
 D=M
-@SP
+@R13
 A=M
 M=D
-@SP
-M=M+1
 @ARG
 D=M
 @SP
-A=M
-M=D
-@SP
-M=M+1
-@THIS
+M=D+1
+@LCL
 D=M
-@SP
-A=M
-M=D
-@SP
-M=M+1
-@THAT
+@1
+A=D-A
 D=M
-@SP
-A=M
+@THAT
 M=D
-@SP
-M=M+1
-@SP
+@LCL
 D=M
-@5
-D=D-A
 @2
-D=D-A
+A=D-A
+D=M
+@THIS
+M=D
+@LCL
+D=M
+@3
+A=D-A
+D=M
 @ARG
 M=D
-@SP
+@LCL
+D=M
+@4
+A=D-A
 D=M
 @LCL
 M=D
-@Class2.set
+@R14
+A=M
 0;JMP
-(Sys.init-ret-2)
-@5
-D=A
-@0
-D=D+A
-@R13
-M=D
-@SP
-AM=M-1
+(Class1.get)
+@Class1.vm.0
 D=M
 @R13
-A=M
-M=D
-@Sys.init-ret-3
-D=A
-@SP
-A=M
 M=D
-@SP
-M=M+1
-@LCL
+@R13
 D=M
 @SP
 A=M
 M=D
 @SP
 M=M+1
-@ARG
+@Class1.vm.1
 D=M
-@SP
-A=M
+@R13
 M=D
-@SP
-M=M+1
-@THIS
+@R13
 D=M
 @SP
 A=M
 M=D
 @SP
 M=M+1
-@THAT
-D=M
 @SP
-A=M
+AM=M-1
+D=M
+@R13
 M=D
 @SP
-M=M+1
-@SP
+AM=M-1
 D=M
-@5
-D=D-A
-@0
-D=D-A
-@ARG
+@R14
 M=D
-@SP
+@R14
 D=M
-@LCL
+@R13
+D=D-M
+@R15
 M=D
-@Class1.get
-0;JMP
-(Sys.init-ret-3)
-@Sys.init-ret-4
-D=A
+@R15
+D=M
 @SP
 A=M
 M=D
@@ -232,51 +166,59 @@ M=D
 M=M+1
 @LCL
 D=M
-@SP
-A=M
+@R13
+M=D
+@5
+A=D-A
+D=M
+@R14
 M=D
-@SP
-M=M+1
 @ARG
 D=M
-@SP
-A=M
+@R13
 M=D
 @SP
-M=M+1
-@THIS
+AM=M-1
 D=M
-@SP
+@R13
 A=M
 M=D
+@ARG
+D=M
 @SP
-M=M+1
+M=D+1
+@LCL
+D=M
+@1
+A=D-A
+D=M
 @THAT
+M=D
+@LCL
 D=M
-@SP
-A=M
+@2
+A=D-A
+D=M
+@THIS
 M=D
-@SP
-M=M+1
-@SP
+@LCL
+D=M
+@3
+A=D-A
 D=M
-@5
-D=D-A
-@0
-D=D-A
 @ARG
 M=D
-@SP
+@LCL
+D=M
+@4
+A=D-A
 D=M
 @LCL
 M=D
-@Class2.get
-0;JMP
-(Sys.init-ret-4)
-(Sys.init$END)
-@Sys.init$END
+@R14
+A=M
 0;JMP
-(Class1.set)
+(Class2.set)
 @ARG
 D=M
 @0
@@ -291,7 +233,7 @@ A=M
 M=D
 @SP
 M=M+1
-@Class1.vm.0
+@Class2.vm.0
 D=A
 @R13
 M=D
@@ -315,7 +257,7 @@ A=M
 M=D
 @SP
 M=M+1
-@Class1.vm.1
+@Class2.vm.1
 D=A
 @R13
 M=D
@@ -390,8 +332,8 @@ M=D
 @R14
 A=M
 0;JMP
-(Class1.get)
-@Class1.vm.0
+(Class2.get)
+@Class2.vm.0
 D=M
 @R13
 M=D
@@ -402,7 +344,7 @@ A=M
 M=D
 @SP
 M=M+1
-@Class1.vm.1
+@Class2.vm.1
 D=M
 @R13
 M=D
@@ -490,12 +432,9 @@ M=D
 @R14
 A=M
 0;JMP
-(Class2.set)
-@ARG
-D=M
-@0
-A=D+A
-D=M
+(Sys.init)
+@6
+D=A
 @R13
 M=D
 @R13
@@ -505,32 +444,71 @@ A=M
 M=D
 @SP
 M=M+1
-@Class2.vm.0
+@8
 D=A
 @R13
 M=D
+@R13
+D=M
 @SP
-AM=M-1
+A=M
+M=D
+@SP
+M=M+1
+@Sys.init$ret.1
+D=A
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@LCL
 D=M
-@R13
+@SP
 A=M
 M=D
+@SP
+M=M+1
 @ARG
 D=M
-@1
-A=D+A
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@THIS
 D=M
-@R13
+@SP
+A=M
 M=D
-@R13
+@SP
+M=M+1
+@THAT
 D=M
 @SP
 A=M
 M=D
 @SP
 M=M+1
-@Class2.vm.1
+@SP
+D=M
+@5
+D=D-A
+@2
+D=D-A
+@ARG
+M=D
+@SP
+D=M
+@LCL
+M=D
+@Class1.set
+0;JMP
+(Sys.init$ret.1)
+@5
 D=A
+@0
+D=D+A
 @R13
 M=D
 @SP
@@ -539,7 +517,7 @@ D=M
 @R13
 A=M
 M=D
-@0
+@23
 D=A
 @R13
 M=D
@@ -550,101 +528,131 @@ A=M
 M=D
 @SP
 M=M+1
-@LCL
-D=M
+@15
+D=A
 @R13
 M=D
-@5
-A=D-A
+@R13
 D=M
-@R14
+@SP
+A=M
 M=D
-@ARG
-D=M
-@R13
+@SP
+M=M+1
+@Sys.init$ret.2
+D=A
+@SP
+A=M
 M=D
 @SP
-AM=M-1
+M=M+1
+@LCL
 D=M
-@R13
+@SP
 A=M
 M=D
+@SP
+M=M+1
 @ARG
 D=M
 @SP
-M=D+1
-@LCL
-D=M
-@1
-A=D-A
-D=M
-@THAT
+A=M
 M=D
-@LCL
-D=M
-@2
-A=D-A
-D=M
+@SP
+M=M+1
 @THIS
+D=M
+@SP
+A=M
 M=D
-@LCL
+@SP
+M=M+1
+@THAT
 D=M
-@3
-A=D-A
+@SP
+A=M
+M=D
+@SP
+M=M+1
+@SP
 D=M
+@5
+D=D-A
+@2
+D=D-A
 @ARG
 M=D
-@LCL
-D=M
-@4
-A=D-A
+@SP
 D=M
 @LCL
 M=D
-@R14
-A=M
+@Class2.set
 0;JMP
-(Class2.get)
-@Class2.vm.0
-D=M
+(Sys.init$ret.2)
+@5
+D=A
+@0
+D=D+A
 @R13
 M=D
-@R13
+@SP
+AM=M-1
 D=M
+@R13
+A=M
+M=D
+@Sys.init$ret.3
+D=A
 @SP
 A=M
 M=D
 @SP
 M=M+1
-@Class2.vm.1
+@LCL
 D=M
-@R13
+@SP
+A=M
 M=D
-@R13
+@SP
+M=M+1
+@ARG
 D=M
 @SP
 A=M
 M=D
 @SP
 M=M+1
-@SP
-AM=M-1
+@THIS
 D=M
-@R13
+@SP
+A=M
 M=D
 @SP
-AM=M-1
+M=M+1
+@THAT
 D=M
-@R14
+@SP
+A=M
 M=D
-@R14
+@SP
+M=M+1
+@SP
 D=M
-@R13
-D=D-M
-@R15
+@5
+D=D-A
+@0
+D=D-A
+@ARG
 M=D
-@R15
+@SP
 D=M
+@LCL
+M=D
+@Class1.get
+0;JMP
+(Sys.init$ret.3)
+@Sys.init$ret.4
+D=A
 @SP
 A=M
 M=D
@@ -652,55 +660,47 @@ M=D
 M=M+1
 @LCL
 D=M
-@R13
-M=D
-@5
-A=D-A
-D=M
-@R14
+@SP
+A=M
 M=D
+@SP
+M=M+1
 @ARG
 D=M
-@R13
+@SP
+A=M
 M=D
 @SP
-AM=M-1
+M=M+1
+@THIS
 D=M
-@R13
+@SP
 A=M
 M=D
-@ARG
-D=M
 @SP
-M=D+1
-@LCL
-D=M
-@1
-A=D-A
-D=M
+M=M+1
 @THAT
-M=D
-@LCL
-D=M
-@2
-A=D-A
 D=M
-@THIS
+@SP
+A=M
 M=D
-@LCL
-D=M
-@3
-A=D-A
+@SP
+M=M+1
+@SP
 D=M
+@5
+D=D-A
+@0
+D=D-A
 @ARG
 M=D
-@LCL
-D=M
-@4
-A=D-A
+@SP
 D=M
 @LCL
 M=D
-@R14
-A=M
+@Class2.get
+0;JMP
+(Sys.init$ret.4)
+(Sys.init$END)
+@Sys.init$END
 0;JMP